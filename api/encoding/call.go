@@ -151,3 +151,13 @@ func (c *Call) RoutingDelegate() string {
 	}
 	return c.ic.req.RoutingDelegate
 }
+
+// PeerIdentity returns the identity of the caller as verified by the
+// transport, for example from its TLS client certificate, and false if the
+// caller was not authenticated.
+func (c *Call) PeerIdentity() (transport.PeerIdentity, bool) {
+	if c == nil {
+		return transport.PeerIdentity{}, false
+	}
+	return c.ic.peerIdentity, c.ic.authenticated
+}
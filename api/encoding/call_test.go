@@ -43,6 +43,8 @@ func TestNilCall(t *testing.T) {
 	assert.Equal(t, "", call.RoutingDelegate())
 	assert.Equal(t, "", call.Header("foo"))
 	assert.Empty(t, call.HeaderNames())
+	_, ok := call.PeerIdentity()
+	assert.False(t, ok)
 
 	assert.Error(t, call.WriteResponseHeader("foo", "bar"))
 }
@@ -79,6 +81,20 @@ func TestReadFromRequest(t *testing.T) {
 	assert.Equal(t, icall.resHeaders[0].v, "bar2")
 }
 
+func TestPeerIdentity(t *testing.T) {
+	ctx, icall := NewInboundCall(context.Background())
+	icall.ReadFromRequest(&transport.Request{Caller: "caller"})
+	_, ok := CallFromContext(ctx).PeerIdentity()
+	assert.False(t, ok, "caller must not be authenticated")
+
+	ctx = transport.WithPeerIdentity(context.Background(), transport.PeerIdentity{SPIFFEID: "spiffe://example.com/caller"})
+	ctx, icall = NewInboundCall(ctx)
+	icall.ReadFromRequest(&transport.Request{Caller: "caller"})
+	id, ok := CallFromContext(ctx).PeerIdentity()
+	assert.True(t, ok, "caller must be authenticated")
+	assert.Equal(t, "spiffe://example.com/caller", id.Name())
+}
+
 func TestReadFromRequestMeta(t *testing.T) {
 	ctx, icall := NewInboundCall(context.Background())
 	icall.ReadFromRequestMeta(&transport.RequestMeta{
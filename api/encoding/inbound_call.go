@@ -35,6 +35,10 @@ type InboundCall struct {
 	resHeaders             []keyValuePair
 	req                    *transport.Request
 	disableResponseHeaders bool
+
+	// Verified identity of the caller, if any.
+	peerIdentity  transport.PeerIdentity
+	authenticated bool
 }
 
 type inboundCallKey struct{} // context key for *InboundCall
@@ -71,6 +75,7 @@ func NewInboundCallWithOptions(ctx context.Context, opts ...InboundCallOption) (
 	for _, opt := range opts {
 		opt.apply(call)
 	}
+	call.peerIdentity, call.authenticated = transport.PeerIdentityFromContext(ctx)
 	return context.WithValue(ctx, inboundCallKey{}, call), call
 }
 
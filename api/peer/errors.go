@@ -107,6 +107,14 @@ func (e ErrPeerRemoveNotInList) Error() string {
 	return fmt.Sprintf("can't remove peer (%s) because it is not in peerlist", string(e))
 }
 
+// ErrPeerReweightNotInList is returned to peer list updater if the peerlist
+// is not tracking the peer to reweight for a given identifier
+type ErrPeerReweightNotInList string
+
+func (e ErrPeerReweightNotInList) Error() string {
+	return fmt.Sprintf("can't reweight peer (%s) because it is not in peerlist", string(e))
+}
+
 // ErrChooseContextHasNoDeadline is returned when a context is sent to a peerlist with no deadline
 // DEPRECATED use yarpcerrors api instead.
 type ErrChooseContextHasNoDeadline string
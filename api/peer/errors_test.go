@@ -114,6 +114,14 @@ func TestErrPeerRemoveNotInList(t *testing.T) {
 	assert.Equal(t, wantErr, err.Error())
 }
 
+func TestErrPeerReweightNotInList(t *testing.T) {
+	p := "test-peer"
+	wantErr := fmt.Sprintf("can't reweight peer (%s) because it is not in peerlist", p)
+
+	err := peer.ErrPeerReweightNotInList(p)
+	assert.Equal(t, wantErr, err.Error())
+}
+
 func TestErrChooseContextHasNoDeadline(t *testing.T) {
 	peerList := "test-peer"
 	wantErr := fmt.Sprintf("can't wait for peer without a context deadline for peerlist %q", peerList)
@@ -51,6 +51,13 @@ type ListUpdates struct {
 
 	// Removals are the identifiers that should be removed to the list
 	Removals []Identifier
+
+	// Reweights are the identifiers of peers already in the list whose
+	// weight, or other attributes carried by the identifier, have changed.
+	// They are applied after additions and removals. These identifiers
+	// should implement WeightedIdentifier. Peer lists that do not support
+	// weights ignore them.
+	Reweights []Identifier
 }
 
 // ChooserList is both a Chooser and a List, useful for expressing both
@@ -48,6 +48,20 @@ type Identifier interface {
 	Identifier() string
 }
 
+// WeightedIdentifier is an Identifier that carries the relative weight of
+// the peer. Peer lists that support weights send proportionally more
+// requests to peers with higher weights, while other peer lists treat all
+// peers equally.
+//
+// A weight of zero drains the peer: weighted peer lists only choose it if no
+// peer with a positive weight is available. Identifiers that do not
+// implement this interface have a weight of 1.
+type WeightedIdentifier interface {
+	Identifier
+
+	Weight() uint32
+}
+
 // StatusPeer captures a concrete peer implementation for a particular
 // transport, exposing its Identifier and Status.
 // StatusPeer provides observability without mutability.
@@ -0,0 +1,44 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package transport
+
+import "io"
+
+// Compressor is a compression strategy for request and response bodies.
+//
+// Outbounds use a Compressor to compress request bodies and advertise which
+// encodings they accept in responses. Inbounds use the Compressors they know
+// about to decompress requests and compress responses for callers that
+// accept them.
+type Compressor interface {
+	// Name is the name of the compression strategy. It is sent over the wire
+	// to identify compressed payloads, for example "gzip" or "snappy".
+	Name() string
+
+	// Compress returns a writer that compresses data written to it and
+	// writes the result to the given writer. The compressed stream is
+	// complete only after the returned writer has been closed.
+	Compress(w io.Writer) (io.WriteCloser, error)
+
+	// Decompress returns a reader that decompresses data read from the given
+	// reader.
+	Decompress(r io.Reader) (io.ReadCloser, error)
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package transport
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"strings"
+)
+
+// PeerIdentity is the identity of the caller of an inbound request, taken
+// from the client certificate it presented on a mutually authenticated TLS
+// connection.
+//
+// Unlike the Caller of a request, which callers may set to any value, the
+// identity is only available if the transport verified the certificate.
+type PeerIdentity struct {
+	// SPIFFEID is the first spiffe:// URI among the subject alternative
+	// names of the certificate, if any.
+	SPIFFEID string
+
+	// CommonName is the common name of the subject of the certificate.
+	CommonName string
+
+	// Certificate is the verified client certificate.
+	Certificate *x509.Certificate
+}
+
+// Name returns the SPIFFE ID of the peer if it has one, and the common name
+// of its certificate otherwise.
+func (id PeerIdentity) Name() string {
+	if id.SPIFFEID != "" {
+		return id.SPIFFEID
+	}
+	return id.CommonName
+}
+
+type peerIdentityKey struct{} // context key for PeerIdentity
+
+// WithPeerIdentity returns a copy of the context that carries the given peer
+// identity. Transports use this to attach verified identities to the
+// contexts of inbound requests.
+func WithPeerIdentity(ctx context.Context, id PeerIdentity) context.Context {
+	return context.WithValue(ctx, peerIdentityKey{}, id)
+}
+
+// PeerIdentityFromContext returns the verified identity of the caller of the
+// inbound request with the given context, and false if the caller was not
+// authenticated.
+func PeerIdentityFromContext(ctx context.Context) (PeerIdentity, bool) {
+	id, ok := ctx.Value(peerIdentityKey{}).(PeerIdentity)
+	return id, ok
+}
+
+// PeerIdentityFromTLS returns the identity of the client of the given TLS
+// connection, and false if the server did not verify a client certificate.
+//
+// Certificates are considered verified if the server's tls.Config verified
+// them against its ClientCAs, that is with ClientAuth set to
+// VerifyClientCertIfGiven or RequireAndVerifyClientCert.
+func PeerIdentityFromTLS(state *tls.ConnectionState) (PeerIdentity, bool) {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.PeerCertificates) == 0 {
+		return PeerIdentity{}, false
+	}
+	cert := state.PeerCertificates[0]
+	id := PeerIdentity{
+		CommonName:  cert.Subject.CommonName,
+		Certificate: cert,
+	}
+	for _, uri := range uriSANs(cert) {
+		if strings.HasPrefix(uri, "spiffe://") {
+			id.SPIFFEID = uri
+			break
+		}
+	}
+	return id, true
+}
+
+var _oidSubjectAltName = asn1.ObjectIdentifier{2, 5, 29, 17}
+
+// uriSANs returns the URI subject alternative names of the certificate.
+//
+// The URIs field of x509.Certificate is not available before Go 1.10, so
+// the extension is parsed here.
+func uriSANs(cert *x509.Certificate) []string {
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(_oidSubjectAltName) {
+			continue
+		}
+		var seq asn1.RawValue
+		if rest, err := asn1.Unmarshal(ext.Value, &seq); err != nil || len(rest) > 0 || !seq.IsCompound {
+			return nil
+		}
+		var uris []string
+		for rest := seq.Bytes; len(rest) > 0; {
+			var name asn1.RawValue
+			var err error
+			if rest, err = asn1.Unmarshal(rest, &name); err != nil {
+				return nil
+			}
+			// GeneralName uniformResourceIdentifier is [6] IA5String.
+			if name.Class == asn1.ClassContextSpecific && name.Tag == 6 {
+				uris = append(uris, string(name.Bytes))
+			}
+		}
+		return uris
+	}
+	return nil
+}
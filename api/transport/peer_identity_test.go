@@ -0,0 +1,91 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package transport_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/testtls"
+)
+
+func TestPeerIdentityFromTLS(t *testing.T) {
+	s := testtls.NewScenario(t)
+	defer s.Cleanup()
+
+	clientCert := func() *x509.Certificate {
+		pair, err := tls.LoadX509KeyPair(s.ClientCertFile, s.ClientKeyFile)
+		require.NoError(t, err)
+		cert, err := x509.ParseCertificate(pair.Certificate[0])
+		require.NoError(t, err)
+		return cert
+	}
+	verified := func(cert *x509.Certificate) *tls.ConnectionState {
+		return &tls.ConnectionState{
+			PeerCertificates: []*x509.Certificate{cert},
+			VerifiedChains:   [][]*x509.Certificate{{cert}},
+		}
+	}
+
+	t.Run("no TLS", func(t *testing.T) {
+		_, ok := transport.PeerIdentityFromTLS(nil)
+		assert.False(t, ok)
+	})
+
+	t.Run("unverified certificate", func(t *testing.T) {
+		_, ok := transport.PeerIdentityFromTLS(&tls.ConnectionState{
+			PeerCertificates: []*x509.Certificate{clientCert()},
+		})
+		assert.False(t, ok, "unverified certificates must not provide an identity")
+	})
+
+	t.Run("common name", func(t *testing.T) {
+		cert := clientCert()
+		id, ok := transport.PeerIdentityFromTLS(verified(cert))
+		require.True(t, ok)
+		assert.Equal(t, transport.PeerIdentity{CommonName: "client", Certificate: cert}, id)
+		assert.Equal(t, "client", id.Name())
+	})
+
+	t.Run("SPIFFE ID", func(t *testing.T) {
+		s.IssueClientCert("frontend", "https://example.com/frontend", "spiffe://example.com/frontend")
+		id, ok := transport.PeerIdentityFromTLS(verified(clientCert()))
+		require.True(t, ok)
+		assert.Equal(t, "spiffe://example.com/frontend", id.SPIFFEID)
+		assert.Equal(t, "frontend", id.CommonName)
+		assert.Equal(t, "spiffe://example.com/frontend", id.Name())
+	})
+}
+
+func TestPeerIdentityContext(t *testing.T) {
+	_, ok := transport.PeerIdentityFromContext(context.Background())
+	assert.False(t, ok)
+
+	ctx := transport.WithPeerIdentity(context.Background(), transport.PeerIdentity{CommonName: "client"})
+	id, ok := transport.PeerIdentityFromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "client", id.Name())
+}
@@ -161,6 +161,20 @@ func (c *Call) RoutingDelegate() string {
 	return (*encoding.Call)(c).RoutingDelegate()
 }
 
+// PeerIdentity returns the identity of the caller as verified by the
+// transport, and false if the caller was not authenticated. Inbounds
+// authenticate callers with client certificates when they require mutual
+// TLS.
+//
+// 	if id, ok := call.PeerIdentity(); ok {
+// 		fmt.Println("Received request from", id.Name())
+// 	}
+//
+// Unlike Caller, the identity cannot be set to arbitrary values by callers.
+func (c *Call) PeerIdentity() (transport.PeerIdentity, bool) {
+	return (*encoding.Call)(c).PeerIdentity()
+}
+
 // StreamOption defines options that may be passed in at streaming function
 // call sites.
 //
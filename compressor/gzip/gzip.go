@@ -0,0 +1,126 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package yarpcgzip provides a gzip transport.Compressor.
+//
+// Pass it to the Compressor options of the HTTP, gRPC and TChannel transports
+// to compress request and response bodies, or register it with a
+// yarpcconfig.Configurator to use it from configuration.
+//
+//   configurator.MustRegisterCompressor(yarpcgzip.New())
+package yarpcgzip
+
+import (
+	"compress/gzip"
+	"io"
+	"sync"
+
+	"go.uber.org/yarpc/api/transport"
+)
+
+// Name is the name of the gzip compressor.
+const Name = "gzip"
+
+// Option customizes the behavior of a gzip compressor.
+type Option func(*Compressor)
+
+// Level sets the compression level used by the compressor. See the
+// compress/gzip package for the available levels.
+//
+// Defaults to gzip.DefaultCompression.
+func Level(level int) Option {
+	return func(c *Compressor) {
+		c.level = level
+	}
+}
+
+// New builds a new gzip compressor.
+func New(opts ...Option) *Compressor {
+	c := &Compressor{level: gzip.DefaultCompression}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Compressor is a gzip compressor. Writers and readers are pooled across
+// calls.
+type Compressor struct {
+	level   int
+	writers sync.Pool
+	readers sync.Pool
+}
+
+var _ transport.Compressor = (*Compressor)(nil)
+
+// Name is "gzip".
+func (*Compressor) Name() string { return Name }
+
+// Compress returns a writer that gzips data written to it into the given
+// writer.
+func (c *Compressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	if cw, ok := c.writers.Get().(*writer); ok {
+		cw.Reset(w)
+		return cw, nil
+	}
+	gw, err := gzip.NewWriterLevel(w, c.level)
+	if err != nil {
+		return nil, err
+	}
+	return &writer{Writer: gw, pool: &c.writers}, nil
+}
+
+// Decompress returns a reader that gunzips data read from the given reader.
+func (c *Compressor) Decompress(r io.Reader) (io.ReadCloser, error) {
+	if cr, ok := c.readers.Get().(*reader); ok {
+		if err := cr.Reset(r); err != nil {
+			c.readers.Put(cr)
+			return nil, err
+		}
+		return cr, nil
+	}
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return &reader{Reader: gr, pool: &c.readers}, nil
+}
+
+type writer struct {
+	*gzip.Writer
+
+	pool *sync.Pool
+}
+
+func (w *writer) Close() error {
+	defer w.pool.Put(w)
+	return w.Writer.Close()
+}
+
+type reader struct {
+	*gzip.Reader
+
+	pool *sync.Pool
+}
+
+func (r *reader) Close() error {
+	defer r.pool.Put(r)
+	return r.Reader.Close()
+}
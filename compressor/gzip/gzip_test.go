@@ -0,0 +1,73 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcgzip
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundTrip(t *testing.T) {
+	c := New()
+	assert.Equal(t, Name, c.Name())
+
+	payload := strings.Repeat("hello, world! ", 1000)
+
+	// Run more than once to exercise pooled writers and readers.
+	for i := 0; i < 3; i++ {
+		var buf bytes.Buffer
+		w, err := c.Compress(&buf)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(payload))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		assert.True(t, buf.Len() < len(payload), "payload must be compressed")
+
+		r, err := c.Decompress(&buf)
+		require.NoError(t, err)
+		got, err := ioutil.ReadAll(r)
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+		assert.Equal(t, payload, string(got))
+	}
+}
+
+func TestDecompressInvalid(t *testing.T) {
+	r, err := New().Decompress(strings.NewReader("not compressed"))
+	if err == nil {
+		_, err = ioutil.ReadAll(r)
+	}
+	assert.Error(t, err)
+}
+
+func TestLevel(t *testing.T) {
+	_, err := New(Level(gzip.BestSpeed)).Compress(ioutil.Discard)
+	assert.NoError(t, err)
+
+	_, err = New(Level(42)).Compress(ioutil.Discard)
+	assert.Error(t, err)
+}
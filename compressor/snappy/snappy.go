@@ -0,0 +1,101 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package yarpcsnappy provides a snappy transport.Compressor.
+//
+// Payloads use the snappy framing format, which allows them to be streamed.
+// Pass it to the Compressor options of the HTTP, gRPC and TChannel transports
+// to compress request and response bodies, or register it with a
+// yarpcconfig.Configurator to use it from configuration.
+//
+//   configurator.MustRegisterCompressor(yarpcsnappy.New())
+package yarpcsnappy
+
+import (
+	"io"
+	"sync"
+
+	"github.com/golang/snappy"
+	"go.uber.org/yarpc/api/transport"
+)
+
+// Name is the name of the snappy compressor.
+const Name = "snappy"
+
+// New builds a new snappy compressor.
+func New() *Compressor {
+	return &Compressor{}
+}
+
+// Compressor is a snappy compressor. Writers and readers are pooled across
+// calls.
+type Compressor struct {
+	writers sync.Pool
+	readers sync.Pool
+}
+
+var _ transport.Compressor = (*Compressor)(nil)
+
+// Name is "snappy".
+func (*Compressor) Name() string { return Name }
+
+// Compress returns a writer that compresses data written to it into the
+// given writer.
+func (c *Compressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	if cw, ok := c.writers.Get().(*writer); ok {
+		cw.Reset(w)
+		return cw, nil
+	}
+	return &writer{Writer: snappy.NewBufferedWriter(w), pool: &c.writers}, nil
+}
+
+// Decompress returns a reader that decompresses data read from the given
+// reader.
+func (c *Compressor) Decompress(r io.Reader) (io.ReadCloser, error) {
+	if cr, ok := c.readers.Get().(*reader); ok {
+		cr.Reset(r)
+		return cr, nil
+	}
+	return &reader{Reader: snappy.NewReader(r), pool: &c.readers}, nil
+}
+
+type writer struct {
+	*snappy.Writer
+
+	pool *sync.Pool
+}
+
+func (w *writer) Close() error {
+	defer w.pool.Put(w)
+	return w.Writer.Close()
+}
+
+type reader struct {
+	*snappy.Reader
+
+	pool *sync.Pool
+}
+
+func (r *reader) Close() error {
+	// Release the underlying reader before returning this one to the pool.
+	r.Reader.Reset(nil)
+	r.pool.Put(r)
+	return nil
+}
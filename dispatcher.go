@@ -29,6 +29,7 @@ import (
 	"go.uber.org/yarpc/api/middleware"
 	"go.uber.org/yarpc/api/transport"
 	"go.uber.org/yarpc/internal"
+	"go.uber.org/yarpc/internal/healthcheck"
 	"go.uber.org/yarpc/internal/inboundmiddleware"
 	"go.uber.org/yarpc/internal/observability"
 	"go.uber.org/yarpc/internal/outboundmiddleware"
@@ -82,16 +83,17 @@ func NewDispatcher(cfg Config) *Dispatcher {
 	cfg = addObservingMiddleware(cfg, meter, logger, extractor)
 
 	return &Dispatcher{
-		name:              cfg.Name,
-		table:             middleware.ApplyRouteTable(NewMapRouter(cfg.Name), cfg.RouterMiddleware),
-		inbounds:          cfg.Inbounds,
-		outbounds:         convertOutbounds(cfg.Outbounds, cfg.OutboundMiddleware),
-		transports:        collectTransports(cfg.Inbounds, cfg.Outbounds),
-		inboundMiddleware: cfg.InboundMiddleware,
-		log:               logger,
-		meter:             meter,
-		stopMeter:         stopMeter,
-		once:              lifecycle.NewOnce(),
+		name:               cfg.Name,
+		table:              healthcheck.NewRouteTable(middleware.ApplyRouteTable(NewMapRouter(cfg.Name), cfg.RouterMiddleware)),
+		inbounds:           cfg.Inbounds,
+		outbounds:          convertOutbounds(cfg.Outbounds, cfg.OutboundMiddleware),
+		transports:         collectTransports(cfg.Inbounds, cfg.Outbounds),
+		inboundMiddleware:  cfg.InboundMiddleware,
+		outboundMiddleware: cfg.OutboundMiddleware,
+		log:                logger,
+		meter:              meter,
+		stopMeter:          stopMeter,
+		once:               lifecycle.NewOnce(),
 	}
 }
 
@@ -198,6 +200,10 @@ func collectTransports(inbounds Inbounds, outbounds Outbounds) []transport.Trans
 
 // Dispatcher encapsulates a YARPC application. It acts as the entry point to
 // send and receive YARPC requests in a transport and encoding agnostic way.
+//
+// Every Dispatcher serves a health procedure, "yarpc::health", for any
+// service name. Transports configured to actively health check their peers
+// call it and stop sending requests to peers that fail to respond.
 type Dispatcher struct {
 	table      transport.RouteTable
 	name       string
@@ -205,7 +211,8 @@ type Dispatcher struct {
 	outbounds  Outbounds
 	transports []transport.Transport
 
-	inboundMiddleware InboundMiddleware
+	inboundMiddleware  InboundMiddleware
+	outboundMiddleware OutboundMiddleware
 
 	log       *zap.Logger
 	meter     *metrics.Scope
@@ -652,6 +652,26 @@ func TestInboundMiddleware(t *testing.T) {
 	assert.NotNil(t, mw)
 }
 
+func TestHealthProcedure(t *testing.T) {
+	dispatcher := NewDispatcher(Config{
+		Name: "test",
+	})
+
+	for _, service := range []string{"test", "yarpc", "other"} {
+		req := &transport.Request{
+			Caller:    "caller",
+			Service:   service,
+			Procedure: "yarpc::health",
+			Encoding:  "raw",
+		}
+		spec, err := dispatcher.Router().Choose(context.Background(), req)
+		require.NoError(t, err, "health procedure must be served for service %q", service)
+		require.Equal(t, transport.Unary, spec.Type())
+		assert.NoError(t, spec.Unary().Handle(context.Background(), req, new(transporttest.FakeResponseWriter)))
+	}
+	assert.Empty(t, dispatcher.Router().Procedures(), "health procedure must not be listed")
+}
+
 func TestClientConfigWithOutboundServiceNameOverride(t *testing.T) {
 	dispatcher := NewDispatcher(Config{
 		Name: "test",
@@ -0,0 +1,70 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package protobuf
+
+import (
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/types"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// NewErrorDetail packs the given Protobuf message into a yarpcerrors.Detail
+// that may be attached to an error with yarpcerrors.Status.WithDetails.
+//
+//   detail, err := protobuf.NewErrorDetail(&errdetails.QuotaFailure{...})
+//   if err != nil {
+//     return err
+//   }
+//   return yarpcerrors.Newf(yarpcerrors.CodeResourceExhausted, "quota exceeded").WithDetails(detail)
+//
+// Arbitrary key-value metadata may be attached by packing a types.Struct.
+func NewErrorDetail(message proto.Message) (yarpcerrors.Detail, error) {
+	packed, err := types.MarshalAny(message)
+	if err != nil {
+		return yarpcerrors.Detail{}, err
+	}
+	return yarpcerrors.Detail{TypeURL: packed.TypeUrl, Value: packed.Value}, nil
+}
+
+// GetErrorDetails returns the details attached to the given error, unpacked
+// into their Protobuf messages.
+//
+// The message types must be registered with the Protobuf library, which is
+// done by importing the package generated for them. An error is returned if
+// any of the details could not be unpacked.
+func GetErrorDetails(err error) ([]proto.Message, error) {
+	if !yarpcerrors.IsStatus(err) {
+		return nil, nil
+	}
+	details := yarpcerrors.FromError(err).Details()
+	if len(details) == 0 {
+		return nil, nil
+	}
+	messages := make([]proto.Message, 0, len(details))
+	for _, detail := range details {
+		var dynamic types.DynamicAny
+		if err := types.UnmarshalAny(&types.Any{TypeUrl: detail.TypeURL, Value: detail.Value}, &dynamic); err != nil {
+			return nil, err
+		}
+		messages = append(messages, dynamic.Message)
+	}
+	return messages, nil
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package protobuf
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+func TestErrorDetails(t *testing.T) {
+	first, err := NewErrorDetail(&types.StringValue{Value: "hello"})
+	require.NoError(t, err)
+	assert.Equal(t, "type.googleapis.com/google.protobuf.StringValue", first.TypeURL)
+
+	second, err := NewErrorDetail(&types.Struct{Fields: map[string]*types.Value{
+		"retryable": {Kind: &types.Value_BoolValue{BoolValue: true}},
+	}})
+	require.NoError(t, err)
+
+	messages, err := GetErrorDetails(
+		yarpcerrors.Newf(yarpcerrors.CodeInternal, "great sadness").WithDetails(first, second),
+	)
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+	assert.True(t, proto.Equal(&types.StringValue{Value: "hello"}, messages[0]))
+	assert.True(t, proto.Equal(&types.Struct{Fields: map[string]*types.Value{
+		"retryable": {Kind: &types.Value_BoolValue{BoolValue: true}},
+	}}, messages[1]))
+}
+
+func TestGetErrorDetailsNone(t *testing.T) {
+	messages, err := GetErrorDetails(errors.New("great sadness"))
+	assert.NoError(t, err)
+	assert.Empty(t, messages)
+
+	messages, err = GetErrorDetails(yarpcerrors.Newf(yarpcerrors.CodeInternal, "great sadness"))
+	assert.NoError(t, err)
+	assert.Empty(t, messages)
+}
+
+func TestGetErrorDetailsUnknownType(t *testing.T) {
+	_, err := GetErrorDetails(yarpcerrors.Newf(yarpcerrors.CodeInternal, "great sadness").WithDetails(
+		yarpcerrors.Detail{TypeURL: "type.example.com/unknown", Value: []byte("foo")},
+	))
+	assert.Error(t, err)
+}
@@ -0,0 +1,142 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package healthcheck implements the health procedure served by every
+// dispatcher and the parts of active health checking shared by transports.
+package healthcheck
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"go.uber.org/yarpc/api/transport"
+)
+
+const (
+	// Procedure is the name of the health procedure. Dispatchers serve it
+	// for every service name.
+	Procedure = "yarpc::health"
+
+	// Service is the service name sent with health checks.
+	Service = "yarpc"
+
+	// Caller is the caller name sent with health checks.
+	Caller = "yarpc-health-check"
+
+	// Encoding is the encoding of health checks and their responses, which
+	// have empty bodies.
+	Encoding transport.Encoding = "raw"
+)
+
+// Options configure active health checking of the peers of a transport.
+type Options struct {
+	// Interval between health checks of an available peer. Health checks
+	// are disabled if zero.
+	Interval time.Duration
+
+	// Timeout of each health check.
+	Timeout time.Duration
+
+	// Failures is the number of consecutive failed health checks after
+	// which a peer is marked unavailable.
+	Failures int
+}
+
+// DefaultOptions are the options of transports that do not configure health
+// checks. Health checks are disabled until given an interval.
+var DefaultOptions = Options{
+	Timeout:  time.Second,
+	Failures: 3,
+}
+
+// Enabled returns whether health checks are enabled.
+func (o Options) Enabled() bool {
+	return o.Interval > 0
+}
+
+// Request builds a request for the health procedure.
+func Request() *transport.Request {
+	return &transport.Request{
+		Caller:    Caller,
+		Service:   Service,
+		Procedure: Procedure,
+		Encoding:  Encoding,
+		Body:      bytes.NewReader(nil),
+	}
+}
+
+// Tracker counts consecutive failed health checks.
+type Tracker struct {
+	threshold int
+	failures  int
+}
+
+// NewTracker builds a Tracker for the given options.
+func NewTracker(opts Options) *Tracker {
+	threshold := opts.Failures
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &Tracker{threshold: threshold}
+}
+
+// Record records the outcome of a health check and returns whether the
+// peer has failed enough consecutive checks to be marked unavailable.
+func (t *Tracker) Record(healthy bool) (unhealthy bool) {
+	if healthy {
+		t.failures = 0
+		return false
+	}
+	t.failures++
+	return t.failures >= t.threshold
+}
+
+// Reset forgets previous failures.
+func (t *Tracker) Reset() {
+	t.failures = 0
+}
+
+// NewRouteTable wraps a route table so that it serves the health procedure
+// for every service name, ahead of any registered procedures.
+func NewRouteTable(rt transport.RouteTable) transport.RouteTable {
+	return routeTable{RouteTable: rt}
+}
+
+type routeTable struct {
+	transport.RouteTable
+}
+
+var _handlerSpec = transport.NewUnaryHandlerSpec(handler{})
+
+func (r routeTable) Choose(ctx context.Context, req *transport.Request) (transport.HandlerSpec, error) {
+	if req.Procedure == Procedure {
+		return _handlerSpec, nil
+	}
+	return r.RouteTable.Choose(ctx, req)
+}
+
+// handler responds to health checks with an empty body. A peer is healthy
+// as long as its inbounds hand requests to the dispatcher.
+type handler struct{}
+
+func (handler) Handle(context.Context, *transport.Request, transport.ResponseWriter) error {
+	return nil
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package healthcheck
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/transport/transporttest"
+)
+
+type fakeRouteTable struct {
+	transport.RouteTable
+
+	chosen []string
+}
+
+func (r *fakeRouteTable) Choose(_ context.Context, req *transport.Request) (transport.HandlerSpec, error) {
+	r.chosen = append(r.chosen, req.Procedure)
+	return transport.HandlerSpec{}, nil
+}
+
+func TestRouteTable(t *testing.T) {
+	inner := &fakeRouteTable{}
+	rt := NewRouteTable(inner)
+
+	spec, err := rt.Choose(context.Background(), Request())
+	require.NoError(t, err)
+	require.Equal(t, transport.Unary, spec.Type())
+	assert.Empty(t, inner.chosen, "health checks must not reach the route table")
+
+	// The health procedure is served for any service.
+	req := Request()
+	req.Service = "myservice"
+	spec, err = rt.Choose(context.Background(), req)
+	require.NoError(t, err)
+	rw := new(transporttest.FakeResponseWriter)
+	assert.NoError(t, spec.Unary().Handle(context.Background(), req, rw))
+	assert.Equal(t, 0, rw.Body.Len())
+
+	_, err = rt.Choose(context.Background(), &transport.Request{Procedure: "hello"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"hello"}, inner.chosen)
+}
+
+func TestTracker(t *testing.T) {
+	tracker := NewTracker(Options{Failures: 2})
+	assert.False(t, tracker.Record(false))
+	assert.False(t, tracker.Record(true), "success must reset failures")
+	assert.False(t, tracker.Record(false))
+	assert.True(t, tracker.Record(false))
+	assert.True(t, tracker.Record(false))
+	tracker.Reset()
+	assert.False(t, tracker.Record(false))
+
+	assert.True(t, NewTracker(Options{}).Record(false), "threshold must be at least one failure")
+}
+
+func TestOptions(t *testing.T) {
+	assert.False(t, DefaultOptions.Enabled())
+	assert.True(t, Options{Interval: 1}.Enabled())
+}
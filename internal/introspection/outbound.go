@@ -34,8 +34,41 @@ type OutboundStatus struct {
 	Chooser     ChooserStatus `json:"chooser"`
 	Service     string        `json:"service"`
 	OutboundKey string        `json:"outboundkey"`
+
+	// Status of the outbound middleware for requests sent to Service.
+	Middleware []MiddlewareStatus `json:"middleware"`
 }
 
 // OutboundStatusNotSupported is returned when not valid OutboundStatus can be
 // produced.
 var OutboundStatusNotSupported = OutboundStatus{}
+
+// IntrospectableOutboundMiddleware is implemented by outbound middleware that
+// can report its state for requests sent to a specific service.
+type IntrospectableOutboundMiddleware interface {
+	IntrospectOutbound(service string) []MiddlewareStatus
+}
+
+// IntrospectOutboundMiddleware returns the status of the given outbound
+// middleware for requests sent to the given service. Returns nil if the
+// middleware does not support introspection.
+func IntrospectOutboundMiddleware(service string, mw interface{}) []MiddlewareStatus {
+	if m, ok := mw.(IntrospectableOutboundMiddleware); ok {
+		return m.IntrospectOutbound(service)
+	}
+	return nil
+}
+
+// MiddlewareStatus is a collection of basic info about a middleware.
+type MiddlewareStatus struct {
+	Name    string                  `json:"name"`
+	State   string                  `json:"state"`
+	Entries []MiddlewareEntryStatus `json:"entries"`
+}
+
+// MiddlewareEntryStatus describes the state a middleware keeps for a
+// specific key, e.g. a procedure.
+type MiddlewareEntryStatus struct {
+	Key   string `json:"key"`
+	State string `json:"state"`
+}
@@ -65,7 +65,8 @@ func (h *HTTPServer) Listener() net.Listener {
 
 // ListenAndServe starts the given HTTP server up in the background and
 // returns immediately. The server listens on the configured Addr or ":http"
-// if unconfigured.
+// if unconfigured. If the server has a TLSConfig, connections are served over
+// TLS using the certificates from that configuration.
 //
 // An error is returned if the server failed to start up, if the server was
 // already listening, or if the server was stopped with Stop().
@@ -99,7 +100,12 @@ func (h *HTTPServer) ListenAndServe() error {
 func (h *HTTPServer) serve(listener net.Listener) {
 	// Serve always returns a non-nil error. For us, it's an error only if
 	// we didn't call Stop().
-	err := h.Server.Serve(listener)
+	var err error
+	if h.Server.TLSConfig != nil {
+		err = h.Server.ServeTLS(listener, "", "")
+	} else {
+		err = h.Server.Serve(listener)
+	}
 	if !h.stopped.Load() {
 		h.done <- err
 	} else {
@@ -64,6 +64,14 @@ func (c call) EndWithAppError(err error, isApplicationError bool) {
 	c.endStats(elapsed, err, isApplicationError)
 }
 
+// EndShed records the request as shed if load shedding middleware marked it
+// as such.
+func (c call) EndShed(m *shedMarker) {
+	if m.shed.Load() {
+		c.edge.shed.Inc()
+	}
+}
+
 func (c call) endLogs(elapsed time.Duration, err error, isApplicationError bool) {
 	var ce *zapcore.CheckedEntry
 	if err == nil && !isApplicationError {
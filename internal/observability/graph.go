@@ -38,6 +38,11 @@ var (
 	// Latency buckets for histograms. At some point, we may want to make these
 	// configurable.
 	_bucketsMs = bucket.NewRPCLatency()
+	// Streams are long-lived, so their durations need a much wider range of
+	// buckets than individual RPCs: 1ms to roughly 35 minutes.
+	_bucketsStreamMs = bucket.NewExponential(1, 2, 22)
+	// Size buckets for stream messages, from 1B to 64MiB.
+	_bucketsBytes = bucket.NewExponential(1, 2, 27)
 )
 
 type directionName string
@@ -48,7 +53,8 @@ const (
 )
 
 // A graph represents a collection of services: each service is a node, and we
-// collect stats for each caller-callee-transport-encoding-procedure-rk-sk-rd edge.
+// collect stats for each caller-callee-transport-encoding-procedure-rk-sk-rd edge
+// and RPC type.
 type graph struct {
 	meter   *metrics.Scope
 	logger  *zap.Logger
@@ -80,7 +86,8 @@ func (g *graph) begin(ctx context.Context, rpcType transport.Type, direction dir
 	d.Add(req.RoutingKey)
 	d.Add(req.RoutingDelegate)
 	d.Add(string(direction))
-	e := g.getOrCreateEdge(d.Digest(), req, string(direction))
+	d.Add(rpcType.String())
+	e := g.getOrCreateEdge(d.Digest(), req, rpcType, string(direction))
 	d.Free()
 
 	return call{
@@ -94,11 +101,11 @@ func (g *graph) begin(ctx context.Context, rpcType transport.Type, direction dir
 	}
 }
 
-func (g *graph) getOrCreateEdge(key []byte, req *transport.Request, direction string) *edge {
+func (g *graph) getOrCreateEdge(key []byte, req *transport.Request, rpcType transport.Type, direction string) *edge {
 	if e := g.getEdge(key); e != nil {
 		return e
 	}
-	return g.createEdge(key, req, direction)
+	return g.createEdge(key, req, rpcType, direction)
 }
 
 func (g *graph) getEdge(key []byte) *edge {
@@ -108,7 +115,7 @@ func (g *graph) getEdge(key []byte) *edge {
 	return e
 }
 
-func (g *graph) createEdge(key []byte, req *transport.Request, direction string) *edge {
+func (g *graph) createEdge(key []byte, req *transport.Request, rpcType transport.Type, direction string) *edge {
 	g.edgesMu.Lock()
 	// Since we'll rarely hit this code path, the overhead of defer is acceptable.
 	defer g.edgesMu.Unlock()
@@ -118,7 +125,7 @@ func (g *graph) createEdge(key []byte, req *transport.Request, direction string)
 		return e
 	}
 
-	e := newEdge(g.logger, g.meter, req, direction)
+	e := newEdge(g.logger, g.meter, req, rpcType, direction)
 	g.edges[string(key)] = e
 	return e
 }
@@ -132,15 +139,23 @@ type edge struct {
 	successes      *metrics.Counter
 	callerFailures *metrics.CounterVector
 	serverFailures *metrics.CounterVector
+	shed           *metrics.Counter // nil for outbound edges
 
 	latencies          *metrics.Histogram
 	callerErrLatencies *metrics.Histogram
 	serverErrLatencies *metrics.Histogram
+
+	// Stream metrics are nil for unary and oneway edges.
+	streamMessagesSent     *metrics.Counter
+	streamMessagesReceived *metrics.Counter
+	streamSentSizes        *metrics.Histogram
+	streamReceivedSizes    *metrics.Histogram
+	streamDurations        *metrics.Histogram
 }
 
 // newEdge constructs a new edge. Since Registries enforce metric uniqueness,
 // edges should be cached and re-used for each RPC.
-func newEdge(logger *zap.Logger, meter *metrics.Scope, req *transport.Request, direction string) *edge {
+func newEdge(logger *zap.Logger, meter *metrics.Scope, req *transport.Request, rpcType transport.Type, direction string) *edge {
 	tags := metrics.Tags{
 		"source":           req.Caller,
 		"dest":             req.Service,
@@ -150,6 +165,7 @@ func newEdge(logger *zap.Logger, meter *metrics.Scope, req *transport.Request, d
 		"routing_key":      req.RoutingKey,
 		"routing_delegate": req.RoutingDelegate,
 		"direction":        direction,
+		"rpc_type":         rpcType.String(),
 	}
 	calls, err := meter.Counter(metrics.Spec{
 		Name:      "calls",
@@ -185,6 +201,17 @@ func newEdge(logger *zap.Logger, meter *metrics.Scope, req *transport.Request, d
 	if err != nil {
 		logger.Error("Failed to create server failures vector.", zap.Error(err))
 	}
+	var shed *metrics.Counter
+	if direction == string(_directionInbound) {
+		shed, err = meter.Counter(metrics.Spec{
+			Name:      "shed_requests",
+			Help:      "Number of inbound RPCs rejected to shed load.",
+			ConstTags: tags,
+		})
+		if err != nil {
+			logger.Error("Failed to create shed requests counter.", zap.Error(err))
+		}
+	}
 	latencies, err := meter.Histogram(metrics.HistogramSpec{
 		Spec: metrics.Spec{
 			Name:      "success_latency_ms",
@@ -221,6 +248,64 @@ func newEdge(logger *zap.Logger, meter *metrics.Scope, req *transport.Request, d
 	if err != nil {
 		logger.Error("Failed to create server failure latency distribution.", zap.Error(err))
 	}
+	var (
+		streamMessagesSent, streamMessagesReceived            *metrics.Counter
+		streamSentSizes, streamReceivedSizes, streamDurations *metrics.Histogram
+	)
+	if rpcType == transport.Streaming {
+		streamMessagesSent, err = meter.Counter(metrics.Spec{
+			Name:      "stream_messages_sent",
+			Help:      "Number of messages sent on streams.",
+			ConstTags: tags,
+		})
+		if err != nil {
+			logger.Error("Failed to create stream messages sent counter.", zap.Error(err))
+		}
+		streamMessagesReceived, err = meter.Counter(metrics.Spec{
+			Name:      "stream_messages_received",
+			Help:      "Number of messages received on streams.",
+			ConstTags: tags,
+		})
+		if err != nil {
+			logger.Error("Failed to create stream messages received counter.", zap.Error(err))
+		}
+		streamSentSizes, err = meter.Histogram(metrics.HistogramSpec{
+			Spec: metrics.Spec{
+				Name:      "stream_sent_message_bytes",
+				Help:      "Size distribution of messages sent on streams.",
+				ConstTags: tags,
+			},
+			Unit:    1, // observed with IncBucket
+			Buckets: _bucketsBytes,
+		})
+		if err != nil {
+			logger.Error("Failed to create stream sent message size distribution.", zap.Error(err))
+		}
+		streamReceivedSizes, err = meter.Histogram(metrics.HistogramSpec{
+			Spec: metrics.Spec{
+				Name:      "stream_received_message_bytes",
+				Help:      "Size distribution of messages received on streams.",
+				ConstTags: tags,
+			},
+			Unit:    1, // observed with IncBucket
+			Buckets: _bucketsBytes,
+		})
+		if err != nil {
+			logger.Error("Failed to create stream received message size distribution.", zap.Error(err))
+		}
+		streamDurations, err = meter.Histogram(metrics.HistogramSpec{
+			Spec: metrics.Spec{
+				Name:      "stream_duration_ms",
+				Help:      "Duration distribution of streams, from open to termination.",
+				ConstTags: tags,
+			},
+			Unit:    time.Millisecond,
+			Buckets: _bucketsStreamMs,
+		})
+		if err != nil {
+			logger.Error("Failed to create stream duration distribution.", zap.Error(err))
+		}
+	}
 	logger = logger.With(
 		zap.String("source", req.Caller),
 		zap.String("dest", req.Service),
@@ -237,9 +322,16 @@ func newEdge(logger *zap.Logger, meter *metrics.Scope, req *transport.Request, d
 		successes:          successes,
 		callerFailures:     callerFailures,
 		serverFailures:     serverFailures,
+		shed:               shed,
 		latencies:          latencies,
 		callerErrLatencies: callerErrLatencies,
 		serverErrLatencies: serverErrLatencies,
+
+		streamMessagesSent:     streamMessagesSent,
+		streamMessagesReceived: streamMessagesReceived,
+		streamSentSizes:        streamSentSizes,
+		streamReceivedSizes:    streamReceivedSizes,
+		streamDurations:        streamDurations,
 	}
 }
 
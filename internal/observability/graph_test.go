@@ -47,11 +47,11 @@ func TestEdgeNopFallbacks(t *testing.T) {
 	}
 
 	// Should succeed, covered by middleware tests.
-	_ = newEdge(zap.NewNop(), meter, req, string(_directionOutbound))
+	_ = newEdge(zap.NewNop(), meter, req, transport.Streaming, string(_directionOutbound))
 
 	// Should fall back to no-op metrics.
 	// Usage of nil metrics should not panic, should not observe changes.
-	e := newEdge(zap.NewNop(), meter, req, string(_directionOutbound))
+	e := newEdge(zap.NewNop(), meter, req, transport.Streaming, string(_directionOutbound))
 
 	e.calls.Inc()
 	assert.Equal(t, int64(0), e.calls.Load(), "Expected to fall back to no-op metrics.")
@@ -72,6 +72,14 @@ func TestEdgeNopFallbacks(t *testing.T) {
 	e.latencies.Observe(0)
 	e.callerErrLatencies.Observe(0)
 	e.serverErrLatencies.Observe(0)
+
+	e.streamMessagesSent.Inc()
+	assert.Equal(t, int64(0), e.streamMessagesSent.Load(), "Expected to fall back to no-op metrics.")
+	e.streamMessagesReceived.Inc()
+	assert.Equal(t, int64(0), e.streamMessagesReceived.Load(), "Expected to fall back to no-op metrics.")
+	e.streamSentSizes.IncBucket(0)
+	e.streamReceivedSizes.IncBucket(0)
+	e.streamDurations.Observe(0)
 }
 
 func TestUnknownIfEmpty(t *testing.T) {
@@ -70,9 +70,11 @@ func NewMiddleware(logger *zap.Logger, scope *metrics.Scope, extract ContextExtr
 // Handle implements middleware.UnaryInbound.
 func (m *Middleware) Handle(ctx context.Context, req *transport.Request, w transport.ResponseWriter, h transport.UnaryHandler) error {
 	call := m.graph.begin(ctx, transport.Unary, _directionInbound, req)
+	ctx, shed := withShedMarker(ctx)
 	wrappedWriter := newWriter(w)
 	err := h.Handle(ctx, req, wrappedWriter)
 	call.EndWithAppError(err, wrappedWriter.isApplicationError)
+	call.EndShed(shed)
 	wrappedWriter.free()
 	return err
 }
@@ -93,8 +95,10 @@ func (m *Middleware) Call(ctx context.Context, req *transport.Request, out trans
 // HandleOneway implements middleware.OnewayInbound.
 func (m *Middleware) HandleOneway(ctx context.Context, req *transport.Request, h transport.OnewayHandler) error {
 	call := m.graph.begin(ctx, transport.Oneway, _directionInbound, req)
+	ctx, shed := withShedMarker(ctx)
 	err := h.HandleOneway(ctx, req)
 	call.End(err)
+	call.EndShed(shed)
 	return err
 }
 
@@ -109,9 +113,12 @@ func (m *Middleware) CallOneway(ctx context.Context, req *transport.Request, out
 // HandleStream implements middleware.StreamInbound.
 func (m *Middleware) HandleStream(serverStream *transport.ServerStream, h transport.StreamHandler) error {
 	call := m.graph.begin(serverStream.Context(), transport.Streaming, _directionInbound, serverStream.Request().Meta.ToRequest())
-	err := h.HandleStream(serverStream)
-	// TODO(pedge): wrap the *transport.ServerStream?
+	serverStream, shed := withStreamShedMarker(serverStream)
+	stream := newStreamCall(call)
+	err := h.HandleStream(newObservedServerStream(serverStream, stream))
 	call.End(err)
+	call.EndShed(shed)
+	stream.End(err)
 	return err
 }
 
@@ -119,7 +126,9 @@ func (m *Middleware) HandleStream(serverStream *transport.ServerStream, h transp
 func (m *Middleware) CallStream(ctx context.Context, request *transport.StreamRequest, out transport.StreamOutbound) (*transport.ClientStream, error) {
 	call := m.graph.begin(ctx, transport.Streaming, _directionOutbound, request.Meta.ToRequest())
 	clientStream, err := out.CallStream(ctx, request)
-	// TODO(pedge): wrap the *transport.ClientStream?
 	call.End(err)
-	return clientStream, err
+	if err != nil {
+		return clientStream, err
+	}
+	return newObservedClientStream(clientStream, newStreamCall(call)), nil
 }
@@ -24,6 +24,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"strings"
 	"testing"
 	"time"
@@ -255,7 +257,33 @@ func TestMiddlewareLogging(t *testing.T) {
 				},
 				Context: logContext,
 			}
-			assert.Equal(t, expected, getLog(), "Unexpected log entry written.")
+			streamMsg := _successfulStreamInbound
+			if tt.err != nil {
+				streamMsg = _errorStreamInbound
+			}
+			expectedEnd := observer.LoggedEntry{
+				Entry: zapcore.Entry{
+					Level:   tt.wantErrLevel,
+					Message: streamMsg,
+				},
+				Context: append(
+					baseFields(),
+					zap.String("direction", string(_directionInbound)),
+					zap.Duration("duration", 0),
+					zap.Int64("messagesSent", 0),
+					zap.Int64("messagesReceived", 0),
+					zap.String("code", yarpcerrors.FromError(tt.err).Code().String()),
+					zap.Skip(),
+					zap.Error(tt.err),
+				),
+			}
+			entries := logs.TakeAll()
+			require.Equal(t, 2, len(entries), "Unexpected number of logs written.")
+			for i := range entries {
+				entries[i].Entry.Time = time.Time{}
+			}
+			assert.Equal(t, expected, entries[0], "Unexpected log entry written.")
+			assert.Equal(t, expectedEnd, entries[1], "Unexpected stream termination log entry written.")
 		})
 		t.Run(tt.desc+", stream outbound", func(t *testing.T) {
 			clientStream, err := mw.CallStream(context.Background(), sreq, newOutbound(tt))
@@ -356,7 +384,7 @@ func TestMiddlewareMetrics(t *testing.T) {
 
 	for _, tt := range tests {
 		validate := func(mw *Middleware, direction string) {
-			key, free := getKey(req, direction)
+			key, free := getKey(req, direction, transport.Unary)
 			edge := mw.graph.getEdge(key)
 			free()
 			assert.Equal(t, int64(tt.wantCalls), edge.calls.Load())
@@ -386,10 +414,320 @@ func TestMiddlewareMetrics(t *testing.T) {
 	}
 }
 
+// shedHandler rejects all requests, marking them as shed.
+type shedHandler struct{}
+
+func (shedHandler) Handle(ctx context.Context, _ *transport.Request, _ transport.ResponseWriter) error {
+	MarkShed(ctx)
+	return yarpcerrors.ResourceExhaustedErrorf("shed")
+}
+
+func (shedHandler) HandleOneway(ctx context.Context, _ *transport.Request) error {
+	MarkShed(ctx)
+	return yarpcerrors.ResourceExhaustedErrorf("shed")
+}
+
+func (shedHandler) HandleStream(stream *transport.ServerStream) error {
+	MarkShed(stream.Context())
+	return yarpcerrors.ResourceExhaustedErrorf("shed")
+}
+
+func TestMiddlewareShedMetrics(t *testing.T) {
+	defer stubTime()()
+	req := &transport.Request{
+		Caller:    "caller",
+		Service:   "service",
+		Encoding:  "raw",
+		Procedure: "procedure",
+		Body:      strings.NewReader("body"),
+	}
+
+	validate := func(t *testing.T, mw *Middleware, rpcType transport.Type, wantShed int64) {
+		key, free := getKey(req, string(_directionInbound), rpcType)
+		edge := mw.graph.getEdge(key)
+		free()
+		require.NotNil(t, edge, "expected an inbound edge")
+		assert.Equal(t, wantShed, edge.shed.Load(), "unexpected shed count")
+		assert.Equal(t, int64(1), edge.serverFailures.MustGet(_error, yarpcerrors.CodeResourceExhausted.String()).Load())
+	}
+
+	t.Run("unary", func(t *testing.T) {
+		mw := NewMiddleware(zap.NewNop(), metrics.New().Scope(), NewNopContextExtractor())
+		err := mw.Handle(context.Background(), req, &transporttest.FakeResponseWriter{}, shedHandler{})
+		assert.Error(t, err)
+		validate(t, mw, transport.Unary, 1)
+	})
+
+	t.Run("oneway", func(t *testing.T) {
+		mw := NewMiddleware(zap.NewNop(), metrics.New().Scope(), NewNopContextExtractor())
+		err := mw.HandleOneway(context.Background(), req, shedHandler{})
+		assert.Error(t, err)
+		validate(t, mw, transport.Oneway, 1)
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		mw := NewMiddleware(zap.NewNop(), metrics.New().Scope(), NewNopContextExtractor())
+		stream, err := transport.NewServerStream(&fakeStream{
+			ctx:     context.Background(),
+			request: &transport.StreamRequest{Meta: req.ToRequestMeta()},
+		})
+		require.NoError(t, err)
+		assert.Error(t, mw.HandleStream(stream, shedHandler{}))
+		validate(t, mw, transport.Streaming, 1)
+	})
+
+	t.Run("not marked", func(t *testing.T) {
+		mw := NewMiddleware(zap.NewNop(), metrics.New().Scope(), NewNopContextExtractor())
+		err := mw.Handle(context.Background(), req, &transporttest.FakeResponseWriter{},
+			fakeHandler{err: yarpcerrors.ResourceExhaustedErrorf("busy")})
+		assert.Error(t, err)
+		validate(t, mw, transport.Unary, 0)
+	})
+
+	t.Run("outbound edges have no shed counter", func(t *testing.T) {
+		mw := NewMiddleware(zap.NewNop(), metrics.New().Scope(), NewNopContextExtractor())
+		mw.Call(context.Background(), req, fakeOutbound{})
+		key, free := getKey(req, string(_directionOutbound), transport.Unary)
+		defer free()
+		assert.Nil(t, mw.graph.getEdge(key).shed)
+	})
+}
+
+// messageStream is a stream that consumes sent messages and replays a fixed
+// set of messages before failing with recvErr.
+type messageStream struct {
+	ctx     context.Context
+	request *transport.StreamRequest
+
+	recv    []string
+	recvErr error
+}
+
+func (s *messageStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *messageStream) Request() *transport.StreamRequest {
+	return s.request
+}
+
+func (s *messageStream) SendMessage(_ context.Context, msg *transport.StreamMessage) error {
+	_, err := ioutil.ReadAll(msg.Body)
+	return err
+}
+
+func (s *messageStream) ReceiveMessage(context.Context) (*transport.StreamMessage, error) {
+	if len(s.recv) == 0 {
+		return nil, s.recvErr
+	}
+	body := s.recv[0]
+	s.recv = s.recv[1:]
+	return &transport.StreamMessage{Body: ioutil.NopCloser(strings.NewReader(body))}, nil
+}
+
+func (s *messageStream) Close(context.Context) error {
+	return nil
+}
+
+type messageStreamOutbound struct {
+	transport.Outbound
+
+	stream *messageStream
+}
+
+func (o messageStreamOutbound) CallStream(ctx context.Context, request *transport.StreamRequest) (*transport.ClientStream, error) {
+	o.stream.ctx = ctx
+	o.stream.request = request
+	return transport.NewClientStream(o.stream)
+}
+
+// echoStreamHandler sends back every message it receives.
+type echoStreamHandler struct{}
+
+func (echoStreamHandler) HandleStream(stream *transport.ServerStream) error {
+	for {
+		msg, err := stream.ReceiveMessage(stream.Context())
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := stream.SendMessage(stream.Context(), msg); err != nil {
+			return err
+		}
+	}
+}
+
+func TestMiddlewareStreamMetrics(t *testing.T) {
+	defer stubTime()()
+	req := &transport.Request{
+		Caller:    "caller",
+		Service:   "service",
+		Encoding:  "raw",
+		Procedure: "procedure",
+	}
+	sreq := &transport.StreamRequest{Meta: req.ToRequestMeta()}
+
+	histogram := func(t *testing.T, root *metrics.Root, name string) []int64 {
+		for _, h := range root.Snapshot().Histograms {
+			if h.Name == name {
+				return h.Values
+			}
+		}
+		t.Fatalf("histogram %q not found", name)
+		return nil
+	}
+
+	send := func(t *testing.T, stream *transport.ClientStream, body string) {
+		require.NoError(t, stream.SendMessage(context.Background(), &transport.StreamMessage{
+			Body: ioutil.NopCloser(strings.NewReader(body)),
+		}))
+	}
+
+	t.Run("outbound", func(t *testing.T) {
+		root := metrics.New()
+		core, logs := observer.New(zapcore.DebugLevel)
+		mw := NewMiddleware(zap.New(core), root.Scope(), NewNopContextExtractor())
+
+		stream, err := mw.CallStream(context.Background(), sreq, messageStreamOutbound{
+			stream: &messageStream{recv: []string{"hello", "yarpc!"}, recvErr: io.EOF},
+		})
+		require.NoError(t, err)
+		send(t, stream, "ab")
+		send(t, stream, "abcd")
+		require.NoError(t, stream.Close(context.Background()))
+		assert.Len(t, logs.FilterMessage(_successfulStreamOutbound).AllUntimed(), 0,
+			"closing the client's half must not end the stream")
+
+		for {
+			msg, err := stream.ReceiveMessage(context.Background())
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			_, err = ioutil.ReadAll(msg.Body)
+			require.NoError(t, err)
+			require.NoError(t, msg.Body.Close())
+		}
+
+		key, free := getKey(req, string(_directionOutbound), transport.Streaming)
+		edge := mw.graph.getEdge(key)
+		free()
+		assert.Equal(t, int64(2), edge.streamMessagesSent.Load())
+		assert.Equal(t, int64(2), edge.streamMessagesReceived.Load())
+		assert.Equal(t, []int64{2, 4}, histogram(t, root, "stream_sent_message_bytes"))
+		assert.Equal(t, []int64{8, 8}, histogram(t, root, "stream_received_message_bytes"))
+		assert.Equal(t, []int64{1}, histogram(t, root, "stream_duration_ms"))
+
+		ended := logs.FilterMessage(_successfulStreamOutbound).AllUntimed()
+		require.Len(t, ended, 1)
+		assert.Equal(t, map[string]interface{}{
+			"source":           "caller",
+			"dest":             "service",
+			"transport":        "unknown",
+			"procedure":        "procedure",
+			"encoding":         "raw",
+			"routingKey":       "",
+			"routingDelegate":  "",
+			"direction":        "outbound",
+			"duration":         time.Duration(0),
+			"messagesSent":     int64(2),
+			"messagesReceived": int64(2),
+			"code":             "ok",
+		}, ended[0].ContextMap())
+	})
+
+	t.Run("outbound error", func(t *testing.T) {
+		core, logs := observer.New(zapcore.DebugLevel)
+		mw := NewMiddleware(zap.New(core), metrics.New().Scope(), NewNopContextExtractor())
+
+		stream, err := mw.CallStream(context.Background(), sreq, messageStreamOutbound{
+			stream: &messageStream{recvErr: yarpcerrors.UnavailableErrorf("gone")},
+		})
+		require.NoError(t, err)
+		_, err = stream.ReceiveMessage(context.Background())
+		require.Error(t, err)
+		_, err = stream.ReceiveMessage(context.Background())
+		require.Error(t, err)
+
+		ended := logs.FilterMessage(_errorStreamOutbound).AllUntimed()
+		require.Len(t, ended, 1, "stream must only end once")
+		assert.Equal(t, zapcore.ErrorLevel, ended[0].Level)
+		assert.Equal(t, "unavailable", ended[0].ContextMap()["code"])
+	})
+
+	t.Run("inbound", func(t *testing.T) {
+		root := metrics.New()
+		core, logs := observer.New(zapcore.DebugLevel)
+		mw := NewMiddleware(zap.New(core), root.Scope(), NewNopContextExtractor())
+
+		stream, err := transport.NewServerStream(&messageStream{
+			ctx:     context.Background(),
+			request: sreq,
+			recv:    []string{"a", "abc", "abcdefghi"},
+			recvErr: io.EOF,
+		})
+		require.NoError(t, err)
+		require.NoError(t, mw.HandleStream(stream, echoStreamHandler{}))
+
+		key, free := getKey(req, string(_directionInbound), transport.Streaming)
+		edge := mw.graph.getEdge(key)
+		free()
+		assert.Equal(t, int64(3), edge.streamMessagesSent.Load())
+		assert.Equal(t, int64(3), edge.streamMessagesReceived.Load())
+		assert.Equal(t, []int64{1, 4, 16}, histogram(t, root, "stream_sent_message_bytes"))
+		assert.Equal(t, []int64{1, 4, 16}, histogram(t, root, "stream_received_message_bytes"))
+
+		ended := logs.FilterMessage(_successfulStreamInbound).AllUntimed()
+		require.Len(t, ended, 1)
+		assert.Equal(t, int64(3), ended[0].ContextMap()["messagesSent"])
+		assert.Equal(t, int64(3), ended[0].ContextMap()["messagesReceived"])
+		assert.Equal(t, "ok", ended[0].ContextMap()["code"])
+	})
+}
+
+func TestMiddlewareStreamAfterUnary(t *testing.T) {
+	defer stubTime()()
+	req := &transport.Request{
+		Caller:    "caller",
+		Service:   "service",
+		Encoding:  "raw",
+		Procedure: "procedure",
+		Body:      strings.NewReader("body"),
+	}
+	mw := NewMiddleware(zap.NewNop(), metrics.New().Scope(), NewNopContextExtractor())
+
+	// A unary call to the same procedure must not keep the stream from
+	// getting an edge with stream metrics.
+	require.NoError(t, mw.Handle(context.Background(), req, &transporttest.FakeResponseWriter{}, fakeHandler{}))
+
+	stream, err := transport.NewServerStream(&messageStream{
+		ctx:     context.Background(),
+		request: &transport.StreamRequest{Meta: req.ToRequestMeta()},
+		recv:    []string{"a"},
+		recvErr: io.EOF,
+	})
+	require.NoError(t, err)
+	require.NoError(t, mw.HandleStream(stream, echoStreamHandler{}))
+
+	key, free := getKey(req, string(_directionInbound), transport.Streaming)
+	edge := mw.graph.getEdge(key)
+	free()
+	require.NotNil(t, edge, "expected a stream edge")
+	assert.Equal(t, int64(1), edge.calls.Load())
+	assert.Equal(t, int64(1), edge.streamMessagesReceived.Load())
+
+	key, free = getKey(req, string(_directionInbound), transport.Unary)
+	edge = mw.graph.getEdge(key)
+	free()
+	assert.Equal(t, int64(1), edge.calls.Load())
+}
+
 // getKey gets the "key" that we will use to get an edge in the graph.  We use
 // a separate function to recreate the logic because extracting it out in the
 // main code could have performance implications.
-func getKey(req *transport.Request, direction string) (key []byte, free func()) {
+func getKey(req *transport.Request, direction string, rpcType transport.Type) (key []byte, free func()) {
 	d := digester.New()
 	d.Add(req.Caller)
 	d.Add(req.Service)
@@ -399,6 +737,7 @@ func getKey(req *transport.Request, direction string) (key []byte, free func())
 	d.Add(req.RoutingKey)
 	d.Add(req.RoutingDelegate)
 	d.Add(direction)
+	d.Add(rpcType.String())
 	return d.Digest(), d.Free
 }
 
@@ -489,11 +828,13 @@ func TestMiddlewareSuccessSnapshot(t *testing.T) {
 		"procedure":        "procedure",
 		"routing_delegate": "rd",
 		"routing_key":      "rk",
+		"rpc_type":         "Unary",
 		"source":           "caller",
 	}
 	want := &metrics.RootSnapshot{
 		Counters: []metrics.Snapshot{
 			{Name: "calls", Tags: tags, Value: 1},
+			{Name: "shed_requests", Tags: tags, Value: 0},
 			{Name: "successes", Tags: tags, Value: 1},
 		},
 		Histograms: []metrics.HistogramSnapshot{
@@ -551,6 +892,7 @@ func TestMiddlewareFailureSnapshot(t *testing.T) {
 		"procedure":        "procedure",
 		"routing_delegate": "rd",
 		"routing_key":      "rk",
+		"rpc_type":         "Unary",
 		"source":           "caller",
 	}
 	errorTags := metrics.Tags{
@@ -561,6 +903,7 @@ func TestMiddlewareFailureSnapshot(t *testing.T) {
 		"procedure":        "procedure",
 		"routing_delegate": "rd",
 		"routing_key":      "rk",
+		"rpc_type":         "Unary",
 		"source":           "caller",
 		"error":            "unknown_internal_yarpc",
 	}
@@ -568,6 +911,7 @@ func TestMiddlewareFailureSnapshot(t *testing.T) {
 		Counters: []metrics.Snapshot{
 			{Name: "calls", Tags: tags, Value: 1},
 			{Name: "server_failures", Tags: errorTags, Value: 1},
+			{Name: "shed_requests", Tags: tags, Value: 0},
 			{Name: "successes", Tags: tags, Value: 0},
 		},
 		Histograms: []metrics.HistogramSnapshot{
@@ -0,0 +1,75 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package observability
+
+import (
+	"context"
+
+	"go.uber.org/atomic"
+	"go.uber.org/yarpc/api/transport"
+)
+
+type shedKey struct{}
+
+// shedMarker records whether an inbound request was rejected by load
+// shedding middleware further down the chain.
+type shedMarker struct {
+	shed atomic.Bool
+}
+
+// MarkShed reports that the inbound request with the given context was
+// rejected to shed load. Requests marked this way are counted by the
+// observing middleware as shed requests on their edge, in addition to the
+// usual failure metrics.
+//
+// This has no effect if the observing middleware is not installed.
+func MarkShed(ctx context.Context) {
+	if m, ok := ctx.Value(shedKey{}).(*shedMarker); ok {
+		m.shed.Store(true)
+	}
+}
+
+func withShedMarker(ctx context.Context) (context.Context, *shedMarker) {
+	m := &shedMarker{}
+	return context.WithValue(ctx, shedKey{}, m), m
+}
+
+// shedStream overrides the context of a server stream so that load shedding
+// middleware can mark it.
+type shedStream struct {
+	*transport.ServerStream
+
+	ctx context.Context
+}
+
+func (s shedStream) Context() context.Context {
+	return s.ctx
+}
+
+func withStreamShedMarker(stream *transport.ServerStream) (*transport.ServerStream, *shedMarker) {
+	ctx, m := withShedMarker(stream.Context())
+	wrapped, err := transport.NewServerStream(shedStream{ServerStream: stream, ctx: ctx})
+	if err != nil {
+		// Not reachable: the wrapped stream is never nil.
+		return stream, &shedMarker{}
+	}
+	return wrapped, m
+}
@@ -0,0 +1,241 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package observability
+
+import (
+	"context"
+	"io"
+
+	"go.uber.org/atomic"
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	_successfulStreamInbound  = "Finished inbound stream."
+	_successfulStreamOutbound = "Finished outbound stream."
+	_errorStreamInbound       = "Inbound stream ended with an error."
+	_errorStreamOutbound      = "Outbound stream ended with an error."
+)
+
+// A streamCall tracks the messages exchanged on a single stream and records
+// its duration and final status once it terminates.
+//
+// Inbound streams terminate when the handler returns. Outbound streams
+// terminate when receiving a message fails, including with io.EOF when the
+// server finishes the stream, or when closing the stream fails.
+type streamCall struct {
+	call call
+
+	sent     atomic.Int64
+	received atomic.Int64
+	ended    atomic.Bool
+}
+
+func newStreamCall(c call) *streamCall {
+	return &streamCall{call: c}
+}
+
+func (c *streamCall) sendMessage(ctx context.Context, msg *transport.StreamMessage, send func(context.Context, *transport.StreamMessage) error) error {
+	var body *sizedBody
+	if msg != nil && msg.Body != nil {
+		body = &sizedBody{ReadCloser: msg.Body}
+		msg = &transport.StreamMessage{Body: body}
+	}
+	if err := send(ctx, msg); err != nil {
+		return err
+	}
+	c.sent.Inc()
+	c.call.edge.streamMessagesSent.Inc()
+	if body != nil {
+		c.call.edge.streamSentSizes.IncBucket(body.n)
+	}
+	return nil
+}
+
+func (c *streamCall) receiveMessage(ctx context.Context, receive func(context.Context) (*transport.StreamMessage, error)) (*transport.StreamMessage, error) {
+	msg, err := receive(ctx)
+	if err != nil {
+		return msg, err
+	}
+	c.received.Inc()
+	c.call.edge.streamMessagesReceived.Inc()
+	if msg != nil && msg.Body != nil {
+		// The size of a received message is only known once the caller has
+		// read it.
+		msg = &transport.StreamMessage{Body: &sizedBody{
+			ReadCloser: msg.Body,
+			observe:    c.call.edge.streamReceivedSizes,
+		}}
+	}
+	return msg, nil
+}
+
+// End records the termination of the stream. Only the first call has any
+// effect.
+func (c *streamCall) End(err error) {
+	if !c.ended.CAS(false, true) {
+		return
+	}
+	elapsed := _timeNow().Sub(c.call.started)
+	c.call.edge.streamDurations.Observe(elapsed)
+
+	var ce *zapcore.CheckedEntry
+	if err == nil {
+		msg := _successfulStreamInbound
+		if c.call.direction != _directionInbound {
+			msg = _successfulStreamOutbound
+		}
+		ce = c.call.edge.logger.Check(zap.DebugLevel, msg)
+	} else {
+		msg := _errorStreamInbound
+		if c.call.direction != _directionInbound {
+			msg = _errorStreamOutbound
+		}
+		ce = c.call.edge.logger.Check(zap.ErrorLevel, msg)
+	}
+	if ce == nil {
+		return
+	}
+	ce.Write(
+		zap.Duration("duration", elapsed),
+		zap.Int64("messagesSent", c.sent.Load()),
+		zap.Int64("messagesReceived", c.received.Load()),
+		zap.String("code", yarpcerrors.FromError(err).Code().String()),
+		c.call.extract(c.call.ctx),
+		zap.Error(err),
+	)
+}
+
+// observedServerStream wraps a transport.ServerStream to record the messages
+// exchanged over it.
+type observedServerStream struct {
+	stream *transport.ServerStream
+	call   *streamCall
+}
+
+func newObservedServerStream(stream *transport.ServerStream, call *streamCall) *transport.ServerStream {
+	wrapped, err := transport.NewServerStream(&observedServerStream{stream: stream, call: call})
+	if err != nil {
+		// Not reachable: the wrapped stream is never nil.
+		return stream
+	}
+	return wrapped
+}
+
+func (s *observedServerStream) Context() context.Context {
+	return s.stream.Context()
+}
+
+func (s *observedServerStream) Request() *transport.StreamRequest {
+	return s.stream.Request()
+}
+
+func (s *observedServerStream) SendMessage(ctx context.Context, msg *transport.StreamMessage) error {
+	return s.call.sendMessage(ctx, msg, s.stream.SendMessage)
+}
+
+func (s *observedServerStream) ReceiveMessage(ctx context.Context) (*transport.StreamMessage, error) {
+	return s.call.receiveMessage(ctx, s.stream.ReceiveMessage)
+}
+
+// observedClientStream wraps a transport.ClientStream to record the messages
+// exchanged over it and detect when it terminates.
+type observedClientStream struct {
+	stream *transport.ClientStream
+	call   *streamCall
+}
+
+func newObservedClientStream(stream *transport.ClientStream, call *streamCall) *transport.ClientStream {
+	wrapped, err := transport.NewClientStream(&observedClientStream{stream: stream, call: call})
+	if err != nil {
+		// Not reachable: the wrapped stream is never nil.
+		return stream
+	}
+	return wrapped
+}
+
+func (s *observedClientStream) Context() context.Context {
+	return s.stream.Context()
+}
+
+func (s *observedClientStream) Request() *transport.StreamRequest {
+	return s.stream.Request()
+}
+
+func (s *observedClientStream) SendMessage(ctx context.Context, msg *transport.StreamMessage) error {
+	return s.call.sendMessage(ctx, msg, s.stream.SendMessage)
+}
+
+func (s *observedClientStream) ReceiveMessage(ctx context.Context) (*transport.StreamMessage, error) {
+	msg, err := s.call.receiveMessage(ctx, s.stream.ReceiveMessage)
+	if err == io.EOF {
+		s.call.End(nil)
+	} else if err != nil {
+		s.call.End(err)
+	}
+	return msg, err
+}
+
+func (s *observedClientStream) Close(ctx context.Context) error {
+	// Close only ends the client's half of the stream; the server may still
+	// send messages, so a successful close does not terminate the stream.
+	err := s.stream.Close(ctx)
+	if err != nil {
+		s.call.End(err)
+	}
+	return err
+}
+
+// sizedBody counts the bytes read from a stream message body. If observe is
+// set, the size is recorded once the body has been read to the end or closed.
+type sizedBody struct {
+	io.ReadCloser
+
+	n        int64
+	observe  *metrics.Histogram
+	observed bool
+}
+
+func (b *sizedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	if err == io.EOF {
+		b.record()
+	}
+	return n, err
+}
+
+func (b *sizedBody) Close() error {
+	b.record()
+	return b.ReadCloser.Close()
+}
+
+func (b *sizedBody) record() {
+	if b.observe == nil || b.observed {
+		return
+	}
+	b.observed = true
+	b.observe.IncBucket(b.n)
+}
@@ -61,6 +61,16 @@ func (c unaryChain) Call(ctx context.Context, request *transport.Request, out tr
 	}.Call(ctx, request)
 }
 
+// IntrospectOutbound reports the status of every middleware in the chain that
+// supports introspection.
+func (c unaryChain) IntrospectOutbound(service string) []introspection.MiddlewareStatus {
+	var statuses []introspection.MiddlewareStatus
+	for _, mw := range c {
+		statuses = append(statuses, introspection.IntrospectOutboundMiddleware(service, mw)...)
+	}
+	return statuses
+}
+
 // unaryChainExec adapts a series of `UnaryOutbound`s into a `UnaryOutbound`. It
 // is scoped to a single call of a UnaryOutbound and is not thread-safe.
 type unaryChainExec struct {
@@ -133,6 +143,16 @@ func (c onewayChain) CallOneway(ctx context.Context, request *transport.Request,
 	}.CallOneway(ctx, request)
 }
 
+// IntrospectOutbound reports the status of every middleware in the chain that
+// supports introspection.
+func (c onewayChain) IntrospectOutbound(service string) []introspection.MiddlewareStatus {
+	var statuses []introspection.MiddlewareStatus
+	for _, mw := range c {
+		statuses = append(statuses, introspection.IntrospectOutboundMiddleware(service, mw)...)
+	}
+	return statuses
+}
+
 // onewayChainExec adapts a series of `OnewayOutbound`s into a `OnewayOutbound`. It
 // is scoped to a single call of a OnewayOutbound and is not thread-safe.
 type onewayChainExec struct {
@@ -252,6 +252,49 @@ func TestIntrospect(t *testing.T) {
 	})
 }
 
+// introspectableMiddleware reports a fixed status for every service.
+type introspectableMiddleware struct {
+	middleware.UnaryOutbound
+	middleware.OnewayOutbound
+
+	name string
+}
+
+func (m introspectableMiddleware) IntrospectOutbound(service string) []introspection.MiddlewareStatus {
+	return []introspection.MiddlewareStatus{{Name: m.name, State: service}}
+}
+
+func TestIntrospectOutboundMiddleware(t *testing.T) {
+	a := introspectableMiddleware{
+		UnaryOutbound:  middleware.NopUnaryOutbound,
+		OnewayOutbound: middleware.NopOnewayOutbound,
+		name:           "a",
+	}
+	b := introspectableMiddleware{
+		UnaryOutbound:  middleware.NopUnaryOutbound,
+		OnewayOutbound: middleware.NopOnewayOutbound,
+		name:           "b",
+	}
+	want := []introspection.MiddlewareStatus{
+		{Name: "a", State: "foo"},
+		{Name: "b", State: "foo"},
+	}
+
+	t.Run("unary", func(t *testing.T) {
+		chain := UnaryChain(a, middleware.NopUnaryOutbound, b)
+		assert.Equal(t, want, introspection.IntrospectOutboundMiddleware("foo", chain))
+	})
+
+	t.Run("oneway", func(t *testing.T) {
+		chain := OnewayChain(a, middleware.NopOnewayOutbound, b)
+		assert.Equal(t, want, introspection.IntrospectOutboundMiddleware("foo", chain))
+	})
+
+	t.Run("not supported", func(t *testing.T) {
+		assert.Nil(t, introspection.IntrospectOutboundMiddleware("foo", middleware.NopUnaryOutbound))
+	})
+}
+
 var retryStreamOutbound middleware.StreamOutboundFunc = func(
 	ctx context.Context, req *transport.StreamRequest, o transport.StreamOutbound) (*transport.ClientStream, error) {
 	res, err := o.CallStream(ctx, req)
@@ -0,0 +1,44 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package strhash
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSum64Deterministic(t *testing.T) {
+	assert.Equal(t, Sum64("127.0.0.1:8080"), Sum64("127.0.0.1:8080"))
+	assert.NotEqual(t, Sum64("127.0.0.1:8080"), Sum64("127.0.0.1:8081"))
+}
+
+func TestSum64SpreadsSimilarStrings(t *testing.T) {
+	// Strings that differ only in their last character must still be spread
+	// over the whole range rather than clustered together.
+	var top [2]int
+	for i := 0; i < 1000; i++ {
+		top[Sum64("peer#"+strconv.Itoa(i))>>63]++
+	}
+	assert.InDelta(t, 500, top[0], 100)
+	assert.InDelta(t, 500, top[1], 100)
+}
@@ -0,0 +1,168 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package testtls generates certificates for tests of TLS support.
+package testtls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Scenario is a certificate authority with a server certificate and a
+// client certificate signed by it, written to PEM files in a temporary
+// directory.
+type Scenario struct {
+	t      testing.TB
+	dir    string
+	serial int64
+
+	ca    *x509.Certificate
+	caKey *ecdsa.PrivateKey
+
+	// CAs holds the certificate authority.
+	CAs *x509.CertPool
+
+	CAFile         string
+	ServerCertFile string
+	ServerKeyFile  string
+	ClientCertFile string
+	ClientKeyFile  string
+}
+
+// NewScenario creates a certificate authority, a server certificate for
+// localhost and 127.0.0.1 with the common name "server", and a client
+// certificate with the common name "client".
+func NewScenario(t testing.TB) *Scenario {
+	dir, err := ioutil.TempDir("", "testtls")
+	require.NoError(t, err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	now := time.Now()
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "test ca"},
+		SerialNumber:          big.NewInt(1),
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		NotBefore:             now.Add(-time.Minute),
+		NotAfter:              now.Add(time.Hour),
+	}, &x509.Certificate{}, key.Public(), key)
+	require.NoError(t, err)
+	ca, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	s := &Scenario{
+		t:              t,
+		dir:            dir,
+		serial:         1,
+		ca:             ca,
+		caKey:          key,
+		CAs:            x509.NewCertPool(),
+		CAFile:         filepath.Join(dir, "ca.pem"),
+		ServerCertFile: filepath.Join(dir, "server.pem"),
+		ServerKeyFile:  filepath.Join(dir, "server-key.pem"),
+		ClientCertFile: filepath.Join(dir, "client.pem"),
+		ClientKeyFile:  filepath.Join(dir, "client-key.pem"),
+	}
+	s.CAs.AddCert(ca)
+	s.writePEM(s.CAFile, "CERTIFICATE", der)
+	s.IssueServerCert("server")
+	s.IssueClientCert("client")
+	return s
+}
+
+// Cleanup removes the files of the scenario.
+func (s *Scenario) Cleanup() {
+	os.RemoveAll(s.dir)
+}
+
+// IssueServerCert replaces the server certificate and key with a new pair
+// with the given common name.
+func (s *Scenario) IssueServerCert(commonName string) {
+	s.issue(s.ServerCertFile, s.ServerKeyFile, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: commonName},
+		DNSNames:    []string{"localhost"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+}
+
+// IssueClientCert replaces the client certificate and key with a new pair
+// with the given common name and URI subject alternative names, such as
+// SPIFFE IDs.
+func (s *Scenario) IssueClientCert(commonName string, uris ...string) {
+	template := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: commonName},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if len(uris) > 0 {
+		// The URIs field of x509.Certificate is not available before Go 1.10.
+		names := make([]asn1.RawValue, len(uris))
+		for i, uri := range uris {
+			names[i] = asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 6, Bytes: []byte(uri)}
+		}
+		value, err := asn1.Marshal(names)
+		require.NoError(s.t, err)
+		template.ExtraExtensions = []pkix.Extension{
+			{Id: asn1.ObjectIdentifier{2, 5, 29, 17}, Value: value},
+		}
+	}
+	s.issue(s.ClientCertFile, s.ClientKeyFile, template)
+}
+
+func (s *Scenario) issue(certFile, keyFile string, template *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(s.t, err)
+
+	s.serial++
+	now := time.Now()
+	template.SerialNumber = big.NewInt(s.serial)
+	template.NotBefore = now.Add(-time.Minute)
+	template.NotAfter = now.Add(time.Hour)
+	template.KeyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageKeyAgreement
+	der, err := x509.CreateCertificate(rand.Reader, template, s.ca, key.Public(), s.caKey)
+	require.NoError(s.t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(s.t, err)
+
+	s.writePEM(keyFile, "EC PRIVATE KEY", keyDER)
+	s.writePEM(certFile, "CERTIFICATE", der)
+}
+
+func (s *Scenario) writePEM(path, blockType string, der []byte) {
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	require.NoError(s.t, ioutil.WriteFile(path, data, 0600))
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcerrors
+
+import (
+	"encoding/base64"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/any"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/zap"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+)
+
+// DetailsToProto converts the details of a Status to google.protobuf.Any
+// messages.
+func DetailsToProto(details []yarpcerrors.Detail) []*any.Any {
+	if len(details) == 0 {
+		return nil
+	}
+	anys := make([]*any.Any, len(details))
+	for i, d := range details {
+		anys[i] = &any.Any{TypeUrl: d.TypeURL, Value: d.Value}
+	}
+	return anys
+}
+
+// DetailsFromProto converts google.protobuf.Any messages to Status details.
+func DetailsFromProto(anys []*any.Any) []yarpcerrors.Detail {
+	if len(anys) == 0 {
+		return nil
+	}
+	details := make([]yarpcerrors.Detail, 0, len(anys))
+	for _, a := range anys {
+		if a != nil {
+			details = append(details, yarpcerrors.Detail{TypeURL: a.TypeUrl, Value: a.Value})
+		}
+	}
+	return details
+}
+
+// MarshalDetails encodes the details of a Status for transports that carry
+// them in a header. The details are encoded as a google.rpc.Status message
+// with only its details field set, in base64.
+//
+// Returns an empty string if there are no details.
+func MarshalDetails(details []yarpcerrors.Detail) (string, error) {
+	if len(details) == 0 {
+		return "", nil
+	}
+	b, err := proto.Marshal(&spb.Status{Details: DetailsToProto(details)})
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// UnmarshalDetails decodes details encoded with MarshalDetails.
+func UnmarshalDetails(s string) ([]yarpcerrors.Detail, error) {
+	if s == "" {
+		return nil, nil
+	}
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var status spb.Status
+	if err := proto.Unmarshal(b, &status); err != nil {
+		return nil, err
+	}
+	return DetailsFromProto(status.Details), nil
+}
+
+// DetailsHeader encodes the details of the given status for an error details
+// header. Details that cannot be encoded are logged and dropped so that the
+// caller still receives the status itself.
+func DetailsHeader(logger *zap.Logger, status *yarpcerrors.Status) string {
+	details, err := MarshalDetails(status.Details())
+	if err != nil {
+		logger.Warn("dropping error details that could not be encoded",
+			zap.Stringer("code", status.Code()), zap.Error(err))
+		return ""
+	}
+	return details
+}
+
+// DetailsFromHeader decodes an error details header. Malformed details are
+// logged and dropped so that the caller still receives the status itself.
+func DetailsFromHeader(logger *zap.Logger, header string) []yarpcerrors.Detail {
+	details, err := UnmarshalDetails(header)
+	if err != nil {
+		logger.Warn("dropping malformed error details", zap.Error(err))
+		return nil
+	}
+	return details
+}
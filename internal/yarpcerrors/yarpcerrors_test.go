@@ -25,6 +25,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/yarpc/yarpcerrors"
 )
 
@@ -51,6 +52,12 @@ func TestAnnotateWithError(t *testing.T) {
 			},
 			wantErr: yarpcerrors.FailedPreconditionErrorf("mytest arg1: test"),
 		},
+		{
+			name:       "with details",
+			giveErr:    yarpcerrors.Newf(yarpcerrors.CodeFailedPrecondition, "test").WithDetails(yarpcerrors.Detail{TypeURL: "foo", Value: []byte("bar")}),
+			giveFormat: "mytest",
+			wantErr:    yarpcerrors.Newf(yarpcerrors.CodeFailedPrecondition, "mytest: test").WithDetails(yarpcerrors.Detail{TypeURL: "foo", Value: []byte("bar")}),
+		},
 		{
 			name:       "unannotated",
 			giveErr:    errors.New("test"),
@@ -65,3 +72,42 @@ func TestAnnotateWithError(t *testing.T) {
 		})
 	}
 }
+
+func TestDetailsRoundTrip(t *testing.T) {
+	details := []yarpcerrors.Detail{
+		{TypeURL: "type.googleapis.com/foo.Bar", Value: []byte("bar")},
+		{TypeURL: "type.googleapis.com/foo.Baz"},
+	}
+
+	encoded, err := MarshalDetails(details)
+	require.NoError(t, err)
+	decoded, err := UnmarshalDetails(encoded)
+	require.NoError(t, err)
+	require.Len(t, decoded, 2)
+	assert.Equal(t, details[0], decoded[0])
+	assert.Equal(t, details[1].TypeURL, decoded[1].TypeURL)
+	assert.Empty(t, decoded[1].Value)
+
+	assert.Equal(t, details[0], DetailsFromProto(DetailsToProto(details))[0])
+}
+
+func TestDetailsEmpty(t *testing.T) {
+	encoded, err := MarshalDetails(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "", encoded)
+
+	decoded, err := UnmarshalDetails("")
+	require.NoError(t, err)
+	assert.Nil(t, decoded)
+
+	assert.Nil(t, DetailsToProto(nil))
+	assert.Nil(t, DetailsFromProto(nil))
+}
+
+func TestUnmarshalDetailsInvalid(t *testing.T) {
+	_, err := UnmarshalDetails("not base64!")
+	assert.Error(t, err)
+
+	_, err = UnmarshalDetails("/////w==")
+	assert.Error(t, err)
+}
@@ -58,6 +58,7 @@ func (d *Dispatcher) Introspect() introspection.DispatcherStatus {
 			status.RPCType = "unary"
 			status.Service = o.ServiceName
 			status.OutboundKey = outboundKey
+			status.Middleware = introspection.IntrospectOutboundMiddleware(o.ServiceName, d.outboundMiddleware.Unary)
 			outbounds = append(outbounds, status)
 		}
 		if o.Oneway != nil {
@@ -70,6 +71,7 @@ func (d *Dispatcher) Introspect() introspection.DispatcherStatus {
 			status.RPCType = "oneway"
 			status.Service = o.ServiceName
 			status.OutboundKey = outboundKey
+			status.Middleware = introspection.IntrospectOutboundMiddleware(o.ServiceName, d.outboundMiddleware.Oneway)
 			outbounds = append(outbounds, status)
 		}
 	}
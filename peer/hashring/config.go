@@ -0,0 +1,79 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hashring
+
+import (
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/yarpcconfig"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// Configuration describes how to build a hash ring peer list.
+type Configuration struct {
+	// Replicas is the number of points at which each peer is placed on the
+	// ring. Defaults to 100.
+	Replicas int `config:"replicas"`
+
+	// Header is the name of a request header holding the key to hash for
+	// requests that have no shard key.
+	Header string `config:"header"`
+}
+
+// Spec returns a configuration specification for the hash ring peer list
+// implementation, making it possible to route requests with the same shard
+// key to the same peer with transports that use outbound peer list
+// configuration (like HTTP).
+//
+//  cfg := yarpcconfig.New()
+//  cfg.MustRegisterPeerList(hashring.Spec())
+//
+// This enables the hash ring peer list:
+//
+//  outbounds:
+//    otherservice:
+//      unary:
+//        http:
+//          url: https://host:port/rpc
+//          hash-ring:
+//            header: x-user-id
+//            peers:
+//              - 127.0.0.1:8080
+//              - 127.0.0.1:8081
+func Spec() yarpcconfig.PeerListSpec {
+	return yarpcconfig.PeerListSpec{
+		Name: "hash-ring",
+		BuildPeerList: func(cfg Configuration, t peer.Transport, k *yarpcconfig.Kit) (peer.ChooserList, error) {
+			if cfg.Replicas < 0 {
+				return nil, yarpcerrors.InvalidArgumentErrorf(
+					"replicas must not be negative, got %d", cfg.Replicas)
+			}
+
+			var opts []ListOption
+			if cfg.Replicas > 0 {
+				opts = append(opts, Replicas(cfg.Replicas))
+			}
+			if cfg.Header != "" {
+				opts = append(opts, ShardKeyHeader(cfg.Header))
+			}
+			return New(t, opts...), nil
+		},
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package hashring provides a peer list that routes requests with the same
+// shard key to the same peer using consistent hashing.
+//
+// Every peer is placed on a ring at a number of points derived from its
+// identifier. A request goes to the first peer on the ring at or after the
+// hash of its shard key, so adding or removing a peer only moves the keys
+// between it and its neighbors. Peers that become unavailable leave the ring
+// until they reconnect, so their keys move on to the next available peer in
+// the meantime.
+//
+// Requests use transport.Request.ShardKey, as set by the yarpc.WithShardKey
+// call option. Services that carry the key in a header instead may name that
+// header with the ShardKeyHeader option; it is consulted only if the request
+// has no shard key. Requests without any key are spread over the peers in
+// turn.
+//
+// 	chooser := peer.Bind(
+// 		hashring.New(transport, hashring.ShardKeyHeader("x-user-id")),
+// 		peer.BindPeers(peers),
+// 	)
+package hashring
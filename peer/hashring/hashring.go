@@ -0,0 +1,168 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hashring
+
+import (
+	"context"
+	"sort"
+	"strconv"
+
+	"go.uber.org/atomic"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/strhash"
+	"go.uber.org/yarpc/peer/peerlist/v2"
+)
+
+type subscriber struct {
+	index int
+	peer  peer.StatusPeer
+}
+
+func (*subscriber) NotifyStatusChanged(peer.Identifier) {}
+
+type point struct {
+	hash uint64
+	sub  *subscriber
+}
+
+// hashRing places each available peer at a number of points on a ring of
+// 64-bit hashes. Add and Remove run under the write lock of the peer list
+// and Choose under its read lock, so Choose must only read the ring.
+type hashRing struct {
+	replicas int
+	header   string
+
+	points      []point // sorted by hash
+	subscribers []*subscriber
+	next        atomic.Uint64 // for rotating through peers for keyless requests
+}
+
+func newHashRing(options listOptions) *hashRing {
+	return &hashRing{
+		replicas:    options.replicas,
+		header:      options.header,
+		points:      make([]point, 0, options.capacity*options.replicas),
+		subscribers: make([]*subscriber, 0, options.capacity),
+	}
+}
+
+var _ peerlist.Implementation = (*hashRing)(nil)
+
+func (r *hashRing) Add(p peer.StatusPeer, pid peer.Identifier) peer.Subscriber {
+	sub := &subscriber{index: len(r.subscribers), peer: p}
+	r.subscribers = append(r.subscribers, sub)
+
+	id := pid.Identifier()
+	added := make([]point, r.replicas)
+	for i := range added {
+		added[i] = point{hash: strhash.Sum64(id + "#" + strconv.Itoa(i)), sub: sub}
+	}
+	sort.Slice(added, func(i, j int) bool {
+		return added[i].hash < added[j].hash
+	})
+	r.merge(added)
+	return sub
+}
+
+// merge merges the sorted points of a new peer into the ring, working back
+// from the end of the ring so that no point is moved more than once.
+func (r *hashRing) merge(added []point) {
+	i := len(r.points) - 1
+	r.points = append(r.points, added...)
+	for j, k := len(added)-1, len(r.points)-1; j >= 0; k-- {
+		if i >= 0 && r.points[i].hash > added[j].hash {
+			r.points[k] = r.points[i]
+			i--
+		} else {
+			r.points[k] = added[j]
+			j--
+		}
+	}
+}
+
+func (r *hashRing) Remove(_ peer.StatusPeer, _ peer.Identifier, ps peer.Subscriber) {
+	sub, ok := ps.(*subscriber)
+	if !ok {
+		return
+	}
+
+	last := len(r.subscribers) - 1
+	if sub.index > last || r.subscribers[sub.index] != sub {
+		return
+	}
+	r.subscribers[sub.index] = r.subscribers[last]
+	r.subscribers[sub.index].index = sub.index
+	r.subscribers[last] = nil
+	r.subscribers = r.subscribers[:last]
+
+	points := r.points[:0]
+	for _, pt := range r.points {
+		if pt.sub != sub {
+			points = append(points, pt)
+		}
+	}
+	for i := len(points); i < len(r.points); i++ {
+		r.points[i] = point{}
+	}
+	r.points = points
+}
+
+func (r *hashRing) Choose(_ context.Context, req *transport.Request) peer.StatusPeer {
+	if len(r.subscribers) == 0 {
+		return nil
+	}
+
+	key := r.key(req)
+	if key == "" {
+		i := r.next.Inc() % uint64(len(r.subscribers))
+		return r.subscribers[i].peer
+	}
+
+	h := strhash.Sum64(key)
+	i := sort.Search(len(r.points), func(i int) bool {
+		return r.points[i].hash >= h
+	})
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.points[i].sub.peer
+}
+
+func (r *hashRing) key(req *transport.Request) string {
+	if req.ShardKey != "" || r.header == "" {
+		return req.ShardKey
+	}
+	key, _ := req.Headers.Get(r.header)
+	return key
+}
+
+func (r *hashRing) Start() error {
+	return nil
+}
+
+func (r *hashRing) Stop() error {
+	return nil
+}
+
+func (r *hashRing) IsRunning() bool {
+	return true
+}
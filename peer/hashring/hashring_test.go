@@ -0,0 +1,180 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hashring
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/peer/hostport"
+	"go.uber.org/yarpc/yarpctest"
+)
+
+func newList(t *testing.T, trans peer.Transport, peers []string, opts ...ListOption) *List {
+	pl := New(trans, opts...)
+	var ids []peer.Identifier
+	for _, p := range peers {
+		ids = append(ids, hostport.Identify(p))
+	}
+	require.NoError(t, pl.Update(peer.ListUpdates{Additions: ids}))
+	require.NoError(t, pl.Start())
+	return pl
+}
+
+func choose(t *testing.T, pl peer.Chooser, req *transport.Request) string {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	p, onFinish, err := pl.Choose(ctx, req)
+	require.NoError(t, err)
+	onFinish(nil)
+	return p.Identifier()
+}
+
+func shardKeys(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+	}
+	return keys
+}
+
+func TestHashRingSticky(t *testing.T) {
+	peers := []string{"a", "b", "c", "d"}
+	trans := yarpctest.NewFakeTransport(yarpctest.InitialConnectionStatus(peer.Available))
+	pl := newList(t, trans, peers)
+	defer pl.Stop()
+
+	// A second list with the peers added in another order must agree.
+	other := newList(t, trans, []string{"d", "c", "b", "a"})
+	defer other.Stop()
+
+	counts := make(map[string]int)
+	for _, key := range shardKeys(1000) {
+		req := &transport.Request{ShardKey: key}
+		chosen := choose(t, pl, req)
+		assert.Equal(t, chosen, choose(t, pl, req), "key %q must stick to a peer", key)
+		assert.Equal(t, chosen, choose(t, other, req), "key %q must not depend on peer order", key)
+		counts[chosen]++
+	}
+
+	for _, p := range peers {
+		assert.InDelta(t, 250, counts[p], 100, "peer %q must own a fair share of keys", p)
+	}
+}
+
+func TestHashRingRemoval(t *testing.T) {
+	trans := yarpctest.NewFakeTransport(yarpctest.InitialConnectionStatus(peer.Available))
+	pl := newList(t, trans, []string{"a", "b", "c", "d"})
+	defer pl.Stop()
+
+	keys := shardKeys(1000)
+	before := make(map[string]string, len(keys))
+	for _, key := range keys {
+		before[key] = choose(t, pl, &transport.Request{ShardKey: key})
+	}
+
+	require.NoError(t, pl.Update(peer.ListUpdates{
+		Removals: []peer.Identifier{hostport.Identify("b")},
+	}))
+	for _, key := range keys {
+		after := choose(t, pl, &transport.Request{ShardKey: key})
+		if before[key] == "b" {
+			assert.NotEqual(t, "b", after, "removed peer must not be chosen")
+		} else {
+			assert.Equal(t, before[key], after, "only keys of the removed peer may move")
+		}
+	}
+}
+
+func TestHashRingUnavailable(t *testing.T) {
+	trans := yarpctest.NewFakeTransport(yarpctest.InitialConnectionStatus(peer.Available))
+	pl := newList(t, trans, []string{"a", "b", "c"})
+	defer pl.Stop()
+
+	req := &transport.Request{ShardKey: "my-key"}
+	owner := choose(t, pl, req)
+
+	trans.SimulateDisconnect(hostport.Identify(owner))
+	fallback := choose(t, pl, req)
+	assert.NotEqual(t, owner, fallback, "unavailable owner must not be chosen")
+	assert.Equal(t, fallback, choose(t, pl, req), "fallback must be sticky")
+
+	trans.SimulateConnect(hostport.Identify(owner))
+	assert.Equal(t, owner, choose(t, pl, req), "owner must be chosen once it is available again")
+}
+
+func TestHashRingKeys(t *testing.T) {
+	trans := yarpctest.NewFakeTransport(yarpctest.InitialConnectionStatus(peer.Available))
+	pl := newList(t, trans, []string{"a", "b", "c"}, ShardKeyHeader("x-user-id"), Replicas(10))
+	defer pl.Stop()
+
+	headerReq := &transport.Request{
+		Headers: transport.NewHeaders().With("x-user-id", "my-key"),
+	}
+	assert.Equal(t,
+		choose(t, pl, &transport.Request{ShardKey: "my-key"}),
+		choose(t, pl, headerReq),
+		"header must be hashed like a shard key")
+
+	// The shard key takes precedence over the header.
+	for _, key := range shardKeys(20) {
+		req := &transport.Request{
+			ShardKey: key,
+			Headers:  transport.NewHeaders().With("x-user-id", "my-key"),
+		}
+		assert.Equal(t, choose(t, pl, &transport.Request{ShardKey: key}), choose(t, pl, req))
+	}
+
+	// Requests without a key rotate through the peers.
+	counts := make(map[string]int)
+	for i := 0; i < 30; i++ {
+		counts[choose(t, pl, &transport.Request{})]++
+	}
+	assert.Equal(t, map[string]int{"a": 10, "b": 10, "c": 10}, counts)
+}
+
+func TestHashRingPointsSorted(t *testing.T) {
+	r := newHashRing(listOptions{replicas: 20})
+	isSorted := func() bool {
+		return sort.SliceIsSorted(r.points, func(i, j int) bool {
+			return r.points[i].hash < r.points[j].hash
+		})
+	}
+
+	var subs []peer.Subscriber
+	for i := 0; i < 10; i++ {
+		subs = append(subs, r.Add(nil, hostport.PeerIdentifier(fmt.Sprintf("10.0.0.%d:8080", i))))
+		require.Len(t, r.points, (i+1)*20)
+		require.True(t, isSorted(), "points must stay sorted after adding peer %d", i)
+	}
+
+	r.Remove(nil, nil, subs[3])
+	assert.Len(t, r.points, 9*20)
+	assert.True(t, isSorted(), "points must stay sorted after removing a peer")
+}
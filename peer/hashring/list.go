@@ -0,0 +1,107 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hashring
+
+import (
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/peer/peerlist/v2"
+)
+
+type listOptions struct {
+	capacity int
+	replicas int
+	header   string
+}
+
+var defaultListOptions = listOptions{
+	capacity: 10,
+	replicas: 100,
+}
+
+// ListOption customizes the behavior of a hash ring peer list.
+type ListOption interface {
+	apply(*listOptions)
+}
+
+type listOptionFunc func(*listOptions)
+
+func (f listOptionFunc) apply(options *listOptions) { f(options) }
+
+// Capacity specifies the default capacity of the underlying
+// data structures for this list.
+//
+// Defaults to 10.
+func Capacity(capacity int) ListOption {
+	return listOptionFunc(func(options *listOptions) {
+		options.capacity = capacity
+	})
+}
+
+// Replicas specifies the number of points at which each peer is placed on
+// the ring. More points spread keys more evenly over the peers at the cost
+// of memory and slower peer list updates.
+//
+// Defaults to 100.
+func Replicas(replicas int) ListOption {
+	return listOptionFunc(func(options *listOptions) {
+		if replicas > 0 {
+			options.replicas = replicas
+		}
+	})
+}
+
+// ShardKeyHeader specifies a request header holding the key to hash for
+// requests that have no shard key.
+//
+// Defaults to no header: only the shard key is used.
+func ShardKeyHeader(name string) ListOption {
+	return listOptionFunc(func(options *listOptions) {
+		options.header = name
+	})
+}
+
+// New creates a new hash ring peer list.
+func New(transport peer.Transport, opts ...ListOption) *List {
+	options := defaultListOptions
+	for _, opt := range opts {
+		opt.apply(&options)
+	}
+
+	plOpts := []peerlist.ListOption{
+		peerlist.Capacity(options.capacity),
+		peerlist.NoShuffle(),
+	}
+
+	return &List{
+		List: peerlist.New(
+			"hash-ring",
+			transport,
+			newHashRing(options),
+			plOpts...,
+		),
+	}
+}
+
+// List is a PeerList that chooses peers by consistent hashing of the shard
+// key of each request.
+type List struct {
+	*peerlist.List
+}
@@ -40,6 +40,26 @@ func Identify(peer string) peer.Identifier {
 	return PeerIdentifier(peer)
 }
 
+// WeightedPeerIdentifier is a PeerIdentifier with a relative weight, for use
+// with peer lists that support weights.
+type WeightedPeerIdentifier struct {
+	PeerIdentifier
+
+	PeerWeight uint32
+}
+
+var _ peer.WeightedIdentifier = WeightedPeerIdentifier{}
+
+// Weight returns the relative weight of the peer.
+func (p WeightedPeerIdentifier) Weight() uint32 {
+	return p.PeerWeight
+}
+
+// IdentifyWeighted coerces a string and a weight to a WeightedPeerIdentifier
+func IdentifyWeighted(peer string, weight uint32) peer.WeightedIdentifier {
+	return WeightedPeerIdentifier{PeerIdentifier: PeerIdentifier(peer), PeerWeight: weight}
+}
+
 // NewPeer creates a new hostport.Peer from a hostport.PeerIdentifier, peer.Transport, and peer.Subscriber
 func NewPeer(pid PeerIdentifier, transport peer.Transport) *Peer {
 	p := &Peer{
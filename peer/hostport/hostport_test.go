@@ -52,6 +52,12 @@ func TestPeerIdentifier(t *testing.T) {
 	}
 }
 
+func TestWeightedPeerIdentifier(t *testing.T) {
+	pid := IdentifyWeighted("localhost:12345", 3)
+	assert.Equal(t, "localhost:12345", pid.Identifier())
+	assert.Equal(t, uint32(3), pid.Weight())
+}
+
 func TestPeer(t *testing.T) {
 	type testStruct struct {
 		msg string
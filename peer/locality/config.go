@@ -0,0 +1,86 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package locality
+
+import (
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/yarpcconfig"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// Configuration describes how to build a locality-aware peer list.
+type Configuration struct {
+	// Locality is the locality of the caller. This is usually interpolated
+	// from the environment.
+	Locality string `config:"locality,interpolate"`
+
+	// Threshold is the number of available peers a tier needs before
+	// requests spill over to the next tier. Defaults to 1.
+	Threshold int `config:"threshold"`
+
+	// Tiers are the failover tiers of localities to use, in order, after
+	// the caller's own locality.
+	Tiers [][]string `config:"tiers"`
+}
+
+// Spec returns a configuration specification for the locality-aware peer
+// list implementation, making it possible to prefer peers in the same
+// locality with transports that use outbound peer list configuration (like
+// HTTP).
+//
+//  cfg := yarpcconfig.New()
+//  cfg.MustRegisterPeerList(locality.Spec())
+//
+// This enables the locality peer list. Peer localities come from the peer
+// list updater; for example, entries in a peers file may have localities:
+//
+//  outbounds:
+//    otherservice:
+//      unary:
+//        http:
+//          url: https://host:port/rpc
+//          locality:
+//            locality: ${ZONE}
+//            threshold: 2
+//            tiers:
+//              - [us-east-1b, us-east-1c]
+//            file:
+//              path: /etc/otherservice/peers.yaml
+func Spec() yarpcconfig.PeerListSpec {
+	return yarpcconfig.PeerListSpec{
+		Name: "locality",
+		BuildPeerList: func(cfg Configuration, t peer.Transport, k *yarpcconfig.Kit) (peer.ChooserList, error) {
+			if cfg.Locality == "" {
+				return nil, yarpcerrors.InvalidArgumentErrorf("locality is required")
+			}
+			if cfg.Threshold < 0 {
+				return nil, yarpcerrors.InvalidArgumentErrorf(
+					"threshold must not be negative, got %d", cfg.Threshold)
+			}
+
+			opts := []ListOption{Threshold(cfg.Threshold)}
+			for _, localities := range cfg.Tiers {
+				opts = append(opts, Tier(localities...))
+			}
+			return New(t, cfg.Locality, opts...), nil
+		},
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package locality
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/yarpcconfig"
+	"go.uber.org/yarpc/yarpctest"
+)
+
+type attrs map[string]interface{}
+
+func TestConfig(t *testing.T) {
+	tests := []struct {
+		desc    string
+		attrs   attrs
+		wantErr string
+	}{
+		{
+			desc: "locality only",
+			attrs: attrs{
+				"locality": "us-east-1a",
+				"peers":    []string{"1.1.1.1:1111"},
+			},
+		},
+		{
+			desc: "interpolated locality with tiers",
+			attrs: attrs{
+				"locality":  "${ZONE}",
+				"threshold": 2,
+				"tiers":     [][]string{{"us-east-1b", "us-east-1c"}, {"us-west-2a"}},
+				"peers":     []string{"1.1.1.1:1111"},
+			},
+		},
+		{
+			desc: "missing locality",
+			attrs: attrs{
+				"peers": []string{"1.1.1.1:1111"},
+			},
+			wantErr: "locality is required",
+		},
+		{
+			desc: "negative threshold",
+			attrs: attrs{
+				"locality":  "us-east-1a",
+				"threshold": -1,
+				"peers":     []string{"1.1.1.1:1111"},
+			},
+			wantErr: "threshold must not be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			cfg := yarpcconfig.New(yarpcconfig.InterpolationResolver(func(key string) (string, bool) {
+				return map[string]string{"ZONE": "us-east-1a"}[key], key == "ZONE"
+			}))
+			cfg.RegisterPeerList(Spec())
+			cfg.RegisterTransport(yarpctest.FakeTransportSpec())
+			config, err := cfg.LoadConfig("our-service", attrs{
+				"outbounds": attrs{
+					"their-service": attrs{
+						"fake-transport": attrs{
+							"locality": tt.attrs,
+						},
+					},
+				},
+			})
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.NotNil(t, config.Outbounds["their-service"].Unary)
+		})
+	}
+}
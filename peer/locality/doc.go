@@ -0,0 +1,47 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package locality provides a peer list that prefers peers in the same
+// locality as the caller, such as the same zone, region or datacenter.
+//
+// Peers are grouped into tiers by the locality label carried by their
+// identifiers. The first tier holds the peers in the caller's own locality,
+// followed by any failover tiers given with the Tier option, and a last tier
+// holding the peers in every other locality or with no locality at all.
+// Requests go to the first tier with at least the threshold number of
+// available peers, and spill over to later tiers only when earlier tiers
+// run low. Peers are chosen within a tier by a separate
+// peerlist.Implementation for each tier, round robin by default.
+//
+// Peer list updaters supply localities by wrapping identifiers with
+// Identify.
+//
+// 	chooser := peer.Bind(
+// 		locality.New(transport, "us-east-1a",
+// 			locality.Tier("us-east-1b", "us-east-1c"),
+// 			locality.Threshold(2),
+// 		),
+// 		peer.BindPeers([]peer.Identifier{
+// 			locality.Identify(hostport.Identify("10.0.0.1:8080"), "us-east-1a"),
+// 			locality.Identify(hostport.Identify("10.0.1.1:8080"), "us-east-1b"),
+// 			locality.Identify(hostport.Identify("10.1.0.1:8080"), "us-west-2a"),
+// 		}),
+// 	)
+package locality
@@ -0,0 +1,69 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package locality
+
+import (
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/peer/peerlist/v2"
+)
+
+// Identifier is a peer identifier that carries the locality of the peer.
+type Identifier interface {
+	peer.Identifier
+
+	// Locality returns a label for where the peer runs, such as its zone,
+	// region or datacenter.
+	Locality() string
+}
+
+// Identify returns an identifier for the given peer in the given locality.
+// The weight of the peer, if any, is preserved.
+func Identify(pid peer.Identifier, locality string) Identifier {
+	return localizedIdentifier{pid: pid, locality: locality}
+}
+
+type localizedIdentifier struct {
+	pid      peer.Identifier
+	locality string
+}
+
+var _ peer.WeightedIdentifier = localizedIdentifier{}
+
+func (i localizedIdentifier) Identifier() string {
+	return i.pid.Identifier()
+}
+
+func (i localizedIdentifier) Locality() string {
+	return i.locality
+}
+
+func (i localizedIdentifier) Weight() uint32 {
+	return peerlist.Weight(i.pid)
+}
+
+// Of returns the locality carried by a peer identifier. Identifiers that do
+// not implement Identifier have no locality.
+func Of(pid peer.Identifier) string {
+	if l, ok := pid.(Identifier); ok {
+		return l.Locality()
+	}
+	return ""
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package locality
+
+import (
+	"time"
+
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/peer/peerlist/v2"
+	"go.uber.org/yarpc/peer/roundrobin"
+)
+
+type listOptions struct {
+	capacity          int
+	seed              int64
+	threshold         int
+	tiers             [][]string
+	newImplementation func() peerlist.Implementation
+}
+
+var defaultListOptions = listOptions{
+	capacity:          10,
+	threshold:         1,
+	newImplementation: roundrobin.NewImplementation,
+}
+
+// ListOption customizes the behavior of a locality-aware peer list.
+type ListOption func(*listOptions)
+
+// Capacity specifies the default capacity of the underlying
+// data structures for this list.
+//
+// Defaults to 10.
+func Capacity(capacity int) ListOption {
+	return func(options *listOptions) {
+		options.capacity = capacity
+	}
+}
+
+// Threshold specifies the number of available peers a tier needs for
+// requests to be sent to it rather than spill over to the next tier. If no
+// tier meets the threshold, requests go to the first tier with any available
+// peers.
+//
+// Defaults to 1.
+func Threshold(n int) ListOption {
+	return func(options *listOptions) {
+		if n > 0 {
+			options.threshold = n
+		}
+	}
+}
+
+// Tier adds a failover tier of the given localities, to be used after the
+// caller's own locality and any previously added tiers. Peers in localities
+// that are not part of any tier form the last tier.
+func Tier(localities ...string) ListOption {
+	return func(options *listOptions) {
+		options.tiers = append(options.tiers, localities)
+	}
+}
+
+// TierImplementation specifies how to build the peerlist.Implementation that
+// chooses among the available peers of each tier.
+//
+// Defaults to roundrobin.NewImplementation.
+func TierImplementation(newImplementation func() peerlist.Implementation) ListOption {
+	return func(options *listOptions) {
+		options.newImplementation = newImplementation
+	}
+}
+
+// New creates a new locality-aware peer list for a caller in the given
+// locality.
+func New(transport peer.Transport, locality string, opts ...ListOption) *List {
+	options := defaultListOptions
+	options.seed = time.Now().UnixNano()
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &List{
+		List: peerlist.New(
+			"locality",
+			transport,
+			newTieredList(locality, options),
+			peerlist.Capacity(options.capacity),
+			peerlist.Seed(options.seed),
+		),
+	}
+}
+
+// NewImplementation creates a locality-aware peerlist.Implementation for a
+// caller in the given locality, for composing with other peer lists built
+// on peerlist.List.
+func NewImplementation(locality string, opts ...ListOption) peerlist.Implementation {
+	options := defaultListOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return newTieredList(locality, options)
+}
+
+// List is a PeerList that prefers peers in the same locality as the caller.
+type List struct {
+	*peerlist.List
+}
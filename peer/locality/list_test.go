@@ -0,0 +1,168 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package locality
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/peer/hostport"
+	"go.uber.org/yarpc/peer/peerlist/v2"
+	"go.uber.org/yarpc/yarpctest"
+)
+
+// chooseSet returns the set of peers chosen over n choices.
+func chooseSet(t *testing.T, pl peer.Chooser, n int) map[string]struct{} {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	chosen := make(map[string]struct{})
+	for i := 0; i < n; i++ {
+		p, onFinish, err := pl.Choose(ctx, &transport.Request{})
+		require.NoError(t, err)
+		onFinish(nil)
+		chosen[p.Identifier()] = struct{}{}
+	}
+	return chosen
+}
+
+func set(peers ...string) map[string]struct{} {
+	s := make(map[string]struct{}, len(peers))
+	for _, p := range peers {
+		s[p] = struct{}{}
+	}
+	return s
+}
+
+func TestIdentify(t *testing.T) {
+	pid := Identify(hostport.IdentifyWeighted("127.0.0.1:8080", 5), "us-east-1a")
+	assert.Equal(t, "127.0.0.1:8080", pid.Identifier())
+	assert.Equal(t, "us-east-1a", Of(pid))
+	assert.Equal(t, uint32(5), peerlist.Weight(pid), "weight must be preserved")
+
+	assert.Equal(t, "", Of(hostport.Identify("127.0.0.1:8080")))
+	assert.Equal(t, uint32(1), peerlist.Weight(Identify(hostport.Identify("127.0.0.1:8080"), "us-east-1a")))
+}
+
+func TestTieredList(t *testing.T) {
+	trans := yarpctest.NewFakeTransport(yarpctest.InitialConnectionStatus(peer.Available))
+	pl := New(trans, "us-east-1a", Tier("us-east-1b"), Threshold(2))
+	require.NoError(t, pl.Update(peer.ListUpdates{
+		Additions: []peer.Identifier{
+			Identify(hostport.Identify("local-1"), "us-east-1a"),
+			Identify(hostport.Identify("local-2"), "us-east-1a"),
+			Identify(hostport.Identify("zone-1"), "us-east-1b"),
+			Identify(hostport.Identify("zone-2"), "us-east-1b"),
+			Identify(hostport.Identify("remote"), "us-west-2a"),
+			hostport.Identify("unknown"),
+		},
+	}))
+	require.NoError(t, pl.Start())
+	defer pl.Stop()
+
+	assert.Equal(t, set("local-1", "local-2"), chooseSet(t, pl, 10),
+		"peers in the local tier must be preferred")
+
+	trans.SimulateDisconnect(hostport.Identify("local-2"))
+	assert.Equal(t, set("zone-1", "zone-2"), chooseSet(t, pl, 10),
+		"requests must spill over once the local tier is below the threshold")
+
+	trans.SimulateDisconnect(hostport.Identify("zone-2"))
+	assert.Equal(t, set("remote", "unknown"), chooseSet(t, pl, 10),
+		"requests must spill over to peers in other localities last")
+
+	trans.SimulateDisconnect(hostport.Identify("unknown"))
+	assert.Equal(t, set("local-1"), chooseSet(t, pl, 10),
+		"the closest available peers must be used if no tier meets the threshold")
+
+	trans.SimulateConnect(hostport.Identify("local-2"))
+	assert.Equal(t, set("local-1", "local-2"), chooseSet(t, pl, 10),
+		"requests must return to the local tier once it recovers")
+}
+
+func TestTierImplementation(t *testing.T) {
+	var impls []*countingImplementation
+	impl := NewImplementation("us-east-1a", TierImplementation(func() peerlist.Implementation {
+		c := &countingImplementation{}
+		impls = append(impls, c)
+		return c
+	}))
+	require.Len(t, impls, 2, "expected a local tier and a tier for all other localities")
+
+	trans := yarpctest.NewFakeTransport(yarpctest.InitialConnectionStatus(peer.Available))
+	pl := peerlist.New("test", trans, impl)
+	require.NoError(t, pl.Update(peer.ListUpdates{
+		Additions: []peer.Identifier{
+			Identify(hostport.Identify("local"), "us-east-1a"),
+			Identify(hostport.Identify("remote"), "us-west-2a"),
+		},
+	}))
+	require.NoError(t, pl.Start())
+	assert.Equal(t, []string{"local"}, impls[0].added)
+	assert.Equal(t, []string{"remote"}, impls[1].added)
+	assert.True(t, impls[0].started && impls[1].started)
+
+	require.NoError(t, pl.Stop())
+	assert.False(t, impls[0].started || impls[1].started)
+}
+
+// countingImplementation records the peers added to it.
+type countingImplementation struct {
+	peerlist.Implementation
+
+	added   []string
+	peers   []peer.StatusPeer
+	started bool
+}
+
+func (c *countingImplementation) Add(p peer.StatusPeer, pid peer.Identifier) peer.Subscriber {
+	c.added = append(c.added, pid.Identifier())
+	c.peers = append(c.peers, p)
+	return nil
+}
+
+func (c *countingImplementation) Remove(peer.StatusPeer, peer.Identifier, peer.Subscriber) {}
+
+func (c *countingImplementation) Choose(context.Context, *transport.Request) peer.StatusPeer {
+	if len(c.peers) == 0 {
+		return nil
+	}
+	return c.peers[0]
+}
+
+func (c *countingImplementation) Start() error {
+	c.started = true
+	return nil
+}
+
+func (c *countingImplementation) Stop() error {
+	c.started = false
+	return nil
+}
+
+func (c *countingImplementation) IsRunning() bool {
+	return c.started
+}
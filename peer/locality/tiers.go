@@ -0,0 +1,147 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package locality
+
+import (
+	"context"
+
+	"go.uber.org/multierr"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/peer/peerlist/v2"
+)
+
+type tier struct {
+	impl      peerlist.Implementation
+	available int
+}
+
+type subscriber struct {
+	tier *tier
+	sub  peer.Subscriber
+}
+
+func (s *subscriber) NotifyStatusChanged(pid peer.Identifier) {
+	if s.sub != nil {
+		s.sub.NotifyStatusChanged(pid)
+	}
+}
+
+// tieredList sorts available peers into tiers by locality and chooses from
+// the first tier that has enough available peers. Add and Remove run under
+// the write lock of the peer list and Choose under its read lock, so Choose
+// must only read the tier counts.
+type tieredList struct {
+	threshold  int
+	tiers      []*tier
+	localities map[string]*tier
+}
+
+func newTieredList(local string, options listOptions) *tieredList {
+	l := &tieredList{
+		threshold:  options.threshold,
+		localities: make(map[string]*tier),
+	}
+	groups := append([][]string{{local}}, options.tiers...)
+	for _, localities := range groups {
+		t := &tier{impl: options.newImplementation()}
+		l.tiers = append(l.tiers, t)
+		for _, locality := range localities {
+			if _, ok := l.localities[locality]; !ok {
+				l.localities[locality] = t
+			}
+		}
+	}
+	// The last tier holds peers in all other localities.
+	l.tiers = append(l.tiers, &tier{impl: options.newImplementation()})
+	return l
+}
+
+var _ peerlist.Implementation = (*tieredList)(nil)
+
+func (l *tieredList) tierOf(pid peer.Identifier) *tier {
+	if locality := Of(pid); locality != "" {
+		if t, ok := l.localities[locality]; ok {
+			return t
+		}
+	}
+	return l.tiers[len(l.tiers)-1]
+}
+
+func (l *tieredList) Add(p peer.StatusPeer, pid peer.Identifier) peer.Subscriber {
+	t := l.tierOf(pid)
+	t.available++
+	return &subscriber{tier: t, sub: t.impl.Add(p, pid)}
+}
+
+func (l *tieredList) Remove(p peer.StatusPeer, pid peer.Identifier, ps peer.Subscriber) {
+	sub, ok := ps.(*subscriber)
+	if !ok {
+		return
+	}
+	sub.tier.available--
+	sub.tier.impl.Remove(p, pid, sub.sub)
+}
+
+func (l *tieredList) Choose(ctx context.Context, req *transport.Request) peer.StatusPeer {
+	for _, t := range l.tiers {
+		if t.available >= l.threshold {
+			if p := t.impl.Choose(ctx, req); p != nil {
+				return p
+			}
+		}
+	}
+	// No tier has enough available peers, so make do with what there is,
+	// still preferring the closest peers.
+	for _, t := range l.tiers {
+		if t.available > 0 {
+			if p := t.impl.Choose(ctx, req); p != nil {
+				return p
+			}
+		}
+	}
+	return nil
+}
+
+func (l *tieredList) Start() error {
+	var err error
+	for _, t := range l.tiers {
+		err = multierr.Append(err, t.impl.Start())
+	}
+	return err
+}
+
+func (l *tieredList) Stop() error {
+	var err error
+	for _, t := range l.tiers {
+		err = multierr.Append(err, t.impl.Stop())
+	}
+	return err
+}
+
+func (l *tieredList) IsRunning() bool {
+	for _, t := range l.tiers {
+		if !t.impl.IsRunning() {
+			return false
+		}
+	}
+	return true
+}
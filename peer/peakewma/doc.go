@@ -0,0 +1,41 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package peakewma provides a peer list that sends each request to the peer
+// with the lowest expected latency, in the style of Finagle's peak EWMA load
+// balancer.
+//
+// The list tracks an exponentially weighted moving average of the latency of
+// the requests sent to each peer. A request slower than the average replaces
+// it outright, so the list reacts to a peer slowing down at once, while the
+// average decays back toward faster latencies over the decay time. The cost
+// of a peer is its average latency multiplied by its pending requests plus
+// one, which, unlike the fewest-pending-requests list, tells apart fast and
+// slow peers with the same number of pending requests.
+//
+// Each request compares two peers picked at random and goes to the cheaper
+// one. Peers that have not served a request yet cost nothing, so new peers
+// are tried promptly.
+//
+// 	chooser := peer.Bind(
+// 		peakewma.New(transport, peakewma.DecayTime(10*time.Second)),
+// 		peer.BindPeers(peers),
+// 	)
+package peakewma
@@ -0,0 +1,194 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package peakewma
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/clock"
+	"go.uber.org/yarpc/peer/peerlist/v2"
+)
+
+// _penalty is the cost of a peer that has pending requests but has not yet
+// completed any, so that it is only chosen over peers that are slower still.
+const _penalty = float64(math.MaxInt64 >> 16)
+
+// peakEWMA is a peer list implementation that chooses the cheaper of two
+// random peers, where the cost of a peer is the moving average of its
+// latency multiplied by its pending requests plus one.
+type peakEWMA struct {
+	decayTime time.Duration
+	clock     clock.Clock
+
+	mu    sync.Mutex
+	peers []*peerCost
+	byID  map[string]*peerCost
+	rand  *rand.Rand
+}
+
+var _ peerlist.Implementation = (*peakEWMA)(nil)
+
+func newPeakEWMA(options listOptions) *peakEWMA {
+	return &peakEWMA{
+		decayTime: options.decayTime,
+		clock:     options.clock,
+		byID:      make(map[string]*peerCost),
+		rand:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (pe *peakEWMA) Add(p peer.StatusPeer, _ peer.Identifier) peer.Subscriber {
+	pc := &peerCost{peer: p, stamp: pe.clock.Now()}
+
+	pe.mu.Lock()
+	pc.index = len(pe.peers)
+	pe.peers = append(pe.peers, pc)
+	pe.byID[p.Identifier()] = pc
+	pe.mu.Unlock()
+	return pc
+}
+
+func (pe *peakEWMA) Remove(p peer.StatusPeer, _ peer.Identifier, sub peer.Subscriber) {
+	pc, ok := sub.(*peerCost)
+	if !ok {
+		return
+	}
+
+	pe.mu.Lock()
+	defer pe.mu.Unlock()
+
+	last := len(pe.peers) - 1
+	pe.peers[pc.index] = pe.peers[last]
+	pe.peers[pc.index].index = pc.index
+	pe.peers[last] = nil
+	pe.peers = pe.peers[:last]
+	if pe.byID[p.Identifier()] == pc {
+		delete(pe.byID, p.Identifier())
+	}
+}
+
+func (pe *peakEWMA) Choose(_ context.Context, _ *transport.Request) peer.StatusPeer {
+	pe.mu.Lock()
+	defer pe.mu.Unlock()
+
+	switch len(pe.peers) {
+	case 0:
+		return nil
+	case 1:
+		return pe.peers[0].peer
+	}
+
+	// Comparing two random peers rather than taking the cheapest of all
+	// keeps peers whose costs are out of date from getting every request.
+	i := pe.rand.Intn(len(pe.peers))
+	j := pe.rand.Intn(len(pe.peers) - 1)
+	if j >= i {
+		j++
+	}
+
+	now := pe.clock.Now()
+	a, b := pe.peers[i], pe.peers[j]
+	if b.score(now, pe.decayTime) < a.score(now, pe.decayTime) {
+		return b.peer
+	}
+	return a.peer
+}
+
+// observe wraps the onFinish callback of a chosen peer to fold the latency
+// of the request into the cost of the peer.
+func (pe *peakEWMA) observe(id string, onFinish func(error)) func(error) {
+	start := pe.clock.Now()
+	return func(err error) {
+		onFinish(err)
+		now := pe.clock.Now()
+
+		pe.mu.Lock()
+		defer pe.mu.Unlock()
+		if pc, ok := pe.byID[id]; ok {
+			pc.observe(now, float64(now.Sub(start)), pe.decayTime)
+		}
+	}
+}
+
+func (pe *peakEWMA) Start() error {
+	return nil
+}
+
+func (pe *peakEWMA) Stop() error {
+	return nil
+}
+
+func (pe *peakEWMA) IsRunning() bool {
+	return true
+}
+
+// peerCost is a book-keeping object for each retained peer. Its mutable
+// fields are guarded by the lock of the peakEWMA.
+type peerCost struct {
+	peer  peer.StatusPeer
+	index int
+
+	// cost is the moving average of the latency of the peer, in
+	// nanoseconds, as of stamp.
+	cost  float64
+	stamp time.Time
+}
+
+// NotifyStatusChanged is a no-op: pending requests are read from the peer
+// whenever it is scored.
+func (pc *peerCost) NotifyStatusChanged(peer.Identifier) {}
+
+// observe folds a latency into the moving average. A latency above the
+// average replaces it, while lower latencies pull it down with a weight that
+// grows with the time since the last observation.
+func (pc *peerCost) observe(now time.Time, latency float64, decayTime time.Duration) {
+	elapsed := now.Sub(pc.stamp)
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	pc.stamp = now
+
+	if latency > pc.cost {
+		pc.cost = latency
+		return
+	}
+	w := math.Exp(-float64(elapsed) / float64(decayTime))
+	pc.cost = pc.cost*w + latency*(1-w)
+}
+
+// score returns the expected cost of sending a request to the peer now.
+func (pc *peerCost) score(now time.Time, decayTime time.Duration) float64 {
+	// Decay the average toward zero for the time since the last request so
+	// that peers that were slow get tried again.
+	pc.observe(now, 0, decayTime)
+
+	pending := pc.peer.Status().PendingRequestCount
+	if pc.cost == 0 && pending > 0 {
+		return _penalty + float64(pending)
+	}
+	return pc.cost * float64(pending+1)
+}
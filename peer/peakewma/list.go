@@ -0,0 +1,121 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package peakewma
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/clock"
+	"go.uber.org/yarpc/peer/peerlist/v2"
+)
+
+type listOptions struct {
+	capacity  int
+	decayTime time.Duration
+	clock     clock.Clock
+}
+
+var defaultListOptions = listOptions{
+	capacity:  10,
+	decayTime: 10 * time.Second,
+}
+
+// ListOption customizes the behavior of a peak EWMA peer list.
+type ListOption interface {
+	apply(*listOptions)
+}
+
+type listOptionFunc func(*listOptions)
+
+func (f listOptionFunc) apply(options *listOptions) { f(options) }
+
+// Capacity specifies the default capacity of the underlying
+// data structures for this list.
+//
+// Defaults to 10.
+func Capacity(capacity int) ListOption {
+	return listOptionFunc(func(options *listOptions) {
+		options.capacity = capacity
+	})
+}
+
+// DecayTime specifies how quickly the latency average of a peer forgets past
+// requests. After a slow request, the average takes about this long to fall
+// most of the way back to the latency of faster requests. Shorter decay
+// times react faster to peers recovering, longer decay times are steadier.
+//
+// Defaults to 10 seconds.
+func DecayTime(d time.Duration) ListOption {
+	return listOptionFunc(func(options *listOptions) {
+		if d > 0 {
+			options.decayTime = d
+		}
+	})
+}
+
+// withClock overrides the clock used to measure latencies. This is used for
+// testing.
+func withClock(c clock.Clock) ListOption {
+	return listOptionFunc(func(options *listOptions) {
+		options.clock = c
+	})
+}
+
+// New creates a new peak EWMA peer list.
+func New(transport peer.Transport, opts ...ListOption) *List {
+	options := defaultListOptions
+	options.clock = clock.NewReal()
+	for _, opt := range opts {
+		opt.apply(&options)
+	}
+
+	costs := newPeakEWMA(options)
+	return &List{
+		List: peerlist.New(
+			"peak-ewma",
+			transport,
+			costs,
+			peerlist.Capacity(options.capacity),
+		),
+		costs: costs,
+	}
+}
+
+// List is a PeerList that sends requests to the peer with the lowest
+// expected latency, accounting for its pending requests.
+type List struct {
+	*peerlist.List
+
+	costs *peakEWMA
+}
+
+// Choose selects the cheapest of two random available peers and measures
+// the latency of the request to update the cost of that peer.
+func (l *List) Choose(ctx context.Context, req *transport.Request) (peer.Peer, func(error), error) {
+	p, onFinish, err := l.List.Choose(ctx, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	return p, l.costs.observe(p.Identifier(), onFinish), nil
+}
@@ -0,0 +1,145 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package peakewma
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/clock"
+	"go.uber.org/yarpc/peer/hostport"
+	"go.uber.org/yarpc/yarpctest"
+)
+
+func newList(t *testing.T, peers []string, opts ...ListOption) (*List, *clock.FakeClock) {
+	clk := clock.NewFake()
+	trans := yarpctest.NewFakeTransport(yarpctest.InitialConnectionStatus(peer.Available))
+	pl := New(trans, append([]ListOption{withClock(clk)}, opts...)...)
+
+	var ids []peer.Identifier
+	for _, p := range peers {
+		ids = append(ids, hostport.Identify(p))
+	}
+	require.NoError(t, pl.Update(peer.ListUpdates{Additions: ids}))
+	require.NoError(t, pl.Start())
+	return pl, clk
+}
+
+func choose(t *testing.T, pl *List) (string, func(error)) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	p, onFinish, err := pl.Choose(ctx, &transport.Request{})
+	require.NoError(t, err)
+	return p.Identifier(), onFinish
+}
+
+// send makes n sequential requests, each taking the latency of its peer.
+func send(t *testing.T, pl *List, clk *clock.FakeClock, n int, latencies map[string]time.Duration) map[string]int {
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		id, onFinish := choose(t, pl)
+		clk.Add(latencies[id])
+		onFinish(nil)
+		counts[id]++
+	}
+	return counts
+}
+
+func TestPreferFastPeer(t *testing.T) {
+	pl, clk := newList(t, []string{"fast", "slow"})
+	defer pl.Stop()
+
+	latencies := map[string]time.Duration{
+		"fast": time.Millisecond,
+		"slow": 100 * time.Millisecond,
+	}
+	send(t, pl, clk, 10, latencies)
+
+	counts := send(t, pl, clk, 100, latencies)
+	assert.True(t, counts["fast"] > 90, "fast peer must get most requests, got %v", counts)
+}
+
+func TestPendingRequests(t *testing.T) {
+	pl, clk := newList(t, []string{"a", "b"})
+	defer pl.Stop()
+
+	latencies := map[string]time.Duration{
+		"a": time.Millisecond,
+		"b": 2500 * time.Microsecond,
+	}
+	send(t, pl, clk, 10, latencies)
+
+	// Requests pile up on the faster peer until its cost exceeds the cost of
+	// the slower one.
+	var pending []func(error)
+	counts := make(map[string]int)
+	for i := 0; i < 4; i++ {
+		id, onFinish := choose(t, pl)
+		pending = append(pending, onFinish)
+		counts[id]++
+	}
+	assert.Equal(t, map[string]int{"a": 3, "b": 1}, counts)
+	for _, onFinish := range pending {
+		onFinish(nil)
+	}
+}
+
+func TestUnobservedPeerPenalty(t *testing.T) {
+	pl, clk := newList(t, []string{"a"})
+	defer pl.Stop()
+
+	send(t, pl, clk, 1, map[string]time.Duration{"a": time.Second})
+	require.NoError(t, pl.Update(peer.ListUpdates{Additions: []peer.Identifier{hostport.Identify("new")}}))
+
+	// A new peer is tried first, but not flooded before it answers.
+	first, finishFirst := choose(t, pl)
+	second, finishSecond := choose(t, pl)
+	assert.Equal(t, "new", first)
+	assert.Equal(t, "a", second)
+	finishFirst(nil)
+	finishSecond(nil)
+}
+
+func TestPeerCost(t *testing.T) {
+	decay := 10 * time.Second
+	start := time.Now()
+	pc := &peerCost{stamp: start}
+
+	pc.observe(start, float64(time.Second), decay)
+	assert.Equal(t, float64(time.Second), pc.cost, "slower latencies must replace the average")
+
+	pc.observe(start.Add(decay), float64(time.Millisecond), decay)
+	assert.InDelta(t, 0.368*float64(time.Second), pc.cost, float64(10*time.Millisecond),
+		"faster latencies must be averaged in")
+
+	pc.observe(start.Add(decay), float64(100*time.Millisecond), decay)
+	assert.InDelta(t, 0.368*float64(time.Second), pc.cost, float64(10*time.Millisecond),
+		"latencies at the same instant must not move the average")
+
+	pc.observe(start.Add(2*time.Hour), 0, decay)
+	assert.InDelta(t, 0, pc.cost, 1, "the average must decay over time")
+}
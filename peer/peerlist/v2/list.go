@@ -164,7 +164,7 @@ type List struct {
 // it returns a multi-error result of every failure that happened without
 // circuit breaking due to failures.
 func (pl *List) Update(updates peer.ListUpdates) error {
-	if len(updates.Additions) == 0 && len(updates.Removals) == 0 {
+	if len(updates.Additions) == 0 && len(updates.Removals) == 0 && len(updates.Reweights) == 0 {
 		return nil
 	}
 
@@ -196,6 +196,10 @@ func (pl *List) updateInitialized(updates peer.ListUpdates) error {
 	for _, pid := range add {
 		errs = multierr.Append(errs, pl.addPeerIdentifier(pid))
 	}
+
+	for _, pid := range updates.Reweights {
+		errs = multierr.Append(errs, pl.reweightPeerIdentifier(pid))
+	}
 	return errs
 }
 
@@ -216,6 +220,13 @@ func (pl *List) updateUninitialized(updates peer.ListUpdates) error {
 	for _, pid := range updates.Additions {
 		pl.uninitializedPeers[pid.Identifier()] = pid
 	}
+	for _, pid := range updates.Reweights {
+		if _, ok := pl.uninitializedPeers[pid.Identifier()]; ok {
+			pl.uninitializedPeers[pid.Identifier()] = pid
+		} else {
+			errs = multierr.Append(errs, peer.ErrPeerReweightNotInList(pid.Identifier()))
+		}
+	}
 
 	return errs
 }
@@ -363,6 +374,27 @@ func (pl *List) releaseAll(errs error, peers []*peerThunk) error {
 	return errs
 }
 
+// reweightPeerIdentifier replaces the identifier of a peer in the list,
+// carrying its new weight. Available peers are removed from and added back to
+// the implementation so that it observes the new weight.
+// Must be run in a mutex.Lock()
+func (pl *List) reweightPeerIdentifier(pid peer.Identifier) error {
+	t := pl.getThunk(pid)
+	if t == nil {
+		return peer.ErrPeerReweightNotInList(pid.Identifier())
+	}
+
+	if pl.availablePeers[t.Identifier()] == nil {
+		t.id = pid
+		return nil
+	}
+
+	pl.availableChooser.Remove(t, t.id, t.Subscriber())
+	t.id = pid
+	t.SetSubscriber(pl.availableChooser.Add(t, t.id))
+	return nil
+}
+
 // removePeerIdentifier will go remove references to the peer identifier and release
 // it from the transport
 // Must be run in a mutex.Lock()
@@ -222,3 +222,60 @@ func TestPeerList(t *testing.T) {
 		},
 	}))
 }
+
+// weightList records the weights of the peers added to it.
+type weightList struct {
+	mraList
+
+	weights map[string]uint32
+}
+
+func (l *weightList) Add(p peer.StatusPeer, pid peer.Identifier) peer.Subscriber {
+	l.weights[pid.Identifier()] = Weight(pid)
+	return l.mraList.Add(p, pid)
+}
+
+func (l *weightList) Remove(p peer.StatusPeer, pid peer.Identifier, ps peer.Subscriber) {
+	delete(l.weights, pid.Identifier())
+	l.mraList.Remove(p, pid, ps)
+}
+
+func TestPeerListReweight(t *testing.T) {
+	fake := yarpctest.NewFakeTransport(yarpctest.InitialConnectionStatus(peer.Available))
+	impl := &weightList{weights: make(map[string]uint32)}
+	list := New("weights", fake, impl, NoShuffle())
+
+	// Reweights before start apply to uninitialized peers.
+	require.NoError(t, list.Update(peer.ListUpdates{
+		Additions: []peer.Identifier{id1, hostport.IdentifyWeighted(string(id2), 2)},
+	}))
+	require.NoError(t, list.Update(peer.ListUpdates{
+		Reweights: []peer.Identifier{hostport.IdentifyWeighted(string(id1), 5)},
+	}))
+	assert.Equal(t,
+		peer.ErrPeerReweightNotInList(id3),
+		list.Update(peer.ListUpdates{Reweights: []peer.Identifier{id3}}))
+
+	require.NoError(t, list.Start())
+	assert.Equal(t, map[string]uint32{string(id1): 5, string(id2): 2}, impl.weights)
+
+	require.NoError(t, list.Update(peer.ListUpdates{
+		Reweights: []peer.Identifier{hostport.IdentifyWeighted(string(id2), 7)},
+	}))
+	assert.Equal(t, map[string]uint32{string(id1): 5, string(id2): 7}, impl.weights)
+	assert.Equal(t,
+		peer.ErrPeerReweightNotInList(id3),
+		list.Update(peer.ListUpdates{Reweights: []peer.Identifier{id3}}))
+
+	// Stopped lists keep the latest weights.
+	require.NoError(t, list.Stop())
+	assert.Empty(t, impl.weights)
+	assert.Equal(t, uint32(5), Weight(list.uninitializedPeers[string(id1)]))
+	assert.Equal(t, uint32(7), Weight(list.uninitializedPeers[string(id2)]))
+}
+
+func TestWeight(t *testing.T) {
+	assert.Equal(t, uint32(1), Weight(id1))
+	assert.Equal(t, uint32(0), Weight(hostport.IdentifyWeighted(string(id1), 0)))
+	assert.Equal(t, uint32(3), Weight(hostport.IdentifyWeighted(string(id1), 3)))
+}
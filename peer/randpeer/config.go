@@ -51,3 +51,32 @@ func Spec() yarpcconfig.PeerListSpec {
 		},
 	}
 }
+
+// WeightedSpec returns a configuration specification for the weighted random
+// peer list implementation, which chooses random peers in proportion to
+// their weights.
+//
+//  cfg := yarpcconfig.New()
+//  cfg.MustRegisterPeerList(randpeer.WeightedSpec())
+//
+// Peer weights come from the peer list updater; for example, entries in a
+// peers file may have weights:
+//
+//  outbounds:
+//    otherservice:
+//      unary:
+//        http:
+//          url: https://host:port/rpc
+//          weighted-random:
+//            file:
+//              path: /etc/otherservice/peers.yaml
+//
+// Peers without weights have a weight of 1.
+func WeightedSpec() yarpcconfig.PeerListSpec {
+	return yarpcconfig.PeerListSpec{
+		Name: "weighted-random",
+		BuildPeerList: func(c struct{}, t peer.Transport, k *yarpcconfig.Kit) (peer.ChooserList, error) {
+			return NewWeighted(t), nil
+		},
+	}
+}
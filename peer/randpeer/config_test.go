@@ -53,3 +53,25 @@ func TestConfig(t *testing.T) {
 	require.NotNil(t, config.Outbounds["their-service"])
 	require.NotNil(t, config.Outbounds["their-service"].Unary)
 }
+
+func TestWeightedConfig(t *testing.T) {
+	cfg := yarpcconfig.New()
+	cfg.RegisterPeerList(WeightedSpec())
+	cfg.RegisterTransport(yarpctest.FakeTransportSpec())
+	config, err := cfg.LoadConfig("our-service", attrs{
+		"outbounds": attrs{
+			"their-service": attrs{
+				"fake-transport": attrs{
+					"weighted-random": attrs{
+						"peers": []string{
+							"1.1.1.1:1111",
+							"2.2.2.2:2222",
+						},
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, config.Outbounds["their-service"].Unary)
+}
@@ -0,0 +1,156 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package randpeer
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	peerlist "go.uber.org/yarpc/peer/peerlist/v2"
+)
+
+// NewWeighted creates a new weighted random peer list.
+//
+// Peers are chosen at random in proportion to their weights, as carried by
+// peer.WeightedIdentifier. Peers with a weight of zero are only chosen if
+// every available peer has a weight of zero.
+func NewWeighted(transport peer.Transport, opts ...ListOption) *List {
+	options := defaultListOptions
+	for _, opt := range opts {
+		opt.apply(&options)
+	}
+
+	if options.source == nil {
+		options.source = rand.NewSource(time.Now().UnixNano())
+	}
+
+	plOpts := []peerlist.ListOption{
+		peerlist.Capacity(options.capacity),
+		peerlist.NoShuffle(),
+	}
+
+	return &List{
+		List: peerlist.New(
+			"weighted-random",
+			transport,
+			newWeightedRandomList(options.capacity, options.source),
+			plOpts...,
+		),
+	}
+}
+
+type weightedSubscriber struct {
+	index  int
+	peer   peer.StatusPeer
+	weight int64
+}
+
+func (*weightedSubscriber) NotifyStatusChanged(peer.Identifier) {}
+
+type weightedRandomList struct {
+	// Choose runs under a read lock of the peer list, so it needs its own
+	// lock to use the random source.
+	lock        sync.Mutex
+	subscribers []*weightedSubscriber
+	total       int64
+	random      *rand.Rand
+}
+
+func newWeightedRandomList(cap int, source rand.Source) *weightedRandomList {
+	return &weightedRandomList{
+		subscribers: make([]*weightedSubscriber, 0, cap),
+		random:      rand.New(source),
+	}
+}
+
+var _ peerlist.Implementation = (*weightedRandomList)(nil)
+
+func (r *weightedRandomList) Add(p peer.StatusPeer, pid peer.Identifier) peer.Subscriber {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	sub := &weightedSubscriber{
+		index:  len(r.subscribers),
+		peer:   p,
+		weight: int64(peerlist.Weight(pid)),
+	}
+	r.subscribers = append(r.subscribers, sub)
+	r.total += sub.weight
+	return sub
+}
+
+func (r *weightedRandomList) Remove(_ peer.StatusPeer, _ peer.Identifier, ps peer.Subscriber) {
+	sub, ok := ps.(*weightedSubscriber)
+	if !ok {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	last := len(r.subscribers) - 1
+	if sub.index > last || r.subscribers[sub.index] != sub {
+		return
+	}
+	r.subscribers[sub.index] = r.subscribers[last]
+	r.subscribers[sub.index].index = sub.index
+	r.subscribers[last] = nil
+	r.subscribers = r.subscribers[:last]
+	r.total -= sub.weight
+}
+
+func (r *weightedRandomList) Choose(_ context.Context, _ *transport.Request) peer.StatusPeer {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if len(r.subscribers) == 0 {
+		return nil
+	}
+	if r.total == 0 {
+		return r.subscribers[r.random.Intn(len(r.subscribers))].peer
+	}
+
+	n := r.random.Int63n(r.total)
+	for _, sub := range r.subscribers {
+		if n < sub.weight {
+			return sub.peer
+		}
+		n -= sub.weight
+	}
+	// Not reachable: the weights add up to the total.
+	return r.subscribers[len(r.subscribers)-1].peer
+}
+
+func (r *weightedRandomList) Start() error {
+	return nil
+}
+
+func (r *weightedRandomList) Stop() error {
+	return nil
+}
+
+func (r *weightedRandomList) IsRunning() bool {
+	return true
+}
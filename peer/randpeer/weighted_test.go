@@ -0,0 +1,82 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package randpeer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/peer/hostport"
+	"go.uber.org/yarpc/yarpctest"
+)
+
+func countChoices(t *testing.T, pl peer.Chooser, n int) map[string]int {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		p, onFinish, err := pl.Choose(ctx, &transport.Request{})
+		require.NoError(t, err)
+		onFinish(nil)
+		counts[p.Identifier()]++
+	}
+	return counts
+}
+
+func TestWeightedRandom(t *testing.T) {
+	trans := yarpctest.NewFakeTransport(yarpctest.InitialConnectionStatus(peer.Available))
+	pl := NewWeighted(trans, Seed(0))
+	require.NoError(t, pl.Update(peer.ListUpdates{
+		Additions: []peer.Identifier{
+			hostport.IdentifyWeighted("canary", 1),
+			hostport.IdentifyWeighted("big", 6),
+			hostport.Identify("small"),
+		},
+		Reweights: []peer.Identifier{
+			hostport.IdentifyWeighted("small", 3),
+		},
+	}))
+	require.NoError(t, pl.Start())
+	defer pl.Stop()
+
+	counts := countChoices(t, pl, 10000)
+	assert.InDelta(t, 1000, counts["canary"], 200)
+	assert.InDelta(t, 6000, counts["big"], 200)
+	assert.InDelta(t, 3000, counts["small"], 200)
+
+	require.NoError(t, pl.Update(peer.ListUpdates{
+		Reweights: []peer.Identifier{hostport.IdentifyWeighted("big", 0)},
+	}))
+	counts = countChoices(t, pl, 1000)
+	assert.Zero(t, counts["big"], "peers with no weight must be drained")
+
+	require.NoError(t, pl.Update(peer.ListUpdates{
+		Removals: []peer.Identifier{hostport.Identify("canary"), hostport.Identify("small")},
+	}))
+	assert.Equal(t, map[string]int{"big": 10}, countChoices(t, pl, 10),
+		"peers with no weight must be chosen if no other peers are available")
+}
@@ -68,3 +68,41 @@ func Spec() yarpcconfig.PeerListSpec {
 		},
 	}
 }
+
+// WeightedSpec returns a configuration specification for the weighted
+// round-robin peer list implementation, which chooses peers in proportion to
+// their weights.
+//
+//  cfg := yarpcconfig.New()
+//  cfg.MustRegisterPeerList(roundrobin.WeightedSpec())
+//
+// Peer weights come from the peer list updater; for example, entries in a
+// peers file may have weights:
+//
+//  outbounds:
+//    otherservice:
+//      unary:
+//        http:
+//          url: https://host:port/rpc
+//          weighted-round-robin:
+//            file:
+//              path: /etc/otherservice/peers.yaml
+//
+// Peers without weights have a weight of 1.
+func WeightedSpec() yarpcconfig.PeerListSpec {
+	return yarpcconfig.PeerListSpec{
+		Name: "weighted-round-robin",
+		BuildPeerList: func(cfg Configuration, t peer.Transport, k *yarpcconfig.Kit) (peer.ChooserList, error) {
+			if cfg.Capacity == nil {
+				return NewWeighted(t), nil
+			}
+
+			if *cfg.Capacity <= 0 {
+				return nil, yarpcerrors.InvalidArgumentErrorf(
+					"Capacity must be greater than 0. Got: %d.", *cfg.Capacity)
+			}
+
+			return NewWeighted(t, Capacity(*cfg.Capacity)), nil
+		},
+	}
+}
@@ -62,19 +62,20 @@ func TestPendingHeapConfig(t *testing.T) {
 		},
 	}
 
-	s := Spec()
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			build := s.BuildPeerList.(func(Configuration, peer.Transport, *yarpcconfig.Kit) (peer.ChooserList, error))
-			pl, err := build(tt.cfg, yarpctest.NewFakeTransport(), nil)
+	for _, s := range []yarpcconfig.PeerListSpec{Spec(), WeightedSpec()} {
+		for _, tt := range tests {
+			t.Run(s.Name+"/"+tt.name, func(t *testing.T) {
+				build := s.BuildPeerList.(func(Configuration, peer.Transport, *yarpcconfig.Kit) (peer.ChooserList, error))
+				pl, err := build(tt.cfg, yarpctest.NewFakeTransport(), nil)
 
-			if tt.wantErr {
-				require.Error(t, err, "must not construct a peer list")
+				if tt.wantErr {
+					require.Error(t, err, "must not construct a peer list")
 
-			} else {
-				require.NoError(t, err)
-				pl.Update(peer.ListUpdates{Additions: []peer.Identifier{hostport.PeerIdentifier("foo-host:port")}})
-			}
-		})
+				} else {
+					require.NoError(t, err)
+					pl.Update(peer.ListUpdates{Additions: []peer.Identifier{hostport.PeerIdentifier("foo-host:port")}})
+				}
+			})
+		}
 	}
 }
@@ -77,6 +77,12 @@ func New(transport peer.Transport, opts ...ListOption) *List {
 	}
 }
 
+// NewImplementation creates a new round robin peerlist.Implementation, for
+// peer lists that choose peers round robin among a subset of their peers.
+func NewImplementation() peerlist.Implementation {
+	return newPeerRing()
+}
+
 // List is a PeerList which rotates which peers are to be selected in a circle
 type List struct {
 	*peerlist.List
@@ -0,0 +1,163 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package roundrobin
+
+import (
+	"context"
+	"sync"
+
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/peer/peerlist/v2"
+)
+
+// NewWeighted creates a new weighted round robin peer list.
+//
+// Peers are chosen in proportion to their weights, as carried by
+// peer.WeightedIdentifier, and choices of the same peer are spread out
+// evenly over each round. Peers with a weight of zero are only chosen if
+// every available peer has a weight of zero.
+func NewWeighted(transport peer.Transport, opts ...ListOption) *List {
+	cfg := defaultListConfig
+	for _, o := range opts {
+		o(&cfg)
+	}
+
+	plOpts := []peerlist.ListOption{
+		peerlist.Capacity(cfg.capacity),
+		peerlist.Seed(cfg.seed),
+	}
+	if !cfg.shuffle {
+		plOpts = append(plOpts, peerlist.NoShuffle())
+	}
+
+	return &List{
+		List: peerlist.New(
+			"weighted-roundrobin",
+			transport,
+			newWeightedRing(cfg.capacity),
+			plOpts...,
+		),
+	}
+}
+
+type weightedSubscriber struct {
+	index   int
+	peer    peer.StatusPeer
+	weight  int64
+	current int64
+}
+
+func (*weightedSubscriber) NotifyStatusChanged(peer.Identifier) {}
+
+// weightedRing implements smooth weighted round robin: on each choice every
+// peer's current weight grows by its weight, and the peer with the highest
+// current weight is chosen and set back by the total weight.
+type weightedRing struct {
+	// Choose runs under a read lock of the peer list, so it needs its own
+	// lock to update current weights.
+	lock        sync.Mutex
+	subscribers []*weightedSubscriber
+	next        int // for rotating through peers that all have no weight
+}
+
+func newWeightedRing(capacity int) *weightedRing {
+	return &weightedRing{
+		subscribers: make([]*weightedSubscriber, 0, capacity),
+	}
+}
+
+var _ peerlist.Implementation = (*weightedRing)(nil)
+
+func (r *weightedRing) Add(p peer.StatusPeer, pid peer.Identifier) peer.Subscriber {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	sub := &weightedSubscriber{
+		index:  len(r.subscribers),
+		peer:   p,
+		weight: int64(peerlist.Weight(pid)),
+	}
+	r.subscribers = append(r.subscribers, sub)
+	return sub
+}
+
+func (r *weightedRing) Remove(_ peer.StatusPeer, _ peer.Identifier, ps peer.Subscriber) {
+	sub, ok := ps.(*weightedSubscriber)
+	if !ok {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	last := len(r.subscribers) - 1
+	if sub.index > last || r.subscribers[sub.index] != sub {
+		return
+	}
+	r.subscribers[sub.index] = r.subscribers[last]
+	r.subscribers[sub.index].index = sub.index
+	r.subscribers[last] = nil
+	r.subscribers = r.subscribers[:last]
+}
+
+func (r *weightedRing) Choose(_ context.Context, _ *transport.Request) peer.StatusPeer {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if len(r.subscribers) == 0 {
+		return nil
+	}
+
+	var (
+		total int64
+		best  *weightedSubscriber
+	)
+	for _, sub := range r.subscribers {
+		if sub.weight == 0 {
+			continue
+		}
+		sub.current += sub.weight
+		total += sub.weight
+		if best == nil || sub.current > best.current {
+			best = sub
+		}
+	}
+
+	if best == nil {
+		r.next = (r.next + 1) % len(r.subscribers)
+		return r.subscribers[r.next].peer
+	}
+	best.current -= total
+	return best.peer
+}
+
+func (r *weightedRing) Start() error {
+	return nil
+}
+
+func (r *weightedRing) Stop() error {
+	return nil
+}
+
+func (r *weightedRing) IsRunning() bool {
+	return true
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package roundrobin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/peer/hostport"
+	"go.uber.org/yarpc/yarpctest"
+)
+
+func chooseN(t *testing.T, pl peer.Chooser, n int) []string {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	var chosen []string
+	for i := 0; i < n; i++ {
+		p, onFinish, err := pl.Choose(ctx, &transport.Request{})
+		require.NoError(t, err)
+		onFinish(nil)
+		chosen = append(chosen, p.Identifier())
+	}
+	return chosen
+}
+
+func TestWeightedRoundRobin(t *testing.T) {
+	trans := yarpctest.NewFakeTransport(yarpctest.InitialConnectionStatus(peer.Available))
+	pl := NewWeighted(trans, func(c *listConfig) { c.shuffle = false })
+	require.NoError(t, pl.Update(peer.ListUpdates{
+		Additions: []peer.Identifier{
+			hostport.IdentifyWeighted("a", 5),
+			hostport.IdentifyWeighted("b", 1),
+			hostport.Identify("c"),
+		},
+	}))
+	require.NoError(t, pl.Start())
+	defer pl.Stop()
+
+	// Choices of the heaviest peer are interleaved with the others.
+	assert.Equal(t,
+		[]string{"a", "a", "b", "a", "c", "a", "a"},
+		chooseN(t, pl, 7))
+
+	require.NoError(t, pl.Update(peer.ListUpdates{
+		Reweights: []peer.Identifier{hostport.IdentifyWeighted("a", 0)},
+	}))
+	assert.ElementsMatch(t, []string{"b", "b", "c", "c"}, chooseN(t, pl, 4),
+		"peers with no weight must be drained")
+
+	require.NoError(t, pl.Update(peer.ListUpdates{
+		Removals: []peer.Identifier{hostport.Identify("b"), hostport.Identify("c")},
+	}))
+	assert.Equal(t, []string{"a", "a"}, chooseN(t, pl, 2),
+		"peers with no weight must be chosen if no other peers are available")
+}
+
+func TestWeightedRoundRobinDistribution(t *testing.T) {
+	trans := yarpctest.NewFakeTransport(yarpctest.InitialConnectionStatus(peer.Available))
+	pl := NewWeighted(trans)
+	require.NoError(t, pl.Update(peer.ListUpdates{
+		Additions: []peer.Identifier{
+			hostport.IdentifyWeighted("canary", 1),
+			hostport.IdentifyWeighted("big", 6),
+			hostport.IdentifyWeighted("small", 3),
+		},
+	}))
+	require.NoError(t, pl.Start())
+	defer pl.Stop()
+
+	counts := make(map[string]int)
+	for _, id := range chooseN(t, pl, 1000) {
+		counts[id]++
+	}
+	assert.Equal(t, map[string]int{"canary": 100, "big": 600, "small": 300}, counts)
+}
@@ -0,0 +1,273 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package failover
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"go.uber.org/atomic"
+	"go.uber.org/multierr"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/introspection"
+	"go.uber.org/yarpc/pkg/lifecycle"
+)
+
+const _name = "failover"
+
+// Option customizes the behavior of a failover chooser.
+type Option func(*options)
+
+type options struct {
+	threshold float64
+	split     bool
+}
+
+// Threshold sets the ratio of available peers, between 0 and 1, at or above
+// which a child receives all requests not taken by the children before it.
+//
+// Defaults to 0.5.
+func Threshold(ratio float64) Option {
+	return func(opts *options) {
+		opts.threshold = ratio
+	}
+}
+
+// SplitTraffic makes children below the threshold keep a share of requests
+// proportional to their ratio of available peers, instead of failing over
+// entirely to the next child.
+//
+// Defaults to failing over entirely.
+func SplitTraffic() Option {
+	return func(opts *options) {
+		opts.split = true
+	}
+}
+
+// Chooser is a peer chooser that fails over between child choosers in order
+// of priority, based on the ratio of available peers of each.
+//
+// Chooser is a peer.Chooser rather than a peer.ChooserList: each child keeps
+// its own peer list, fed by its own updater, so there is no single set of
+// peers that the failover chooser itself could be updated with.
+type Chooser struct {
+	children []peer.Chooser
+	opts     options
+	once     *lifecycle.Once
+
+	drained []atomic.Bool
+}
+
+var (
+	_ peer.Chooser                        = (*Chooser)(nil)
+	_ introspection.IntrospectableChooser = (*Chooser)(nil)
+)
+
+// New builds a chooser that fails over between the given children, from the
+// first to the last. The chooser starts and stops its children.
+func New(children []peer.Chooser, opts ...Option) *Chooser {
+	options := options{threshold: 0.5}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &Chooser{
+		children: children,
+		opts:     options,
+		once:     lifecycle.NewOnce(),
+		drained:  make([]atomic.Bool, len(children)),
+	}
+}
+
+// Drain stops or resumes sending requests to the child at the given index.
+// A drained child only receives requests while no other child has
+// available peers.
+func (c *Chooser) Drain(index int, drained bool) {
+	c.drained[index].Store(drained)
+}
+
+// Choose chooses a peer from the child selected by the availability of the
+// peers of every child.
+func (c *Chooser) Choose(ctx context.Context, req *transport.Request) (peer.Peer, func(error), error) {
+	return c.children[c.pick()].Choose(ctx, req)
+}
+
+// pick returns the index of the child to send a request to. It runs on
+// every request, so it makes a single pass over the children, without
+// locking or allocating.
+//
+// By default, the first child that is not drained and whose availability is
+// at or above the threshold takes the request. With SplitTraffic, each child
+// takes a share of the requests not taken by the children before it, in
+// proportion to its availability relative to the threshold, and one child
+// is drawn with a probability proportional to its share.
+//
+// If no child may take the request, the request goes to the child with the
+// most available peers, preferring children that are not drained. If no
+// child has available peers, it goes to the first child that is not
+// drained, whose peer list waits for a peer to become available.
+func (c *Chooser) pick() int {
+	var (
+		picked, firstUndrained = -1, -1
+		remaining, total       = 1.0, 0.0
+
+		best, bestDrained           = -1, -1
+		bestRatio, bestDrainedRatio float64
+	)
+	for i, child := range c.children {
+		ratio := availability(child)
+		if c.drained[i].Load() {
+			if ratio > bestDrainedRatio {
+				bestDrained, bestDrainedRatio = i, ratio
+			}
+			continue
+		}
+		if firstUndrained < 0 {
+			firstUndrained = i
+		}
+		if ratio > bestRatio {
+			best, bestRatio = i, ratio
+		}
+
+		if !c.opts.split {
+			if ratio >= c.opts.threshold {
+				return i
+			}
+			continue
+		}
+		if ratio == 0 {
+			continue
+		}
+		health := 1.0
+		if c.opts.threshold > 0 && ratio < c.opts.threshold {
+			health = ratio / c.opts.threshold
+		}
+		share := remaining * health
+		remaining -= share
+		total += share
+		// Replacing the pick with probability share/total leaves every
+		// child seen so far picked in proportion to its share.
+		if rand.Float64()*total < share {
+			picked = i
+		}
+		if remaining <= 0 {
+			break
+		}
+	}
+
+	switch {
+	case picked >= 0:
+		return picked
+	case best >= 0:
+		return best
+	case bestDrained >= 0:
+		return bestDrained
+	case firstUndrained >= 0:
+		return firstUndrained
+	default:
+		return 0
+	}
+}
+
+// Start starts all children.
+func (c *Chooser) Start() error {
+	return c.once.Start(c.start)
+}
+
+func (c *Chooser) start() error {
+	var errs error
+	for _, child := range c.children {
+		errs = multierr.Append(errs, child.Start())
+	}
+	return errs
+}
+
+// Stop stops all children.
+func (c *Chooser) Stop() error {
+	return c.once.Stop(c.stop)
+}
+
+func (c *Chooser) stop() error {
+	var errs error
+	for _, child := range c.children {
+		errs = multierr.Append(errs, child.Stop())
+	}
+	return errs
+}
+
+// IsRunning returns whether the chooser is running.
+func (c *Chooser) IsRunning() bool {
+	return c.once.IsRunning()
+}
+
+// Introspect returns the availability of every child and the peers of the
+// children that are introspectable.
+func (c *Chooser) Introspect() introspection.ChooserStatus {
+	status := introspection.ChooserStatus{Name: _name}
+	states := make([]string, len(c.children))
+	for i, child := range c.children {
+		states[i] = fmt.Sprintf("child %d: %.0f%% available", i, availability(child)*100)
+		if c.drained[i].Load() {
+			states[i] += ", drained"
+		}
+
+		ic, ok := child.(introspection.IntrospectableChooser)
+		if !ok {
+			continue
+		}
+		for _, ps := range ic.Introspect().Peers {
+			ps.State = fmt.Sprintf("child %d, %s", i, ps.State)
+			status.Peers = append(status.Peers, ps)
+		}
+	}
+	status.State = fmt.Sprintf("threshold %.0f%%; %s", c.opts.threshold*100, strings.Join(states, "; "))
+	return status
+}
+
+// availabilityCounter is implemented by peer lists that count their
+// available and unavailable peers, like the lists built on peerlist.List.
+type availabilityCounter interface {
+	NumAvailable() int
+	NumUnavailable() int
+}
+
+// availability returns the ratio of available peers of a child. Children
+// that do not count their peers are considered fully available.
+func availability(child peer.Chooser) float64 {
+	var x interface{} = child
+	if bc, ok := child.(interface{ ChooserList() peer.ChooserList }); ok {
+		x = bc.ChooserList()
+	}
+
+	counter, ok := x.(availabilityCounter)
+	if !ok {
+		return 1
+	}
+	available := counter.NumAvailable()
+	total := available + counter.NumUnavailable()
+	if total == 0 {
+		return 0
+	}
+	return float64(available) / float64(total)
+}
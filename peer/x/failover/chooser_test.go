@@ -0,0 +1,156 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package failover
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	yarpcpeer "go.uber.org/yarpc/peer"
+	"go.uber.org/yarpc/peer/hostport"
+	"go.uber.org/yarpc/peer/roundrobin"
+	"go.uber.org/yarpc/yarpctest"
+)
+
+// cluster returns a child with n peers named after the cluster.
+func cluster(trans peer.Transport, name string, n int) peer.Chooser {
+	ids := make([]peer.Identifier, n)
+	for i := range ids {
+		ids[i] = hostport.Identify(fmt.Sprintf("%s-%d", name, i))
+	}
+	return yarpcpeer.Bind(roundrobin.New(trans), yarpcpeer.BindPeers(ids))
+}
+
+func disconnect(trans *yarpctest.FakeTransport, name string, n int) {
+	for i := 0; i < n; i++ {
+		trans.SimulateDisconnect(hostport.Identify(fmt.Sprintf("%s-%d", name, i)))
+	}
+}
+
+// chosen returns the number of requests sent to each cluster over n
+// requests.
+func chosen(t *testing.T, c *Chooser, n int) map[string]int {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		p, onFinish, err := c.Choose(ctx, &transport.Request{})
+		require.NoError(t, err)
+		onFinish(nil)
+		counts[p.Identifier()[:len(p.Identifier())-2]]++
+	}
+	return counts
+}
+
+func newChooser(t *testing.T, opts ...Option) (*Chooser, *yarpctest.FakeTransport) {
+	trans := yarpctest.NewFakeTransport(yarpctest.InitialConnectionStatus(peer.Available))
+	c := New([]peer.Chooser{
+		cluster(trans, "primary", 4),
+		cluster(trans, "backup", 4),
+	}, opts...)
+	require.NoError(t, c.Start())
+	return c, trans
+}
+
+func TestFailover(t *testing.T) {
+	c, trans := newChooser(t)
+	defer c.Stop()
+
+	assert.Equal(t, map[string]int{"primary": 20}, chosen(t, c, 20))
+
+	disconnect(trans, "primary", 2)
+	assert.Equal(t, map[string]int{"primary": 20}, chosen(t, c, 20),
+		"primary at the threshold must receive all requests")
+
+	disconnect(trans, "primary", 3)
+	assert.Equal(t, map[string]int{"backup": 20}, chosen(t, c, 20),
+		"primary below the threshold must fail over")
+
+	disconnect(trans, "backup", 4)
+	assert.Equal(t, map[string]int{"primary": 20}, chosen(t, c, 20),
+		"the child with the most available peers must be used if none is healthy")
+
+	trans.SimulateConnect(hostport.Identify("primary-0"))
+	trans.SimulateConnect(hostport.Identify("primary-1"))
+	assert.Equal(t, map[string]int{"primary": 20}, chosen(t, c, 20))
+}
+
+func TestSplitTraffic(t *testing.T) {
+	c, trans := newChooser(t, SplitTraffic(), Threshold(0.8))
+	defer c.Stop()
+
+	assert.Equal(t, map[string]int{"primary": 100}, chosen(t, c, 100))
+
+	// With half of its peers available, the primary keeps 0.5/0.8 of the
+	// requests.
+	disconnect(trans, "primary", 2)
+	counts := chosen(t, c, 1000)
+	assert.InDelta(t, 625, counts["primary"], 100, "unexpected split: %v", counts)
+	assert.InDelta(t, 375, counts["backup"], 100, "unexpected split: %v", counts)
+}
+
+func TestDrain(t *testing.T) {
+	c, trans := newChooser(t)
+	defer c.Stop()
+
+	c.Drain(0, true)
+	assert.Equal(t, map[string]int{"backup": 20}, chosen(t, c, 20), "drained child must not receive requests")
+	assert.Contains(t, c.Introspect().State, "child 0: 100% available, drained")
+
+	disconnect(trans, "backup", 4)
+	assert.Equal(t, map[string]int{"primary": 20}, chosen(t, c, 20),
+		"drained child must receive requests if no other child has peers")
+
+	c.Drain(0, false)
+	assert.NotContains(t, c.Introspect().State, "drained")
+}
+
+func TestIntrospect(t *testing.T) {
+	c, trans := newChooser(t)
+	defer c.Stop()
+
+	disconnect(trans, "primary", 1)
+	status := c.Introspect()
+	assert.Equal(t, "failover", status.Name)
+	assert.Equal(t, "threshold 50%; child 0: 75% available; child 1: 100% available", status.State)
+	assert.Len(t, status.Peers, 8)
+}
+
+func TestLifecycle(t *testing.T) {
+	c, _ := newChooser(t)
+	assert.True(t, c.IsRunning())
+	for _, child := range c.children {
+		assert.True(t, child.IsRunning())
+	}
+
+	require.NoError(t, c.Stop())
+	assert.False(t, c.IsRunning())
+	for _, child := range c.children {
+		assert.False(t, child.IsRunning())
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package failover
+
+import (
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/yarpcconfig"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// Configuration describes how to build a failover peer chooser.
+type Configuration struct {
+	// Threshold is the ratio of available peers at or above which a child
+	// receives all requests not taken by the children before it. Defaults
+	// to 0.5.
+	Threshold *float64 `config:"threshold"`
+
+	// Split makes children below the threshold keep a share of requests
+	// proportional to their available peers.
+	Split bool `config:"split"`
+
+	// Children are the peer choosers to fail over between, in order of
+	// priority.
+	Children []ChildConfiguration `config:"children"`
+}
+
+// ChildConfiguration describes a child of a failover peer chooser: any peer
+// chooser configuration an outbound accepts, and whether the child is
+// drained.
+type ChildConfiguration struct {
+	yarpcconfig.PeerChooser
+
+	// Drained children only receive requests while no other child has
+	// available peers.
+	Drained bool `config:"drained"`
+}
+
+// Spec returns a configuration specification for the failover peer chooser,
+// making it possible to fail over between clusters with transports that use
+// outbound peer list configuration (like HTTP).
+//
+//  cfg := yarpcconfig.New()
+//  cfg.MustRegisterPeerChooser(failover.Spec())
+//
+// This enables the failover peer chooser:
+//
+//  outbounds:
+//    otherservice:
+//      unary:
+//        http:
+//          url: https://host:port/rpc
+//          failover:
+//            threshold: 0.5
+//            children:
+//              - round-robin:
+//                  peers:
+//                    - 127.0.0.1:8080
+//              - drained: true
+//                round-robin:
+//                  peers:
+//                    - 127.0.0.2:8080
+func Spec() yarpcconfig.PeerChooserSpec {
+	return yarpcconfig.PeerChooserSpec{
+		Name: "failover",
+		BuildPeerChooser: func(cfg Configuration, t peer.Transport, k *yarpcconfig.Kit) (peer.Chooser, error) {
+			if len(cfg.Children) < 2 {
+				return nil, yarpcerrors.InvalidArgumentErrorf(
+					"failover needs at least two children, got %d", len(cfg.Children))
+			}
+
+			var opts []Option
+			if cfg.Threshold != nil {
+				if *cfg.Threshold < 0 || *cfg.Threshold > 1 {
+					return nil, yarpcerrors.InvalidArgumentErrorf(
+						"threshold must be between 0 and 1, got %v", *cfg.Threshold)
+				}
+				opts = append(opts, Threshold(*cfg.Threshold))
+			}
+			if cfg.Split {
+				opts = append(opts, SplitTraffic())
+			}
+
+			children := make([]peer.Chooser, len(cfg.Children))
+			for i, childCfg := range cfg.Children {
+				child, err := childCfg.BuildPeerChooser(t, k.Identify, k)
+				if err != nil {
+					return nil, yarpcerrors.InvalidArgumentErrorf(
+						"failed to build failover child %d: %v", i, err)
+				}
+				children[i] = child
+			}
+
+			chooser := New(children, opts...)
+			for i, childCfg := range cfg.Children {
+				chooser.Drain(i, childCfg.Drained)
+			}
+			return chooser, nil
+		},
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package failover
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/peer/roundrobin"
+	"go.uber.org/yarpc/yarpcconfig"
+	"go.uber.org/yarpc/yarpctest"
+)
+
+type attrs map[string]interface{}
+
+func TestConfig(t *testing.T) {
+	child := func(peers ...string) attrs {
+		return attrs{"round-robin": attrs{"peers": peers}}
+	}
+
+	tests := []struct {
+		desc        string
+		attrs       attrs
+		wantErr     string
+		wantDrained []bool
+	}{
+		{
+			desc: "defaults",
+			attrs: attrs{
+				"children": []attrs{child("1.1.1.1:1111"), child("2.2.2.2:2222")},
+			},
+			wantDrained: []bool{false, false},
+		},
+		{
+			desc: "threshold, split and drained",
+			attrs: attrs{
+				"threshold": 0.8,
+				"split":     true,
+				"children": []attrs{
+					{"drained": true, "round-robin": attrs{"peers": []string{"1.1.1.1:1111"}}},
+					child("2.2.2.2:2222"),
+					child("3.3.3.3:3333"),
+				},
+			},
+			wantDrained: []bool{true, false, false},
+		},
+		{
+			desc: "single child",
+			attrs: attrs{
+				"children": []attrs{child("1.1.1.1:1111")},
+			},
+			wantErr: "failover needs at least two children, got 1",
+		},
+		{
+			desc: "invalid threshold",
+			attrs: attrs{
+				"threshold": 1.5,
+				"children":  []attrs{child("1.1.1.1:1111"), child("2.2.2.2:2222")},
+			},
+			wantErr: "threshold must be between 0 and 1",
+		},
+		{
+			desc: "invalid child",
+			attrs: attrs{
+				"children": []attrs{child("1.1.1.1:1111"), {"bogus-list": attrs{}}},
+			},
+			wantErr: "failed to build failover child 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			cfg := yarpcconfig.New()
+			cfg.RegisterPeerChooser(Spec())
+			cfg.RegisterPeerList(roundrobin.Spec())
+			cfg.RegisterTransport(yarpctest.FakeTransportSpec())
+			config, err := cfg.LoadConfig("our-service", attrs{
+				"outbounds": attrs{
+					"their-service": attrs{
+						"fake-transport": attrs{
+							"failover": tt.attrs,
+						},
+					},
+				},
+			})
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			outbound := config.Outbounds["their-service"].Unary.(*yarpctest.FakeOutbound)
+			chooser, ok := outbound.Chooser().(*Chooser)
+			require.True(t, ok, "expected a failover chooser, got %T", outbound.Chooser())
+			assert.Equal(t, tt.wantDrained, chooser.drained)
+			assert.Len(t, chooser.children, len(tt.wantDrained))
+		})
+	}
+}
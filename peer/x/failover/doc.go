@@ -0,0 +1,61 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package failover provides a peer chooser that sends requests to the first
+// of several peer choosers, for example one per cluster, that has enough
+// available peers.
+//
+// The children are listed in order of priority: typically the local cluster
+// first and remote clusters after it. Each child brings its own peer list
+// and updater. While the ratio of available peers of a child is at or above
+// the threshold, it receives all the requests that the children before it do
+// not. By default, requests fail over entirely to the next healthy child
+// once the primary falls below the threshold. With the SplitTraffic option,
+// a degraded child keeps a share of requests proportional to its available
+// peers, and only the rest spills over to the children after it.
+//
+// 	chooser := failover.New([]peer.Chooser{
+// 		peer.Bind(roundrobin.New(transport), peer.BindPeers(local)),
+// 		peer.Bind(roundrobin.New(transport), peer.BindPeers(remote)),
+// 	}, failover.Threshold(0.5))
+//
+// The chooser may also be configured with yarpcconfig, listing the
+// configuration of each child as an outbound would:
+//
+// 	outbounds:
+// 	  otherservice:
+// 	    unary:
+// 	      http:
+// 	        url: http://host:port/rpc
+// 	        failover:
+// 	          threshold: 0.5
+// 	          children:
+// 	            - drained: false
+// 	              round-robin:
+// 	                dns:
+// 	                  name: otherservice.local.example.com
+// 	            - round-robin:
+// 	                dns:
+// 	                  name: otherservice.remote.example.com
+//
+// Disaster recovery drills can drain a child, so that it receives no
+// requests while any other child has available peers, with the Drain method
+// or the drained attribute of the child in configuration.
+package failover
@@ -0,0 +1,240 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package outlier
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// peerStats holds the requests observed for a peer within the current
+// interval, and its ejection state.
+type peerStats struct {
+	requests  int
+	failures  int
+	latencies []time.Duration
+	// samples is the number of latencies observed within the interval, of
+	// which latencies holds a uniform sample.
+	samples int
+
+	ejected      bool
+	ejectedUntil time.Time
+	reason       string
+	// ejections is the number of consecutive ejections, which decays by one
+	// for every interval in which the peer is not an outlier.
+	ejections int
+}
+
+func (s *peerStats) isEjected() bool {
+	return s.ejected
+}
+
+func (s *peerStats) record(failed bool, latency time.Duration, r *rand.Rand) {
+	s.requests++
+	if failed {
+		s.failures++
+	}
+
+	// Reservoir sampling keeps a bounded, uniform sample of the latencies.
+	s.samples++
+	if len(s.latencies) < _reservoirSize {
+		s.latencies = append(s.latencies, latency)
+	} else if i := r.Intn(s.samples); i < _reservoirSize {
+		s.latencies[i] = latency
+	}
+}
+
+func (s *peerStats) errorRate() float64 {
+	return float64(s.failures) / float64(s.requests)
+}
+
+// latency returns the latency of the peer at the given percentile.
+func (s *peerStats) latency(percentile float64) float64 {
+	sorted := make([]time.Duration, len(s.latencies))
+	copy(sorted, s.latencies)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return float64(sorted[int(percentile*float64(len(sorted)-1))])
+}
+
+func (s *peerStats) reset() {
+	s.requests = 0
+	s.failures = 0
+	s.latencies = s.latencies[:0]
+	s.samples = 0
+}
+
+// outlier is a peer found to be an outlier, with the number of standard
+// deviations by which it deviates from the mean.
+type outlier struct {
+	id     string
+	stats  *peerStats
+	reason string
+	score  float64
+}
+
+// detect ends the current interval: it lets expired ejections end, ejects
+// the peers that were outliers during the interval, and starts a new
+// interval.
+func (l *List) detect(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, s := range l.peers {
+		if s.ejected && !now.Before(s.ejectedUntil) {
+			s.ejected = false
+			s.reason = ""
+			l.ejected--
+		}
+	}
+
+	outliers := l.findOutliers()
+	maxEjected := len(l.peers) * l.opts.maxEjectionPercent / 100
+	if maxEjected < 1 {
+		maxEjected = 1
+	}
+	for _, o := range outliers {
+		if l.ejected >= maxEjected {
+			break
+		}
+		l.eject(o, now)
+	}
+
+	isOutlier := make(map[*peerStats]bool, len(outliers))
+	for _, o := range outliers {
+		isOutlier[o.stats] = true
+	}
+	for id, s := range l.peers {
+		if s.ejected {
+			s.reset()
+			continue
+		}
+		if s.requests == 0 && s.ejections == 0 {
+			delete(l.peers, id)
+			continue
+		}
+		if s.requests > 0 && s.ejections > 0 && !isOutlier[s] {
+			s.ejections--
+		}
+		s.reset()
+	}
+	l.ejectedGauge.Store(int64(l.ejected))
+}
+
+// findOutliers returns the peers whose error rate or latency exceeds the
+// mean across peers by more than the configured number of standard
+// deviations, most deviant first. Peers are only compared if enough of them
+// served enough requests during the interval.
+func (l *List) findOutliers() []outlier {
+	var (
+		ids        []string
+		candidates []*peerStats
+	)
+	for id, s := range l.peers {
+		if !s.ejected && s.requests >= l.opts.minRequests && s.requests > 0 {
+			ids = append(ids, id)
+			candidates = append(candidates, s)
+		}
+	}
+	if len(candidates) < l.opts.minPeers || len(candidates) < 2 {
+		return nil
+	}
+
+	errorRates := make([]float64, len(candidates))
+	latencies := make([]float64, len(candidates))
+	for i, s := range candidates {
+		errorRates[i] = s.errorRate()
+		latencies[i] = s.latency(l.opts.latencyPercentile)
+	}
+	errorScores := scores(errorRates)
+	latencyScores := scores(latencies)
+
+	var outliers []outlier
+	for i, s := range candidates {
+		switch {
+		case errorScores[i] > l.opts.stdevFactor:
+			outliers = append(outliers, outlier{id: ids[i], stats: s, reason: _reasonErrorRate, score: errorScores[i]})
+		case latencyScores[i] > l.opts.stdevFactor:
+			outliers = append(outliers, outlier{id: ids[i], stats: s, reason: _reasonLatency, score: latencyScores[i]})
+		}
+	}
+	sort.Slice(outliers, func(i, j int) bool {
+		if outliers[i].score != outliers[j].score {
+			return outliers[i].score > outliers[j].score
+		}
+		return outliers[i].id < outliers[j].id
+	})
+	return outliers
+}
+
+func (l *List) eject(o outlier, now time.Time) {
+	s := o.stats
+	s.ejections++
+	duration := l.opts.baseEjectionTime
+	for i := 1; i < s.ejections && duration < l.opts.maxEjectionTime; i++ {
+		duration *= 2
+	}
+	if duration > l.opts.maxEjectionTime {
+		duration = l.opts.maxEjectionTime
+	}
+
+	s.ejected = true
+	s.ejectedUntil = now.Add(duration)
+	s.reason = o.reason
+	l.ejected++
+
+	l.ejections.MustGet("reason", o.reason).Inc()
+	l.logger.Info("Ejected outlier peer.",
+		zap.String("peer", o.id),
+		zap.String("reason", o.reason),
+		zap.Duration("duration", duration),
+		zap.Int("consecutiveEjections", s.ejections),
+	)
+}
+
+// scores returns the number of standard deviations by which each value
+// exceeds the mean of the values. All scores are zero if the values do not
+// deviate.
+func scores(values []float64) []float64 {
+	var mean float64
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+
+	var variance float64
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	stdev := math.Sqrt(variance / float64(len(values)))
+
+	scores := make([]float64, len(values))
+	if stdev == 0 {
+		return scores
+	}
+	for i, v := range values {
+		scores[i] = (v - mean) / stdev
+	}
+	return scores
+}
@@ -0,0 +1,45 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package outlier provides a peer list decorator that temporarily ejects
+// peers whose error rates or latencies are statistical outliers.
+//
+// The decorator observes the outcome and latency of every request through
+// the onFinish callback returned by Choose. At the end of every interval,
+// it compares the peers that served enough requests in that interval: a
+// peer whose error rate, or whose latency at a percentile, exceeds the mean
+// across peers by more than a number of standard deviations is ejected.
+// The decorator does not choose ejected peers while other peers are
+// available. A peer is ejected for a base ejection time multiplied by two
+// for every consecutive ejection, up to a maximum, and no more than a
+// percentage of the peers are ejected at once.
+//
+// 	chooser := peer.Bind(
+// 		outlier.New(roundrobin.New(transport),
+// 			outlier.Interval(10*time.Second),
+// 			outlier.BaseEjectionTime(30*time.Second),
+// 			outlier.Meter(meter),
+// 		),
+// 		peer.BindPeers(peers),
+// 	)
+//
+// Ejections are counted in the outlier_ejections metric, tagged with their
+// reason, and ejected peers appear in the dispatcher's introspection.
+package outlier
@@ -0,0 +1,268 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package outlier
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/clock"
+	"go.uber.org/yarpc/internal/introspection"
+	"go.uber.org/yarpc/pkg/lifecycle"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/zap"
+)
+
+const (
+	_name = "outlier"
+
+	// _reservoirSize is the number of latency samples kept for each peer
+	// within an interval.
+	_reservoirSize = 1024
+
+	_reasonErrorRate = "error_rate"
+	_reasonLatency   = "latency"
+)
+
+// List is a peer list decorator that stops choosing peers that are
+// outliers among the peers of the list it decorates.
+type List struct {
+	list   peer.ChooserList
+	opts   options
+	once   *lifecycle.Once
+	logger *zap.Logger
+
+	ejections     *metrics.CounterVector
+	ejectedGauge  *metrics.Gauge
+	quit          chan struct{}
+	detectionDone sync.WaitGroup
+
+	mu      sync.Mutex
+	rand    *rand.Rand
+	peers   map[string]*peerStats
+	ejected int
+}
+
+var (
+	_ peer.ChooserList                    = (*List)(nil)
+	_ introspection.IntrospectableChooser = (*List)(nil)
+)
+
+// New decorates the given peer list with outlier detection.
+func New(list peer.ChooserList, opts ...Option) *List {
+	options := options{
+		interval:           10 * time.Second,
+		baseEjectionTime:   30 * time.Second,
+		maxEjectionTime:    5 * time.Minute,
+		maxEjectionPercent: 10,
+		minRequests:        100,
+		minPeers:           5,
+		stdevFactor:        1.5,
+		latencyPercentile:  0.95,
+		logger:             zap.NewNop(),
+		clock:              clock.NewReal(),
+	}
+	FailureCodes(_defaultFailureCodes...)(&options)
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	logger := options.logger.Named(_name)
+	ejections, err := options.meter.CounterVector(metrics.Spec{
+		Name:    "outlier_ejections",
+		Help:    "Number of peers ejected as outliers.",
+		VarTags: []string{"reason"},
+	})
+	if err != nil {
+		logger.Error("Failed to create outlier ejections vector.", zap.Error(err))
+	}
+	ejectedGauge, err := options.meter.Gauge(metrics.Spec{
+		Name: "outlier_ejected_peers",
+		Help: "Number of peers currently ejected as outliers.",
+	})
+	if err != nil {
+		logger.Error("Failed to create outlier ejected peers gauge.", zap.Error(err))
+	}
+
+	return &List{
+		list:         list,
+		opts:         options,
+		once:         lifecycle.NewOnce(),
+		logger:       logger,
+		ejections:    ejections,
+		ejectedGauge: ejectedGauge,
+		quit:         make(chan struct{}),
+		rand:         rand.New(rand.NewSource(time.Now().UnixNano())),
+		peers:        make(map[string]*peerStats),
+	}
+}
+
+// Update applies the given additions and removals to the decorated list and
+// forgets the statistics of removed peers.
+func (l *List) Update(updates peer.ListUpdates) error {
+	err := l.list.Update(updates)
+
+	l.mu.Lock()
+	for _, pid := range updates.Removals {
+		if s, ok := l.peers[pid.Identifier()]; ok {
+			if s.isEjected() {
+				l.ejected--
+			}
+			delete(l.peers, pid.Identifier())
+		}
+	}
+	l.ejectedGauge.Store(int64(l.ejected))
+	l.mu.Unlock()
+
+	return err
+}
+
+// Choose chooses a peer from the decorated list, choosing again if it
+// returns an ejected peer. If the decorated list only returns ejected peers,
+// the last of them is used rather than failing the request.
+func (l *List) Choose(ctx context.Context, req *transport.Request) (peer.Peer, func(error), error) {
+	l.mu.Lock()
+	attempts := l.ejected + 1
+	l.mu.Unlock()
+
+	for i := 1; ; i++ {
+		p, onFinish, err := l.list.Choose(ctx, req)
+		if err != nil {
+			return nil, nil, err
+		}
+		if i < attempts && l.isEjected(p.Identifier()) {
+			onFinish(nil)
+			continue
+		}
+		return p, l.observe(p.Identifier(), onFinish), nil
+	}
+}
+
+// Start starts the decorated list and the periodic detection of outliers.
+func (l *List) Start() error {
+	return l.once.Start(l.start)
+}
+
+func (l *List) start() error {
+	if err := l.list.Start(); err != nil {
+		return err
+	}
+	l.detectionDone.Add(1)
+	go l.detectLoop()
+	return nil
+}
+
+// Stop stops the detection of outliers and the decorated list.
+func (l *List) Stop() error {
+	return l.once.Stop(l.stop)
+}
+
+func (l *List) stop() error {
+	close(l.quit)
+	l.detectionDone.Wait()
+	return l.list.Stop()
+}
+
+// IsRunning returns whether the list is running.
+func (l *List) IsRunning() bool {
+	return l.once.IsRunning()
+}
+
+// Introspect returns the status of the decorated list, annotated with the
+// ejected peers.
+func (l *List) Introspect() introspection.ChooserStatus {
+	var status introspection.ChooserStatus
+	if ic, ok := l.list.(introspection.IntrospectableChooser); ok {
+		status = ic.Introspect()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if status.State != "" {
+		status.State += ", "
+	}
+	status.State += fmt.Sprintf("%d ejected", l.ejected)
+	for i, ps := range status.Peers {
+		s, ok := l.peers[ps.Identifier]
+		if !ok || !s.isEjected() {
+			continue
+		}
+		status.Peers[i].State = fmt.Sprintf("%s, ejected until %v for %s (%d consecutive)",
+			ps.State, s.ejectedUntil.Format(time.RFC3339), s.reason, s.ejections)
+	}
+	return status
+}
+
+func (l *List) isEjected(id string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s, ok := l.peers[id]
+	return ok && s.isEjected()
+}
+
+// observe wraps the onFinish callback of a chosen peer to record the outcome
+// and latency of the request.
+func (l *List) observe(id string, onFinish func(error)) func(error) {
+	start := l.opts.clock.Now()
+	return func(err error) {
+		onFinish(err)
+		latency := l.opts.clock.Now().Sub(start)
+
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		s, ok := l.peers[id]
+		if !ok {
+			s = &peerStats{}
+			l.peers[id] = s
+		}
+		s.record(l.failed(err), latency, l.rand)
+	}
+}
+
+func (l *List) failed(err error) bool {
+	if err == nil {
+		return false
+	}
+	_, ok := l.opts.failureCodes[yarpcerrors.FromError(err).Code()]
+	return ok
+}
+
+func (l *List) detectLoop() {
+	defer l.detectionDone.Done()
+
+	ticker := time.NewTicker(l.opts.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.quit:
+			return
+		case <-ticker.C:
+			l.detect(l.opts.clock.Now())
+		}
+	}
+}
@@ -0,0 +1,253 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package outlier
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/clock"
+	"go.uber.org/yarpc/peer/hostport"
+	"go.uber.org/yarpc/peer/roundrobin"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/yarpc/yarpctest"
+)
+
+var _peers = []string{"a", "b", "c", "d", "e"}
+
+func newList(t *testing.T, peers []string, opts ...Option) (*List, *clock.FakeClock) {
+	clk := clock.NewFake()
+	trans := yarpctest.NewFakeTransport(yarpctest.InitialConnectionStatus(peer.Available))
+	opts = append([]Option{MinRequests(10), withClock(clk)}, opts...)
+	pl := New(roundrobin.New(trans), opts...)
+
+	var ids []peer.Identifier
+	for _, p := range peers {
+		ids = append(ids, hostport.Identify(p))
+	}
+	require.NoError(t, pl.Update(peer.ListUpdates{Additions: ids}))
+	require.NoError(t, pl.Start())
+	return pl, clk
+}
+
+// send makes n requests through the list, failing or delaying those sent
+// to the peers in the given maps.
+func send(t *testing.T, pl *List, clk *clock.FakeClock, n int, errs map[string]error, delays map[string]time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	for i := 0; i < n; i++ {
+		p, onFinish, err := pl.Choose(ctx, &transport.Request{})
+		require.NoError(t, err)
+		clk.Add(time.Millisecond + delays[p.Identifier()])
+		onFinish(errs[p.Identifier()])
+	}
+}
+
+// chosen returns the peers chosen over n requests.
+func chosen(t *testing.T, pl *List, n int) map[string]int {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		p, onFinish, err := pl.Choose(ctx, &transport.Request{})
+		require.NoError(t, err)
+		onFinish(nil)
+		counts[p.Identifier()]++
+	}
+	return counts
+}
+
+func counter(root *metrics.Root, name, reason string) int64 {
+	for _, c := range root.Snapshot().Counters {
+		if c.Name == name && c.Tags["reason"] == reason {
+			return c.Value
+		}
+	}
+	return 0
+}
+
+func TestEjectErrorRateOutlier(t *testing.T) {
+	root := metrics.New()
+	pl, clk := newList(t, _peers, Meter(root.Scope()))
+	defer pl.Stop()
+
+	internal := yarpcerrors.InternalErrorf("oops")
+	send(t, pl, clk, 100, map[string]error{"c": internal}, nil)
+	pl.detect(clk.Now())
+
+	counts := chosen(t, pl, 100)
+	assert.Zero(t, counts["c"], "ejected peer must not be chosen")
+	assert.Len(t, counts, 4)
+	assert.Equal(t, int64(1), counter(root, "outlier_ejections", _reasonErrorRate))
+
+	status := pl.Introspect()
+	assert.Contains(t, status.State, "1 ejected")
+	for _, ps := range status.Peers {
+		if ps.Identifier == "c" {
+			assert.Contains(t, ps.State, "ejected until")
+			assert.Contains(t, ps.State, _reasonErrorRate)
+		} else {
+			assert.NotContains(t, ps.State, "ejected")
+		}
+	}
+
+	clk.Add(30 * time.Second)
+	pl.detect(clk.Now())
+	assert.Len(t, chosen(t, pl, 100), 5, "peer must return once its ejection expires")
+	assert.Contains(t, pl.Introspect().State, "0 ejected")
+}
+
+func TestEjectLatencyOutlier(t *testing.T) {
+	root := metrics.New()
+	pl, clk := newList(t, _peers, Meter(root.Scope()))
+	defer pl.Stop()
+
+	send(t, pl, clk, 100, nil, map[string]time.Duration{"e": time.Second})
+	pl.detect(clk.Now())
+
+	counts := chosen(t, pl, 100)
+	assert.Zero(t, counts["e"], "ejected peer must not be chosen")
+	assert.Equal(t, int64(1), counter(root, "outlier_ejections", _reasonLatency))
+}
+
+func TestIgnoredErrors(t *testing.T) {
+	pl, clk := newList(t, _peers)
+	defer pl.Stop()
+
+	invalid := yarpcerrors.InvalidArgumentErrorf("bad request")
+	send(t, pl, clk, 100, map[string]error{"c": invalid}, nil)
+	pl.detect(clk.Now())
+	assert.Len(t, chosen(t, pl, 100), 5, "caller errors must not eject peers")
+}
+
+func TestNotEnoughRequests(t *testing.T) {
+	pl, clk := newList(t, _peers, MinRequests(50))
+	defer pl.Stop()
+
+	internal := yarpcerrors.InternalErrorf("oops")
+	send(t, pl, clk, 100, map[string]error{"c": internal}, nil)
+	pl.detect(clk.Now())
+	assert.Len(t, chosen(t, pl, 100), 5, "peers with too few requests must not be compared")
+}
+
+func TestNotEnoughPeers(t *testing.T) {
+	pl, clk := newList(t, _peers[:4])
+	defer pl.Stop()
+
+	internal := yarpcerrors.InternalErrorf("oops")
+	send(t, pl, clk, 100, map[string]error{"c": internal}, nil)
+	pl.detect(clk.Now())
+	assert.Len(t, chosen(t, pl, 100), 4, "too few peers must not be compared")
+}
+
+func TestMaxEjectionPercent(t *testing.T) {
+	var peers []string
+	for i := 0; i < 20; i++ {
+		peers = append(peers, fmt.Sprintf("peer-%02d", i))
+	}
+	pl, clk := newList(t, peers)
+	defer pl.Stop()
+
+	internal := yarpcerrors.InternalErrorf("oops")
+	errs := map[string]error{"peer-00": internal, "peer-01": internal, "peer-02": internal}
+	send(t, pl, clk, 400, errs, nil)
+	pl.detect(clk.Now())
+
+	counts := chosen(t, pl, 200)
+	assert.Len(t, counts, 18, "only 10 percent of peers may be ejected")
+}
+
+func TestEjectionBackoff(t *testing.T) {
+	pl, clk := newList(t, _peers, MaxEjectionTime(100*time.Second))
+	defer pl.Stop()
+
+	internal := yarpcerrors.InternalErrorf("oops")
+	for _, want := range []time.Duration{30 * time.Second, 60 * time.Second, 100 * time.Second} {
+		send(t, pl, clk, 100, map[string]error{"c": internal}, nil)
+		pl.detect(clk.Now())
+
+		pl.mu.Lock()
+		until := pl.peers["c"].ejectedUntil
+		pl.mu.Unlock()
+		assert.Equal(t, want, until.Sub(clk.Now()), "unexpected ejection time")
+
+		clk.Add(want)
+		pl.detect(clk.Now())
+	}
+
+	// Healthy intervals decay the ejection count again.
+	for i := 0; i < 3; i++ {
+		send(t, pl, clk, 100, nil, nil)
+		pl.detect(clk.Now())
+	}
+	send(t, pl, clk, 100, map[string]error{"c": internal}, nil)
+	pl.detect(clk.Now())
+
+	pl.mu.Lock()
+	until := pl.peers["c"].ejectedUntil
+	pl.mu.Unlock()
+	assert.Equal(t, 30*time.Second, until.Sub(clk.Now()))
+}
+
+func TestFailOpen(t *testing.T) {
+	pl, _ := newList(t, []string{"a"})
+	defer pl.Stop()
+
+	pl.mu.Lock()
+	pl.peers["a"] = &peerStats{ejected: true, ejections: 1, reason: _reasonErrorRate}
+	pl.ejected = 1
+	pl.mu.Unlock()
+
+	assert.Equal(t, map[string]int{"a": 10}, chosen(t, pl, 10),
+		"ejected peers must be chosen rather than failing requests")
+}
+
+func TestRemovedPeers(t *testing.T) {
+	pl, clk := newList(t, _peers)
+	defer pl.Stop()
+
+	internal := yarpcerrors.InternalErrorf("oops")
+	send(t, pl, clk, 100, map[string]error{"c": internal}, nil)
+	pl.detect(clk.Now())
+	require.Contains(t, pl.Introspect().State, "1 ejected")
+
+	require.NoError(t, pl.Update(peer.ListUpdates{Removals: []peer.Identifier{hostport.Identify("c")}}))
+	assert.Contains(t, pl.Introspect().State, "0 ejected")
+}
+
+func TestLifecycle(t *testing.T) {
+	trans := yarpctest.NewFakeTransport()
+	pl := New(roundrobin.New(trans), Interval(time.Millisecond))
+	assert.False(t, pl.IsRunning())
+	require.NoError(t, pl.Start())
+	assert.True(t, pl.IsRunning())
+	require.NoError(t, pl.Stop())
+	assert.False(t, pl.IsRunning())
+}
@@ -0,0 +1,180 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package outlier
+
+import (
+	"time"
+
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/internal/clock"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/zap"
+)
+
+// _defaultFailureCodes are the error codes counted as failures by default.
+// These indicate that the peer is unhealthy or overloaded rather than that
+// the request itself was invalid.
+var _defaultFailureCodes = []yarpcerrors.Code{
+	yarpcerrors.CodeUnknown,
+	yarpcerrors.CodeDeadlineExceeded,
+	yarpcerrors.CodeResourceExhausted,
+	yarpcerrors.CodeInternal,
+	yarpcerrors.CodeUnavailable,
+}
+
+// Option customizes the behavior of outlier detection.
+type Option func(*options)
+
+type options struct {
+	interval           time.Duration
+	baseEjectionTime   time.Duration
+	maxEjectionTime    time.Duration
+	maxEjectionPercent int
+	minRequests        int
+	minPeers           int
+	stdevFactor        float64
+	latencyPercentile  float64
+	failureCodes       map[yarpcerrors.Code]struct{}
+	meter              *metrics.Scope
+	logger             *zap.Logger
+	clock              clock.Clock
+}
+
+// Interval sets how often peers are compared to detect outliers. Each
+// comparison only considers the requests made since the previous one.
+//
+// Defaults to 10 seconds.
+func Interval(d time.Duration) Option {
+	return func(opts *options) {
+		opts.interval = d
+	}
+}
+
+// BaseEjectionTime sets how long a peer is ejected for the first time. The
+// ejection time doubles for each consecutive ejection of the same peer, and
+// halves again for each interval in which the peer is not an outlier.
+//
+// Defaults to 30 seconds.
+func BaseEjectionTime(d time.Duration) Option {
+	return func(opts *options) {
+		opts.baseEjectionTime = d
+	}
+}
+
+// MaxEjectionTime sets the longest time a peer is ejected for.
+//
+// Defaults to 5 minutes.
+func MaxEjectionTime(d time.Duration) Option {
+	return func(opts *options) {
+		opts.maxEjectionTime = d
+	}
+}
+
+// MaxEjectionPercent sets the largest percentage of peers that may be
+// ejected at once. At least one peer may always be ejected.
+//
+// Defaults to 10.
+func MaxEjectionPercent(percent int) Option {
+	return func(opts *options) {
+		opts.maxEjectionPercent = percent
+	}
+}
+
+// MinRequests sets the number of requests a peer must serve within an
+// interval to be considered for ejection.
+//
+// Defaults to 100.
+func MinRequests(n int) Option {
+	return func(opts *options) {
+		opts.minRequests = n
+	}
+}
+
+// MinPeers sets the number of peers that must serve at least MinRequests
+// requests within an interval for outliers to be detected.
+//
+// Defaults to 5.
+func MinPeers(n int) Option {
+	return func(opts *options) {
+		opts.minPeers = n
+	}
+}
+
+// StdevFactor sets how many standard deviations above the mean across peers
+// the error rate or latency of a peer must be for it to be an outlier.
+//
+// Defaults to 1.5.
+func StdevFactor(f float64) Option {
+	return func(opts *options) {
+		opts.stdevFactor = f
+	}
+}
+
+// LatencyPercentile sets the percentile, between 0 and 1, of the request
+// latencies of each peer that is compared across peers.
+//
+// Defaults to 0.95.
+func LatencyPercentile(p float64) Option {
+	return func(opts *options) {
+		opts.latencyPercentile = p
+	}
+}
+
+// FailureCodes sets the error codes that are counted as failures. Errors
+// with any other code are counted as successes.
+//
+// Defaults to CodeUnknown, CodeDeadlineExceeded, CodeResourceExhausted,
+// CodeInternal and CodeUnavailable.
+func FailureCodes(codes ...yarpcerrors.Code) Option {
+	return func(opts *options) {
+		opts.failureCodes = make(map[yarpcerrors.Code]struct{}, len(codes))
+		for _, code := range codes {
+			opts.failureCodes[code] = struct{}{}
+		}
+	}
+}
+
+// Meter sets the metrics scope used to count ejections and ejected peers.
+// Use a tagged scope to tell apart the peer lists of different outbounds.
+//
+// Defaults to no metrics.
+func Meter(meter *metrics.Scope) Option {
+	return func(opts *options) {
+		opts.meter = meter
+	}
+}
+
+// Logger sets the logger used to report ejections.
+//
+// Defaults to a no-op logger.
+func Logger(logger *zap.Logger) Option {
+	return func(opts *options) {
+		opts.logger = logger
+	}
+}
+
+// withClock overrides the clock used to measure latencies and ejection
+// times. This is used for testing.
+func withClock(c clock.Clock) Option {
+	return func(opts *options) {
+		opts.clock = c
+	}
+}
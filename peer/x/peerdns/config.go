@@ -0,0 +1,110 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package peerdns
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/yarpcconfig"
+)
+
+// Config describes how to build a DNS peer list updater.
+//
+//  name: _http._tcp.myservice.example.com
+//  minInterval: 1s
+//  maxInterval: 5m
+//
+// Name is required. If port is set, the A and AAAA records of the name are
+// resolved instead of SRV records. Servers lists the host:port pairs of the
+// name servers to query and defaults to those in /etc/resolv.conf.
+type Config struct {
+	Name        string        `config:"name,interpolate"`
+	Port        int           `config:"port"`
+	Servers     []string      `config:"servers"`
+	MinInterval time.Duration `config:"minInterval"`
+	MaxInterval time.Duration `config:"maxInterval"`
+}
+
+// Spec returns a configuration specification for the DNS peer list updater,
+// making it possible to resolve the peers of any configurable peer list
+// from DNS.
+//
+//  cfg := yarpcconfig.New()
+//  cfg.MustRegisterPeerList(roundrobin.Spec())
+//  cfg.MustRegisterPeerListUpdater(peerdns.Spec())
+//
+// This keeps a round-robin peer list in sync with the A and AAAA records of
+// a name:
+//
+//  outbounds:
+//    otherservice:
+//      unary:
+//        http:
+//          url: https://host:port/rpc
+//          round-robin:
+//            dns:
+//              name: otherservice.example.com
+//              port: 8080
+func Spec() yarpcconfig.PeerListUpdaterSpec {
+	return yarpcconfig.PeerListUpdaterSpec{
+		Name: "dns",
+		BuildPeerListUpdater: func(cfg Config, k *yarpcconfig.Kit) (peer.Binder, error) {
+			opts, err := cfg.options()
+			if err != nil {
+				return nil, err
+			}
+			return Bind(cfg.Name, opts...), nil
+		},
+	}
+}
+
+func (c Config) options() ([]Option, error) {
+	if c.Name == "" {
+		return nil, errors.New("name is required")
+	}
+	if c.Port < 0 || c.Port > 65535 {
+		return nil, fmt.Errorf("port must be between 1 and 65535, got %d", c.Port)
+	}
+	if c.MinInterval < 0 {
+		return nil, fmt.Errorf("minInterval must not be negative, got %v", c.MinInterval)
+	}
+	if c.MaxInterval < 0 {
+		return nil, fmt.Errorf("maxInterval must not be negative, got %v", c.MaxInterval)
+	}
+
+	var opts []Option
+	if c.Port > 0 {
+		opts = append(opts, Port(c.Port))
+	}
+	if len(c.Servers) > 0 {
+		opts = append(opts, WithResolver(NewResolver(c.Servers...)))
+	}
+	if c.MinInterval > 0 {
+		opts = append(opts, MinInterval(c.MinInterval))
+	}
+	if c.MaxInterval > 0 {
+		opts = append(opts, MaxInterval(c.MaxInterval))
+	}
+	return opts, nil
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package peerdns
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/peer/hostport"
+	"go.uber.org/yarpc/yarpcconfig"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestSpec(t *testing.T) {
+	server := newStubServer(t)
+	defer server.Close()
+	server.Set("example.com", dnsmessage.TypeA, 10,
+		&dnsmessage.AResource{A: [4]byte{10, 0, 0, 1}},
+	)
+	server.Set("_http._tcp.example.com", dnsmessage.TypeSRV, 10,
+		&dnsmessage.SRVResource{Target: dnsmessage.MustNewName("a.example.com."), Port: 8080},
+		&dnsmessage.SRVResource{Target: dnsmessage.MustNewName("b.example.com."), Port: 8080, Weight: 5},
+	)
+
+	tests := []struct {
+		desc      string
+		cfg       Config
+		wantPeers []peer.Identifier
+		wantErr   string
+	}{
+		{
+			desc: "srv",
+			cfg:  Config{Name: "_http._tcp.example.com", Servers: []string{server.Addr()}},
+			wantPeers: []peer.Identifier{
+				hostport.PeerIdentifier("a.example.com:8080"),
+				hostport.IdentifyWeighted("b.example.com:8080", 5),
+			},
+		},
+		{
+			desc: "address records",
+			cfg: Config{
+				Name:        "example.com",
+				Port:        9090,
+				Servers:     []string{server.Addr()},
+				MinInterval: time.Second,
+				MaxInterval: time.Minute,
+			},
+			wantPeers: ids("10.0.0.1:9090"),
+		},
+		{
+			desc:    "missing name",
+			wantErr: "name is required",
+		},
+		{
+			desc:    "invalid port",
+			cfg:     Config{Name: "example.com", Port: 70000},
+			wantErr: "port must be between 1 and 65535, got 70000",
+		},
+		{
+			desc:    "negative min interval",
+			cfg:     Config{Name: "example.com", MinInterval: -time.Second},
+			wantErr: "minInterval must not be negative",
+		},
+		{
+			desc:    "negative max interval",
+			cfg:     Config{Name: "example.com", MaxInterval: -time.Second},
+			wantErr: "maxInterval must not be negative",
+		},
+	}
+
+	build := Spec().BuildPeerListUpdater.(func(Config, *yarpcconfig.Kit) (peer.Binder, error))
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			binder, err := build(tt.cfg, nil)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			pl := newRecordingList()
+			u := binder(pl)
+			require.NoError(t, u.Start())
+			assert.Equal(t, peer.ListUpdates{Additions: tt.wantPeers}, pl.next(t))
+			require.NoError(t, u.Stop())
+		})
+	}
+}
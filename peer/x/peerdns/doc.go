@@ -0,0 +1,47 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package peerdns provides a peer list updater that keeps a peer list in
+// sync with the DNS records of a name.
+//
+// By default, the SRV records of the name are resolved and each record's
+// target and port becomes a peer, weighted by the record's weight for peer
+// lists that honor weights. Records with a weight of 0 are given the minimum
+// weight of 1, so they are chosen rarely but never drained. With the Port
+// option, the A and AAAA records of the name are resolved instead and each
+// address is combined with the given port.
+//
+// 	chooser := peer.Bind(
+// 		roundrobin.New(transport),
+// 		peerdns.Bind("_http._tcp.myservice.example.com"),
+// 	)
+//
+// The name is resolved again as its records expire, with some jitter, and
+// the difference between the old and new set of peers is pushed to the peer
+// list. Refreshes are bounded by MinInterval and MaxInterval. If a lookup
+// fails or returns no records, the peer list keeps its current peers.
+//
+// The Resolver used for lookups can be replaced with WithResolver. The
+// default Resolver queries the system's name servers directly so that the
+// TTL of each record is known.
+//
+// Register Spec with a yarpcconfig.Configurator to use this updater from
+// configuration.
+package peerdns
@@ -0,0 +1,375 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package peerdns
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	_resolvConf    = "/etc/resolv.conf"
+	_defaultServer = "127.0.0.1:53"
+
+	// Maximum size of UDP responses advertised with EDNS(0).
+	_maxUDPSize = 4096
+
+	// Maximum time spent on each exchange with a name server.
+	_exchangeTimeout = 5 * time.Second
+)
+
+// errNoSuchHost is returned when the name server reports that a name does
+// not exist.
+var errNoSuchHost = errors.New("no such host")
+
+// Resolver looks up the DNS records used to build peer lists.
+//
+// Names are always fully qualified.
+type Resolver interface {
+	// LookupSRV returns the SRV records for the given name.
+	LookupSRV(ctx context.Context, name string) ([]SRVRecord, error)
+
+	// LookupIP returns the A and AAAA records for the given name.
+	LookupIP(ctx context.Context, name string) ([]IPRecord, error)
+}
+
+// SRVRecord is a resolved SRV record.
+type SRVRecord struct {
+	Target string
+	Port   uint16
+	Weight uint16
+	TTL    time.Duration
+}
+
+// IPRecord is a resolved A or AAAA record.
+type IPRecord struct {
+	IP  net.IP
+	TTL time.Duration
+}
+
+// NewResolver builds a Resolver that queries the given name servers, in
+// order, until one of them answers. Servers are host:port pairs.
+//
+// If no servers are given, the name servers listed in /etc/resolv.conf are
+// used.
+//
+// Unlike the resolver of the net package, this Resolver reports the TTL of
+// each record so that peer lists can be refreshed as records expire. It does
+// not apply search domains or consult /etc/hosts.
+func NewResolver(servers ...string) Resolver {
+	if len(servers) == 0 {
+		servers = systemServers()
+	}
+	return &client{
+		servers: servers,
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// systemServers returns the name servers listed in /etc/resolv.conf.
+func systemServers() []string {
+	f, err := os.Open(_resolvConf)
+	if err != nil {
+		return []string{_defaultServer}
+	}
+	defer f.Close()
+
+	var servers []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			servers = append(servers, net.JoinHostPort(fields[1], "53"))
+		}
+	}
+	if len(servers) == 0 {
+		return []string{_defaultServer}
+	}
+	return servers
+}
+
+type client struct {
+	servers []string
+
+	randMu sync.Mutex
+	rand   *rand.Rand
+}
+
+func (c *client) LookupSRV(ctx context.Context, name string) ([]SRVRecord, error) {
+	var records []SRVRecord
+	err := c.query(ctx, name, dnsmessage.TypeSRV, func(p *dnsmessage.Parser, h dnsmessage.ResourceHeader) error {
+		if h.Type != dnsmessage.TypeSRV {
+			return p.SkipAnswer()
+		}
+		srv, err := p.SRVResource()
+		if err != nil {
+			return err
+		}
+		records = append(records, SRVRecord{
+			Target: strings.TrimSuffix(srv.Target.String(), "."),
+			Port:   srv.Port,
+			Weight: srv.Weight,
+			TTL:    time.Duration(h.TTL) * time.Second,
+		})
+		return nil
+	})
+	return records, err
+}
+
+func (c *client) LookupIP(ctx context.Context, name string) ([]IPRecord, error) {
+	var records []IPRecord
+	parse := func(p *dnsmessage.Parser, h dnsmessage.ResourceHeader) error {
+		ttl := time.Duration(h.TTL) * time.Second
+		switch h.Type {
+		case dnsmessage.TypeA:
+			a, err := p.AResource()
+			if err != nil {
+				return err
+			}
+			records = append(records, IPRecord{IP: net.IP(a.A[:]), TTL: ttl})
+		case dnsmessage.TypeAAAA:
+			aaaa, err := p.AAAAResource()
+			if err != nil {
+				return err
+			}
+			records = append(records, IPRecord{IP: net.IP(aaaa.AAAA[:]), TTL: ttl})
+		default:
+			return p.SkipAnswer()
+		}
+		return nil
+	}
+
+	// Hosts with only IPv4 or only IPv6 addresses are common, so the lookup
+	// only fails if both queries do.
+	errA := c.query(ctx, name, dnsmessage.TypeA, parse)
+	errAAAA := c.query(ctx, name, dnsmessage.TypeAAAA, parse)
+	if errA != nil && errAAAA != nil {
+		return nil, multierr.Append(errA, errAAAA)
+	}
+	return records, nil
+}
+
+// query sends a query to each server until one of them answers, calling
+// parse with each answer of a successful response.
+func (c *client) query(ctx context.Context, name string, qtype dnsmessage.Type, parse func(*dnsmessage.Parser, dnsmessage.ResourceHeader) error) error {
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+	qname, err := dnsmessage.NewName(name)
+	if err != nil {
+		return fmt.Errorf("invalid DNS name %q: %v", name, err)
+	}
+
+	var errs error
+	for _, server := range c.servers {
+		res, err := c.exchange(ctx, server, qname, qtype)
+		if err == nil {
+			err = parseResponse(res, parse)
+		}
+		if err == nil {
+			return nil
+		}
+		if err == errNoSuchHost {
+			return fmt.Errorf("lookup %v on %v: %v", name, server, err)
+		}
+		errs = multierr.Append(errs, fmt.Errorf("lookup %v on %v: %v", name, server, err))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return errs
+}
+
+func parseResponse(res []byte, parse func(*dnsmessage.Parser, dnsmessage.ResourceHeader) error) error {
+	var p dnsmessage.Parser
+	h, err := p.Start(res)
+	if err != nil {
+		return err
+	}
+	switch h.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return errNoSuchHost
+	default:
+		return fmt.Errorf("server responded with %v", h.RCode)
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return err
+	}
+	for {
+		ah, err := p.AnswerHeader()
+		if err == dnsmessage.ErrSectionDone {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := parse(&p, ah); err != nil {
+			return err
+		}
+	}
+}
+
+// exchange sends a query to a server over UDP, falling back to TCP if the
+// response was truncated.
+func (c *client) exchange(ctx context.Context, server string, name dnsmessage.Name, qtype dnsmessage.Type) ([]byte, error) {
+	c.randMu.Lock()
+	id := uint16(c.rand.Uint32())
+	c.randMu.Unlock()
+
+	req, err := newQuery(id, name, qtype)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := exchangeUDP(ctx, server, req)
+	if err != nil {
+		return nil, err
+	}
+	h, err := checkResponse(id, res)
+	if err != nil {
+		return nil, err
+	}
+	if !h.Truncated {
+		return res, nil
+	}
+
+	res, err = exchangeTCP(ctx, server, req)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := checkResponse(id, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func newQuery(id uint16, name dnsmessage.Name, qtype dnsmessage.Type) ([]byte, error) {
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id, RecursionDesired: true})
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := b.Question(dnsmessage.Question{
+		Name:  name,
+		Type:  qtype,
+		Class: dnsmessage.ClassINET,
+	}); err != nil {
+		return nil, err
+	}
+	if err := b.StartAdditionals(); err != nil {
+		return nil, err
+	}
+	var opt dnsmessage.ResourceHeader
+	if err := opt.SetEDNS0(_maxUDPSize, dnsmessage.RCodeSuccess, false); err != nil {
+		return nil, err
+	}
+	if err := b.OPTResource(opt, dnsmessage.OPTResource{}); err != nil {
+		return nil, err
+	}
+	return b.Finish()
+}
+
+func checkResponse(id uint16, res []byte) (dnsmessage.Header, error) {
+	var p dnsmessage.Parser
+	h, err := p.Start(res)
+	if err != nil {
+		return h, err
+	}
+	if !h.Response || h.ID != id {
+		return h, errors.New("mismatched response")
+	}
+	return h, nil
+}
+
+func dial(ctx context.Context, network, server string) (net.Conn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, server)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(_exchangeTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func exchangeUDP(ctx context.Context, server string, req []byte) ([]byte, error) {
+	conn, err := dial(ctx, "udp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write(req); err != nil {
+		return nil, err
+	}
+	res := make([]byte, _maxUDPSize)
+	n, err := conn.Read(res)
+	if err != nil {
+		return nil, err
+	}
+	return res[:n], nil
+}
+
+func exchangeTCP(ctx context.Context, server string, req []byte) ([]byte, error) {
+	conn, err := dial(ctx, "tcp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// Messages sent over TCP are prefixed with their length.
+	msg := make([]byte, 2+len(req))
+	binary.BigEndian.PutUint16(msg, uint16(len(req)))
+	copy(msg[2:], req)
+	if _, err := conn.Write(msg); err != nil {
+		return nil, err
+	}
+
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, err
+	}
+	res := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
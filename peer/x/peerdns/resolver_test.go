@@ -0,0 +1,123 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package peerdns
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestResolverLookupSRV(t *testing.T) {
+	server := newStubServer(t)
+	defer server.Close()
+	server.Set("_http._tcp.example.com", dnsmessage.TypeSRV, 30,
+		&dnsmessage.SRVResource{Target: dnsmessage.MustNewName("a.example.com."), Port: 8080},
+		&dnsmessage.SRVResource{Target: dnsmessage.MustNewName("b.example.com."), Port: 8081, Weight: 5},
+	)
+
+	records, err := NewResolver(server.Addr()).LookupSRV(context.Background(), "_http._tcp.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, []SRVRecord{
+		{Target: "a.example.com", Port: 8080, TTL: 30 * time.Second},
+		{Target: "b.example.com", Port: 8081, Weight: 5, TTL: 30 * time.Second},
+	}, records)
+}
+
+func TestResolverLookupIP(t *testing.T) {
+	server := newStubServer(t)
+	defer server.Close()
+	server.Set("example.com", dnsmessage.TypeA, 10,
+		&dnsmessage.AResource{A: [4]byte{10, 0, 0, 1}},
+	)
+	server.Set("example.com", dnsmessage.TypeAAAA, 20,
+		&dnsmessage.AAAAResource{AAAA: [16]byte{0xfd, 15: 1}},
+	)
+	server.Set("v4.example.com", dnsmessage.TypeA, 10,
+		&dnsmessage.AResource{A: [4]byte{10, 0, 0, 2}},
+	)
+
+	r := NewResolver(server.Addr())
+
+	records, err := r.LookupIP(context.Background(), "example.com.")
+	require.NoError(t, err)
+	assert.Equal(t, []IPRecord{
+		{IP: net.IP{10, 0, 0, 1}, TTL: 10 * time.Second},
+		{IP: net.ParseIP("fd00::1"), TTL: 20 * time.Second},
+	}, records)
+
+	records, err = r.LookupIP(context.Background(), "v4.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, []IPRecord{
+		{IP: net.IP{10, 0, 0, 2}, TTL: 10 * time.Second},
+	}, records)
+}
+
+func TestResolverTruncatedFallsBackToTCP(t *testing.T) {
+	server := newStubServer(t)
+	defer server.Close()
+	server.Set("example.com", dnsmessage.TypeA, 10,
+		&dnsmessage.AResource{A: [4]byte{10, 0, 0, 1}},
+	)
+	server.SetTruncate(true)
+
+	records, err := NewResolver(server.Addr()).LookupIP(context.Background(), "example.com")
+	require.NoError(t, err)
+	assert.Equal(t, []IPRecord{
+		{IP: net.IP{10, 0, 0, 1}, TTL: 10 * time.Second},
+	}, records)
+}
+
+func TestResolverNoSuchHost(t *testing.T) {
+	server := newStubServer(t)
+	defer server.Close()
+
+	_, err := NewResolver(server.Addr()).LookupSRV(context.Background(), "missing.example.com")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "lookup missing.example.com. on "+server.Addr()+": no such host")
+}
+
+func TestResolverTriesNextServer(t *testing.T) {
+	server := newStubServer(t)
+	defer server.Close()
+	server.Set("example.com", dnsmessage.TypeSRV, 10,
+		&dnsmessage.SRVResource{Target: dnsmessage.MustNewName("a.example.com."), Port: 8080},
+	)
+
+	// Grab a port that nothing listens on.
+	dead, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	deadAddr := dead.LocalAddr().String()
+	require.NoError(t, dead.Close())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	records, err := NewResolver(deadAddr, server.Addr()).LookupSRV(ctx, "example.com")
+	require.NoError(t, err)
+	assert.Equal(t, []SRVRecord{
+		{Target: "a.example.com", Port: 8080, TTL: 10 * time.Second},
+	}, records)
+}
@@ -0,0 +1,193 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package peerdns
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// stubServer is a DNS server for tests that serves a fixed set of records
+// over UDP and TCP.
+type stubServer struct {
+	udp net.PacketConn
+	tcp net.Listener
+
+	mu       sync.Mutex
+	answers  map[dnsmessage.Type]map[string][]dnsmessage.Resource
+	truncate bool // truncate all UDP responses
+}
+
+func newStubServer(t *testing.T) *stubServer {
+	udp, tcp := listenUDPAndTCP(t)
+	s := &stubServer{
+		udp:     udp,
+		tcp:     tcp,
+		answers: make(map[dnsmessage.Type]map[string][]dnsmessage.Resource),
+	}
+	go s.serveUDP()
+	go s.serveTCP()
+	return s
+}
+
+// listenUDPAndTCP binds a UDP socket and a TCP listener to the same port, as
+// resolvers retry truncated responses over TCP on the port they queried. The
+// port picked for UDP may already be taken for TCP, so we retry with another.
+func listenUDPAndTCP(t *testing.T) (net.PacketConn, net.Listener) {
+	const attempts = 10
+	var lastErr error
+	for i := 0; i < attempts; i++ {
+		udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+		require.NoError(t, err)
+		tcp, err := net.Listen("tcp", udp.LocalAddr().String())
+		if err == nil {
+			return udp, tcp
+		}
+		udp.Close()
+		lastErr = err
+	}
+	require.FailNow(t, "failed to bind UDP and TCP to the same port", "last error: %v", lastErr)
+	return nil, nil
+}
+
+func (s *stubServer) Addr() string {
+	return s.udp.LocalAddr().String()
+}
+
+func (s *stubServer) Close() {
+	s.udp.Close()
+	s.tcp.Close()
+}
+
+// Set replaces the records of the given type for a name.
+func (s *stubServer) Set(name string, rtype dnsmessage.Type, ttl uint32, bodies ...dnsmessage.ResourceBody) {
+	name = strings.TrimSuffix(name, ".") + "."
+	resources := make([]dnsmessage.Resource, len(bodies))
+	for i, body := range bodies {
+		resources[i] = dnsmessage.Resource{
+			Header: dnsmessage.ResourceHeader{
+				Name:  dnsmessage.MustNewName(name),
+				Type:  rtype,
+				Class: dnsmessage.ClassINET,
+				TTL:   ttl,
+			},
+			Body: body,
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.answers[rtype] == nil {
+		s.answers[rtype] = make(map[string][]dnsmessage.Resource)
+	}
+	s.answers[rtype][name] = resources
+}
+
+func (s *stubServer) SetTruncate(truncate bool) {
+	s.mu.Lock()
+	s.truncate = truncate
+	s.mu.Unlock()
+}
+
+func (s *stubServer) serveUDP() {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := s.udp.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if res, err := s.respond(buf[:n], true); err == nil {
+			s.udp.WriteTo(res, addr)
+		}
+	}
+}
+
+func (s *stubServer) serveTCP() {
+	for {
+		conn, err := s.tcp.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			var length [2]byte
+			if _, err := io.ReadFull(conn, length[:]); err != nil {
+				return
+			}
+			req := make([]byte, binary.BigEndian.Uint16(length[:]))
+			if _, err := io.ReadFull(conn, req); err != nil {
+				return
+			}
+			res, err := s.respond(req, false)
+			if err != nil {
+				return
+			}
+			binary.BigEndian.PutUint16(length[:], uint16(len(res)))
+			conn.Write(append(length[:], res...))
+		}()
+	}
+}
+
+func (s *stubServer) respond(req []byte, udp bool) ([]byte, error) {
+	var msg dnsmessage.Message
+	if err := msg.Unpack(req); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := dnsmessage.Message{
+		Header: dnsmessage.Header{
+			ID:       msg.ID,
+			Response: true,
+		},
+		Questions: msg.Questions,
+	}
+	if len(msg.Questions) != 1 {
+		res.RCode = dnsmessage.RCodeFormatError
+		return res.Pack()
+	}
+	q := msg.Questions[0]
+
+	known := false
+	for _, names := range s.answers {
+		if _, ok := names[q.Name.String()]; ok {
+			known = true
+		}
+	}
+	switch {
+	case !known:
+		res.RCode = dnsmessage.RCodeNameError
+	case udp && s.truncate:
+		res.Truncated = true
+	default:
+		res.Answers = s.answers[q.Type][q.Name.String()]
+	}
+	return res.Pack()
+}
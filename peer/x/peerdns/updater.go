@@ -0,0 +1,359 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package peerdns
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/peer/hostport"
+	"go.uber.org/yarpc/pkg/lifecycle"
+	"go.uber.org/zap"
+)
+
+const (
+	_defaultMinInterval = time.Second
+	_defaultMaxInterval = 5 * time.Minute
+
+	// Refreshes are delayed by up to this fraction of their interval so that
+	// instances sharing a name do not all query it at once.
+	_jitter = 0.1
+
+	_resolveTimeout = 10 * time.Second
+)
+
+// Option customizes the behavior of a DNS peer list updater.
+type Option func(*options)
+
+type options struct {
+	port        int
+	resolver    Resolver
+	minInterval time.Duration
+	maxInterval time.Duration
+	logger      *zap.Logger
+}
+
+// Port resolves A and AAAA records for the name instead of SRV records, and
+// uses the given port for all of the resolved addresses.
+//
+// By default, SRV records are resolved, which specify a port for each peer.
+func Port(port int) Option {
+	return func(opts *options) {
+		opts.port = port
+	}
+}
+
+// WithResolver sets the Resolver used to look up the name.
+//
+// Defaults to NewResolver(), which queries the system's name servers.
+func WithResolver(r Resolver) Option {
+	return func(opts *options) {
+		opts.resolver = r
+	}
+}
+
+// MinInterval sets the minimum time between lookups. Records with a shorter
+// TTL are refreshed at this interval, and failed lookups are retried at this
+// interval.
+//
+// Defaults to 1 second.
+func MinInterval(d time.Duration) Option {
+	return func(opts *options) {
+		opts.minInterval = d
+	}
+}
+
+// MaxInterval sets the maximum time between lookups. Records with a longer
+// TTL are refreshed at this interval.
+//
+// Defaults to 5 minutes.
+func MaxInterval(d time.Duration) Option {
+	return func(opts *options) {
+		opts.maxInterval = d
+	}
+}
+
+// Logger sets the logger used to report failed lookups.
+//
+// Defaults to a no-op logger.
+func Logger(logger *zap.Logger) Option {
+	return func(opts *options) {
+		opts.logger = logger
+	}
+}
+
+// Bind returns a peer.Binder that binds peer lists to an Updater for the
+// given DNS name, suitable as an argument to peer.Bind.
+func Bind(name string, opts ...Option) peer.Binder {
+	return func(pl peer.List) transport.Lifecycle {
+		return New(name, pl, opts...)
+	}
+}
+
+// Updater is a peer list updater that keeps a peer list in sync with the
+// DNS records of a name.
+type Updater struct {
+	once   *lifecycle.Once
+	name   string
+	pl     peer.List
+	opts   options
+	logger *zap.Logger
+	rand   *rand.Rand
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	// peers is only accessed by Start, the refreshing goroutine, and Stop
+	// once refreshing has ended.
+	peers map[string]uint32
+}
+
+var _ transport.Lifecycle = (*Updater)(nil)
+
+// New builds an Updater that pushes the peers resolved from the given DNS
+// name to the given peer list.
+func New(name string, pl peer.List, opts ...Option) *Updater {
+	options := options{
+		minInterval: _defaultMinInterval,
+		maxInterval: _defaultMaxInterval,
+		logger:      zap.NewNop(),
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.resolver == nil {
+		options.resolver = NewResolver()
+	}
+	if options.minInterval <= 0 {
+		options.minInterval = _defaultMinInterval
+	}
+	if options.maxInterval < options.minInterval {
+		options.maxInterval = options.minInterval
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Updater{
+		once:   lifecycle.NewOnce(),
+		name:   name,
+		pl:     pl,
+		opts:   options,
+		logger: options.logger.With(zap.String("name", name)),
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Start resolves the name, adds the resulting peers to the peer list, and
+// starts refreshing them as their records expire. It fails if the name
+// cannot be resolved.
+func (u *Updater) Start() error {
+	return u.once.Start(u.start)
+}
+
+func (u *Updater) start() error {
+	ttl, err := u.refresh()
+	if err != nil {
+		return err
+	}
+	u.wg.Add(1)
+	go u.watch(u.interval(ttl))
+	return nil
+}
+
+// Stop stops refreshing the peers and removes them from the peer list.
+func (u *Updater) Stop() error {
+	return u.once.Stop(u.stop)
+}
+
+func (u *Updater) stop() error {
+	u.cancel()
+	u.wg.Wait()
+	return u.pl.Update(peer.ListUpdates{Removals: identifiers(u.peers)})
+}
+
+// IsRunning returns whether the updater is refreshing its peers.
+func (u *Updater) IsRunning() bool {
+	return u.once.IsRunning()
+}
+
+func (u *Updater) watch(delay time.Duration) {
+	defer u.wg.Done()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	for {
+		select {
+		case <-u.ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		ttl, err := u.refresh()
+		if err != nil && u.ctx.Err() == nil {
+			u.logger.Error("Failed to resolve peers, keeping previous peers.", zap.Error(err))
+		}
+		timer.Reset(u.interval(ttl))
+	}
+}
+
+// interval returns the time to wait before the next lookup for records with
+// the given TTL.
+func (u *Updater) interval(ttl time.Duration) time.Duration {
+	d := ttl
+	if d < u.opts.minInterval {
+		d = u.opts.minInterval
+	}
+	if d > u.opts.maxInterval {
+		d = u.opts.maxInterval
+	}
+	if jitter := int64(float64(d) * _jitter); jitter > 0 {
+		d += time.Duration(u.rand.Int63n(jitter))
+	}
+	return d
+}
+
+// refresh resolves the name and pushes any changes to its peers to the peer
+// list. It returns the shortest TTL of the resolved records.
+func (u *Updater) refresh() (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(u.ctx, _resolveTimeout)
+	defer cancel()
+
+	peers, ttl, err := u.resolve(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var updates peer.ListUpdates
+	for p, weight := range peers {
+		oldWeight, ok := u.peers[p]
+		switch {
+		case !ok:
+			updates.Additions = append(updates.Additions, identify(p, weight))
+		case oldWeight != weight:
+			updates.Reweights = append(updates.Reweights, identify(p, weight))
+		}
+	}
+	for p := range u.peers {
+		if _, ok := peers[p]; !ok {
+			updates.Removals = append(updates.Removals, hostport.PeerIdentifier(p))
+		}
+	}
+	sortIdentifiers(updates.Additions)
+	sortIdentifiers(updates.Removals)
+	sortIdentifiers(updates.Reweights)
+
+	// Peer lists apply as much of an update as they can even when they
+	// return an error, so the new set of peers is recorded regardless.
+	u.peers = peers
+	if len(updates.Additions) == 0 && len(updates.Removals) == 0 && len(updates.Reweights) == 0 {
+		return ttl, nil
+	}
+	u.logger.Info("Updating peers from DNS.",
+		zap.Int("additions", len(updates.Additions)),
+		zap.Int("removals", len(updates.Removals)),
+		zap.Int("reweights", len(updates.Reweights)))
+	return ttl, u.pl.Update(updates)
+}
+
+// resolve looks up the peers for the name, with their weights, and the
+// shortest TTL of their records. Peers resolved from A and AAAA records have
+// a weight of 1.
+//
+// An SRV weight of 0 means that the target should rarely be chosen when
+// others have weights (RFC 2782), not that it should not be chosen at all,
+// so it becomes the minimum weight of 1 rather than draining the peer.
+func (u *Updater) resolve(ctx context.Context) (map[string]uint32, time.Duration, error) {
+	peers := make(map[string]uint32)
+	var ttls []time.Duration
+
+	if u.opts.port > 0 {
+		records, err := u.opts.resolver.LookupIP(ctx, u.name)
+		if err != nil {
+			return nil, 0, err
+		}
+		port := strconv.Itoa(u.opts.port)
+		for _, r := range records {
+			peers[net.JoinHostPort(r.IP.String(), port)] = 1
+			ttls = append(ttls, r.TTL)
+		}
+	} else {
+		records, err := u.opts.resolver.LookupSRV(ctx, u.name)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, r := range records {
+			weight := uint32(r.Weight)
+			if weight == 0 {
+				weight = 1
+			}
+			peers[net.JoinHostPort(r.Target, strconv.Itoa(int(r.Port)))] = weight
+			ttls = append(ttls, r.TTL)
+		}
+	}
+
+	if len(peers) == 0 {
+		// Removing every peer because of a misconfigured record would take
+		// the service down, so empty answers are treated as failures.
+		return nil, 0, fmt.Errorf("no records found for %q", u.name)
+	}
+
+	ttl := ttls[0]
+	for _, t := range ttls[1:] {
+		if t < ttl {
+			ttl = t
+		}
+	}
+	return peers, ttl, nil
+}
+
+// identify builds the identifier for a peer, carrying its weight if it is
+// not the default weight of 1.
+func identify(hp string, weight uint32) peer.Identifier {
+	if weight != 1 {
+		return hostport.IdentifyWeighted(hp, weight)
+	}
+	return hostport.PeerIdentifier(hp)
+}
+
+func identifiers(peers map[string]uint32) []peer.Identifier {
+	ids := make([]peer.Identifier, 0, len(peers))
+	for p := range peers {
+		ids = append(ids, hostport.PeerIdentifier(p))
+	}
+	sortIdentifiers(ids)
+	return ids
+}
+
+func sortIdentifiers(ids []peer.Identifier) {
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].Identifier() < ids[j].Identifier()
+	})
+}
@@ -0,0 +1,291 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package peerdns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/peer/hostport"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// recordingList is a peer list that reports every update on a channel.
+type recordingList struct {
+	updates chan peer.ListUpdates
+}
+
+func newRecordingList() *recordingList {
+	return &recordingList{updates: make(chan peer.ListUpdates, 10)}
+}
+
+func (l *recordingList) Update(updates peer.ListUpdates) error {
+	l.updates <- updates
+	return nil
+}
+
+func (l *recordingList) next(t *testing.T) peer.ListUpdates {
+	select {
+	case updates := <-l.updates:
+		return updates
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timed out waiting for peer list update")
+		return peer.ListUpdates{}
+	}
+}
+
+func ids(peers ...string) []peer.Identifier {
+	var ids []peer.Identifier
+	for _, p := range peers {
+		ids = append(ids, hostport.PeerIdentifier(p))
+	}
+	return ids
+}
+
+// fakeResolver serves records that can be changed by tests.
+type fakeResolver struct {
+	mu      sync.Mutex
+	srv     []SRVRecord
+	ips     []IPRecord
+	err     error
+	lookups chan struct{}
+}
+
+func newFakeResolver() *fakeResolver {
+	return &fakeResolver{lookups: make(chan struct{}, 100)}
+}
+
+func (r *fakeResolver) LookupSRV(ctx context.Context, name string) ([]SRVRecord, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lookups <- struct{}{}
+	return r.srv, r.err
+}
+
+func (r *fakeResolver) LookupIP(ctx context.Context, name string) ([]IPRecord, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lookups <- struct{}{}
+	return r.ips, r.err
+}
+
+func (r *fakeResolver) set(f func(r *fakeResolver)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f(r)
+	// Discard lookups that happened before the change.
+	for len(r.lookups) > 0 {
+		<-r.lookups
+	}
+}
+
+// waitForLookup waits for the next lookup after the last change.
+func (r *fakeResolver) waitForLookup(t *testing.T) {
+	select {
+	case <-r.lookups:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timed out waiting for lookup")
+	}
+}
+
+func TestUpdaterSRV(t *testing.T) {
+	resolver := newFakeResolver()
+	resolver.srv = []SRVRecord{
+		{Target: "a.example.com", Port: 8080, Weight: 1, TTL: time.Minute},
+		{Target: "b.example.com", Port: 8080, Weight: 1, TTL: time.Minute},
+	}
+
+	pl := newRecordingList()
+	u := New("_http._tcp.example.com", pl,
+		WithResolver(resolver),
+		MinInterval(time.Millisecond),
+		MaxInterval(time.Millisecond),
+	)
+	require.NoError(t, u.Start())
+	assert.True(t, u.IsRunning())
+	assert.Equal(t, peer.ListUpdates{
+		Additions: ids("a.example.com:8080", "b.example.com:8080"),
+	}, pl.next(t))
+
+	resolver.set(func(r *fakeResolver) {
+		r.srv = []SRVRecord{
+			{Target: "b.example.com", Port: 8080, Weight: 1, TTL: time.Minute},
+			{Target: "c.example.com", Port: 9090, Weight: 1, TTL: time.Minute},
+		}
+	})
+	assert.Equal(t, peer.ListUpdates{
+		Additions: ids("c.example.com:9090"),
+		Removals:  ids("a.example.com:8080"),
+	}, pl.next(t))
+
+	// Failed and empty lookups keep the previous peers.
+	resolver.set(func(r *fakeResolver) { r.err = errors.New("great sadness") })
+	resolver.waitForLookup(t)
+	resolver.set(func(r *fakeResolver) { r.srv, r.err = nil, nil })
+	resolver.waitForLookup(t)
+	assert.Empty(t, pl.updates, "no updates expected")
+
+	require.NoError(t, u.Stop())
+	assert.False(t, u.IsRunning())
+	assert.Equal(t, peer.ListUpdates{
+		Removals: ids("b.example.com:8080", "c.example.com:9090"),
+	}, pl.next(t))
+}
+
+func TestUpdaterSRVWeights(t *testing.T) {
+	resolver := newFakeResolver()
+	resolver.srv = []SRVRecord{
+		{Target: "a.example.com", Port: 8080, Weight: 1, TTL: time.Minute},
+		{Target: "b.example.com", Port: 8080, Weight: 5, TTL: time.Minute},
+	}
+
+	pl := newRecordingList()
+	u := New("_http._tcp.example.com", pl,
+		WithResolver(resolver),
+		MinInterval(time.Millisecond),
+		MaxInterval(time.Millisecond),
+	)
+	require.NoError(t, u.Start())
+	assert.Equal(t, peer.ListUpdates{
+		Additions: []peer.Identifier{
+			hostport.PeerIdentifier("a.example.com:8080"),
+			hostport.IdentifyWeighted("b.example.com:8080", 5),
+		},
+	}, pl.next(t))
+
+	resolver.set(func(r *fakeResolver) {
+		r.srv = []SRVRecord{
+			{Target: "a.example.com", Port: 8080, Weight: 0, TTL: time.Minute},
+			{Target: "b.example.com", Port: 8080, Weight: 2, TTL: time.Minute},
+		}
+	})
+	assert.Equal(t, peer.ListUpdates{
+		Reweights: []peer.Identifier{hostport.IdentifyWeighted("b.example.com:8080", 2)},
+	}, pl.next(t), "a weight of 0 must stay the minimum weight of 1")
+
+	require.NoError(t, u.Stop())
+	assert.Equal(t, peer.ListUpdates{
+		Removals: ids("a.example.com:8080", "b.example.com:8080"),
+	}, pl.next(t))
+}
+
+func TestUpdaterIP(t *testing.T) {
+	resolver := newFakeResolver()
+	resolver.ips = []IPRecord{
+		{IP: net.ParseIP("10.0.0.1"), TTL: time.Minute},
+		{IP: net.ParseIP("fd00::1"), TTL: time.Minute},
+	}
+
+	pl := newRecordingList()
+	u := New("example.com", pl, WithResolver(resolver), Port(8080))
+	require.NoError(t, u.Start())
+	assert.Equal(t, peer.ListUpdates{
+		Additions: ids("10.0.0.1:8080", "[fd00::1]:8080"),
+	}, pl.next(t))
+	require.NoError(t, u.Stop())
+	assert.Equal(t, peer.ListUpdates{
+		Removals: ids("10.0.0.1:8080", "[fd00::1]:8080"),
+	}, pl.next(t))
+}
+
+func TestUpdaterStartErrors(t *testing.T) {
+	t.Run("lookup error", func(t *testing.T) {
+		resolver := newFakeResolver()
+		resolver.err = errors.New("great sadness")
+		pl := newRecordingList()
+		err := New("example.com", pl, WithResolver(resolver)).Start()
+		assert.EqualError(t, err, "great sadness")
+		assert.Empty(t, pl.updates, "no updates expected")
+	})
+
+	t.Run("no records", func(t *testing.T) {
+		pl := newRecordingList()
+		err := New("example.com", pl, WithResolver(newFakeResolver())).Start()
+		assert.EqualError(t, err, `no records found for "example.com"`)
+		assert.Empty(t, pl.updates, "no updates expected")
+	})
+}
+
+func TestUpdaterInterval(t *testing.T) {
+	u := New("example.com", newRecordingList(),
+		WithResolver(newFakeResolver()),
+		MinInterval(time.Second),
+		MaxInterval(time.Minute),
+	)
+
+	tests := []struct {
+		ttl  time.Duration
+		want time.Duration
+	}{
+		{ttl: 0, want: time.Second},
+		{ttl: 500 * time.Millisecond, want: time.Second},
+		{ttl: 30 * time.Second, want: 30 * time.Second},
+		{ttl: time.Hour, want: time.Minute},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 10; i++ {
+			got := u.interval(tt.ttl)
+			assert.True(t, got >= tt.want && got < tt.want+tt.want/10,
+				"interval for TTL %v: got %v, want %v plus up to 10%% jitter", tt.ttl, got, tt.want)
+		}
+	}
+}
+
+func TestUpdaterWithStubServer(t *testing.T) {
+	server := newStubServer(t)
+	defer server.Close()
+	server.Set("_http._tcp.example.com", dnsmessage.TypeSRV, 1,
+		&dnsmessage.SRVResource{Target: dnsmessage.MustNewName("a.example.com."), Port: 8080},
+		&dnsmessage.SRVResource{Target: dnsmessage.MustNewName("c.example.com."), Port: 8080, Weight: 3},
+	)
+
+	pl := newRecordingList()
+	u := New("_http._tcp.example.com", pl,
+		WithResolver(NewResolver(server.Addr())),
+		MinInterval(time.Millisecond),
+		MaxInterval(time.Millisecond),
+	)
+	require.NoError(t, u.Start())
+	defer u.Stop()
+	assert.Equal(t, peer.ListUpdates{
+		Additions: []peer.Identifier{
+			hostport.PeerIdentifier("a.example.com:8080"),
+			hostport.IdentifyWeighted("c.example.com:8080", 3),
+		},
+	}, pl.next(t))
+
+	server.Set("_http._tcp.example.com", dnsmessage.TypeSRV, 1,
+		&dnsmessage.SRVResource{Target: dnsmessage.MustNewName("b.example.com."), Port: 8080, Weight: 2},
+		&dnsmessage.SRVResource{Target: dnsmessage.MustNewName("c.example.com."), Port: 8080},
+	)
+	assert.Equal(t, peer.ListUpdates{
+		Additions: []peer.Identifier{hostport.IdentifyWeighted("b.example.com:8080", 2)},
+		Removals:  ids("a.example.com:8080"),
+		Reweights: ids("c.example.com:8080"),
+	}, pl.next(t))
+}
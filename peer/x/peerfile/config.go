@@ -0,0 +1,75 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package peerfile
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/yarpcconfig"
+)
+
+// Config describes how to build a file peer list updater.
+//
+//  path: /etc/myservice/peers.yaml
+//  pollInterval: 1s
+//
+// Path is required.
+type Config struct {
+	Path         string        `config:"path,interpolate"`
+	PollInterval time.Duration `config:"pollInterval"`
+}
+
+// Spec returns a configuration specification for the file peer list
+// updater, making it possible to load the peers of any configurable peer
+// list from a file.
+//
+//  cfg := yarpcconfig.New()
+//  cfg.MustRegisterPeerList(roundrobin.Spec())
+//  cfg.MustRegisterPeerListUpdater(peerfile.Spec())
+//
+// This keeps a round-robin peer list in sync with a file:
+//
+//  outbounds:
+//    otherservice:
+//      unary:
+//        http:
+//          url: https://host:port/rpc
+//          round-robin:
+//            file:
+//              path: /etc/otherservice/peers.yaml
+//              pollInterval: 1s
+func Spec() yarpcconfig.PeerListUpdaterSpec {
+	return yarpcconfig.PeerListUpdaterSpec{
+		Name: "file",
+		BuildPeerListUpdater: func(cfg Config, k *yarpcconfig.Kit) (peer.Binder, error) {
+			if cfg.Path == "" {
+				return nil, errors.New("path is required")
+			}
+			if cfg.PollInterval < 0 {
+				return nil, fmt.Errorf("pollInterval must not be negative, got %v", cfg.PollInterval)
+			}
+			return Bind(cfg.Path, PollInterval(cfg.PollInterval)), nil
+		},
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package peerfile
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/yarpcconfig"
+)
+
+func TestSpec(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	path := filepath.Join(dir, "peers.json")
+	writeFile(t, path, `["127.0.0.1:8080"]`)
+
+	tests := []struct {
+		desc    string
+		cfg     Config
+		wantErr string
+	}{
+		{
+			desc: "path only",
+			cfg:  Config{Path: path},
+		},
+		{
+			desc: "poll interval",
+			cfg:  Config{Path: path, PollInterval: time.Second},
+		},
+		{
+			desc:    "missing path",
+			wantErr: "path is required",
+		},
+		{
+			desc:    "negative poll interval",
+			cfg:     Config{Path: path, PollInterval: -time.Second},
+			wantErr: "pollInterval must not be negative",
+		},
+	}
+
+	build := Spec().BuildPeerListUpdater.(func(Config, *yarpcconfig.Kit) (peer.Binder, error))
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			binder, err := build(tt.cfg, nil)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			pl := newRecordingList()
+			u := binder(pl)
+			require.NoError(t, u.Start())
+			assert.Equal(t, peer.ListUpdates{Additions: ids("127.0.0.1:8080")}, pl.next(t))
+			require.NoError(t, u.Stop())
+		})
+	}
+}
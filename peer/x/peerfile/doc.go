@@ -0,0 +1,53 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package peerfile provides a peer list updater that keeps a peer list in
+// sync with a file of host:port pairs.
+//
+// The file holds a JSON or YAML list of peers.
+//
+// 	- 127.0.0.1:8080
+// 	- 127.0.0.1:8081
+//
+// Entries may also carry a weight for peer lists that support weighted
+// peers, like roundrobin.NewWeighted, and a locality for locality-aware peer
+// lists. Entries without a weight have a weight of 1, and changing the
+// weight or locality of a listed peer updates it in place.
+//
+// 	- 127.0.0.1:8080
+// 	- {peer: 127.0.0.1:8081, weight: 10}
+// 	- {peer: 10.1.0.1:8080, locality: us-west-2a}
+//
+// The updater polls the file for changes and pushes the difference between
+// the old and new set of peers to the peer list, so peers can be added and
+// removed without restarting the dispatcher. If the file cannot be read or
+// parsed, the peer list keeps its current peers until the next successful
+// poll. Tools writing the file should replace it atomically, for example by
+// writing to a temporary file and renaming it, so that partial writes are
+// never observed.
+//
+// 	chooser := peer.Bind(
+// 		roundrobin.New(transport),
+// 		peerfile.Bind("/etc/myservice/peers.yaml", peerfile.PollInterval(time.Second)),
+// 	)
+//
+// Register Spec with a yarpcconfig.Configurator to use this updater from
+// configuration.
+package peerfile
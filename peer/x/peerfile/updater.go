@@ -0,0 +1,287 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package peerfile
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/peer/hostport"
+	"go.uber.org/yarpc/peer/locality"
+	"go.uber.org/yarpc/pkg/lifecycle"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v2"
+)
+
+const _defaultPollInterval = 5 * time.Second
+
+// Option customizes the behavior of a file peer list updater.
+type Option func(*options)
+
+type options struct {
+	pollInterval time.Duration
+	logger       *zap.Logger
+}
+
+// PollInterval sets how often the file is checked for changes.
+//
+// Defaults to 5 seconds.
+func PollInterval(d time.Duration) Option {
+	return func(opts *options) {
+		if d > 0 {
+			opts.pollInterval = d
+		}
+	}
+}
+
+// Logger sets the logger used to report files that cannot be read or
+// parsed.
+//
+// Defaults to a no-op logger.
+func Logger(logger *zap.Logger) Option {
+	return func(opts *options) {
+		opts.logger = logger
+	}
+}
+
+// Bind returns a peer.Binder that binds peer lists to an Updater for the
+// file at the given path, suitable as an argument to peer.Bind.
+func Bind(path string, opts ...Option) peer.Binder {
+	return func(pl peer.List) transport.Lifecycle {
+		return New(path, pl, opts...)
+	}
+}
+
+// Updater is a peer list updater that keeps a peer list in sync with the
+// peers listed in a file.
+type Updater struct {
+	once         *lifecycle.Once
+	path         string
+	pl           peer.List
+	pollInterval time.Duration
+	logger       *zap.Logger
+	quit         chan struct{}
+	wg           sync.WaitGroup
+
+	// The following are only accessed by Start, the polling goroutine, and
+	// Stop once polling has ended.
+	contents []byte
+	peers    map[string]peerInfo
+}
+
+var _ transport.Lifecycle = (*Updater)(nil)
+
+// New builds an Updater that pushes the peers listed in the file at the
+// given path to the given peer list.
+func New(path string, pl peer.List, opts ...Option) *Updater {
+	options := options{
+		pollInterval: _defaultPollInterval,
+		logger:       zap.NewNop(),
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &Updater{
+		once:         lifecycle.NewOnce(),
+		path:         path,
+		pl:           pl,
+		pollInterval: options.pollInterval,
+		logger:       options.logger.With(zap.String("path", path)),
+		quit:         make(chan struct{}),
+	}
+}
+
+// Start loads the peers from the file into the peer list and starts watching
+// the file for changes. It fails if the file cannot be loaded.
+func (u *Updater) Start() error {
+	return u.once.Start(u.start)
+}
+
+func (u *Updater) start() error {
+	if err := u.reload(); err != nil {
+		return err
+	}
+	u.wg.Add(1)
+	go u.watch()
+	return nil
+}
+
+// Stop stops watching the file and removes its peers from the peer list.
+func (u *Updater) Stop() error {
+	return u.once.Stop(u.stop)
+}
+
+func (u *Updater) stop() error {
+	close(u.quit)
+	u.wg.Wait()
+	return u.pl.Update(peer.ListUpdates{Removals: identifiers(u.peers)})
+}
+
+// IsRunning returns whether the updater is watching the file.
+func (u *Updater) IsRunning() bool {
+	return u.once.IsRunning()
+}
+
+func (u *Updater) watch() {
+	defer u.wg.Done()
+
+	ticker := time.NewTicker(u.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-u.quit:
+			return
+		case <-ticker.C:
+			if err := u.reload(); err != nil {
+				u.logger.Error("Failed to reload peers file, keeping previous peers.", zap.Error(err))
+			}
+		}
+	}
+}
+
+// reload reads the file and pushes any changes to its peers to the peer
+// list.
+func (u *Updater) reload() error {
+	contents, err := ioutil.ReadFile(u.path)
+	if err != nil {
+		return err
+	}
+	if u.contents != nil && bytes.Equal(contents, u.contents) {
+		return nil
+	}
+	peers, err := parsePeers(contents)
+	if err != nil {
+		return fmt.Errorf("failed to parse peers file %q: %v", u.path, err)
+	}
+
+	var updates peer.ListUpdates
+	for p, info := range peers {
+		oldInfo, ok := u.peers[p]
+		switch {
+		case !ok:
+			updates.Additions = append(updates.Additions, identify(p, info))
+		case oldInfo != info:
+			updates.Reweights = append(updates.Reweights, identify(p, info))
+		}
+	}
+	for p := range u.peers {
+		if _, ok := peers[p]; !ok {
+			updates.Removals = append(updates.Removals, hostport.PeerIdentifier(p))
+		}
+	}
+	sortIdentifiers(updates.Additions)
+	sortIdentifiers(updates.Removals)
+	sortIdentifiers(updates.Reweights)
+
+	// Peer lists apply as much of an update as they can even when they
+	// return an error, so the new set of peers is recorded regardless.
+	u.contents = contents
+	u.peers = peers
+	if len(updates.Additions) == 0 && len(updates.Removals) == 0 && len(updates.Reweights) == 0 {
+		return nil
+	}
+	u.logger.Info("Updating peers from file.",
+		zap.Int("additions", len(updates.Additions)),
+		zap.Int("removals", len(updates.Removals)),
+		zap.Int("reweights", len(updates.Reweights)))
+	return u.pl.Update(updates)
+}
+
+// peerEntry is a single entry of a peers file: either a host:port string or
+// a mapping with a peer and its weight and locality.
+type peerEntry struct {
+	Peer     string  `yaml:"peer"`
+	Weight   *uint32 `yaml:"weight"`
+	Locality string  `yaml:"locality"`
+}
+
+// peerInfo holds the attributes of a peer that are carried by its
+// identifier.
+type peerInfo struct {
+	weight   uint32
+	locality string
+}
+
+func (e *peerEntry) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if err := unmarshal(&e.Peer); err == nil {
+		return nil
+	}
+	type plain peerEntry
+	return unmarshal((*plain)(e))
+}
+
+// parsePeers parses a JSON or YAML list of host:port pairs, optionally with
+// weights and localities.
+func parsePeers(contents []byte) (map[string]peerInfo, error) {
+	var entries []peerEntry
+	if err := yaml.Unmarshal(contents, &entries); err != nil {
+		return nil, err
+	}
+	peers := make(map[string]peerInfo, len(entries))
+	for _, entry := range entries {
+		hp := strings.TrimSpace(entry.Peer)
+		if _, _, err := net.SplitHostPort(hp); err != nil {
+			return nil, fmt.Errorf("invalid peer %q: %v", hp, err)
+		}
+		info := peerInfo{weight: 1, locality: strings.TrimSpace(entry.Locality)}
+		if entry.Weight != nil {
+			info.weight = *entry.Weight
+		}
+		peers[hp] = info
+	}
+	return peers, nil
+}
+
+// identify builds the identifier for a peer, carrying its weight if it is
+// not the default weight of 1 and its locality if it has one.
+func identify(hp string, info peerInfo) peer.Identifier {
+	var pid peer.Identifier = hostport.PeerIdentifier(hp)
+	if info.weight != 1 {
+		pid = hostport.IdentifyWeighted(hp, info.weight)
+	}
+	if info.locality != "" {
+		pid = locality.Identify(pid, info.locality)
+	}
+	return pid
+}
+
+func identifiers(peers map[string]peerInfo) []peer.Identifier {
+	ids := make([]peer.Identifier, 0, len(peers))
+	for p := range peers {
+		ids = append(ids, hostport.PeerIdentifier(p))
+	}
+	sortIdentifiers(ids)
+	return ids
+}
+
+func sortIdentifiers(ids []peer.Identifier) {
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].Identifier() < ids[j].Identifier()
+	})
+}
@@ -0,0 +1,214 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package peerfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/peer/hostport"
+	"go.uber.org/yarpc/peer/locality"
+)
+
+// recordingList is a peer list that reports every update on a channel.
+type recordingList struct {
+	updates chan peer.ListUpdates
+}
+
+func newRecordingList() *recordingList {
+	return &recordingList{updates: make(chan peer.ListUpdates, 10)}
+}
+
+func (l *recordingList) Update(updates peer.ListUpdates) error {
+	l.updates <- updates
+	return nil
+}
+
+func (l *recordingList) next(t *testing.T) peer.ListUpdates {
+	select {
+	case updates := <-l.updates:
+		return updates
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timed out waiting for peer list update")
+		return peer.ListUpdates{}
+	}
+}
+
+func ids(peers ...string) []peer.Identifier {
+	var ids []peer.Identifier
+	for _, p := range peers {
+		ids = append(ids, hostport.PeerIdentifier(p))
+	}
+	return ids
+}
+
+func tempDir(t *testing.T) (dir string, cleanup func()) {
+	dir, err := ioutil.TempDir("", "peerfile")
+	require.NoError(t, err)
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+// writeFile atomically replaces the contents of the file at path.
+func writeFile(t *testing.T, path, contents string) {
+	tmp := path + ".tmp"
+	require.NoError(t, ioutil.WriteFile(tmp, []byte(contents), 0644))
+	require.NoError(t, os.Rename(tmp, path))
+}
+
+func TestUpdater(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	path := filepath.Join(dir, "peers.yaml")
+	writeFile(t, path, "- 127.0.0.1:8080\n- 127.0.0.1:8081\n")
+
+	pl := newRecordingList()
+	u := New(path, pl, PollInterval(time.Millisecond))
+	require.NoError(t, u.Start())
+	assert.True(t, u.IsRunning())
+	assert.Equal(t, peer.ListUpdates{
+		Additions: ids("127.0.0.1:8080", "127.0.0.1:8081"),
+	}, pl.next(t))
+
+	// JSON is accepted as well.
+	writeFile(t, path, `["127.0.0.1:8081", "127.0.0.1:8082"]`)
+	assert.Equal(t, peer.ListUpdates{
+		Additions: ids("127.0.0.1:8082"),
+		Removals:  ids("127.0.0.1:8080"),
+	}, pl.next(t))
+
+	// Invalid files are ignored until they are fixed.
+	writeFile(t, path, "- not a peer\n")
+	writeFile(t, path, "- 127.0.0.1:8081\n- 127.0.0.1:8082\n- 127.0.0.1:8083\n")
+	assert.Equal(t, peer.ListUpdates{
+		Additions: ids("127.0.0.1:8083"),
+	}, pl.next(t))
+
+	require.NoError(t, u.Stop())
+	assert.False(t, u.IsRunning())
+	assert.Equal(t, peer.ListUpdates{
+		Removals: ids("127.0.0.1:8081", "127.0.0.1:8082", "127.0.0.1:8083"),
+	}, pl.next(t))
+}
+
+func TestUpdaterWeights(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	path := filepath.Join(dir, "peers.yaml")
+	writeFile(t, path, "- 127.0.0.1:8080\n- {peer: 127.0.0.1:8081, weight: 5}\n")
+
+	pl := newRecordingList()
+	u := New(path, pl, PollInterval(time.Millisecond))
+	require.NoError(t, u.Start())
+	assert.Equal(t, peer.ListUpdates{
+		Additions: []peer.Identifier{
+			hostport.PeerIdentifier("127.0.0.1:8080"),
+			hostport.IdentifyWeighted("127.0.0.1:8081", 5),
+		},
+	}, pl.next(t))
+
+	writeFile(t, path, `[{"peer": "127.0.0.1:8080", "weight": 0}, {"peer": "127.0.0.1:8081", "weight": 5}]`)
+	assert.Equal(t, peer.ListUpdates{
+		Reweights: []peer.Identifier{hostport.IdentifyWeighted("127.0.0.1:8080", 0)},
+	}, pl.next(t))
+
+	writeFile(t, path, "- 127.0.0.1:8080\n- 127.0.0.1:8081\n")
+	assert.Equal(t, peer.ListUpdates{
+		Reweights: ids("127.0.0.1:8080", "127.0.0.1:8081"),
+	}, pl.next(t))
+
+	require.NoError(t, u.Stop())
+	assert.Equal(t, peer.ListUpdates{
+		Removals: ids("127.0.0.1:8080", "127.0.0.1:8081"),
+	}, pl.next(t))
+}
+
+func TestUpdaterLocalities(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	path := filepath.Join(dir, "peers.yaml")
+	writeFile(t, path, "- {peer: 127.0.0.1:8080, locality: us-east-1a, weight: 2}\n- 127.0.0.1:8081\n")
+
+	pl := newRecordingList()
+	u := New(path, pl, PollInterval(time.Millisecond))
+	require.NoError(t, u.Start())
+	assert.Equal(t, peer.ListUpdates{
+		Additions: []peer.Identifier{
+			locality.Identify(hostport.IdentifyWeighted("127.0.0.1:8080", 2), "us-east-1a"),
+			hostport.PeerIdentifier("127.0.0.1:8081"),
+		},
+	}, pl.next(t))
+
+	writeFile(t, path, "- {peer: 127.0.0.1:8080, locality: us-east-1b, weight: 2}\n- 127.0.0.1:8081\n")
+	assert.Equal(t, peer.ListUpdates{
+		Reweights: []peer.Identifier{
+			locality.Identify(hostport.IdentifyWeighted("127.0.0.1:8080", 2), "us-east-1b"),
+		},
+	}, pl.next(t))
+
+	require.NoError(t, u.Stop())
+}
+
+func TestUpdaterStartErrors(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	tests := []struct {
+		desc     string
+		contents string // file is not created if empty
+		wantErr  string
+	}{
+		{
+			desc:    "missing file",
+			wantErr: "no such file or directory",
+		},
+		{
+			desc:     "not a list",
+			contents: "peers: 127.0.0.1:8080",
+			wantErr:  "failed to parse peers file",
+		},
+		{
+			desc:     "missing port",
+			contents: "- 127.0.0.1",
+			wantErr:  `invalid peer "127.0.0.1"`,
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			path := filepath.Join(dir, string('a'+rune(i)))
+			if tt.contents != "" {
+				writeFile(t, path, tt.contents)
+			}
+
+			pl := newRecordingList()
+			err := New(path, pl).Start()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+			assert.Empty(t, pl.updates, "no updates expected")
+		})
+	}
+}
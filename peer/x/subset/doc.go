@@ -0,0 +1,42 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package subset provides a peer list decorator that only passes a
+// deterministic subset of its peers to the peer list it decorates, so that
+// clients of services with many instances do not each retain and connect to
+// every instance.
+//
+// The subset is chosen by deterministic subsetting. Clients are numbered
+// with ClientIndex and grouped in rounds of as many clients as there are
+// disjoint subsets of the peers. Each round shuffles the peers in its own
+// way and gives each of its clients a distinct slice of them, so every peer
+// is retained by the same number of clients of a full round. Adding or
+// removing a peer changes at most one peer of any subset, unless it changes
+// the number of subsets that fit in the peers, which reshuffles every
+// subset.
+//
+// 	chooser := peer.Bind(
+// 		subset.New(roundrobin.New(transport),
+// 			subset.Size(20),
+// 			subset.ClientIndex(instanceIndex),
+// 		),
+// 		peer.BindPeers(peers),
+// 	)
+package subset
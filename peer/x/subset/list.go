@@ -0,0 +1,285 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package subset
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"go.uber.org/multierr"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/introspection"
+	"go.uber.org/yarpc/internal/strhash"
+	"go.uber.org/yarpc/peer/peerlist/v2"
+)
+
+const _name = "subset"
+
+// Option customizes the behavior of a subset peer list.
+type Option func(*options)
+
+type options struct {
+	size        int
+	clientIndex uint64
+}
+
+// Size sets the largest number of peers passed to the decorated list.
+//
+// Defaults to 20.
+func Size(n int) Option {
+	return func(opts *options) {
+		if n > 0 {
+			opts.size = n
+		}
+	}
+}
+
+// ClientIndex sets the index of this client, which determines its subset.
+// The instances of a client must be numbered consecutively from zero, like
+// the ordinals of a stateful set or the shard numbers of a job, for the
+// instances of the service they call to be spread evenly over them.
+//
+// Defaults to a hash of the host name, which only spreads the instances of
+// the service evenly on average.
+func ClientIndex(i int) Option {
+	return func(opts *options) {
+		if i >= 0 {
+			opts.clientIndex = uint64(i)
+		}
+	}
+}
+
+// List is a peer list decorator that passes a deterministic subset of its
+// peers to the list it decorates, and chooses peers from that list.
+type List struct {
+	list peer.ChooserList
+	opts options
+
+	mu sync.Mutex
+	// peers holds all peers added to this list, by identifier.
+	peers map[string]peer.Identifier
+	// subset holds the peers passed to the decorated list, by identifier.
+	subset map[string]peer.Identifier
+}
+
+var (
+	_ peer.ChooserList                    = (*List)(nil)
+	_ introspection.IntrospectableChooser = (*List)(nil)
+)
+
+// New decorates the given peer list so that it only receives a subset of
+// the peers.
+func New(list peer.ChooserList, opts ...Option) *List {
+	options := options{size: 20}
+	if hostname, err := os.Hostname(); err == nil {
+		options.clientIndex = strhash.Sum64(hostname)
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &List{
+		list:   list,
+		opts:   options,
+		peers:  make(map[string]peer.Identifier),
+		subset: make(map[string]peer.Identifier),
+	}
+}
+
+// Update applies the given additions, removals and reweights to the peers
+// of the list, and passes the resulting changes to the subset on to the
+// decorated list.
+//
+// The changes are passed on one peer at a time so that the subset recorded
+// by this list always matches the peers the decorated list holds, even if
+// it fails to apply some of them. Changes that failed are retried by the
+// next update.
+func (l *List) Update(updates peer.ListUpdates) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	peers := make(map[string]peer.Identifier, len(l.peers)+len(updates.Additions))
+	for id, pid := range l.peers {
+		peers[id] = pid
+	}
+
+	var errs error
+	for _, pid := range updates.Removals {
+		if _, ok := peers[pid.Identifier()]; !ok {
+			errs = multierr.Append(errs, peer.ErrPeerRemoveNotInList(pid.Identifier()))
+			continue
+		}
+		delete(peers, pid.Identifier())
+	}
+	for _, pid := range updates.Additions {
+		if _, ok := peers[pid.Identifier()]; ok {
+			errs = multierr.Append(errs, peer.ErrPeerAddAlreadyInList(pid.Identifier()))
+			continue
+		}
+		peers[pid.Identifier()] = pid
+	}
+	for _, pid := range updates.Reweights {
+		if _, ok := peers[pid.Identifier()]; !ok {
+			errs = multierr.Append(errs, peer.ErrPeerReweightNotInList(pid.Identifier()))
+			continue
+		}
+		peers[pid.Identifier()] = pid
+	}
+	l.peers = peers
+
+	subset := l.choose()
+	for id, pid := range l.subset {
+		if _, ok := subset[id]; ok {
+			continue
+		}
+		err := l.list.Update(peer.ListUpdates{Removals: []peer.Identifier{pid}})
+		if _, notInList := err.(peer.ErrPeerRemoveNotInList); err == nil || notInList {
+			delete(l.subset, id)
+			continue
+		}
+		errs = multierr.Append(errs, err)
+	}
+	for id, pid := range subset {
+		old, ok := l.subset[id]
+		var err error
+		switch {
+		case !ok:
+			err = l.list.Update(peer.ListUpdates{Additions: []peer.Identifier{pid}})
+			if _, inList := err.(peer.ErrPeerAddAlreadyInList); inList {
+				err = nil
+			}
+		case peerlist.Weight(old) != peerlist.Weight(pid):
+			err = l.list.Update(peer.ListUpdates{Reweights: []peer.Identifier{pid}})
+		default:
+			continue
+		}
+		if err != nil {
+			errs = multierr.Append(errs, err)
+			continue
+		}
+		l.subset[id] = pid
+	}
+	return errs
+}
+
+// choose returns the subset of peers for this client.
+//
+// Clients are grouped in rounds of as many clients as there are disjoint
+// subsets of the peers. Every round shuffles the peers differently, and each
+// client of the round takes its own slice of the shuffled peers, so every
+// peer is used by the same number of clients of a full round. Peers are
+// shuffled by ordering them by a hash of the round and their identifier,
+// rather than by permuting their positions, so that adding or removing a
+// peer only moves the other peers by one position.
+//
+// Must be called with the lock held.
+func (l *List) choose() map[string]peer.Identifier {
+	size := l.opts.size
+	if len(l.peers) <= size {
+		subset := make(map[string]peer.Identifier, len(l.peers))
+		for id, pid := range l.peers {
+			subset[id] = pid
+		}
+		return subset
+	}
+
+	count := uint64(len(l.peers) / size)
+	round := l.opts.clientIndex / count
+	var seed [8]byte
+	binary.BigEndian.PutUint64(seed[:], round)
+
+	shuffled := make([]rankedPeer, 0, len(l.peers))
+	for id, pid := range l.peers {
+		shuffled = append(shuffled, rankedPeer{pid: pid, rank: strhash.Sum64(string(seed[:]) + id)})
+	}
+	sort.Slice(shuffled, func(i, j int) bool {
+		if shuffled[i].rank != shuffled[j].rank {
+			return shuffled[i].rank < shuffled[j].rank
+		}
+		return shuffled[i].pid.Identifier() < shuffled[j].pid.Identifier()
+	})
+
+	start := int(l.opts.clientIndex%count) * size
+	subset := make(map[string]peer.Identifier, size)
+	for _, rp := range shuffled[start : start+size] {
+		subset[rp.pid.Identifier()] = rp.pid
+	}
+	return subset
+}
+
+// Choose chooses a peer of the subset from the decorated list.
+func (l *List) Choose(ctx context.Context, req *transport.Request) (peer.Peer, func(error), error) {
+	return l.list.Choose(ctx, req)
+}
+
+// Start starts the decorated list.
+func (l *List) Start() error {
+	return l.list.Start()
+}
+
+// Stop stops the decorated list.
+func (l *List) Stop() error {
+	return l.list.Stop()
+}
+
+// IsRunning returns whether the decorated list is running.
+func (l *List) IsRunning() bool {
+	return l.list.IsRunning()
+}
+
+// Introspect returns the status of the decorated list, which holds the
+// peers of the subset, and the size of the subset.
+func (l *List) Introspect() introspection.ChooserStatus {
+	l.mu.Lock()
+	total, size := len(l.peers), len(l.subset)
+	ids := make([]string, 0, len(l.subset))
+	for id := range l.subset {
+		ids = append(ids, id)
+	}
+	l.mu.Unlock()
+
+	var status introspection.ChooserStatus
+	if ic, ok := l.list.(introspection.IntrospectableChooser); ok {
+		status = ic.Introspect()
+	} else {
+		sort.Strings(ids)
+		status.Name = _name
+		for _, id := range ids {
+			status.Peers = append(status.Peers, introspection.PeerStatus{Identifier: id})
+		}
+	}
+
+	if status.State != "" {
+		status.State += ", "
+	}
+	status.State += fmt.Sprintf("subset of %d out of %d peers for client %d", size, total, l.opts.clientIndex)
+	return status
+}
+
+type rankedPeer struct {
+	pid  peer.Identifier
+	rank uint64
+}
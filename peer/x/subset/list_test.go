@@ -0,0 +1,219 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package subset
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/peer/hostport"
+	"go.uber.org/yarpc/peer/roundrobin"
+	"go.uber.org/yarpc/yarpctest"
+)
+
+func identifiers(ids ...string) []peer.Identifier {
+	pids := make([]peer.Identifier, len(ids))
+	for i, id := range ids {
+		pids[i] = hostport.Identify(id)
+	}
+	return pids
+}
+
+func peerNames(n int) []string {
+	names := make([]string, n)
+	for i := range names {
+		names[i] = fmt.Sprintf("10.0.0.%d:80", i)
+	}
+	return names
+}
+
+func newList(t *testing.T, peers []string, opts ...Option) *List {
+	trans := yarpctest.NewFakeTransport(yarpctest.InitialConnectionStatus(peer.Available))
+	pl := New(roundrobin.New(trans), opts...)
+	require.NoError(t, pl.Update(peer.ListUpdates{Additions: identifiers(peers...)}))
+	require.NoError(t, pl.Start())
+	return pl
+}
+
+// subsetOf returns the sorted identifiers of the peers passed on to the
+// decorated list.
+func subsetOf(pl *List) []string {
+	status := pl.Introspect()
+	var ids []string
+	for _, ps := range status.Peers {
+		ids = append(ids, ps.Identifier)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func diff(a, b []string) int {
+	in := make(map[string]bool, len(a))
+	for _, id := range a {
+		in[id] = true
+	}
+	n := 0
+	for _, id := range b {
+		if !in[id] {
+			n++
+		}
+	}
+	return n
+}
+
+func TestSubsetSize(t *testing.T) {
+	pl := newList(t, peerNames(10), Size(3), ClientIndex(7))
+	defer pl.Stop()
+
+	assert.Len(t, subsetOf(pl), 3)
+	assert.Contains(t, pl.Introspect().State, "subset of 3 out of 10 peers for client 7")
+
+	small := newList(t, peerNames(2), Size(3), ClientIndex(7))
+	defer small.Stop()
+	assert.Len(t, subsetOf(small), 2, "all peers must be used if there are fewer than the subset size")
+}
+
+func TestSubsetDeterministic(t *testing.T) {
+	peers := peerNames(50)
+	pl := newList(t, peers, Size(5), ClientIndex(0))
+	defer pl.Stop()
+
+	reversed := make([]string, len(peers))
+	for i, p := range peers {
+		reversed[len(peers)-1-i] = p
+	}
+	other := newList(t, reversed, Size(5), ClientIndex(0))
+	defer other.Stop()
+	assert.Equal(t, subsetOf(pl), subsetOf(other), "subset must not depend on peer order")
+
+	// Clients 0 through 9 make up the first round and share no peers.
+	seen := make(map[string]bool)
+	for i := 0; i < 10; i++ {
+		client := newList(t, peers, Size(5), ClientIndex(i))
+		for _, id := range subsetOf(client) {
+			assert.False(t, seen[id], "peer %q must be in a single subset of the round", id)
+			seen[id] = true
+		}
+		require.NoError(t, client.Stop())
+	}
+	assert.Len(t, seen, 50)
+
+	next := newList(t, peers, Size(5), ClientIndex(10))
+	defer next.Stop()
+	assert.NotEqual(t, subsetOf(pl), subsetOf(next), "rounds must shuffle peers differently")
+}
+
+func TestSubsetBalance(t *testing.T) {
+	peers := peerNames(50)
+	counts := make(map[string]int)
+	for i := 0; i < 200; i++ {
+		pl := newList(t, peers, Size(10), ClientIndex(i))
+		for _, id := range subsetOf(pl) {
+			counts[id]++
+		}
+		require.NoError(t, pl.Stop())
+	}
+
+	// 200 clients make up 40 full rounds of 5 disjoint subsets each.
+	for _, id := range peers {
+		assert.Equal(t, 40, counts[id], "peer %q must be in a fair share of subsets", id)
+	}
+}
+
+func TestSubsetChurn(t *testing.T) {
+	peers := peerNames(50)
+	pl := newList(t, peers, Size(10), ClientIndex(3))
+	defer pl.Stop()
+	before := subsetOf(pl)
+
+	require.NoError(t, pl.Update(peer.ListUpdates{Additions: identifiers("10.0.1.1:80", "10.0.1.2:80")}))
+	afterAdd := subsetOf(pl)
+	assert.Len(t, afterAdd, 10)
+	assert.True(t, diff(before, afterAdd) <= 2, "adding peers must change at most as many peers of the subset")
+
+	require.NoError(t, pl.Update(peer.ListUpdates{Removals: identifiers(afterAdd[0])}))
+	afterRemove := subsetOf(pl)
+	assert.Len(t, afterRemove, 10)
+	assert.NotContains(t, afterRemove, afterAdd[0])
+	assert.Equal(t, 1, diff(afterAdd, afterRemove), "removing a peer must only replace that peer")
+
+	var outside string
+	for _, p := range peers {
+		if p != afterAdd[0] && diff(afterRemove, []string{p}) == 1 {
+			outside = p
+			break
+		}
+	}
+	require.NoError(t, pl.Update(peer.ListUpdates{Removals: identifiers(outside)}))
+	assert.True(t, diff(afterRemove, subsetOf(pl)) <= 1, "removing a peer outside the subset must change at most one peer")
+}
+
+func TestSubsetUpdateErrors(t *testing.T) {
+	pl := newList(t, peerNames(3), ClientIndex(0))
+	defer pl.Stop()
+
+	assert.Error(t, pl.Update(peer.ListUpdates{Additions: identifiers("10.0.0.0:80")}), "duplicate additions must fail")
+	assert.Error(t, pl.Update(peer.ListUpdates{Removals: identifiers("10.0.9.9:80")}), "unknown removals must fail")
+	assert.Error(t, pl.Update(peer.ListUpdates{Reweights: identifiers("10.0.9.9:80")}), "unknown reweights must fail")
+	assert.Len(t, subsetOf(pl), 3)
+}
+
+// flakyList is a peer list that fails to add some peers.
+type flakyList struct {
+	peer.ChooserList
+
+	failing map[string]bool
+}
+
+func (l *flakyList) Update(updates peer.ListUpdates) error {
+	for _, pid := range updates.Additions {
+		if l.failing[pid.Identifier()] {
+			return errors.New("great sadness")
+		}
+	}
+	return l.ChooserList.Update(updates)
+}
+
+func TestSubsetDecoratedUpdateFailsPartway(t *testing.T) {
+	trans := yarpctest.NewFakeTransport(yarpctest.InitialConnectionStatus(peer.Available))
+	inner := &flakyList{
+		ChooserList: roundrobin.New(trans),
+		failing:     map[string]bool{"10.0.0.1:80": true},
+	}
+	pl := New(inner, ClientIndex(0))
+	require.NoError(t, pl.Start())
+
+	err := pl.Update(peer.ListUpdates{Additions: identifiers(peerNames(3)...)})
+	assert.EqualError(t, err, "great sadness")
+	assert.Equal(t, []string{"10.0.0.0:80", "10.0.0.2:80"}, subsetOf(pl), "decorated list must hold the peers it accepted")
+	assert.Contains(t, pl.Introspect().State, "subset of 2 out of 3 peers", "subset must match the decorated list")
+
+	// The peer that failed is passed on again by the next update.
+	delete(inner.failing, "10.0.0.1:80")
+	require.NoError(t, pl.Update(peer.ListUpdates{Additions: identifiers("10.0.0.3:80")}))
+	assert.Equal(t, peerNames(4), subsetOf(pl))
+	assert.Contains(t, pl.Introspect().State, "subset of 4 out of 4 peers")
+}
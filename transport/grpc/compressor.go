@@ -0,0 +1,89 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package grpc
+
+import (
+	"io"
+
+	"go.uber.org/yarpc/api/transport"
+	"google.golang.org/grpc/encoding"
+)
+
+// RegisterCompressor makes the given compressor available to gRPC clients
+// and servers under its name, allowing inbounds to decompress requests and
+// compress responses with it, and outbounds to use it with the Compressor
+// option.
+//
+// gRPC keeps a single process-wide registry of compressors, so this affects
+// every gRPC client and server in the process, including those not created
+// by YARPC. A compressor replaces any compressor registered earlier under the
+// same name, including gRPC's built-in gzip compressor. Like
+// encoding.RegisterCompressor, this must only be called during
+// initialization, for example from an init function.
+//
+//  func init() {
+//  	grpc.RegisterCompressor(yarpcgzip.New())
+//  }
+func RegisterCompressor(c transport.Compressor) {
+	encoding.RegisterCompressor(compressor{c})
+}
+
+// compressor adapts a transport.Compressor into a gRPC compressor.
+type compressor struct{ c transport.Compressor }
+
+var _ encoding.Compressor = compressor{}
+
+func (c compressor) Name() string {
+	return c.c.Name()
+}
+
+func (c compressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	return c.c.Compress(w)
+}
+
+func (c compressor) Decompress(r io.Reader) (io.Reader, error) {
+	rc, err := c.c.Decompress(r)
+	if err != nil {
+		return nil, err
+	}
+	return &closeOnEOFReader{rc: rc}, nil
+}
+
+// gRPC reads decompressed payloads to the end but never closes them, so we
+// release the reader back to the compressor as soon as we reach EOF.
+type closeOnEOFReader struct {
+	rc     io.ReadCloser
+	closed bool
+}
+
+func (r *closeOnEOFReader) Read(p []byte) (int, error) {
+	if r.closed {
+		return 0, io.EOF
+	}
+	n, err := r.rc.Read(p)
+	if err == io.EOF {
+		r.closed = true
+		if cerr := r.rc.Close(); cerr != nil {
+			return n, cerr
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+	"go.uber.org/yarpc/api/transport"
+	yarpcgzip "go.uber.org/yarpc/compressor/gzip"
+	"google.golang.org/grpc/encoding"
+)
+
+// countingCompressor counts the number of payloads it compresses and
+// decompresses.
+type countingCompressor struct {
+	transport.Compressor
+
+	name         string
+	compressed   atomic.Int32
+	decompressed atomic.Int32
+}
+
+func newCountingCompressor(name string) *countingCompressor {
+	return &countingCompressor{Compressor: yarpcgzip.New(), name: name}
+}
+
+func (c *countingCompressor) Name() string { return c.name }
+
+func (c *countingCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	c.compressed.Inc()
+	return c.Compressor.Compress(w)
+}
+
+func (c *countingCompressor) Decompress(r io.Reader) (io.ReadCloser, error) {
+	c.decompressed.Inc()
+	return c.Compressor.Decompress(r)
+}
+
+func TestCompressorAdapter(t *testing.T) {
+	RegisterCompressor(yarpcgzip.New())
+	c := encoding.GetCompressor(yarpcgzip.Name)
+	require.NotNil(t, c)
+	assert.Equal(t, yarpcgzip.Name, c.Name())
+
+	var buf bytes.Buffer
+	w, err := c.Compress(&buf)
+	require.NoError(t, err)
+	_, err = w.Write([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	r, err := c.Decompress(&buf)
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(body))
+
+	n, err := r.Read(make([]byte, 1))
+	assert.Equal(t, 0, n)
+	assert.Equal(t, io.EOF, err, "reads after EOF must keep returning EOF")
+
+	_, err = c.Decompress(bytes.NewBufferString("not gzip"))
+	assert.Error(t, err)
+}
+
+func TestCompressedRoundTrip(t *testing.T) {
+	t.Parallel()
+	compressor := newCountingCompressor("test-grpc-roundtrip")
+	RegisterCompressor(compressor)
+	te := testEnvOptions{
+		OutboundOptions: []OutboundOption{Compressor(compressor)},
+	}
+	te.do(t, func(t *testing.T, e *testEnv) {
+		assert.NoError(t, e.SetValueYARPC(context.Background(), "foo", "bar"))
+		value, err := e.GetValueYARPC(context.Background(), "foo")
+		assert.NoError(t, err)
+		assert.Equal(t, "bar", value)
+
+		// Both requests and responses must have been compressed.
+		assert.Equal(t, int32(4), compressor.compressed.Load())
+		assert.Equal(t, int32(4), compressor.decompressed.Load())
+	})
+}
+
+func TestUnregisteredCompressor(t *testing.T) {
+	trans := NewTransport()
+	out := trans.NewSingleOutbound("127.0.0.1:0", Compressor(newCountingCompressor("test-grpc-unregistered")))
+	err := out.Start()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `compressor "test-grpc-unregistered" is not registered with gRPC`)
+}
@@ -64,6 +64,10 @@ func TransportSpec(opts ...Option) yarpcconfig.TransportSpec {
 //        exponential:
 //          first: 10ms
 //          max: 30s
+//      healthCheck:
+//        interval: 5s
+//        timeout: 1s
+//        failures: 3
 //
 // All parameters of TransportConfig are optional. This section
 // may be omitted in the transports section.
@@ -73,23 +77,41 @@ type TransportConfig struct {
 	ClientMaxRecvMsgSize int                 `config:"clientMaxRecvMsgSize"`
 	ClientMaxSendMsgSize int                 `config:"clientMaxSendMsgSize"`
 	Backoff              yarpcconfig.Backoff `config:"backoff"`
+	// Configures active health checking of peers. This field is optional.
+	HealthCheck yarpcconfig.HealthCheck `config:"healthCheck"`
 }
 
 // InboundConfig configures a gRPC Inbound.
 //
 // inbounds:
-//   grpc:
-//     address: ":80"
+//
+//	grpc:
+//	  address: ":80"
 //
 // A gRPC inbound can also enable TLS from key and cert files.
 //
 // inbounds:
-//   grpc:
-//     address: ":443"
-//     tls:
-//       enabled: true
-//       keyFile: "/path/to/key"
-//       certFile: "/path/to/cert"
+//
+//	grpc:
+//	  address: ":443"
+//	  tls:
+//	    enabled: true
+//	    keyFile: "/path/to/key"
+//	    certFile: "/path/to/cert"
+//
+// Clients can be required to present certificates signed by the authorities
+// of a CA bundle for mutual TLS. See yarpcconfig.InboundTLS for details.
+//
+// inbounds:
+//
+//	grpc:
+//	  address: ":443"
+//	  tls:
+//	    enabled: true
+//	    keyFile: "/path/to/key"
+//	    certFile: "/path/to/cert"
+//	    caFile: "/path/to/ca"
+//	    clientAuth: require-and-verify
 type InboundConfig struct {
 	// Address to listen on. This field is required.
 	Address string           `config:"address,interpolate"`
@@ -101,10 +123,18 @@ func (c InboundConfig) inboundOptions() ([]InboundOption, error) {
 }
 
 // InboundTLSConfig specifies the TLS configuration for the gRPC inbound.
+//
+// Certificates are read again when the files change, so rotated
+// certificates apply to new connections without a restart.
 type InboundTLSConfig struct {
 	Enabled  bool   `config:"enabled"` // disabled by default
 	CertFile string `config:"certFile,interpolate"`
 	KeyFile  string `config:"keyFile,interpolate"`
+	// CA bundle used to verify client certificates. Optional.
+	CAFile string `config:"caFile,interpolate"`
+	// Client certificate policy. Defaults to require-and-verify if caFile is
+	// set and none otherwise.
+	ClientAuth string `config:"clientAuth"`
 }
 
 func (c InboundTLSConfig) inboundOptions() ([]InboundOption, error) {
@@ -119,18 +149,24 @@ func (c InboundTLSConfig) inboundOptions() ([]InboundOption, error) {
 }
 
 func (c InboundTLSConfig) newInboundCredentials() (credentials.TransportCredentials, error) {
-	if c.CertFile != "" && c.KeyFile != "" {
-		return credentials.NewServerTLSFromFile(c.CertFile, c.KeyFile)
+	config, err := yarpcconfig.InboundTLS(c).ServerConfig()
+	if err != nil {
+		return nil, fmt.Errorf("cannot construct gRPC transport credentials: %v", err)
 	}
-	return nil, fmt.Errorf("both certFile and keyFile are necessary to construct gRPC transport credentials, got certFile=%q and keyFile=%q", c.CertFile, c.KeyFile)
+	// Connections may be handled by copies of this configuration, see
+	// yarpcconfig.InboundTLS, so HTTP/2 is offered here rather than only on
+	// the copy made by the credentials.
+	config.NextProtos = []string{"h2"}
+	return credentials.NewTLS(config), nil
 }
 
 // OutboundConfig configures a gRPC Outbound.
 //
 // outbounds:
-//   myservice:
-//     grpc:
-//       address: ":80"
+//
+//	myservice:
+//	  grpc:
+//	    address: ":80"
 //
 // A gRPC outbound can also configure a peer list.
 //
@@ -151,30 +187,70 @@ func (c InboundTLSConfig) newInboundCredentials() (credentials.TransportCredenti
 //        tls:
 //          enabled: true
 //
+// It can also present a client certificate for mutual TLS, verify servers
+// against a CA bundle and override the server name used for verification.
+// See yarpcconfig.OutboundTLS for details.
+//
+//  outbounds:
+//    theirsecureservice:
+//      grpc:
+//        address: ":443"
+//        tls:
+//          enabled: true
+//          certFile: "/path/to/cert"
+//          keyFile: "/path/to/key"
+//          caFile: "/path/to/ca"
+//          serverName: theirsecureservice.example.com
+//
+// A gRPC outbound can compress requests with a compressor registered with
+// the Configurator. Because gRPC keeps a process-wide registry of
+// compressors, the compressor must also be registered with gRPC by calling
+// RegisterCompressor during initialization.
+//
+//	outbounds:
+//	  myservice:
+//	    grpc:
+//	      address: ":80"
+//	      compressor: gzip
 type OutboundConfig struct {
 	yarpcconfig.PeerChooser
 
 	// Address to connect to if no peer options set.
 	Address string            `config:"address,interpolate"`
 	TLS     OutboundTLSConfig `config:"tls"`
+	// Name of the compressor used for requests, if any.
+	Compressor string `config:"compressor"`
 }
 
-func (c OutboundConfig) dialOptions() []DialOption {
+func (c OutboundConfig) dialOptions() ([]DialOption, error) {
 	return c.TLS.dialOptions()
 }
 
 // OutboundTLSConfig configures TLS for a gRPC outbound.
+//
+// Client certificates are read again when the files change, so rotated
+// certificates apply to new connections without a restart.
 type OutboundTLSConfig struct {
 	Enabled bool `config:"enabled"`
+	// Client certificate and key presented to servers. Optional.
+	CertFile string `config:"certFile,interpolate"`
+	KeyFile  string `config:"keyFile,interpolate"`
+	// CA bundle used to verify servers. Defaults to the system roots.
+	CAFile string `config:"caFile,interpolate"`
+	// Name used to verify the server certificate. Defaults to the host of
+	// the peer.
+	ServerName string `config:"serverName,interpolate"`
 }
 
-func (c OutboundTLSConfig) dialOptions() []DialOption {
-	if !c.Enabled {
-		return nil
+func (c OutboundTLSConfig) dialOptions() ([]DialOption, error) {
+	config, err := yarpcconfig.OutboundTLS(c).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("cannot construct gRPC transport credentials: %v", err)
 	}
-	creds := credentials.NewClientTLSFromCert(nil, "")
-	option := DialerCredentials(creds)
-	return []DialOption{option}
+	if config == nil {
+		return nil, nil
+	}
+	return []DialOption{DialerCredentials(credentials.NewTLS(config))}, nil
 }
 
 type transportSpec struct {
@@ -200,7 +276,7 @@ func newTransportSpec(opts ...Option) (*transportSpec, error) {
 	return transportSpec, nil
 }
 
-func (t *transportSpec) buildTransport(transportConfig *TransportConfig, _ *yarpcconfig.Kit) (transport.Transport, error) {
+func (t *transportSpec) buildTransport(transportConfig *TransportConfig, kit *yarpcconfig.Kit) (transport.Transport, error) {
 	options := t.TransportOptions
 	if transportConfig.ServerMaxRecvMsgSize > 0 {
 		options = append(options, ServerMaxRecvMsgSize(transportConfig.ServerMaxRecvMsgSize))
@@ -219,6 +295,18 @@ func (t *transportSpec) buildTransport(transportConfig *TransportConfig, _ *yarp
 		return nil, err
 	}
 	options = append(options, BackoffStrategy(backoffStrategy))
+	if healthCheck := transportConfig.HealthCheck; healthCheck.Interval < 0 || healthCheck.Timeout < 0 || healthCheck.Failures < 0 {
+		return nil, fmt.Errorf("health check interval, timeout and failures must not be negative")
+	}
+	if transportConfig.HealthCheck.Interval > 0 {
+		options = append(options, HealthCheckInterval(transportConfig.HealthCheck.Interval))
+	}
+	if transportConfig.HealthCheck.Timeout > 0 {
+		options = append(options, HealthCheckTimeout(transportConfig.HealthCheck.Timeout))
+	}
+	if transportConfig.HealthCheck.Failures > 0 {
+		options = append(options, HealthCheckFailures(transportConfig.HealthCheck.Failures))
+	}
 	return newTransport(newTransportOptions(options)), nil
 }
 
@@ -255,7 +343,11 @@ func (t *transportSpec) buildOutbound(outboundConfig *OutboundConfig, tr transpo
 		return nil, newTransportCastError(tr)
 	}
 
-	dialer := trans.NewDialer(outboundConfig.dialOptions()...)
+	dialOptions, err := outboundConfig.dialOptions()
+	if err != nil {
+		return nil, err
+	}
+	dialer := trans.NewDialer(dialOptions...)
 
 	var chooser peer.Chooser
 	if outboundConfig.Empty() {
@@ -271,7 +363,16 @@ func (t *transportSpec) buildOutbound(outboundConfig *OutboundConfig, tr transpo
 		}
 	}
 
-	return trans.NewOutbound(chooser, t.OutboundOptions...), nil
+	outboundOptions := t.OutboundOptions
+	if outboundConfig.Compressor != "" {
+		compressor, err := kit.Compressor(outboundConfig.Compressor)
+		if err != nil {
+			return nil, err
+		}
+		outboundOptions = append(outboundOptions, Compressor(compressor))
+	}
+
+	return trans.NewOutbound(chooser, outboundOptions...), nil
 }
 
 func newTransportCastError(tr transport.Transport) error {
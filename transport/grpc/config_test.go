@@ -28,6 +28,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/yarpc/api/transport"
+	yarpcgzip "go.uber.org/yarpc/compressor/gzip"
 	"go.uber.org/yarpc/peer"
 	"go.uber.org/yarpc/yarpcconfig"
 )
@@ -95,8 +96,9 @@ func TestTransportSpec(t *testing.T) {
 	}
 
 	type wantOutbound struct {
-		Address string
-		TLS     bool
+		Address    string
+		TLS        bool
+		Compressor string
 	}
 
 	type test struct {
@@ -236,6 +238,36 @@ func TestTransportSpec(t *testing.T) {
 			},
 			wantErrors: []string{`both certFile and keyFile`},
 		},
+		{
+			desc: "mutual TLS enabled on an inbound",
+			inboundCfg: attrs{
+				"address": "localhost:54570",
+				"tls": attrs{
+					"enabled":    true,
+					"certFile":   "testdata/cert",
+					"keyFile":    "testdata/key",
+					"caFile":     "testdata/cert",
+					"clientAuth": "verify-if-given",
+				},
+			},
+			wantInbound: &wantInbound{
+				Address: "127.0.0.1:54570",
+				TLS:     true,
+			},
+		},
+		{
+			desc: "TLS enabled on an inbound with unknown client auth",
+			inboundCfg: attrs{
+				"address": "localhost:54714",
+				"tls": attrs{
+					"enabled":    true,
+					"certFile":   "testdata/cert",
+					"keyFile":    "testdata/key",
+					"clientAuth": "sometimes",
+				},
+			},
+			wantErrors: []string{`unknown clientAuth "sometimes"`},
+		},
 		{
 			desc: "TLS enabled on an outbound",
 			outboundCfg: attrs{
@@ -255,6 +287,73 @@ func TestTransportSpec(t *testing.T) {
 				},
 			},
 		},
+		{
+			desc: "mutual TLS enabled on an outbound",
+			outboundCfg: attrs{
+				"myservice": attrs{
+					transportName: attrs{
+						"address": "localhost:54817",
+						"tls": attrs{
+							"enabled":    true,
+							"certFile":   "testdata/cert",
+							"keyFile":    "testdata/key",
+							"caFile":     "testdata/cert",
+							"serverName": "myservice.example.com",
+						},
+					},
+				},
+			},
+			wantOutbounds: map[string]wantOutbound{
+				"myservice": {
+					Address: "localhost:54817",
+					TLS:     true,
+				},
+			},
+		},
+		{
+			desc: "TLS enabled on an outbound with invalid config",
+			outboundCfg: attrs{
+				"myservice": attrs{
+					transportName: attrs{
+						"address": "localhost:54818",
+						"tls": attrs{
+							"enabled":  true,
+							"certFile": "testdata/cert",
+						},
+					},
+				},
+			},
+			wantErrors: []string{`certFile and keyFile must be given together`},
+		},
+		{
+			desc: "compressor on an outbound",
+			outboundCfg: attrs{
+				"myservice": attrs{
+					transportName: attrs{
+						"address":    "localhost:54816",
+						"compressor": "gzip",
+					},
+				},
+			},
+			wantOutbounds: map[string]wantOutbound{
+				"myservice": {
+					Address:    "localhost:54816",
+					Compressor: "gzip",
+				},
+			},
+		},
+		{
+			desc: "unknown compressor on an outbound",
+			outboundCfg: attrs{
+				"myservice": attrs{
+					transportName: attrs{
+						"address":    "localhost:54816",
+						"compressor": "zstd",
+					},
+				},
+			},
+			wantErrors: []string{`no recognized compressor "zstd"; need one of gzip`},
+		},
 	}
 
 	for _, tt := range tests {
@@ -267,6 +366,7 @@ func TestTransportSpec(t *testing.T) {
 			configurator := yarpcconfig.New(yarpcconfig.InterpolationResolver(mapResolver(env)))
 			err := configurator.RegisterTransport(TransportSpec(tt.opts...))
 			require.NoError(t, err)
+			configurator.MustRegisterCompressor(yarpcgzip.New())
 
 			cfgData := make(attrs)
 			if tt.transportCfg != nil {
@@ -323,6 +423,7 @@ func TestTransportSpec(t *testing.T) {
 				require.True(t, ok, "no outbounds for %s", svc)
 				outbound, ok := ob.Unary.(*Outbound)
 				require.True(t, ok, "expected *Outbound, got %T", ob)
+				assert.Equal(t, wantOutbound.Compressor, outbound.options.compressor)
 				if wantOutbound.Address != "" {
 					single, ok := outbound.peerChooser.(*peer.Single)
 					require.True(t, ok, "expected *peer.Single, got %T", outbound.peerChooser)
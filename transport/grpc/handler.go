@@ -21,6 +21,7 @@
 package grpc
 
 import (
+	"strconv"
 	"strings"
 	"time"
 
@@ -28,12 +29,16 @@ import (
 	"go.uber.org/yarpc"
 	"go.uber.org/yarpc/api/transport"
 	"go.uber.org/yarpc/internal/bufferpool"
+	intyarpcerrors "go.uber.org/yarpc/internal/yarpcerrors"
 	"go.uber.org/yarpc/yarpcerrors"
 	"go.uber.org/zap"
 	"golang.org/x/net/context"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 )
 
@@ -54,7 +59,11 @@ func newHandler(i *Inbound, l *zap.Logger) *handler {
 
 func (h *handler) handle(srv interface{}, serverStream grpc.ServerStream) error {
 	start := time.Now()
-	ctx := serverStream.Context()
+	ctx, cancel, err := withTTL(withPeerIdentity(serverStream.Context()))
+	if err != nil {
+		return err
+	}
+	defer cancel()
 	streamMethod, ok := grpc.MethodFromServerStream(serverStream)
 	if !ok {
 		return errInvalidGRPCStream
@@ -78,6 +87,39 @@ func (h *handler) handle(srv interface{}, serverStream grpc.ServerStream) error
 	return yarpcerrors.Newf(yarpcerrors.CodeUnimplemented, "transport grpc does not handle %s handlers", handlerSpec.Type().String())
 }
 
+// withPeerIdentity attaches the identity of the client to the context if it
+// presented a verified certificate.
+func withPeerIdentity(ctx context.Context) context.Context {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ctx
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return ctx
+	}
+	if id, ok := transport.PeerIdentityFromTLS(&info.State); ok {
+		return transport.WithPeerIdentity(ctx, id)
+	}
+	return ctx
+}
+
+// withTTL clamps the context to the deadline given by the context-ttl-ms
+// header, if any.
+func withTTL(ctx context.Context) (_ context.Context, cancel func(), _ error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md[ttlMSHeader]
+	if len(values) == 0 {
+		return ctx, func() {}, nil
+	}
+	ttlms, err := strconv.Atoi(values[0])
+	if err != nil || ttlms < 0 || len(values) > 1 {
+		return ctx, func() {}, yarpcerrors.InvalidArgumentErrorf("invalid %s header %q", ttlMSHeader, strings.Join(values, ","))
+	}
+	ctx, cancel = context.WithTimeout(ctx, time.Duration(ttlms)*time.Millisecond)
+	return ctx, cancel, nil
+}
+
 // getBasicTransportRequest converts the grpc request metadata into a
 // transport.Request without a body field.
 func (h *handler) getBasicTransportRequest(ctx context.Context, streamMethod string) (*transport.Request, error) {
@@ -270,5 +312,18 @@ func handlerErrorToGRPCError(err error, responseWriter *responseWriter) error {
 	if !ok {
 		grpcCode = codes.Unknown
 	}
-	return status.Error(grpcCode, message)
+	return newGRPCError(grpcCode, message, yarpcStatus.Details())
+}
+
+// newGRPCError returns a grpc-go error with the given code and message. Any
+// details are sent to the client in the grpc-status-details-bin trailer.
+func newGRPCError(code codes.Code, message string, details []yarpcerrors.Detail) error {
+	if len(details) == 0 {
+		return status.Error(code, message)
+	}
+	return status.FromProto(&spb.Status{
+		Code:    int32(code),
+		Message: message,
+		Details: intyarpcerrors.DetailsToProto(details),
+	}).Err()
 }
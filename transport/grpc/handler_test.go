@@ -22,12 +22,21 @@ package grpc
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"net"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/testtls"
+	"go.uber.org/yarpc/yarpcerrors"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 )
 
 func TestInvalidStreamContext(t *testing.T) {
@@ -115,3 +124,125 @@ func TestInvalidStreamMultipleHeaders(t *testing.T) {
 	require.Contains(t, err.Error(), "code:invalid-argument")
 	require.Contains(t, err.Error(), "header has more than one value: rpc-caller")
 }
+
+func TestWithPeerIdentity(t *testing.T) {
+	s := testtls.NewScenario(t)
+	defer s.Cleanup()
+	s.IssueClientCert("client", "spiffe://example.com/client")
+
+	pair, err := tls.LoadX509KeyPair(s.ClientCertFile, s.ClientKeyFile)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	require.NoError(t, err)
+
+	tests := []struct {
+		desc     string
+		give     context.Context
+		wantName string
+	}{
+		{
+			desc: "no peer",
+			give: context.Background(),
+		},
+		{
+			desc: "no TLS",
+			give: peer.NewContext(context.Background(), &peer.Peer{}),
+		},
+		{
+			desc: "unverified certificate",
+			give: peer.NewContext(context.Background(), &peer.Peer{
+				AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{
+					PeerCertificates: []*x509.Certificate{cert},
+				}},
+			}),
+		},
+		{
+			desc: "verified certificate",
+			give: peer.NewContext(context.Background(), &peer.Peer{
+				AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{
+					PeerCertificates: []*x509.Certificate{cert},
+					VerifiedChains:   [][]*x509.Certificate{{cert}},
+				}},
+			}),
+			wantName: "spiffe://example.com/client",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			id, ok := transport.PeerIdentityFromContext(withPeerIdentity(tt.give))
+			assert.Equal(t, tt.wantName != "", ok)
+			assert.Equal(t, tt.wantName, id.Name())
+		})
+	}
+}
+
+func TestWithTTL(t *testing.T) {
+	parent, cancelParent := context.WithTimeout(context.Background(), time.Minute)
+	defer cancelParent()
+
+	tests := []struct {
+		desc    string
+		give    context.Context
+		want    time.Duration // zero for no deadline
+		wantErr string
+	}{
+		{
+			desc: "no metadata",
+			give: context.Background(),
+		},
+		{
+			desc: "no ttl",
+			give: metadata.NewIncomingContext(context.Background(), metadata.Pairs("rpc-caller", "caller")),
+		},
+		{
+			desc: "ttl",
+			give: metadata.NewIncomingContext(context.Background(), metadata.Pairs(ttlMSHeader, "1500")),
+			want: 1500 * time.Millisecond,
+		},
+		{
+			desc: "ttl before grpc deadline",
+			give: metadata.NewIncomingContext(parent, metadata.Pairs(ttlMSHeader, "1500")),
+			want: 1500 * time.Millisecond,
+		},
+		{
+			desc: "grpc deadline before ttl",
+			give: metadata.NewIncomingContext(parent, metadata.Pairs(ttlMSHeader, "3600000")),
+			want: time.Minute,
+		},
+		{
+			desc:    "negative ttl",
+			give:    metadata.NewIncomingContext(context.Background(), metadata.Pairs(ttlMSHeader, "-1")),
+			wantErr: `invalid context-ttl-ms header "-1"`,
+		},
+		{
+			desc:    "invalid ttl",
+			give:    metadata.NewIncomingContext(context.Background(), metadata.Pairs(ttlMSHeader, "soon")),
+			wantErr: `invalid context-ttl-ms header "soon"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			start := time.Now()
+			ctx, cancel, err := withTTL(tt.give)
+			defer cancel()
+
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Equal(t, yarpcerrors.CodeInvalidArgument, yarpcerrors.FromError(err).Code())
+				assert.Equal(t, tt.wantErr, yarpcerrors.FromError(err).Message())
+				return
+			}
+			require.NoError(t, err)
+
+			deadline, ok := ctx.Deadline()
+			if tt.want == 0 {
+				assert.False(t, ok, "context must not have a deadline")
+				return
+			}
+			require.True(t, ok, "context must have a deadline")
+			assert.InDelta(t, float64(tt.want), float64(deadline.Sub(start)), float64(100*time.Millisecond))
+		})
+	}
+}
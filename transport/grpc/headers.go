@@ -75,6 +75,12 @@ const (
 
 	baseContentType   = "application/grpc"
 	contentTypeHeader = "content-type"
+
+	// ttlMSHeader is the header key for the amount of time (in milliseconds)
+	// within which the request is expected to finish, as sent by HTTP clients
+	// and proxies. gRPC clients send grpc-timeout instead, which gRPC applies
+	// on its own. If both are given, the earlier deadline applies.
+	ttlMSHeader = "context-ttl-ms"
 )
 
 // TODO: there are way too many repeat calls to strings.ToLower
@@ -132,6 +138,8 @@ func metadataToTransportRequest(md metadata.MD) (*transport.Request, error) {
 			request.RoutingDelegate = value
 		case EncodingHeader:
 			request.Encoding = transport.Encoding(value)
+		case ttlMSHeader:
+			// applied to the context by the handler
 		case contentTypeHeader:
 			// if request.Encoding was set, do not parse content-type
 			// this results in EncodingHeader overriding content-type
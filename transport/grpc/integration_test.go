@@ -222,6 +222,35 @@ func TestGRPCNamedErrorNoMessage(t *testing.T) {
 	})
 }
 
+var _testErrorDetail = yarpcerrors.Detail{
+	TypeURL: "type.googleapis.com/uber.yarpc.test.Detail",
+	Value:   []byte("detail"),
+}
+
+func TestYARPCErrorDetails(t *testing.T) {
+	t.Parallel()
+	te := testEnvOptions{}
+	te.do(t, func(t *testing.T, e *testEnv) {
+		e.KeyValueYARPCServer.SetNextError(yarpcerrors.Newf(yarpcerrors.CodeFailedPrecondition, "bar 1").WithDetails(_testErrorDetail))
+		err := e.SetValueYARPC(context.Background(), "foo", "bar")
+		assert.Equal(t, yarpcerrors.Newf(yarpcerrors.CodeFailedPrecondition, "bar 1").WithDetails(_testErrorDetail), err)
+	})
+}
+
+func TestGRPCErrorDetails(t *testing.T) {
+	t.Parallel()
+	te := testEnvOptions{}
+	te.do(t, func(t *testing.T, e *testEnv) {
+		e.KeyValueYARPCServer.SetNextError(yarpcerrors.Newf(yarpcerrors.CodeFailedPrecondition, "bar 1").WithDetails(_testErrorDetail))
+		err := e.SetValueGRPC(context.Background(), "foo", "bar")
+		st, ok := status.FromError(err)
+		require.True(t, ok, "expected a gRPC status error, got %v", err)
+		assert.Equal(t, codes.FailedPrecondition, st.Code())
+		assert.Equal(t, "bar 1", st.Message())
+		assert.Equal(t, []yarpcerrors.Detail{_testErrorDetail}, intyarpcerrors.DetailsFromProto(st.Proto().Details))
+	})
+}
+
 func TestYARPCResponseAndError(t *testing.T) {
 	t.Parallel()
 	te := testEnvOptions{}
@@ -559,10 +588,10 @@ func createTLSScenario(t *testing.T, clientValidity time.Duration, serverValidit
 			},
 			SerialNumber:          big.NewInt(1),
 			BasicConstraintsValid: true,
-			IsCA:      true,
-			KeyUsage:  x509.KeyUsageCertSign,
-			NotBefore: now,
-			NotAfter:  now.Add(10 * time.Minute),
+			IsCA:                  true,
+			KeyUsage:              x509.KeyUsageCertSign,
+			NotBefore:             now,
+			NotAfter:              now.Add(10 * time.Minute),
 		},
 		&x509.Certificate{},
 		caKey.Public(),
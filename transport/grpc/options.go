@@ -22,9 +22,12 @@ package grpc
 
 import (
 	"math"
+	"time"
 
 	"go.uber.org/yarpc/api/backoff"
+	"go.uber.org/yarpc/api/transport"
 	intbackoff "go.uber.org/yarpc/internal/backoff"
+	"go.uber.org/yarpc/internal/healthcheck"
 
 	"github.com/opentracing/opentracing-go"
 	"go.uber.org/zap"
@@ -69,6 +72,39 @@ func BackoffStrategy(backoffStrategy backoff.Strategy) TransportOption {
 	}
 }
 
+// HealthCheckInterval enables active health checking of peers at the given
+// interval. While a peer is connected, the transport periodically calls the
+// health procedure that YARPC dispatchers serve, and marks the peer
+// unavailable after HealthCheckFailures consecutive failed checks. A
+// connected peer only becomes available once it passes a health check.
+//
+// Health checks are disabled by default, leaving peers available as long as
+// their connection is ready.
+func HealthCheckInterval(d time.Duration) TransportOption {
+	return func(transportOptions *transportOptions) {
+		transportOptions.healthCheck.Interval = d
+	}
+}
+
+// HealthCheckTimeout specifies the timeout of each health check.
+//
+// The default is 1 second.
+func HealthCheckTimeout(d time.Duration) TransportOption {
+	return func(transportOptions *transportOptions) {
+		transportOptions.healthCheck.Timeout = d
+	}
+}
+
+// HealthCheckFailures specifies the number of consecutive failed health
+// checks after which a peer is marked unavailable.
+//
+// The default is 3.
+func HealthCheckFailures(n int) TransportOption {
+	return func(transportOptions *transportOptions) {
+		transportOptions.healthCheck.Failures = n
+	}
+}
+
 // Tracer specifies the tracer to use.
 //
 // By default, opentracing.GlobalTracer() is used.
@@ -141,6 +177,20 @@ type OutboundOption func(*outboundOptions)
 
 func (OutboundOption) grpcOption() {}
 
+// Compressor compresses requests sent by the outbound with the given
+// compressor, using the grpc-encoding header. Inbounds compress responses
+// with the same compressor if they know about it.
+//
+// The compressor must have been registered with RegisterCompressor, or be
+// one that gRPC registers itself; the outbound fails to start otherwise.
+//
+// Requests are not compressed by default.
+func Compressor(compressor transport.Compressor) OutboundOption {
+	return func(outboundOptions *outboundOptions) {
+		outboundOptions.compressor = compressor.Name()
+	}
+}
+
 // DialOption is an option that influences grpc.Dial.
 type DialOption func(*dialOptions)
 
@@ -162,6 +212,7 @@ type transportOptions struct {
 	serverMaxSendMsgSize int
 	clientMaxRecvMsgSize int
 	clientMaxSendMsgSize int
+	healthCheck          healthcheck.Options
 }
 
 func newTransportOptions(options []TransportOption) *transportOptions {
@@ -171,6 +222,7 @@ func newTransportOptions(options []TransportOption) *transportOptions {
 		serverMaxSendMsgSize: defaultServerMaxSendMsgSize,
 		clientMaxRecvMsgSize: defaultClientMaxRecvMsgSize,
 		clientMaxSendMsgSize: defaultClientMaxSendMsgSize,
+		healthCheck:          healthcheck.DefaultOptions,
 	}
 	for _, option := range options {
 		option(transportOptions)
@@ -199,7 +251,9 @@ func newInboundOptions(options []InboundOption) *inboundOptions {
 	return inboundOptions
 }
 
-type outboundOptions struct{}
+type outboundOptions struct {
+	compressor string
+}
 
 func newOutboundOptions(options []OutboundOption) *outboundOptions {
 	outboundOptions := &outboundOptions{}
@@ -23,6 +23,7 @@ package grpc
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"io/ioutil"
 	"strings"
 	"sync"
@@ -38,6 +39,7 @@ import (
 	"go.uber.org/yarpc/pkg/lifecycle"
 	"go.uber.org/yarpc/yarpcerrors"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 )
@@ -72,7 +74,12 @@ func newOutbound(t *Transport, peerChooser peer.Chooser, options ...OutboundOpti
 
 // Start implements transport.Lifecycle#Start.
 func (o *Outbound) Start() error {
-	return o.once.Start(o.peerChooser.Start)
+	return o.once.Start(func() error {
+		if name := o.options.compressor; name != "" && encoding.GetCompressor(name) == nil {
+			return fmt.Errorf("compressor %q is not registered with gRPC, see RegisterCompressor", name)
+		}
+		return o.peerChooser.Start()
+	})
 }
 
 // Stop implements transport.Lifecycle#Stop.
@@ -144,6 +151,9 @@ func (o *Outbound) invoke(
 	if responseMD != nil {
 		callOptions = []grpc.CallOption{grpc.Trailer(responseMD)}
 	}
+	if o.options.compressor != "" {
+		callOptions = append(callOptions, grpc.UseCompressor(o.options.compressor))
+	}
 	apiPeer, onFinish, err := o.peerChooser.Choose(ctx, request)
 	if err != nil {
 		return err
@@ -233,7 +243,8 @@ func invokeErrorToYARPCError(err error, responseMD metadata.MD) error {
 	} else if name != "" && message == name {
 		message = ""
 	}
-	return intyarpcerrors.NewWithNamef(code, name, message)
+	return intyarpcerrors.NewWithNamef(code, name, message).
+		WithDetails(intyarpcerrors.DetailsFromProto(status.Proto().GetDetails())...)
 }
 
 // CallStream implements transport.StreamOutbound#CallStream.
@@ -291,6 +302,11 @@ func (o *Outbound) stream(
 		return nil, err
 	}
 
+	var callOptions []grpc.CallOption
+	if o.options.compressor != "" {
+		callOptions = []grpc.CallOption{grpc.UseCompressor(o.options.compressor)}
+	}
+
 	streamCtx := metadata.NewOutgoingContext(ctx, md)
 	clientStream, err := grpcPeer.clientConn.NewStream(
 		streamCtx,
@@ -299,6 +315,7 @@ func (o *Outbound) stream(
 			ServerStreams: true,
 		},
 		fullMethod,
+		callOptions...,
 	)
 	if err != nil {
 		span.Finish()
@@ -23,12 +23,15 @@ package grpc
 import (
 	"context"
 	"sync"
+	"time"
 
 	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/internal/healthcheck"
 	"go.uber.org/yarpc/peer/hostport"
 	"go.uber.org/yarpc/yarpcerrors"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/metadata"
 )
 
 type grpcPeer struct {
@@ -42,6 +45,13 @@ type grpcPeer struct {
 	stopping   bool
 	stopped    bool
 	stoppedErr error
+
+	// The status of the peer is the status of its connection, unless it
+	// failed health checks.
+	statusLock       sync.Mutex
+	connectionStatus peer.ConnectionStatus
+	healthy          bool
+	checkHealthNow   chan struct{}
 }
 
 func (t *Transport) newPeer(address string, options *dialOptions) (*grpcPeer, error) {
@@ -64,8 +74,14 @@ func (t *Transport) newPeer(address string, options *dialOptions) (*grpcPeer, er
 		clientConn: clientConn,
 		stoppingC:  make(chan struct{}, 1),
 		stoppedC:   make(chan error, 1),
+		// Peers must pass a health check to become available.
+		healthy:        !t.options.healthCheck.Enabled(),
+		checkHealthNow: make(chan struct{}, 1),
 	}
 	go grpcPeer.monitor()
+	if t.options.healthCheck.Enabled() {
+		go grpcPeer.monitorHealth()
+	}
 	return grpcPeer, nil
 }
 
@@ -90,7 +106,7 @@ func (p *grpcPeer) monitor() {
 				p.monitorStop(err)
 				return
 			}
-			p.Peer.SetStatus(peerConnectionStatus)
+			p.setConnectionStatus(peerConnectionStatus)
 		}
 
 		var ctx context.Context
@@ -162,6 +178,86 @@ func (p *grpcPeer) monitorLoopWait(ctx context.Context, cancel context.CancelFun
 	return connectivityState, loop
 }
 
+// monitorHealth checks the health of the peer periodically while it is
+// connected, and as soon as it connects.
+func (p *grpcPeer) monitorHealth() {
+	opts := p.t.options.healthCheck
+	tracker := healthcheck.NewTracker(opts)
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-p.checkHealthNow:
+		case <-p.stoppingC:
+			return
+		case <-p.t.once.Stopping():
+			return
+		}
+
+		if p.clientConn.GetState() != connectivity.Ready {
+			tracker.Reset()
+			continue
+		}
+		healthy := p.checkHealth()
+		if unhealthy := tracker.Record(healthy); healthy || unhealthy {
+			p.setHealthy(healthy)
+		}
+	}
+}
+
+// checkHealth calls the health procedure of the peer and returns whether it
+// succeeded.
+func (p *grpcPeer) checkHealth() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), p.t.options.healthCheck.Timeout)
+	defer cancel()
+
+	request := healthcheck.Request()
+	md, err := transportRequestToMetadata(request)
+	if err != nil {
+		return false
+	}
+	fullMethod, err := procedureNameToFullMethod(request.Procedure)
+	if err != nil {
+		return false
+	}
+	var responseBody []byte
+	err = p.clientConn.Invoke(metadata.NewOutgoingContext(ctx, md), fullMethod, []byte{}, &responseBody)
+	return err == nil
+}
+
+func (p *grpcPeer) setConnectionStatus(status peer.ConnectionStatus) {
+	p.statusLock.Lock()
+	defer p.statusLock.Unlock()
+
+	if status == peer.Available && p.connectionStatus != peer.Available {
+		select {
+		case p.checkHealthNow <- struct{}{}:
+		default:
+		}
+	}
+	p.connectionStatus = status
+	p.updateStatus()
+}
+
+func (p *grpcPeer) setHealthy(healthy bool) {
+	p.statusLock.Lock()
+	defer p.statusLock.Unlock()
+
+	p.healthy = healthy
+	p.updateStatus()
+}
+
+// updateStatus must be called with statusLock held.
+func (p *grpcPeer) updateStatus() {
+	status := p.connectionStatus
+	if status == peer.Available && !p.healthy {
+		status = peer.Unavailable
+	}
+	p.Peer.SetStatus(status)
+}
+
 func (p *grpcPeer) stop() {
 	p.lock.Lock()
 	defer p.lock.Unlock()
@@ -22,10 +22,13 @@ package grpc
 
 import (
 	"context"
+	"errors"
 	"net"
 	"testing"
 	"time"
 
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
 	"go.uber.org/yarpc/api/backoff"
 	"go.uber.org/yarpc/api/peer"
 	"go.uber.org/yarpc/api/transport"
@@ -100,3 +103,66 @@ func TestPeerIntegration(t *testing.T) {
 	t.Skip("Skipping due to test flakiness")
 	spec.Test(t)
 }
+
+// healthRouter serves health checks that fail while the server is unhealthy.
+type healthRouter struct {
+	healthy *atomic.Bool
+}
+
+func (r healthRouter) Procedures() []transport.Procedure {
+	return nil
+}
+
+func (r healthRouter) Choose(context.Context, *transport.Request) (transport.HandlerSpec, error) {
+	return transport.NewUnaryHandlerSpec(r), nil
+}
+
+func (r healthRouter) Handle(context.Context, *transport.Request, transport.ResponseWriter) error {
+	if !r.healthy.Load() {
+		return errors.New("unhealthy")
+	}
+	return nil
+}
+
+func TestPeerHealthCheck(t *testing.T) {
+	healthy := atomic.NewBool(true)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	serverTransport := NewTransport()
+	inbound := serverTransport.NewInbound(listener)
+	inbound.SetRouter(healthRouter{healthy: healthy})
+	require.NoError(t, serverTransport.Start())
+	defer serverTransport.Stop()
+	require.NoError(t, inbound.Start())
+	defer inbound.Stop()
+
+	clientTransport := NewTransport(
+		BackoffStrategy(backoff.None),
+		HealthCheckInterval(5*time.Millisecond),
+		HealthCheckFailures(2),
+	)
+	require.NoError(t, clientTransport.Start())
+	defer clientTransport.Stop()
+
+	pid := hostport.Identify(listener.Addr().String())
+	p, err := clientTransport.RetainPeer(pid, testPeerSubscriber{})
+	require.NoError(t, err)
+	defer clientTransport.ReleasePeer(pid, testPeerSubscriber{})
+
+	waitForStatus := func(want peer.ConnectionStatus) {
+		deadline := time.Now().Add(time.Second)
+		for p.Status().ConnectionStatus != want {
+			if time.Now().After(deadline) {
+				require.FailNow(t, "timed out waiting for peer status", "want %v", want)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	waitForStatus(peer.Available)
+	healthy.Store(false)
+	waitForStatus(peer.Unavailable)
+	healthy.Store(true)
+	waitForStatus(peer.Available)
+}
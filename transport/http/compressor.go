@@ -0,0 +1,112 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package http
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"go.uber.org/multierr"
+	"go.uber.org/yarpc/api/transport"
+)
+
+const (
+	contentEncodingHeader = "Content-Encoding"
+	acceptEncodingHeader  = "Accept-Encoding"
+
+	identityEncoding = "identity"
+)
+
+// Compressor specifies that the outbound should compress request bodies with
+// the given compressor. The outbound advertises the compressor in the
+// Accept-Encoding header of its requests and decompresses responses that use
+// it.
+//
+// Inbounds must know about the compressor, see the Compressors
+// TransportOption.
+func Compressor(compressor transport.Compressor) OutboundOption {
+	return func(o *Outbound) {
+		o.compressor = compressor
+	}
+}
+
+// compress returns a copy of the given body compressed with the given
+// compressor.
+func compress(compressor transport.Compressor, body io.Reader) (*bytes.Buffer, error) {
+	var buf bytes.Buffer
+	w, err := compressor.Compress(&buf)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(w, body); err != nil {
+		return nil, multierr.Append(err, w.Close())
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
+
+// decompressedBody reads the decompressed contents of a body and closes both
+// the decompressor and the body.
+type decompressedBody struct {
+	io.ReadCloser
+
+	body io.Closer
+}
+
+func decompress(compressor transport.Compressor, body io.ReadCloser) (io.ReadCloser, error) {
+	r, err := compressor.Decompress(body)
+	if err != nil {
+		return nil, err
+	}
+	return decompressedBody{ReadCloser: r, body: body}, nil
+}
+
+func (b decompressedBody) Close() error {
+	return multierr.Append(b.ReadCloser.Close(), b.body.Close())
+}
+
+// acceptedCompressor returns the first compressor in the given
+// Accept-Encoding header value that is known, or nil if there are none.
+// Quality values are ignored, except for q=0, which marks an encoding as not
+// acceptable.
+func acceptedCompressor(compressors map[string]transport.Compressor, acceptEncoding string) transport.Compressor {
+	if len(compressors) == 0 || acceptEncoding == "" {
+		return nil
+	}
+	for _, item := range strings.Split(acceptEncoding, ",") {
+		name, params := item, ""
+		if i := strings.IndexByte(item, ';'); i >= 0 {
+			name, params = item[:i], item[i+1:]
+		}
+		if c, ok := compressors[strings.TrimSpace(name)]; ok && !isZeroQuality(params) {
+			return c
+		}
+	}
+	return nil
+}
+
+func isZeroQuality(params string) bool {
+	params = strings.Replace(params, " ", "", -1)
+	return params == "q=0" || strings.HasPrefix(params, "q=0.") && strings.Trim(params[len("q=0."):], "0") == ""
+}
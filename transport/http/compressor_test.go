@@ -0,0 +1,213 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package http
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/opentracing/opentracing-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/transport/transporttest"
+	yarpcgzip "go.uber.org/yarpc/compressor/gzip"
+	yarpcsnappy "go.uber.org/yarpc/compressor/snappy"
+	"go.uber.org/yarpc/encoding/raw"
+	"go.uber.org/yarpc/internal/testtime"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+func TestAcceptedCompressor(t *testing.T) {
+	gzip := yarpcgzip.New()
+	snappy := yarpcsnappy.New()
+	compressors := map[string]transport.Compressor{
+		gzip.Name():   gzip,
+		snappy.Name(): snappy,
+	}
+
+	tests := []struct {
+		give string
+		want transport.Compressor
+	}{
+		{give: "", want: nil},
+		{give: "identity", want: nil},
+		{give: "gzip", want: gzip},
+		{give: "br, snappy, gzip", want: snappy},
+		{give: "gzip;q=0, snappy;q=0.5", want: snappy},
+		{give: "gzip; q=0.000", want: nil},
+		{give: "gzip;q=0.01", want: gzip},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.give, func(t *testing.T) {
+			assert.Equal(t, tt.want, acceptedCompressor(compressors, tt.give))
+		})
+	}
+
+	assert.Nil(t, acceptedCompressor(nil, "gzip"))
+}
+
+func TestHandlerCompression(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	gzip := yarpcgzip.New()
+	payload := strings.Repeat("Nyuck ", 100)
+
+	router := transporttest.NewMockRouter(mockCtrl)
+	rpcHandler := transporttest.NewMockUnaryHandler(mockCtrl)
+	router.EXPECT().Choose(gomock.Any(), gomock.Any()).Return(transport.NewUnaryHandlerSpec(rpcHandler), nil)
+	rpcHandler.EXPECT().Handle(gomock.Any(), gomock.Any(), gomock.Any()).Do(
+		func(_ context.Context, req *transport.Request, rw transport.ResponseWriter) {
+			body, err := ioutil.ReadAll(req.Body)
+			require.NoError(t, err)
+			assert.Equal(t, payload, string(body), "request body must be decompressed")
+			_, err = rw.Write(body)
+			require.NoError(t, err)
+		}).Return(nil)
+
+	body, err := compress(gzip, strings.NewReader(payload))
+	require.NoError(t, err)
+
+	httpHandler := handler{
+		router:            router,
+		tracer:            &opentracing.NoopTracer{},
+		bothResponseError: true,
+		compressors:       map[string]transport.Compressor{gzip.Name(): gzip},
+	}
+	req := &http.Request{
+		Method: "POST",
+		Header: http.Header{
+			CallerHeader:                         {"moe"},
+			EncodingHeader:                       {"raw"},
+			ProcedureHeader:                      {"nyuck"},
+			ServiceHeader:                        {"curly"},
+			http.CanonicalHeaderKey(TTLMSHeader): {"1000"},
+			contentEncodingHeader:                {"gzip"},
+			acceptEncodingHeader:                 {"br, gzip"},
+		},
+		Body: ioutil.NopCloser(body),
+	}
+	rw := httptest.NewRecorder()
+	httpHandler.ServeHTTP(rw, req)
+	require.Equal(t, http.StatusOK, rw.Code)
+	require.Equal(t, "gzip", rw.Header().Get(contentEncodingHeader))
+
+	res, err := decompress(gzip, ioutil.NopCloser(rw.Body))
+	require.NoError(t, err)
+	resBody, err := ioutil.ReadAll(res)
+	require.NoError(t, err)
+	assert.Equal(t, payload, string(resBody), "response body must be compressed")
+}
+
+func TestHandlerUnsupportedContentEncoding(t *testing.T) {
+	httpHandler := handler{tracer: &opentracing.NoopTracer{}, bothResponseError: true}
+	req := &http.Request{
+		Method: "POST",
+		Header: http.Header{
+			CallerHeader:                         {"moe"},
+			EncodingHeader:                       {"raw"},
+			ProcedureHeader:                      {"nyuck"},
+			ServiceHeader:                        {"curly"},
+			http.CanonicalHeaderKey(TTLMSHeader): {"1000"},
+			contentEncodingHeader:                {"zstd"},
+		},
+		Body: ioutil.NopCloser(bytes.NewReader([]byte("Nyuck Nyuck"))),
+	}
+	rw := httptest.NewRecorder()
+	httpHandler.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Equal(t, "invalid-argument", rw.Header().Get(ErrorCodeHeader))
+	assert.Contains(t, rw.Body.String(), `unsupported Content-Encoding "zstd"`)
+}
+
+func TestOutboundCompression(t *testing.T) {
+	gzip := yarpcgzip.New()
+	payload := strings.Repeat("Nyuck ", 100)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "gzip", req.Header.Get(contentEncodingHeader))
+		assert.Equal(t, "gzip", req.Header.Get(acceptEncodingHeader))
+
+		body, err := decompress(gzip, req.Body)
+		require.NoError(t, err)
+		compressed, err := compress(gzip, body)
+		require.NoError(t, err)
+
+		w.Header().Set(contentEncodingHeader, "gzip")
+		_, err = compressed.WriteTo(w)
+		assert.NoError(t, err)
+	}))
+	defer server.Close()
+
+	out := NewTransport().NewSingleOutbound(server.URL, Compressor(gzip))
+	require.NoError(t, out.Start())
+	defer out.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+	res, err := out.Call(ctx, &transport.Request{
+		Caller:    "moe",
+		Service:   "curly",
+		Encoding:  raw.Encoding,
+		Procedure: "nyuck",
+		Body:      strings.NewReader(payload),
+	})
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	require.NoError(t, err)
+	assert.Equal(t, payload, string(body))
+}
+
+func TestOutboundInvalidCompressedResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set(contentEncodingHeader, "gzip")
+		_, err := w.Write([]byte("not gzip"))
+		assert.NoError(t, err)
+	}))
+	defer server.Close()
+
+	out := NewTransport().NewSingleOutbound(server.URL, Compressor(yarpcgzip.New()))
+	require.NoError(t, out.Start())
+	defer out.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+	_, err := out.Call(ctx, &transport.Request{
+		Caller:    "moe",
+		Service:   "curly",
+		Encoding:  raw.Encoding,
+		Procedure: "nyuck",
+		Body:      strings.NewReader("Nyuck"),
+	})
+	require.Error(t, err)
+	assert.Equal(t, yarpcerrors.CodeInternal, yarpcerrors.FromError(err).Code())
+	assert.Contains(t, err.Error(), "failed to decompress gzip response body")
+}
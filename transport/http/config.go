@@ -27,6 +27,7 @@ import (
 	"go.uber.org/yarpc/api/transport"
 	"go.uber.org/yarpc/peer/hostport"
 	"go.uber.org/yarpc/yarpcconfig"
+	"golang.org/x/net/http2"
 )
 
 // TransportSpec returns a TransportSpec for the HTTP transport.
@@ -72,6 +73,7 @@ func (ts *transportSpec) Spec() yarpcconfig.TransportSpec {
 		BuildInbound:        ts.buildInbound,
 		BuildUnaryOutbound:  ts.buildUnaryOutbound,
 		BuildOnewayOutbound: ts.buildOnewayOutbound,
+		BuildStreamOutbound: ts.buildStreamOutbound,
 	}
 }
 
@@ -91,6 +93,15 @@ func (ts *transportSpec) Spec() yarpcconfig.TransportSpec {
 //        exponential:
 //          first: 10ms
 //          max: 30s
+//      healthCheck:
+//        interval: 5s
+//        timeout: 1s
+//        failures: 3
+//      tls:
+//        enabled: true
+//        caFile: /etc/certs/ca.pem
+//      http2: true
+//      maxConcurrentStreams: 100
 //
 // All parameters of TransportConfig are optional. This section may be omitted
 // in the transports section.
@@ -106,6 +117,17 @@ type TransportConfig struct {
 	ResponseHeaderTimeout time.Duration       `config:"responseHeaderTimeout"`
 	ConnTimeout           time.Duration       `config:"connTimeout"`
 	ConnBackoff           yarpcconfig.Backoff `config:"connBackoff"`
+	// Configures active health checking of peers. This field is optional.
+	HealthCheck yarpcconfig.HealthCheck `config:"healthCheck"`
+	// Configures TLS for all outbounds of this transport. This field is
+	// optional. See yarpcconfig.OutboundTLS for details.
+	TLS yarpcconfig.OutboundTLS `config:"tls"`
+	// Sends requests over HTTP/2 instead of HTTP/1.1. Peers must accept h2c
+	// unless TLS is enabled. This field is optional.
+	HTTP2 bool `config:"http2"`
+	// Limits the number of requests in flight on each HTTP/2 connection,
+	// opening more connections to peers as needed. This field is optional.
+	MaxConcurrentStreams int `config:"maxConcurrentStreams"`
 }
 
 func (ts *transportSpec) buildTransport(tc *TransportConfig, k *yarpcconfig.Kit) (transport.Transport, error) {
@@ -136,12 +158,43 @@ func (ts *transportSpec) buildTransport(tc *TransportConfig, k *yarpcconfig.Kit)
 	if tc.ConnTimeout > 0 {
 		options.connTimeout = tc.ConnTimeout
 	}
+	if tc.HTTP2 {
+		options.http2 = true
+	}
+	if tc.MaxConcurrentStreams < 0 {
+		return nil, fmt.Errorf("maxConcurrentStreams must not be negative, got: %v", tc.MaxConcurrentStreams)
+	}
+	if tc.MaxConcurrentStreams > 0 {
+		options.maxConcurrentStreams = tc.MaxConcurrentStreams
+	}
 
 	strategy, err := tc.ConnBackoff.Strategy()
 	if err != nil {
 		return nil, err
 	}
 	options.connBackoffStrategy = strategy
+	options.compressors = append(options.compressors, k.Compressors()...)
+
+	if tc.HealthCheck.Interval < 0 || tc.HealthCheck.Timeout < 0 || tc.HealthCheck.Failures < 0 {
+		return nil, fmt.Errorf("health check interval, timeout and failures must not be negative")
+	}
+	if tc.HealthCheck.Interval > 0 {
+		options.healthCheck.Interval = tc.HealthCheck.Interval
+	}
+	if tc.HealthCheck.Timeout > 0 {
+		options.healthCheck.Timeout = tc.HealthCheck.Timeout
+	}
+	if tc.HealthCheck.Failures > 0 {
+		options.healthCheck.Failures = tc.HealthCheck.Failures
+	}
+
+	tlsConfig, err := tc.TLS.ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("cannot configure TLS for HTTP transport: %v", err)
+	}
+	if tlsConfig != nil {
+		options.tlsConfig = tlsConfig
+	}
 
 	return options.newTransport(), nil
 }
@@ -155,6 +208,12 @@ func (ts *transportSpec) buildTransport(tc *TransportConfig, k *yarpcconfig.Kit)
 //        - x-foo
 //        - x-bar
 //      shutdownTimeout: 5s
+//      tls:
+//        enabled: true
+//        certFile: /etc/certs/server.pem
+//        keyFile: /etc/certs/server-key.pem
+//      h2c: true
+//      maxConcurrentStreams: 250
 type InboundConfig struct {
 	// Address to listen on. This field is required.
 	Address string `config:"address,interpolate"`
@@ -163,6 +222,15 @@ type InboundConfig struct {
 	GrabHeaders []string `config:"grabHeaders"`
 	// The maximum amount of time to wait for the inbound to shutdown.
 	ShutdownTimeout *time.Duration `config:"shutdownTimeout"`
+	// Configures the inbound to serve requests over TLS. This field is
+	// optional. See yarpcconfig.InboundTLS for details.
+	TLS yarpcconfig.InboundTLS `config:"tls"`
+	// Accepts HTTP/2 requests over cleartext in addition to HTTP/1.1. This
+	// field is optional.
+	H2C bool `config:"h2c"`
+	// Limits the number of requests each HTTP/2 connection may have in
+	// flight. This field is optional.
+	MaxConcurrentStreams uint32 `config:"maxConcurrentStreams"`
 }
 
 func (ts *transportSpec) buildInbound(ic *InboundConfig, t transport.Transport, k *yarpcconfig.Kit) (transport.Inbound, error) {
@@ -181,6 +249,24 @@ func (ts *transportSpec) buildInbound(ic *InboundConfig, t transport.Transport,
 		inboundOptions = append(inboundOptions, ShutdownTimeout(*ic.ShutdownTimeout))
 	}
 
+	tlsConfig, err := ic.TLS.ServerConfig()
+	if err != nil {
+		return nil, fmt.Errorf("cannot configure TLS for HTTP inbound: %v", err)
+	}
+	if tlsConfig != nil {
+		// Connections may be handled by copies of this configuration, see
+		// yarpcconfig.InboundTLS, so the protocols the server adds to its own
+		// copy are offered here as well.
+		tlsConfig.NextProtos = []string{http2.NextProtoTLS, "http/1.1"}
+		inboundOptions = append(inboundOptions, InboundTLSConfig(tlsConfig))
+	}
+	if ic.H2C {
+		inboundOptions = append(inboundOptions, H2C())
+	}
+	if ic.MaxConcurrentStreams > 0 {
+		inboundOptions = append(inboundOptions, MaxConcurrentStreams(ic.MaxConcurrentStreams))
+	}
+
 	return t.(*Transport).NewInbound(ic.Address, inboundOptions...), nil
 }
 
@@ -231,6 +317,27 @@ type OutboundConfig struct {
 	//      X-Caller: myserice
 	//      X-Token: foo
 	AddHeaders map[string]string `config:"addHeaders"`
+
+	// Name of the compressor used for request bodies, if any. The compressor
+	// must be registered with the Configurator.
+	//
+	//  http:
+	//    url: "http://localhost:8080/yarpc"
+	//    compressor: gzip
+	Compressor string `config:"compressor"`
+
+	// Configures this outbound to send requests over TLS, overriding the
+	// TLS configuration of the transport. Requests are sent to https URLs
+	// when TLS is enabled.
+	//
+	//  http:
+	//    url: "https://myservice.example.com/yarpc"
+	//    tls:
+	//      enabled: true
+	//      certFile: /etc/certs/client.pem
+	//      keyFile: /etc/certs/client-key.pem
+	//      caFile: /etc/certs/ca.pem
+	TLS yarpcconfig.OutboundTLS `config:"tls"`
 }
 
 func (ts *transportSpec) buildOutbound(oc *OutboundConfig, t transport.Transport, k *yarpcconfig.Kit) (*Outbound, error) {
@@ -242,6 +349,20 @@ func (ts *transportSpec) buildOutbound(oc *OutboundConfig, t transport.Transport
 			opts = append(opts, AddHeader(k, v))
 		}
 	}
+	if oc.Compressor != "" {
+		compressor, err := k.Compressor(oc.Compressor)
+		if err != nil {
+			return nil, fmt.Errorf("cannot configure compressor for HTTP outbound: %v", err)
+		}
+		opts = append(opts, Compressor(compressor))
+	}
+	tlsConfig, err := oc.TLS.ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("cannot configure TLS for HTTP outbound: %v", err)
+	}
+	if tlsConfig != nil {
+		opts = append(opts, OutboundTLSConfig(tlsConfig))
+	}
 
 	// Special case where the URL implies the single peer.
 	if oc.Empty() {
@@ -266,3 +387,7 @@ func (ts *transportSpec) buildUnaryOutbound(oc *OutboundConfig, t transport.Tran
 func (ts *transportSpec) buildOnewayOutbound(oc *OutboundConfig, t transport.Transport, k *yarpcconfig.Kit) (transport.OnewayOutbound, error) {
 	return ts.buildOutbound(oc, t, k)
 }
+
+func (ts *transportSpec) buildStreamOutbound(oc *OutboundConfig, t transport.Transport, k *yarpcconfig.Kit) (transport.StreamOutbound, error) {
+	return ts.buildOutbound(oc, t, k)
+}
@@ -28,6 +28,8 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	yarpcgzip "go.uber.org/yarpc/compressor/gzip"
+	"go.uber.org/yarpc/internal/healthcheck"
 	"go.uber.org/yarpc/yarpcconfig"
 )
 
@@ -61,6 +63,8 @@ func TestTransportSpec(t *testing.T) {
 		MuxPattern      string
 		GrabHeaders     map[string]struct{}
 		ShutdownTimeout time.Duration
+		H2C             bool
+		MaxStreams      uint32
 	}
 
 	type inboundTest struct {
@@ -78,6 +82,7 @@ func TestTransportSpec(t *testing.T) {
 	type wantOutbound struct {
 		URLTemplate string
 		Headers     http.Header
+		Compressor  string
 	}
 
 	type outboundTest struct {
@@ -136,6 +141,36 @@ func TestTransportSpec(t *testing.T) {
 				ResponseHeaderTimeout: 1 * time.Second,
 			},
 		},
+		{
+			desc: "http2 config",
+			cfg: attrs{
+				"http2":                true,
+				"maxConcurrentStreams": 100,
+			},
+			wantClient: &wantHTTPClient{
+				KeepAlive:            30 * time.Second,
+				MaxIdleConnsPerHost:  2,
+				ConnTimeout:          defaultConnTimeout,
+				HTTP2:                true,
+				MaxConcurrentStreams: 100,
+			},
+		},
+		{
+			desc: "health check config",
+			cfg: attrs{
+				"healthCheck": attrs{"interval": "5s", "failures": 2},
+			},
+			wantClient: &wantHTTPClient{
+				KeepAlive:           30 * time.Second,
+				MaxIdleConnsPerHost: 2,
+				ConnTimeout:         defaultConnTimeout,
+				HealthCheck: healthcheck.Options{
+					Interval: 5 * time.Second,
+					Timeout:  time.Second,
+					Failures: 2,
+				},
+			},
+		},
 	}
 
 	serveMux := http.NewServeMux()
@@ -195,6 +230,27 @@ func TestTransportSpec(t *testing.T) {
 			cfg:        attrs{"address": ":8080", "shutdownTimeout": "-1s"},
 			wantErrors: []string{`shutdownTimeout must not be negative, got: "-1s"`},
 		},
+		{
+			desc: "h2c",
+			cfg:  attrs{"address": ":8080", "h2c": true, "maxConcurrentStreams": 50},
+			wantInbound: &wantInbound{
+				Address:         ":8080",
+				ShutdownTimeout: defaultShutdownTimeout,
+				H2C:             true,
+				MaxStreams:      50,
+			},
+		},
+		{
+			desc: "tls without key",
+			cfg: attrs{
+				"address": ":8080",
+				"tls":     attrs{"enabled": true, "certFile": "cert.pem"},
+			},
+			wantErrors: []string{
+				"cannot configure TLS for HTTP inbound",
+				"both certFile and keyFile are required for TLS",
+			},
+		},
 	}
 
 	outboundTests := []outboundTest{
@@ -329,6 +385,53 @@ func TestTransportSpec(t *testing.T) {
 				},
 			},
 		},
+		{
+			desc: "outbound compressor",
+			cfg: attrs{
+				"myservice": attrs{
+					"http": attrs{
+						"url":        "http://localhost/yarpc",
+						"compressor": "gzip",
+					},
+				},
+			},
+			wantOutbounds: map[string]wantOutbound{
+				"myservice": {
+					URLTemplate: "http://localhost/yarpc",
+					Compressor:  "gzip",
+				},
+			},
+		},
+		{
+			desc: "outbound unknown compressor",
+			cfg: attrs{
+				"myservice": attrs{
+					"http": attrs{
+						"url":        "http://localhost/yarpc",
+						"compressor": "zstd",
+					},
+				},
+			},
+			wantErrors: []string{
+				"cannot configure compressor for HTTP outbound",
+				`no recognized compressor "zstd"; need one of gzip`,
+			},
+		},
+		{
+			desc: "outbound tls config error",
+			cfg: attrs{
+				"myservice": attrs{
+					"http": attrs{
+						"url": "https://localhost/yarpc",
+						"tls": attrs{"enabled": true, "keyFile": "key.pem"},
+					},
+				},
+			},
+			wantErrors: []string{
+				"cannot configure TLS for HTTP outbound",
+				"certFile and keyFile must be given together",
+			},
+		},
 		{
 			desc: "outbound peer build error",
 			cfg: attrs{
@@ -387,6 +490,7 @@ func TestTransportSpec(t *testing.T) {
 		}
 		err := configurator.RegisterTransport(TransportSpec(opts...))
 		require.NoError(t, err, "failed to register transport spec")
+		configurator.MustRegisterCompressor(yarpcgzip.New())
 
 		cfgData := make(attrs)
 		if trans.cfg != nil {
@@ -428,6 +532,9 @@ func TestTransportSpec(t *testing.T) {
 					assert.Empty(t, ib.grabHeaders)
 				}
 				assert.Equal(t, want.ShutdownTimeout, ib.shutdownTimeout, "shutdownTimeout should match")
+				assert.Equal(t, want.H2C, ib.h2c, "h2c should match")
+				assert.Equal(t, want.MaxStreams, ib.maxConcurrentStreams, "maxConcurrentStreams should match")
+				assert.Contains(t, ib.transport.compressors, yarpcgzip.Name, "registered compressors must be available to inbounds")
 			}
 		}
 
@@ -438,8 +545,17 @@ func TestTransportSpec(t *testing.T) {
 				_, ok := cfg.Outbounds[svc].Oneway.(*Outbound)
 				assert.True(t, ok, "expected *Outbound for %q oneway, got %T", svc, cfg.Outbounds[svc].Oneway)
 
+				// And a stream outbound
+				_, ok = cfg.Outbounds[svc].Stream.(*Outbound)
+				assert.True(t, ok, "expected *Outbound for %q stream, got %T", svc, cfg.Outbounds[svc].Stream)
+
 				assert.Equal(t, want.URLTemplate, ob.urlTemplate.String(), "outbound URLTemplate should match")
 				assert.Equal(t, want.Headers, ob.headers, "outbound headers should match")
+				if want.Compressor != "" {
+					assert.Equal(t, want.Compressor, ob.compressor.Name(), "outbound compressor should match")
+				} else {
+					assert.Nil(t, ob.compressor, "outbound must not have a compressor")
+				}
 			}
 
 		}
@@ -481,6 +597,9 @@ type wantHTTPClient struct {
 	DisableCompression    bool
 	ResponseHeaderTimeout time.Duration
 	ConnTimeout           time.Duration
+	HTTP2                 bool
+	MaxConcurrentStreams  int
+	HealthCheck           healthcheck.Options // not checked if zero
 }
 
 // useFakeBuildClient verifies the configuration we use to build an HTTP
@@ -496,6 +615,11 @@ func useFakeBuildClient(t *testing.T, want *wantHTTPClient) TransportOption {
 		assert.Equal(t, want.DisableCompression, options.disableCompression, "http.Client: DisableCompression should match")
 		assert.Equal(t, want.ResponseHeaderTimeout, options.responseHeaderTimeout, "http.Client: ResponseHeaderTimeout should match")
 		assert.Equal(t, want.ConnTimeout, options.connTimeout, "http.Client: ConnTimeout should match")
+		assert.Equal(t, want.HTTP2, options.http2, "http.Client: HTTP2 should match")
+		assert.Equal(t, want.MaxConcurrentStreams, options.maxConcurrentStreams, "http.Client: MaxConcurrentStreams should match")
+		if want.HealthCheck != (healthcheck.Options{}) {
+			assert.Equal(t, want.HealthCheck, options.healthCheck, "health check options should match")
+		}
 		return buildHTTPClient(options)
 	})
 }
@@ -43,6 +43,12 @@ const (
 	// to finish.
 	TTLMSHeader = "Context-TTL-MS"
 
+	// Amount of time within which the request is expected to finish, in the
+	// format used by gRPC. This is understood for interoperability with
+	// gRPC-aware clients and proxies. If both this and Context-TTL-MS are
+	// given, the earlier deadline applies.
+	GRPCTimeoutHeader = "Grpc-Timeout"
+
 	// Name of the procedure being called. This corresponds to the
 	// Request.Procedure attribute.
 	ProcedureHeader = "Rpc-Procedure"
@@ -77,6 +83,11 @@ const (
 	// BothResponseError feature is enabled.
 	ErrorMessageHeader = "Rpc-Error-Message"
 
+	// ErrorDetailsHeader contains the details of an error, if any. They are
+	// encoded as a google.rpc.Status Protobuf message with only the details
+	// field set, in base64.
+	ErrorDetailsHeader = "Rpc-Error-Details"
+
 	// AcceptsBothResponseErrorHeader says that the BothResponseError
 	// feature is supported on the client. If the value is "true",
 	// this indicates true.
@@ -86,6 +97,13 @@ const (
 	// feature is supported on the server. If any non-empty value is set,
 	// this indicates true.
 	BothResponseErrorHeader = "Rpc-Both-Response-Error"
+
+	// StreamHeader says that the request opens a stream, or that the
+	// response is for a stream, if the value is "true". The bodies of
+	// stream requests and responses are sequences of messages, each
+	// prefixed by its length as a 4-byte big-endian integer. Errors that
+	// end a stream are reported in the response trailers.
+	StreamHeader = "Rpc-Stream"
 )
 
 // Valid values for the Rpc-Status header.
@@ -20,7 +20,7 @@
 
 // Package http implements a YARPC transport based on the HTTP/1.1 protocol.
 // The HTTP transport provides first class support for Unary RPCs and
-// experimental support for Oneway RPCs.
+// experimental support for Oneway and Streaming RPCs.
 //
 // Usage
 //
@@ -66,6 +66,19 @@
 // the names of these headers. The request and response bodies are sent as-is
 // in the HTTP request or response body.
 //
+// Streams are sent as a single HTTP request and its response, marked by the
+// Rpc-Stream header. Each message in the request or response body is
+// prefixed by its length as a 4-byte big-endian integer. Errors that end a
+// stream are sent as HTTP trailers. Messages flow in both directions at once:
+// over HTTP/1.1 both bodies are chunked and the inbound reads the request
+// while it writes the response, and over HTTP/2 each stream is a single
+// HTTP/2 stream.
+//
+// Requests are sent over HTTP/1.1 by default. With the HTTP2 transport
+// option, outbounds send requests over HTTP/2 instead, multiplexing them over
+// a few connections to each peer. Inbounds accept HTTP/2 over TLS, and over
+// cleartext if given the H2C option.
+//
 // See Also
 //
 // YARPC Properties: https://github.com/yarpc/yarpc/blob/master/properties.md
@@ -24,16 +24,19 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"time"
 
 	"github.com/opentracing/opentracing-go"
 	"github.com/opentracing/opentracing-go/ext"
 	opentracinglog "github.com/opentracing/opentracing-go/log"
+	"go.uber.org/multierr"
 	"go.uber.org/yarpc"
 	"go.uber.org/yarpc/api/transport"
 	"go.uber.org/yarpc/internal/bufferpool"
 	"go.uber.org/yarpc/internal/iopool"
+	intyarpcerrors "go.uber.org/yarpc/internal/yarpcerrors"
 	"go.uber.org/yarpc/pkg/errors"
 	"go.uber.org/yarpc/yarpcerrors"
 	"go.uber.org/zap"
@@ -52,16 +55,22 @@ type handler struct {
 	grabHeaders       map[string]struct{}
 	bothResponseError bool
 	logger            *zap.Logger
+	compressors       map[string]transport.Compressor
 }
 
 func (h handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	responseWriter := newResponseWriter(w)
+	responseWriter.compressor = acceptedCompressor(h.compressors, req.Header.Get(acceptEncodingHeader))
 	service := popHeader(req.Header, ServiceHeader)
 	procedure := popHeader(req.Header, ProcedureHeader)
 	bothResponseError := popHeader(req.Header, AcceptsBothResponseErrorHeader) == AcceptTrue
 	// add response header to echo accepted rpc-service
 	responseWriter.AddSystemHeader(ServiceHeader, service)
 	status := yarpcerrors.FromError(errors.WrapHandlerError(h.callHandler(responseWriter, req, service, procedure), service, procedure))
+	if responseWriter.streaming {
+		responseWriter.closeStream(status, h.logger)
+		return
+	}
 	if status == nil {
 		responseWriter.Close(http.StatusOK)
 		return
@@ -75,6 +84,9 @@ func (h handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	if status.Name() != "" {
 		responseWriter.AddSystemHeader(ErrorNameHeader, status.Name())
 	}
+	if details := intyarpcerrors.DetailsHeader(h.logger, status); details != "" {
+		responseWriter.AddSystemHeader(ErrorDetailsHeader, details)
+	}
 	if bothResponseError && h.bothResponseError {
 		responseWriter.AddSystemHeader(BothResponseErrorHeader, AcceptTrue)
 		responseWriter.AddSystemHeader(ErrorMessageHeader, status.Message())
@@ -92,10 +104,34 @@ func (h handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 
 func (h handler) callHandler(responseWriter *responseWriter, req *http.Request, service string, procedure string) (retErr error) {
 	start := time.Now()
-	defer req.Body.Close()
+	isStream := popHeader(req.Header, StreamHeader) == AcceptTrue
+	// The server closes the request body of a stream after the response
+	// is complete. Closing it earlier waits for the caller to end the
+	// stream.
+	defer func() {
+		if !isStream {
+			_ = req.Body.Close()
+		}
+	}()
 	if req.Method != http.MethodPost {
 		return yarpcerrors.Newf(yarpcerrors.CodeNotFound, "request method was %s but only %s is allowed", req.Method, http.MethodPost)
 	}
+	if isStream && req.ProtoMajor < 2 {
+		// HTTP/1.x servers drain the request body before they write the
+		// response unless told that messages flow both ways at once.
+		if err := http.NewResponseController(responseWriter.w).EnableFullDuplex(); err != nil {
+			return yarpcerrors.UnimplementedErrorf("streaming over %s is not supported by this HTTP server", req.Proto)
+		}
+	}
+	body, err := h.requestBody(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if !isStream {
+			_ = body.Close()
+		}
+	}()
 	treq := &transport.Request{
 		Caller:          popHeader(req.Header, CallerHeader),
 		Service:         service,
@@ -106,7 +142,7 @@ func (h handler) callHandler(responseWriter *responseWriter, req *http.Request,
 		RoutingKey:      popHeader(req.Header, RoutingKeyHeader),
 		RoutingDelegate: popHeader(req.Header, RoutingDelegateHeader),
 		Headers:         applicationHeaders.FromHTTPHeaders(req.Header, transport.Headers{}),
-		Body:            req.Body,
+		Body:            body,
 	}
 	for header := range h.grabHeaders {
 		if value := req.Header.Get(header); value != "" {
@@ -125,7 +161,10 @@ func (h handler) callHandler(responseWriter *responseWriter, req *http.Request,
 	}()
 
 	ctx := req.Context()
-	ctx, cancel, parseTTLErr := parseTTL(ctx, treq, popHeader(req.Header, TTLMSHeader))
+	if id, ok := transport.PeerIdentityFromTLS(req.TLS); ok {
+		ctx = transport.WithPeerIdentity(ctx, id)
+	}
+	ctx, cancel, parseTTLErr := parseDeadline(ctx, treq, req.Header)
 	// parseTTLErr != nil is a problem only if the request is unary.
 	defer cancel()
 	ctx, span := h.createSpan(ctx, req, treq, start)
@@ -139,8 +178,19 @@ func (h handler) callHandler(responseWriter *responseWriter, req *http.Request,
 	if parseTTLErr != nil {
 		return parseTTLErr
 	}
-	if err := transport.ValidateRequestContext(ctx); err != nil {
-		return err
+	if isStream != (spec.Type() == transport.Streaming) {
+		if isStream {
+			return yarpcerrors.InvalidArgumentErrorf(
+				"procedure %q of service %q does not support streaming", procedure, service)
+		}
+		return yarpcerrors.InvalidArgumentErrorf(
+			"procedure %q of service %q requires a stream request", procedure, service)
+	}
+	// Streams may be long-lived so they are not required to have a TTL.
+	if spec.Type() != transport.Streaming {
+		if err := transport.ValidateRequestContext(ctx); err != nil {
+			return err
+		}
 	}
 	switch spec.Type() {
 	case transport.Unary:
@@ -156,7 +206,12 @@ func (h handler) callHandler(responseWriter *responseWriter, req *http.Request,
 		})
 
 	case transport.Oneway:
-		err = handleOnewayRequest(span, treq, spec.Oneway(), h.logger)
+		err = handleOnewayRequest(ctx, span, treq, spec.Oneway(), h.logger)
+
+	case transport.Streaming:
+		defer span.Finish()
+
+		err = h.handleStream(ctx, responseWriter, req, treq, spec.Stream())
 
 	default:
 		err = yarpcerrors.Newf(yarpcerrors.CodeUnimplemented, "transport http does not handle %s handlers", spec.Type().String())
@@ -166,7 +221,26 @@ func (h handler) callHandler(responseWriter *responseWriter, req *http.Request,
 	return err
 }
 
+// requestBody returns the body of the request, decompressed according to its
+// Content-Encoding header.
+func (h handler) requestBody(req *http.Request) (io.ReadCloser, error) {
+	encoding := req.Header.Get(contentEncodingHeader)
+	if encoding == "" || encoding == identityEncoding {
+		return req.Body, nil
+	}
+	compressor, ok := h.compressors[encoding]
+	if !ok {
+		return nil, yarpcerrors.InvalidArgumentErrorf("unsupported Content-Encoding %q", encoding)
+	}
+	body, err := decompress(compressor, req.Body)
+	if err != nil {
+		return nil, yarpcerrors.InvalidArgumentErrorf("failed to decompress %s request body: %v", encoding, err)
+	}
+	return body, nil
+}
+
 func handleOnewayRequest(
+	reqCtx context.Context,
 	span opentracing.Span,
 	treq *transport.Request,
 	onewayHandler transport.OnewayHandler,
@@ -183,6 +257,9 @@ func handleOnewayRequest(
 	// create a new context for oneway requests since the HTTP handler cancels
 	// http.Request's context when ServeHTTP returns
 	ctx := opentracing.ContextWithSpan(context.Background(), span)
+	if id, ok := transport.PeerIdentityFromContext(reqCtx); ok {
+		ctx = transport.WithPeerIdentity(ctx, id)
+	}
 
 	go func() {
 		// ensure the span lasts for length of the handler in case of errors
@@ -238,6 +315,12 @@ func (h handler) createSpan(ctx context.Context, req *http.Request, treq *transp
 type responseWriter struct {
 	w      http.ResponseWriter
 	buffer *bufferpool.Buffer
+
+	// Compressor for the response body, if the caller accepts one.
+	compressor transport.Compressor
+
+	// Whether the response headers were sent for a stream.
+	streaming bool
 }
 
 func newResponseWriter(w http.ResponseWriter) *responseWriter {
@@ -271,6 +354,9 @@ func (rw *responseWriter) ResetBuffer() {
 }
 
 func (rw *responseWriter) Close(httpStatusCode int) {
+	if rw.compressor != nil && rw.buffer != nil && rw.buffer.Len() > 0 {
+		rw.compressBuffer()
+	}
 	rw.w.WriteHeader(httpStatusCode)
 	if rw.buffer != nil {
 		// TODO: what to do with error?
@@ -279,6 +365,24 @@ func (rw *responseWriter) Close(httpStatusCode int) {
 	}
 }
 
+// compressBuffer replaces the buffered body with its compressed form. The
+// body is left uncompressed if compression fails.
+func (rw *responseWriter) compressBuffer() {
+	compressed := bufferpool.Get()
+	w, err := rw.compressor.Compress(compressed)
+	if err == nil {
+		_, err = w.Write(rw.buffer.Bytes())
+		err = multierr.Append(err, w.Close())
+	}
+	if err != nil {
+		bufferpool.Put(compressed)
+		return
+	}
+	bufferpool.Put(rw.buffer)
+	rw.buffer = compressed
+	rw.w.Header().Set(contentEncodingHeader, rw.compressor.Name())
+}
+
 func getContentType(encoding transport.Encoding) string {
 	switch encoding {
 	case "json":
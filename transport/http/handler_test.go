@@ -149,6 +149,23 @@ func TestHandlerHeaders(t *testing.T) {
 			wantTTL:     time.Second,
 			wantHeaders: map[string]string{},
 		},
+		{
+			giveEncoding: "proto",
+			giveHeaders: http.Header{
+				GRPCTimeoutHeader: {"500m"},
+			},
+			wantTTL:     500 * time.Millisecond,
+			wantHeaders: map[string]string{},
+		},
+		{
+			giveEncoding: "raw",
+			giveHeaders: http.Header{
+				TTLMSHeader:       {"1000"},
+				GRPCTimeoutHeader: {"200m"},
+			},
+			wantTTL:     200 * time.Millisecond,
+			wantHeaders: map[string]string{},
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,327 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// http2Transport is an http.RoundTripper that sends requests over HTTP/2:
+// in cleartext (h2c) for http URLs and over TLS for https URLs.
+//
+// Requests to the same host share connections. A new connection is opened
+// only when every open connection to the host has maxStreams requests in
+// flight, or when no connection can take new requests. The connections to a
+// host are closed when the transport releases the peer, and all connections
+// are closed when the transport stops.
+type http2Transport struct {
+	transport  *http2.Transport
+	dialer     *net.Dialer
+	tlsConfig  *tls.Config
+	maxStreams int // 0 for no limit
+
+	mu    sync.Mutex
+	hosts map[string]*http2Host
+}
+
+// http2Host holds the connections to a single host.
+type http2Host struct {
+	// dialMu is held while dialing so that a burst of requests to a new
+	// host opens one connection instead of one for each request.
+	dialMu sync.Mutex
+
+	conns []*http2Conn // guarded by http2Transport.mu
+}
+
+type http2Conn struct {
+	*http2.ClientConn
+
+	// Connections with requests in flight when their host is closed are
+	// closed once those requests finish.
+	streams int  // guarded by http2Transport.mu
+	closing bool // guarded by http2Transport.mu
+}
+
+func newHTTP2Transport(options *transportOptions) *http2Transport {
+	tlsConfig := &tls.Config{}
+	if options.tlsConfig != nil {
+		tlsConfig = options.tlsConfig.Clone()
+	}
+	tlsConfig.NextProtos = []string{http2.NextProtoTLS}
+
+	return &http2Transport{
+		transport: &http2.Transport{
+			AllowHTTP:          true,
+			DisableCompression: options.disableCompression,
+		},
+		dialer: &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: options.keepAlive,
+		},
+		tlsConfig:  tlsConfig,
+		maxStreams: options.maxConcurrentStreams,
+		hosts:      make(map[string]*http2Host),
+	}
+}
+
+func (t *http2Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	addr, err := authorityAddr(req)
+	if err != nil {
+		return nil, err
+	}
+	key := req.URL.Scheme + "://" + addr
+
+	conn, err := t.acquire(req.Context(), key, req.URL.Scheme, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := conn.RoundTrip(req)
+	if err != nil {
+		t.release(conn)
+		return nil, err
+	}
+	res.Body = &releaseBody{ReadCloser: res.Body, release: func() { t.release(conn) }}
+	return res, nil
+}
+
+// acquire returns a connection to the given address that can take another
+// request, dialing a new one if necessary. The caller must release the
+// connection when the request finishes.
+func (t *http2Transport) acquire(ctx context.Context, key, scheme, addr string) (*http2Conn, error) {
+	t.mu.Lock()
+	h, ok := t.hosts[key]
+	if !ok {
+		h = &http2Host{}
+		t.hosts[key] = h
+	}
+	if c := t.pickLocked(h); c != nil {
+		t.mu.Unlock()
+		return c, nil
+	}
+	t.mu.Unlock()
+
+	h.dialMu.Lock()
+	defer h.dialMu.Unlock()
+
+	// Another request may have opened a connection while we waited.
+	t.mu.Lock()
+	if c := t.pickLocked(h); c != nil {
+		t.mu.Unlock()
+		return c, nil
+	}
+	t.mu.Unlock()
+
+	cc, err := t.dial(ctx, scheme, addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &http2Conn{ClientConn: cc, streams: 1}
+
+	t.mu.Lock()
+	if t.hosts[key] == h {
+		h.conns = append(h.conns, c)
+	} else {
+		// The host was closed while dialing.
+		c.closing = true
+	}
+	t.mu.Unlock()
+	return c, nil
+}
+
+// pickLocked returns the first connection of the host that has room for
+// another request and drops connections that cannot take new requests.
+func (t *http2Transport) pickLocked(h *http2Host) *http2Conn {
+	var picked *http2Conn
+	conns := h.conns[:0]
+	for _, c := range h.conns {
+		if !c.CanTakeNewRequest() {
+			// Connections with requests in flight are closed by the
+			// server once those requests finish.
+			if c.streams == 0 {
+				c.Close()
+			}
+			continue
+		}
+		conns = append(conns, c)
+		if picked == nil && (t.maxStreams <= 0 || c.streams < t.maxStreams) {
+			picked = c
+		}
+	}
+	for i := len(conns); i < len(h.conns); i++ {
+		h.conns[i] = nil
+	}
+	h.conns = conns
+
+	if picked != nil {
+		picked.streams++
+	}
+	return picked
+}
+
+func (t *http2Transport) release(c *http2Conn) {
+	t.mu.Lock()
+	c.streams--
+	done := c.closing && c.streams == 0
+	t.mu.Unlock()
+
+	if done {
+		c.Close()
+	}
+}
+
+// closeHost closes the connections to the given host:port address over
+// cleartext and TLS.
+func (t *http2Transport) closeHost(addr string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, key := range []string{"http://" + addr, "https://" + addr} {
+		if h, ok := t.hosts[key]; ok {
+			delete(t.hosts, key)
+			closeConnsLocked(h)
+		}
+	}
+}
+
+// closeAll closes the connections to all hosts.
+func (t *http2Transport) closeAll() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for key, h := range t.hosts {
+		delete(t.hosts, key)
+		closeConnsLocked(h)
+	}
+}
+
+// closeConnsLocked closes the idle connections of a host that was removed
+// from the transport, and marks the others to be closed once idle.
+func closeConnsLocked(h *http2Host) {
+	for _, c := range h.conns {
+		if c.streams == 0 {
+			c.Close()
+		} else {
+			c.closing = true
+		}
+	}
+	h.conns = nil
+}
+
+func (t *http2Transport) dial(ctx context.Context, scheme, addr string) (*http2.ClientConn, error) {
+	conn, err := t.dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if scheme == "https" {
+		conn, err = t.handshake(ctx, conn, addr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cc, err := t.transport.NewClientConn(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return cc, nil
+}
+
+// handshake establishes a TLS session over the given connection and
+// verifies that the server agreed to speak HTTP/2.
+func (t *http2Transport) handshake(ctx context.Context, conn net.Conn, addr string) (net.Conn, error) {
+	config := t.tlsConfig
+	if config.ServerName == "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		config = config.Clone()
+		config.ServerName = host
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+
+	if p := tlsConn.ConnectionState().NegotiatedProtocol; p != http2.NextProtoTLS {
+		conn.Close()
+		return nil, fmt.Errorf("server at %v does not support HTTP/2, negotiated protocol %q", addr, p)
+	}
+	return tlsConn, nil
+}
+
+// authorityAddr returns the host:port address of the request, adding the
+// default port for its scheme if needed.
+func authorityAddr(req *http.Request) (string, error) {
+	port := req.URL.Port()
+	if port == "" {
+		switch req.URL.Scheme {
+		case "http":
+			port = "80"
+		case "https":
+			port = "443"
+		default:
+			return "", fmt.Errorf("unsupported URL scheme %q for HTTP/2", req.URL.Scheme)
+		}
+	}
+	return net.JoinHostPort(req.URL.Hostname(), port), nil
+}
+
+// releaseBody releases the connection of an HTTP/2 request once its
+// response has been read in full or closed.
+type releaseBody struct {
+	io.ReadCloser
+
+	once    sync.Once
+	release func()
+}
+
+func (b *releaseBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.once.Do(b.release)
+	}
+	return n, err
+}
+
+func (b *releaseBody) Close() error {
+	b.once.Do(b.release)
+	return b.ReadCloser.Close()
+}
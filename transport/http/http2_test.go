@@ -0,0 +1,340 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package http
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/encoding/raw"
+	"go.uber.org/yarpc/internal/testtime"
+	"go.uber.org/yarpc/internal/testtls"
+	"go.uber.org/yarpc/yarpcconfig"
+)
+
+// connRecorder records the protocol and client address of the requests
+// received by an inbound.
+type connRecorder struct {
+	mu     sync.Mutex
+	protos []int
+	addrs  map[string]struct{}
+}
+
+func (r *connRecorder) intercept(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.mu.Lock()
+		r.protos = append(r.protos, req.ProtoMajor)
+		if r.addrs == nil {
+			r.addrs = make(map[string]struct{})
+		}
+		r.addrs[req.RemoteAddr] = struct{}{}
+		r.mu.Unlock()
+		h.ServeHTTP(w, req)
+	})
+}
+
+func (r *connRecorder) numConns() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.addrs)
+}
+
+func (r *connRecorder) protocols() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]int(nil), r.protos...)
+}
+
+func startHTTP2Inbound(t *testing.T, x *Transport, procedure transport.Procedure, opts ...InboundOption) (*Inbound, *connRecorder) {
+	rec := &connRecorder{}
+	inbound := x.NewInbound("127.0.0.1:0", append(opts, Interceptor(rec.intercept))...)
+	inbound.SetRouter(newTestRouter([]transport.Procedure{procedure}))
+	require.NoError(t, inbound.Start())
+	return inbound, rec
+}
+
+func callHello(t *testing.T, out *Outbound) error {
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+	res, err := out.Call(ctx, &transport.Request{
+		Caller:    "caller",
+		Service:   "service",
+		Encoding:  raw.Encoding,
+		Procedure: "hello",
+		Body:      bytes.NewReader(nil),
+	})
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "world", string(body))
+	return nil
+}
+
+var helloProcedure = raw.Procedure("hello", func(context.Context, []byte) ([]byte, error) {
+	return []byte("world"), nil
+})[0]
+
+func TestHTTP2Cleartext(t *testing.T) {
+	x := NewTransport(HTTP2())
+	require.NoError(t, x.Start())
+	defer x.Stop()
+
+	inbound, rec := startHTTP2Inbound(t, x, helloProcedure, H2C())
+	defer inbound.Stop()
+
+	out := x.NewSingleOutbound("http://" + inbound.Addr().String())
+	require.NoError(t, out.Start())
+	defer out.Stop()
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, callHello(t, out))
+	}
+	for _, proto := range rec.protocols() {
+		assert.Equal(t, 2, proto, "requests must use HTTP/2")
+	}
+	assert.Equal(t, 1, rec.numConns(), "requests must share a connection")
+}
+
+// echoStream echoes the messages of a stream back to the caller.
+type echoStream struct{}
+
+func (echoStream) HandleStream(s *transport.ServerStream) error {
+	for {
+		msg, err := s.ReceiveMessage(s.Context())
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if err := s.SendMessage(s.Context(), msg); err != nil {
+			return err
+		}
+	}
+}
+
+func TestHTTP2Stream(t *testing.T) {
+	x := NewTransport(HTTP2())
+	require.NoError(t, x.Start())
+	defer x.Stop()
+
+	inbound, rec := startHTTP2Inbound(t, x, transport.Procedure{
+		Name:        "echo",
+		Service:     "service",
+		HandlerSpec: transport.NewStreamHandlerSpec(echoStream{}),
+	}, H2C())
+	defer inbound.Stop()
+
+	out := x.NewSingleOutbound("http://" + inbound.Addr().String())
+	require.NoError(t, out.Start())
+	defer out.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+	stream, err := out.CallStream(ctx, &transport.StreamRequest{Meta: &transport.RequestMeta{
+		Caller:    "caller",
+		Service:   "service",
+		Encoding:  raw.Encoding,
+		Procedure: "echo",
+	}})
+	require.NoError(t, err)
+
+	for _, want := range []string{"hello", "world"} {
+		require.NoError(t, stream.SendMessage(ctx, &transport.StreamMessage{
+			Body: ioutil.NopCloser(bytes.NewBufferString(want)),
+		}))
+		msg, err := stream.ReceiveMessage(ctx)
+		require.NoError(t, err)
+		got, err := ioutil.ReadAll(msg.Body)
+		require.NoError(t, err)
+		assert.Equal(t, want, string(got))
+	}
+	require.NoError(t, stream.Close(ctx))
+	assert.Equal(t, []int{2}, rec.protocols(), "streams must use HTTP/2")
+}
+
+func TestHTTP2ClosesConns(t *testing.T) {
+	x := NewTransport(HTTP2())
+	require.NoError(t, x.Start())
+	defer x.Stop()
+
+	inbound, _ := startHTTP2Inbound(t, x, helloProcedure, H2C())
+	defer inbound.Stop()
+
+	h2 := x.client.Transport.(*http2Transport)
+	openConns := func() []*http2Conn {
+		h2.mu.Lock()
+		defer h2.mu.Unlock()
+		var conns []*http2Conn
+		for _, h := range h2.hosts {
+			conns = append(conns, h.conns...)
+		}
+		return conns
+	}
+
+	t.Run("released peer", func(t *testing.T) {
+		out := x.NewSingleOutbound("http://" + inbound.Addr().String())
+		require.NoError(t, out.Start())
+		require.NoError(t, callHello(t, out))
+		conns := openConns()
+		require.Len(t, conns, 1)
+
+		require.NoError(t, out.Stop())
+		assert.Empty(t, openConns(), "connections to released peers must be dropped")
+		assert.False(t, conns[0].CanTakeNewRequest(), "connections to released peers must be closed")
+	})
+
+	t.Run("stopped transport", func(t *testing.T) {
+		out := x.NewSingleOutbound("http://" + inbound.Addr().String())
+		require.NoError(t, out.Start())
+		defer out.Stop()
+		require.NoError(t, callHello(t, out))
+		conns := openConns()
+		require.Len(t, conns, 1)
+
+		h2.closeAll()
+		assert.Empty(t, openConns(), "connections must be dropped when the transport stops")
+		assert.False(t, conns[0].CanTakeNewRequest(), "connections must be closed when the transport stops")
+	})
+}
+
+func TestH2CAcceptsHTTP1(t *testing.T) {
+	x := NewTransport()
+	require.NoError(t, x.Start())
+	defer x.Stop()
+
+	inbound, rec := startHTTP2Inbound(t, x, helloProcedure, H2C(), MaxConcurrentStreams(10))
+	defer inbound.Stop()
+
+	out := x.NewSingleOutbound("http://" + inbound.Addr().String())
+	require.NoError(t, out.Start())
+	defer out.Stop()
+
+	require.NoError(t, callHello(t, out))
+	assert.Equal(t, []int{1}, rec.protocols())
+}
+
+func TestHTTP2RequiresH2C(t *testing.T) {
+	x := NewTransport(HTTP2())
+	require.NoError(t, x.Start())
+	defer x.Stop()
+
+	inbound, _ := startHTTP2Inbound(t, x, helloProcedure)
+	defer inbound.Stop()
+
+	out := x.NewSingleOutbound("http://" + inbound.Addr().String())
+	require.NoError(t, out.Start())
+	defer out.Stop()
+
+	assert.Error(t, callHello(t, out))
+}
+
+func TestHTTP2MaxConcurrentStreamsPerConn(t *testing.T) {
+	const (
+		maxStreams = 2
+		calls      = 6
+	)
+
+	entered := make(chan struct{}, calls+1)
+	unblock := make(chan struct{})
+	blocking := raw.Procedure("hello", func(context.Context, []byte) ([]byte, error) {
+		entered <- struct{}{}
+		<-unblock
+		return []byte("world"), nil
+	})[0]
+
+	x := NewTransport(HTTP2(), MaxConcurrentStreamsPerConn(maxStreams))
+	require.NoError(t, x.Start())
+	defer x.Stop()
+
+	inbound, rec := startHTTP2Inbound(t, x, blocking, H2C())
+	defer inbound.Stop()
+
+	out := x.NewSingleOutbound("http://" + inbound.Addr().String())
+	require.NoError(t, out.Start())
+	defer out.Stop()
+
+	var done sync.WaitGroup
+	done.Add(calls)
+	for i := 0; i < calls; i++ {
+		go func() {
+			defer done.Done()
+			assert.NoError(t, callHello(t, out))
+		}()
+	}
+
+	for i := 0; i < calls; i++ {
+		<-entered
+	}
+	assert.Equal(t, calls/maxStreams, rec.numConns(), "each connection must carry at most %v requests", maxStreams)
+	close(unblock)
+	done.Wait()
+
+	// Finished requests free up their connections for new ones.
+	require.NoError(t, callHello(t, out))
+	assert.Equal(t, calls/maxStreams, rec.numConns())
+}
+
+func TestHTTP2TLS(t *testing.T) {
+	s := testtls.NewScenario(t)
+	defer s.Cleanup()
+
+	serverConfig, err := yarpcconfig.InboundTLS{
+		Enabled:  true,
+		CertFile: s.ServerCertFile,
+		KeyFile:  s.ServerKeyFile,
+	}.ServerConfig()
+	require.NoError(t, err)
+	clientConfig, err := yarpcconfig.OutboundTLS{
+		Enabled:    true,
+		CAFile:     s.CAFile,
+		ServerName: "localhost",
+	}.ClientConfig()
+	require.NoError(t, err)
+
+	x := NewTransport(HTTP2(), ClientTLSConfig(clientConfig))
+	require.NoError(t, x.Start())
+	defer x.Stop()
+
+	inbound, rec := startHTTP2Inbound(t, x, helloProcedure, InboundTLSConfig(serverConfig), MaxConcurrentStreams(10))
+	defer inbound.Stop()
+
+	out := x.NewSingleOutbound("http://" + inbound.Addr().String())
+	require.NoError(t, out.Start())
+	defer out.Stop()
+
+	require.NoError(t, callHello(t, out))
+	require.NoError(t, callHello(t, out))
+	assert.Equal(t, []int{2, 2}, rec.protocols())
+	assert.Equal(t, 1, rec.numConns())
+	assert.Empty(t, serverConfig.NextProtos, "inbound must not modify the TLS configuration")
+}
@@ -22,6 +22,7 @@ package http
 
 import (
 	"context"
+	"crypto/tls"
 	"net"
 	"net/http"
 	"strings"
@@ -34,6 +35,8 @@ import (
 	"go.uber.org/yarpc/pkg/lifecycle"
 	"go.uber.org/yarpc/yarpcerrors"
 	"go.uber.org/zap"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 const defaultShutdownTimeout = 5 * time.Second
@@ -95,6 +98,43 @@ func ShutdownTimeout(timeout time.Duration) InboundOption {
 	}
 }
 
+// InboundTLSConfig specifies that the inbound should serve requests over TLS
+// with the given configuration. The configuration must provide the server
+// certificate with Certificates or GetCertificate.
+//
+// TLS is disabled by default.
+func InboundTLSConfig(config *tls.Config) InboundOption {
+	return func(i *Inbound) {
+		i.tlsConfig = config
+	}
+}
+
+// H2C specifies that the inbound should accept HTTP/2 requests over
+// cleartext (h2c) in addition to HTTP/1.1 requests. Both clients that start
+// with the HTTP/2 connection preface and clients that upgrade from HTTP/1.1
+// are supported. Outbounds send h2c requests with the HTTP2 transport
+// option.
+//
+// Inbounds that serve TLS accept HTTP/2 regardless of this option.
+//
+// h2c is disabled by default.
+func H2C() InboundOption {
+	return func(i *Inbound) {
+		i.h2c = true
+	}
+}
+
+// MaxConcurrentStreams limits the number of requests that each HTTP/2
+// connection may have in flight. The limit is advertised to clients, which
+// wait for a slot or open another connection once they reach it.
+//
+// Defaults to 250.
+func MaxConcurrentStreams(n uint32) InboundOption {
+	return func(i *Inbound) {
+		i.maxConcurrentStreams = n
+	}
+}
+
 // NewInbound builds a new HTTP inbound that listens on the given address and
 // sharing this transport.
 func (t *Transport) NewInbound(addr string, opts ...InboundOption) *Inbound {
@@ -128,6 +168,10 @@ type Inbound struct {
 	transport       *Transport
 	grabHeaders     map[string]struct{}
 	interceptor     func(http.Handler) http.Handler
+	tlsConfig       *tls.Config
+
+	h2c                  bool
+	maxConcurrentStreams uint32
 
 	once *lifecycle.Once
 
@@ -175,6 +219,7 @@ func (i *Inbound) start() error {
 		grabHeaders:       i.grabHeaders,
 		bothResponseError: i.bothResponseError,
 		logger:            i.logger,
+		compressors:       i.transport.compressors,
 	}
 	if i.interceptor != nil {
 		httpHandler = i.interceptor(httpHandler)
@@ -184,10 +229,16 @@ func (i *Inbound) start() error {
 		httpHandler = i.mux
 	}
 
-	i.server = intnet.NewHTTPServer(&http.Server{
-		Addr:    i.addr,
-		Handler: httpHandler,
-	})
+	server := &http.Server{
+		Addr:      i.addr,
+		Handler:   httpHandler,
+		TLSConfig: i.tlsConfig,
+	}
+	if err := i.configureHTTP2(server); err != nil {
+		return err
+	}
+
+	i.server = intnet.NewHTTPServer(server)
 	if err := i.server.ListenAndServe(); err != nil {
 		return err
 	}
@@ -200,6 +251,34 @@ func (i *Inbound) start() error {
 	return nil
 }
 
+// configureHTTP2 applies the HTTP/2 options of the inbound to the server.
+// Servers without any HTTP/2 options keep the defaults of net/http.
+func (i *Inbound) configureHTTP2(server *http.Server) error {
+	if !i.h2c && i.maxConcurrentStreams == 0 {
+		return nil
+	}
+
+	// ConfigureServer modifies the TLS configuration, which may be shared
+	// with other servers, and adds one if there is none.
+	if i.tlsConfig != nil {
+		server.TLSConfig = i.tlsConfig.Clone()
+	}
+	h2Server := &http2.Server{MaxConcurrentStreams: i.maxConcurrentStreams}
+	if err := http2.ConfigureServer(server, h2Server); err != nil {
+		return yarpcerrors.Newf(yarpcerrors.CodeInvalidArgument, "cannot configure HTTP/2 for HTTP inbound: %v", err)
+	}
+	if i.tlsConfig == nil {
+		server.TLSConfig = nil
+	}
+
+	// Connections upgraded to h2c are hijacked from the server. Configuring
+	// the server above lets Shutdown close them gracefully as well.
+	if i.h2c {
+		server.Handler = h2c.NewHandler(server.Handler, h2Server)
+	}
+	return nil
+}
+
 // Stop the inbound using Shutdown.
 func (i *Inbound) Stop() error {
 	ctx, cancel := context.WithTimeout(context.Background(), i.shutdownTimeout)
@@ -22,6 +22,8 @@ package http
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -42,12 +44,14 @@ import (
 	"go.uber.org/yarpc/peer/hostport"
 	"go.uber.org/yarpc/pkg/lifecycle"
 	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/zap"
 )
 
-// this ensures the HTTP outbound implements both transport.Outbound interfaces
+// this ensures the HTTP outbound implements all transport.Outbound interfaces
 var (
 	_ transport.UnaryOutbound              = (*Outbound)(nil)
 	_ transport.OnewayOutbound             = (*Outbound)(nil)
+	_ transport.StreamOutbound             = (*Outbound)(nil)
 	_ introspection.IntrospectableOutbound = (*Outbound)(nil)
 )
 
@@ -90,6 +94,21 @@ func AddHeader(key, value string) OutboundOption {
 	}
 }
 
+// OutboundTLSConfig specifies that this outbound should dial its peers over
+// TLS with the given configuration, sending requests to https URLs. This
+// overrides the transport's ClientTLSConfig, if any.
+//
+// Outbounds with their own TLS configuration do not share connections with
+// the other outbounds of the transport. Peers are shared by all outbounds of
+// the transport and health checked with the transport's configuration, so
+// outbounds with their own TLS configuration fail to start if the transport
+// has health checks enabled.
+func OutboundTLSConfig(config *tls.Config) OutboundOption {
+	return func(o *Outbound) {
+		o.tlsConfig = config
+	}
+}
+
 // NewOutbound builds an HTTP outbound that sends requests to peers supplied
 // by the given peer.Chooser. The URL template for used for the different
 // peers may be customized using the URLTemplate option.
@@ -111,6 +130,10 @@ func (t *Transport) NewOutbound(chooser peer.Chooser, opts ...OutboundOption) *O
 	for _, opt := range opts {
 		opt(o)
 	}
+	o.client, o.tls = t.client, t.tls
+	if o.tlsConfig != nil {
+		o.client, o.tls = t.newTLSClient(o.tlsConfig), true
+	}
 	return o
 }
 
@@ -138,10 +161,7 @@ func (t *Transport) NewSingleOutbound(uri string, opts ...OutboundOption) *Outbo
 	}
 
 	chooser := peerchooser.NewSingle(hostport.PeerIdentifier(parsedURL.Host), t)
-	o := t.NewOutbound(chooser)
-	for _, opt := range opts {
-		opt(o)
-	}
+	o := t.NewOutbound(chooser, opts...)
 	o.setURLTemplate(uri)
 	return o
 }
@@ -160,6 +180,16 @@ type Outbound struct {
 	// Headers to add to all outgoing requests.
 	headers http.Header
 
+	// Compressor for request bodies, if any.
+	compressor transport.Compressor
+
+	// Client used to send requests. This is the transport's client unless
+	// the outbound has its own TLS configuration. If tls is true, requests
+	// are sent to https URLs.
+	client    *http.Client
+	tls       bool
+	tlsConfig *tls.Config
+
 	once *lifecycle.Once
 
 	// should only be false in testing
@@ -189,7 +219,12 @@ func (o *Outbound) Chooser() peer.Chooser {
 
 // Start the HTTP outbound
 func (o *Outbound) Start() error {
-	return o.once.Start(o.chooser.Start)
+	return o.once.Start(func() error {
+		if o.tlsConfig != nil && o.transport.healthCheck.Enabled() {
+			return errors.New("outbound TLS configuration cannot be used with health checks, use ClientTLSConfig on the transport instead")
+		}
+		return o.chooser.Start()
+	})
 }
 
 // Stop the HTTP outbound
@@ -256,6 +291,16 @@ func (o *Outbound) call(ctx context.Context, treq *transport.Request) (*transpor
 
 	span.SetTag("http.status_code", response.StatusCode)
 
+	if o.compressor != nil && response.Header.Get(contentEncodingHeader) == o.compressor.Name() {
+		body, err := decompress(o.compressor, response.Body)
+		if err != nil {
+			_ = response.Body.Close()
+			return nil, transport.UpdateSpanWithErr(span,
+				yarpcerrors.InternalErrorf("failed to decompress %s response body: %v", o.compressor.Name(), err))
+		}
+		response.Body = body
+	}
+
 	// Service name match validation, return yarpcerrors.CodeInternal error if not match
 	if match, resSvcName := checkServiceMatch(treq.Service, response.Header); !match {
 		return nil, transport.UpdateSpanWithErr(span,
@@ -272,14 +317,14 @@ func (o *Outbound) call(ctx context.Context, treq *transport.Request) (*transpor
 	bothResponseError := response.Header.Get(BothResponseErrorHeader) == AcceptTrue
 	if bothResponseError && o.bothResponseError {
 		if response.StatusCode >= 300 {
-			return tres, getYARPCErrorFromResponse(response, true)
+			return tres, getYARPCErrorFromResponse(response, true, o.transport.logger)
 		}
 		return tres, nil
 	}
 	if response.StatusCode >= 200 && response.StatusCode < 300 {
 		return tres, nil
 	}
-	return nil, getYARPCErrorFromResponse(response, false)
+	return nil, getYARPCErrorFromResponse(response, false, o.transport.logger)
 }
 
 func (o *Outbound) getPeerForRequest(ctx context.Context, treq *transport.Request) (*httpPeer, func(error), error) {
@@ -301,7 +346,15 @@ func (o *Outbound) getPeerForRequest(ctx context.Context, treq *transport.Reques
 
 func (o *Outbound) createRequest(treq *transport.Request) (*http.Request, error) {
 	newURL := *o.urlTemplate
-	return http.NewRequest("POST", newURL.String(), treq.Body)
+	body := treq.Body
+	if o.compressor != nil && body != nil {
+		compressed, err := compress(o.compressor, body)
+		if err != nil {
+			return nil, yarpcerrors.InternalErrorf("failed to compress request body with %s: %v", o.compressor.Name(), err)
+		}
+		body = compressed
+	}
+	return http.NewRequest("POST", newURL.String(), body)
 }
 
 func (o *Outbound) withOpentracingSpan(ctx context.Context, req *http.Request, treq *transport.Request, start time.Time) (context.Context, *http.Request, opentracing.Span, error) {
@@ -373,10 +426,17 @@ func (o *Outbound) withCoreHeaders(req *http.Request, treq *transport.Request, t
 		req.Header.Set(AcceptsBothResponseErrorHeader, AcceptTrue)
 	}
 
+	if o.compressor != nil {
+		req.Header.Set(acceptEncodingHeader, o.compressor.Name())
+		if req.Body != nil {
+			req.Header.Set(contentEncodingHeader, o.compressor.Name())
+		}
+	}
+
 	return req
 }
 
-func getYARPCErrorFromResponse(response *http.Response, bothResponseError bool) error {
+func getYARPCErrorFromResponse(response *http.Response, bothResponseError bool, logger *zap.Logger) error {
 	var contents string
 	if bothResponseError {
 		contents = response.Header.Get(ErrorMessageHeader)
@@ -403,7 +463,7 @@ func getYARPCErrorFromResponse(response *http.Response, bothResponseError bool)
 		code,
 		response.Header.Get(ErrorNameHeader),
 		strings.TrimSuffix(contents, "\n"),
-	)
+	).WithDetails(intyarpcerrors.DetailsFromHeader(logger, response.Header.Get(ErrorDetailsHeader))...)
 }
 
 // Only does verification if there is a response header
@@ -431,6 +491,7 @@ func checkServiceMatch(reqSvcName string, resHeaders http.Header) (bool, string)
 //
 // All requests must have a deadline on the context.
 // The peer chooser for raw HTTP requests will receive a YARPC transport.Request with no body.
+// Requests sent this way are not compressed by the outbound's Compressor.
 //
 // OpenTracing information must be added manually, before this call, to support context propagation.
 func (o *Outbound) RoundTrip(hreq *http.Request) (*http.Response, error) {
@@ -494,8 +555,11 @@ func (o *Outbound) doWithPeer(
 	p *httpPeer,
 ) (*http.Response, error) {
 	hreq.URL.Host = p.HostPort()
+	if o.tls {
+		hreq.URL.Scheme = "https"
+	}
 
-	response, err := o.transport.client.Do(hreq.WithContext(ctx))
+	response, err := o.client.Do(hreq.WithContext(ctx))
 
 	if err != nil {
 		// Workaround borrowed from ctxhttp until
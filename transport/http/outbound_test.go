@@ -525,3 +525,30 @@ func TestServiceMatchNoHeader(t *testing.T) {
 	})
 	require.NoError(t, err)
 }
+
+func TestMalformedErrorDetails(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, req *http.Request) {
+			defer req.Body.Close()
+			w.Header().Set(ErrorCodeHeader, "not-found")
+			w.Header().Set(ErrorDetailsHeader, "not base64!")
+			http.Error(w, "no such thing", http.StatusNotFound)
+		},
+	))
+	defer server.Close()
+
+	out := NewTransport().NewSingleOutbound(server.URL)
+	require.NoError(t, out.Start(), "failed to start outbound")
+	defer out.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+	_, err := out.Call(ctx, &transport.Request{Service: "service"})
+	require.Error(t, err)
+
+	// The status is still reported but the details are dropped.
+	status := yarpcerrors.FromError(err)
+	assert.Equal(t, yarpcerrors.CodeNotFound, status.Code())
+	assert.Equal(t, "no such thing", status.Message())
+	assert.Empty(t, status.Details())
+}
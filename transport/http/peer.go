@@ -21,11 +21,17 @@
 package http
 
 import (
+	"context"
+	"io"
+	"io/ioutil"
 	"net"
+	"net/http"
+	"strconv"
 	"time"
 
 	"go.uber.org/atomic"
 	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/internal/healthcheck"
 	"go.uber.org/yarpc/peer/hostport"
 )
 
@@ -51,12 +57,12 @@ func newPeer(addr string, t *Transport) *httpPeer {
 	}
 
 	return &httpPeer{
-		Peer:      hostport.NewPeer(hostport.PeerIdentifier(addr), t),
-		transport: t,
-		addr:      addr,
-		changed:   make(chan struct{}, 1),
-		released:  make(chan struct{}, 0),
-		timer:     timer,
+		Peer:                  hostport.NewPeer(hostport.PeerIdentifier(addr), t),
+		transport:             t,
+		addr:                  addr,
+		changed:               make(chan struct{}, 1),
+		released:              make(chan struct{}, 0),
+		timer:                 timer,
 		innocentUntilUnixNano: atomic.NewInt64(0),
 	}
 }
@@ -73,10 +79,47 @@ func (p *httpPeer) isAvailable() bool {
 	if conn != nil {
 		conn.Close()
 	}
-	if conn != nil && err == nil {
-		return true
+	if conn == nil || err != nil {
+		return false
 	}
-	return false
+	// With health checks enabled, accepting connections is not enough: the
+	// peer must also answer a health check.
+	if p.transport.healthCheck.Enabled() {
+		return p.checkHealth()
+	}
+	return true
+}
+
+// checkHealth calls the health procedure of the peer and returns whether it
+// succeeded.
+func (p *httpPeer) checkHealth() bool {
+	timeout := p.transport.healthCheck.Timeout
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	hreq := healthcheck.Request()
+	scheme := "http"
+	if p.transport.tls {
+		scheme = "https"
+	}
+	req, err := http.NewRequest(http.MethodPost, scheme+"://"+p.addr+"/", hreq.Body)
+	if err != nil {
+		return false
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set(CallerHeader, hreq.Caller)
+	req.Header.Set(ServiceHeader, hreq.Service)
+	req.Header.Set(ProcedureHeader, hreq.Procedure)
+	req.Header.Set(EncodingHeader, string(hreq.Encoding))
+	req.Header.Set(TTLMSHeader, strconv.FormatInt(int64(timeout/time.Millisecond), 10))
+
+	res, err := p.transport.client.Do(req)
+	if err != nil {
+		return false
+	}
+	_, _ = io.Copy(ioutil.Discard, res.Body)
+	_ = res.Body.Close()
+	return res.StatusCode == http.StatusOK
 }
 
 func (p *httpPeer) OnSuspect() {
@@ -130,8 +173,8 @@ func (p *httpPeer) MaintainConn() {
 	p.Peer.SetStatus(peer.Connecting)
 	for {
 		// Invariant: Status is Connecting initially, or after exponential
-		// back-off, or after OnDisconnected, but still Available after
-		// OnSuspect.
+		// back-off, or after OnDisconnected, Unavailable after failed health
+		// checks, but still Available after OnSuspect.
 		if p.isAvailable() {
 			p.Peer.SetStatus(peer.Available)
 			// Reset on success
@@ -140,7 +183,8 @@ func (p *httpPeer) MaintainConn() {
 				break
 			}
 			// Invariant: the status is Connecting if change is triggered by
-			// OnDisconnected, but remains Available if triggered by OnSuspect.
+			// OnDisconnected, Unavailable if triggered by failed health
+			// checks, but remains Available if triggered by OnSuspect.
 		} else {
 			p.Peer.SetStatus(peer.Unavailable)
 			// Back-off on fail
@@ -160,13 +204,35 @@ func (p *httpPeer) MaintainConn() {
 // change notification, but exits early if the transport releases the peer or
 // stops.  waitForChange returns whether it is resuming due to a connection
 // status change event.
+//
+// With health checks enabled, waitForChange also checks the health of the
+// peer periodically, and resumes as if the connection status changed once
+// the peer fails enough consecutive checks.
 func (p *httpPeer) waitForChange() (changed bool) {
+	var (
+		tick    <-chan time.Time
+		tracker *healthcheck.Tracker
+	)
+	if opts := p.transport.healthCheck; opts.Enabled() {
+		ticker := time.NewTicker(opts.Interval)
+		defer ticker.Stop()
+		tick = ticker.C
+		tracker = healthcheck.NewTracker(opts)
+	}
+
 	// Wait for a connection status change
-	select {
-	case <-p.changed:
-		return true
-	case <-p.released:
-		return false
+	for {
+		select {
+		case <-p.changed:
+			return true
+		case <-p.released:
+			return false
+		case <-tick:
+			if tracker.Record(p.checkHealth()) {
+				p.Peer.SetStatus(peer.Unavailable)
+				return true
+			}
+		}
 	}
 }
 
@@ -22,11 +22,17 @@ package http_test
 
 import (
 	"context"
+	nethttp "net/http"
+	"net/http/httptest"
 	"runtime"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/opentracing/opentracing-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
 	"go.uber.org/yarpc/api/backoff"
 	"go.uber.org/yarpc/api/peer"
 	"go.uber.org/yarpc/api/transport"
@@ -147,6 +153,53 @@ func TestHTTPOnSuspect(t *testing.T) {
 	}
 }
 
+type nopSubscriber struct{}
+
+func (nopSubscriber) NotifyStatusChanged(peer.Identifier) {}
+
+func TestHTTPHealthCheck(t *testing.T) {
+	healthy := atomic.NewBool(true)
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, req *nethttp.Request) {
+		assert.Equal(t, "yarpc::health", req.Header.Get(http.ProcedureHeader))
+		if !healthy.Load() {
+			w.WriteHeader(nethttp.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	trans := http.NewTransport(
+		http.ConnTimeout(testtime.Second),
+		http.ConnBackoff(backoff.None),
+		http.HealthCheckInterval(5*time.Millisecond),
+		http.HealthCheckFailures(2),
+	)
+	require.NoError(t, trans.Start())
+	defer trans.Stop()
+
+	pid := hostport.Identify(strings.TrimPrefix(server.URL, "http://"))
+	p, err := trans.RetainPeer(pid, nopSubscriber{})
+	require.NoError(t, err)
+	defer trans.ReleasePeer(pid, nopSubscriber{})
+
+	waitForAvailable := func(want bool) {
+		deadline := time.Now().Add(testtime.Second)
+		for (p.Status().ConnectionStatus == peer.Available) != want {
+			if time.Now().After(deadline) {
+				require.FailNow(t, "timed out waiting for peer status", "want available: %v", want)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	waitForAvailable(true)
+	healthy.Store(false)
+	// The peer cycles between unavailable and connecting while it fails
+	// health checks.
+	waitForAvailable(false)
+	healthy.Store(true)
+	waitForAvailable(true)
+}
+
 func TestIntegration(t *testing.T) {
 	t.Skip("Skipping due to test flakiness")
 	spec.Test(t)
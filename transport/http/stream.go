@@ -0,0 +1,380 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	opentracinglog "github.com/opentracing/opentracing-go/log"
+	"go.uber.org/atomic"
+	"go.uber.org/yarpc/api/transport"
+	intyarpcerrors "go.uber.org/yarpc/internal/yarpcerrors"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/zap"
+)
+
+// Messages on a stream are framed by prefixing them with their length as a
+// 4-byte big-endian integer.
+const streamMessageHeaderSize = 4
+
+// maxStreamMessageSize is the largest stream message we are willing to
+// receive. This matches the default limit of the gRPC transport.
+const maxStreamMessageSize = 1024 * 1024 * 4
+
+// writeStreamMessage writes a single length-prefixed message to w.
+func writeStreamMessage(w io.Writer, msg []byte) error {
+	frame := make([]byte, streamMessageHeaderSize+len(msg))
+	binary.BigEndian.PutUint32(frame, uint32(len(msg)))
+	copy(frame[streamMessageHeaderSize:], msg)
+	_, err := w.Write(frame)
+	return err
+}
+
+// readStreamMessage reads a single length-prefixed message from r. It
+// returns io.EOF if the stream ended cleanly before the message started.
+func readStreamMessage(r io.Reader) (*transport.StreamMessage, error) {
+	var header [streamMessageHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if size > maxStreamMessageSize {
+		return nil, yarpcerrors.ResourceExhaustedErrorf(
+			"stream message of %d bytes exceeds the limit of %d bytes", size, maxStreamMessageSize)
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return &transport.StreamMessage{Body: ioutil.NopCloser(bytes.NewReader(msg))}, nil
+}
+
+// startStream sends the response headers for a stream, after which messages
+// may be sent with sendStreamMessage.
+func (rw *responseWriter) startStream() error {
+	flusher, ok := rw.w.(http.Flusher)
+	if !ok {
+		return yarpcerrors.UnimplementedErrorf("streaming is not supported by this HTTP server")
+	}
+	rw.w.Header().Set(StreamHeader, AcceptTrue)
+	rw.w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	rw.streaming = true
+	return nil
+}
+
+// closeStream ends a stream started with startStream, reporting the given
+// error in the response trailers.
+func (rw *responseWriter) closeStream(status *yarpcerrors.Status, logger *zap.Logger) {
+	if status == nil {
+		return
+	}
+	trailer := rw.w.Header()
+	if codeText, err := status.Code().MarshalText(); err == nil {
+		trailer.Set(http.TrailerPrefix+ErrorCodeHeader, string(codeText))
+	} else {
+		trailer.Set(http.TrailerPrefix+ErrorCodeHeader, "internal")
+	}
+	if status.Name() != "" {
+		trailer.Set(http.TrailerPrefix+ErrorNameHeader, status.Name())
+	}
+	trailer.Set(http.TrailerPrefix+ErrorMessageHeader, status.Message())
+	if details := intyarpcerrors.DetailsHeader(logger, status); details != "" {
+		trailer.Set(http.TrailerPrefix+ErrorDetailsHeader, details)
+	}
+}
+
+// errorFromTrailers returns the error reported in the trailers of a stream
+// response, or nil if the stream ended successfully.
+func errorFromTrailers(trailer http.Header, logger *zap.Logger) error {
+	codeText := trailer.Get(ErrorCodeHeader)
+	if codeText == "" {
+		return nil
+	}
+	// A code we don't know is as good as none, so it stays CodeUnknown.
+	code := yarpcerrors.CodeUnknown
+	_ = code.UnmarshalText([]byte(codeText))
+	return intyarpcerrors.NewWithNamef(
+		code,
+		trailer.Get(ErrorNameHeader),
+		"%s", trailer.Get(ErrorMessageHeader),
+	).WithDetails(intyarpcerrors.DetailsFromHeader(logger, trailer.Get(ErrorDetailsHeader))...)
+}
+
+func (h handler) handleStream(
+	ctx context.Context,
+	responseWriter *responseWriter,
+	req *http.Request,
+	treq *transport.Request,
+	streamHandler transport.StreamHandler,
+) error {
+	if contentType := getContentType(treq.Encoding); contentType != "" {
+		responseWriter.AddSystemHeader("Content-Type", contentType)
+	}
+	if err := responseWriter.startStream(); err != nil {
+		return err
+	}
+
+	stream := &serverStream{
+		ctx:  ctx,
+		req:  &transport.StreamRequest{Meta: treq.ToRequestMeta()},
+		body: req.Body,
+		w:    responseWriter.w,
+	}
+	tServerStream, err := transport.NewServerStream(stream)
+	if err != nil {
+		return err
+	}
+	return transport.InvokeStreamHandler(transport.StreamInvokeRequest{
+		Stream:  tServerStream,
+		Handler: streamHandler,
+		Logger:  h.logger,
+	})
+}
+
+type serverStream struct {
+	ctx  context.Context
+	req  *transport.StreamRequest
+	body io.Reader
+	w    http.ResponseWriter
+}
+
+func (ss *serverStream) Context() context.Context {
+	return ss.ctx
+}
+
+func (ss *serverStream) Request() *transport.StreamRequest {
+	return ss.req
+}
+
+func (ss *serverStream) SendMessage(_ context.Context, m *transport.StreamMessage) error {
+	// TODO pool buffers for performance.
+	msg, err := ioutil.ReadAll(m.Body)
+	_ = m.Body.Close()
+	if err != nil {
+		return yarpcerrors.FromError(err)
+	}
+	if err := writeStreamMessage(ss.w, msg); err != nil {
+		return yarpcerrors.FromError(err)
+	}
+	ss.w.(http.Flusher).Flush()
+	return nil
+}
+
+func (ss *serverStream) ReceiveMessage(context.Context) (*transport.StreamMessage, error) {
+	msg, err := readStreamMessage(ss.body)
+	if err != nil && err != io.EOF {
+		return nil, yarpcerrors.FromError(err)
+	}
+	return msg, err
+}
+
+// CallStream implements transport.StreamOutbound#CallStream.
+//
+// Messages are sent in the body of a single HTTP request and received in
+// the body of its response. Stream messages are not compressed by the
+// outbound's Compressor.
+//
+// Over HTTP/1.1, both bodies use chunked transfer encoding and the server
+// must allow reading the request while it writes the response, which YARPC
+// inbounds do.
+func (o *Outbound) CallStream(ctx context.Context, req *transport.StreamRequest) (*transport.ClientStream, error) {
+	if err := o.once.WaitUntilRunning(ctx); err != nil {
+		return nil, err
+	}
+	return o.stream(ctx, req, time.Now())
+}
+
+func (o *Outbound) stream(
+	ctx context.Context,
+	req *transport.StreamRequest,
+	start time.Time,
+) (*transport.ClientStream, error) {
+	if req.Meta == nil {
+		return nil, yarpcerrors.InvalidArgumentErrorf("stream request requires a request metadata")
+	}
+	treq := req.Meta.ToRequest()
+
+	// The TTL is optional for streams.
+	var ttl time.Duration
+	if deadline, ok := ctx.Deadline(); ok {
+		ttl = deadline.Sub(start)
+	}
+
+	bodyReader, bodyWriter := io.Pipe()
+	hreq, err := http.NewRequest("POST", o.urlTemplate.String(), bodyReader)
+	if err != nil {
+		return nil, err
+	}
+	hreq.Header = applicationHeaders.ToHTTPHeaders(treq.Headers, nil)
+	ctx, hreq, span, err := o.withOpentracingSpan(ctx, hreq, treq, start)
+	if err != nil {
+		span.Finish()
+		return nil, err
+	}
+	hreq = o.withCoreHeaders(hreq, treq, ttl)
+	hreq.Header.Del(contentEncodingHeader)
+	hreq.Header.Del(acceptEncodingHeader)
+	hreq.Header.Set(StreamHeader, AcceptTrue)
+
+	p, onFinish, err := o.getPeerForRequest(ctx, treq)
+	if err != nil {
+		span.Finish()
+		return nil, err
+	}
+
+	response, err := o.doWithPeer(ctx, hreq, treq, start, ttl, p)
+	if err == nil {
+		err = checkStreamResponse(treq, response, o.bothResponseError, o.transport.logger)
+	}
+	if err != nil {
+		onFinish(err)
+		_ = bodyWriter.CloseWithError(err)
+		_ = transport.UpdateSpanWithErr(span, err)
+		span.Finish()
+		return nil, err
+	}
+	span.SetTag("http.status_code", response.StatusCode)
+
+	stream := &clientStream{
+		ctx:      ctx,
+		req:      req,
+		span:     span,
+		body:     bodyWriter,
+		response: response,
+		logger:   o.transport.logger,
+		onFinish: onFinish,
+	}
+	tClientStream, err := transport.NewClientStream(stream)
+	if err != nil {
+		_ = stream.closeWithErr(err)
+		return nil, err
+	}
+	return tClientStream, nil
+}
+
+// checkStreamResponse verifies that the response opened a stream. The
+// response body is closed if it did not.
+func checkStreamResponse(treq *transport.Request, response *http.Response, bothResponseError bool, logger *zap.Logger) error {
+	if response.StatusCode != http.StatusOK {
+		bothResponseError = bothResponseError && response.Header.Get(BothResponseErrorHeader) == AcceptTrue
+		err := getYARPCErrorFromResponse(response, bothResponseError, logger)
+		_ = response.Body.Close()
+		return err
+	}
+	if match, resSvcName := checkServiceMatch(treq.Service, response.Header); !match {
+		_ = response.Body.Close()
+		return yarpcerrors.InternalErrorf("service name sent from the request "+
+			"does not match the service name received in the response, sent %q, got: %q", treq.Service, resSvcName)
+	}
+	if response.Header.Get(StreamHeader) != AcceptTrue {
+		_ = response.Body.Close()
+		return yarpcerrors.UnimplementedErrorf(
+			"procedure %q of service %q did not respond with a stream", treq.Procedure, treq.Service)
+	}
+	return nil
+}
+
+type clientStream struct {
+	ctx      context.Context
+	req      *transport.StreamRequest
+	span     opentracing.Span
+	body     *io.PipeWriter
+	response *http.Response
+	logger   *zap.Logger
+	closed   atomic.Bool
+
+	// onFinish releases the peer once the stream has ended.
+	onFinish func(error)
+}
+
+func (cs *clientStream) Context() context.Context {
+	return cs.ctx
+}
+
+func (cs *clientStream) Request() *transport.StreamRequest {
+	return cs.req
+}
+
+func (cs *clientStream) SendMessage(_ context.Context, m *transport.StreamMessage) error {
+	if cs.closed.Load() { // If the stream is closed, we should not be sending messages on it.
+		return io.EOF
+	}
+	// TODO pool buffers for performance.
+	msg, err := ioutil.ReadAll(m.Body)
+	_ = m.Body.Close()
+	if err != nil {
+		return yarpcerrors.FromError(err)
+	}
+	if err := writeStreamMessage(cs.body, msg); err != nil {
+		if err == io.ErrClosedPipe {
+			// The request body is closed once the server has ended the
+			// stream. The reason is available from ReceiveMessage.
+			return io.EOF
+		}
+		return yarpcerrors.FromError(cs.closeWithErr(err))
+	}
+	return nil
+}
+
+func (cs *clientStream) ReceiveMessage(context.Context) (*transport.StreamMessage, error) {
+	msg, err := readStreamMessage(cs.response.Body)
+	if err == io.EOF {
+		if err := errorFromTrailers(cs.response.Trailer, cs.logger); err != nil {
+			return nil, cs.closeWithErr(err)
+		}
+		return nil, cs.closeWithErr(io.EOF)
+	}
+	if err != nil {
+		return nil, yarpcerrors.FromError(cs.closeWithErr(err))
+	}
+	return msg, nil
+}
+
+func (cs *clientStream) Close(context.Context) error {
+	return cs.body.Close()
+}
+
+func (cs *clientStream) closeWithErr(err error) error {
+	if !cs.closed.Swap(true) {
+		_ = cs.body.CloseWithError(err)
+		_ = cs.response.Body.Close()
+		var finishErr error
+		if err != nil && err != io.EOF {
+			finishErr = err
+			cs.span.SetTag("error", true)
+			cs.span.LogFields(opentracinglog.String("event", err.Error()))
+		}
+		cs.span.Finish()
+		cs.onFinish(finishErr)
+	}
+	return err
+}
@@ -0,0 +1,193 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/encoding/raw"
+	"go.uber.org/yarpc/internal/testtime"
+	"go.uber.org/yarpc/internal/testtls"
+	"go.uber.org/yarpc/yarpcconfig"
+)
+
+func TestMutualTLS(t *testing.T) {
+	s := testtls.NewScenario(t)
+	defer s.Cleanup()
+
+	serverConfig, err := yarpcconfig.InboundTLS{
+		Enabled:  true,
+		CertFile: s.ServerCertFile,
+		KeyFile:  s.ServerKeyFile,
+		CAFile:   s.CAFile,
+	}.ServerConfig()
+	require.NoError(t, err)
+
+	x := NewTransport()
+	require.NoError(t, x.Start())
+	defer x.Stop()
+
+	inbound := x.NewInbound("127.0.0.1:0", InboundTLSConfig(serverConfig))
+	inbound.SetRouter(newTestRouter(raw.Procedure("whoami", func(ctx context.Context, _ []byte) ([]byte, error) {
+		id, ok := yarpc.CallFromContext(ctx).PeerIdentity()
+		if !ok {
+			return []byte("anonymous"), nil
+		}
+		return []byte(id.Name()), nil
+	})))
+	require.NoError(t, inbound.Start())
+	defer inbound.Stop()
+	addr := inbound.Addr().String()
+
+	call := func(t *testing.T, opts ...OutboundOption) (string, error) {
+		out := x.NewSingleOutbound("http://"+addr, opts...)
+		require.NoError(t, out.Start())
+		defer out.Stop()
+
+		ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+		defer cancel()
+		res, err := out.Call(ctx, &transport.Request{
+			Caller:    "caller",
+			Service:   "service",
+			Encoding:  raw.Encoding,
+			Procedure: "whoami",
+			Body:      bytes.NewReader(nil),
+		})
+		if err != nil {
+			return "", err
+		}
+		defer res.Body.Close()
+		body, err := ioutil.ReadAll(res.Body)
+		require.NoError(t, err)
+		return string(body), nil
+	}
+
+	t.Run("client certificate", func(t *testing.T) {
+		clientConfig, err := yarpcconfig.OutboundTLS{
+			Enabled:    true,
+			CertFile:   s.ClientCertFile,
+			KeyFile:    s.ClientKeyFile,
+			CAFile:     s.CAFile,
+			ServerName: "localhost",
+		}.ClientConfig()
+		require.NoError(t, err)
+		name, err := call(t, OutboundTLSConfig(clientConfig))
+		require.NoError(t, err)
+		assert.Equal(t, "client", name, "handler must see the identity of the client")
+	})
+
+	t.Run("client SPIFFE ID", func(t *testing.T) {
+		s.IssueClientCert("client", "spiffe://example.com/client")
+		clientConfig, err := yarpcconfig.OutboundTLS{
+			Enabled:    true,
+			CertFile:   s.ClientCertFile,
+			KeyFile:    s.ClientKeyFile,
+			CAFile:     s.CAFile,
+			ServerName: "localhost",
+		}.ClientConfig()
+		require.NoError(t, err)
+		name, err := call(t, OutboundTLSConfig(clientConfig))
+		require.NoError(t, err)
+		assert.Equal(t, "spiffe://example.com/client", name)
+	})
+
+	t.Run("no client certificate", func(t *testing.T) {
+		clientConfig, err := yarpcconfig.OutboundTLS{
+			Enabled:    true,
+			CAFile:     s.CAFile,
+			ServerName: "localhost",
+		}.ClientConfig()
+		require.NoError(t, err)
+		_, err = call(t, OutboundTLSConfig(clientConfig))
+		assert.Error(t, err)
+	})
+
+	t.Run("plaintext", func(t *testing.T) {
+		_, err := call(t)
+		assert.Error(t, err)
+	})
+}
+
+func TestTransportTLS(t *testing.T) {
+	s := testtls.NewScenario(t)
+	defer s.Cleanup()
+
+	serverConfig, err := yarpcconfig.InboundTLS{
+		Enabled:  true,
+		CertFile: s.ServerCertFile,
+		KeyFile:  s.ServerKeyFile,
+	}.ServerConfig()
+	require.NoError(t, err)
+	clientConfig, err := yarpcconfig.OutboundTLS{
+		Enabled:    true,
+		CAFile:     s.CAFile,
+		ServerName: "localhost",
+	}.ClientConfig()
+	require.NoError(t, err)
+
+	x := NewTransport(ClientTLSConfig(clientConfig))
+	require.NoError(t, x.Start())
+	defer x.Stop()
+
+	inbound := x.NewInbound("127.0.0.1:0", InboundTLSConfig(serverConfig))
+	inbound.SetRouter(newTestRouter(raw.Procedure("hello", func(context.Context, []byte) ([]byte, error) {
+		return []byte("world"), nil
+	})))
+	require.NoError(t, inbound.Start())
+	defer inbound.Stop()
+
+	out := x.NewSingleOutbound("http://" + inbound.Addr().String())
+	require.NoError(t, out.Start())
+	defer out.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+	res, err := out.Call(ctx, &transport.Request{
+		Caller:    "caller",
+		Service:   "service",
+		Encoding:  raw.Encoding,
+		Procedure: "hello",
+		Body:      bytes.NewReader(nil),
+	})
+	require.NoError(t, err)
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "world", string(body))
+}
+
+func TestOutboundTLSWithHealthChecks(t *testing.T) {
+	x := NewTransport(HealthCheckInterval(time.Second))
+	require.NoError(t, x.Start())
+	defer x.Stop()
+
+	out := x.NewSingleOutbound("http://127.0.0.1:0", OutboundTLSConfig(&tls.Config{}))
+	assert.Error(t, out.Start(), "outbound TLS must be rejected when health checks are enabled")
+}
@@ -21,6 +21,7 @@
 package http
 
 import (
+	"crypto/tls"
 	"math/rand"
 	"net"
 	"net/http"
@@ -32,6 +33,7 @@ import (
 	"go.uber.org/yarpc/api/peer"
 	"go.uber.org/yarpc/api/transport"
 	"go.uber.org/yarpc/internal/backoff"
+	"go.uber.org/yarpc/internal/healthcheck"
 	"go.uber.org/yarpc/pkg/lifecycle"
 	"go.uber.org/zap"
 )
@@ -51,6 +53,11 @@ type transportOptions struct {
 	tracer                opentracing.Tracer
 	buildClient           func(*transportOptions) *http.Client
 	logger                *zap.Logger
+	compressors           []transport.Compressor
+	healthCheck           healthcheck.Options
+	tlsConfig             *tls.Config
+	http2                 bool
+	maxConcurrentStreams  int
 }
 
 var defaultTransportOptions = transportOptions{
@@ -61,6 +68,7 @@ var defaultTransportOptions = transportOptions{
 	buildClient:         buildHTTPClient,
 	innocenceWindow:     defaultInnocenceWindow,
 	jitter:              rand.Int63n,
+	healthCheck:         healthcheck.DefaultOptions,
 }
 
 func newTransportOptions() transportOptions {
@@ -200,6 +208,102 @@ func Logger(logger *zap.Logger) TransportOption {
 	}
 }
 
+// Compressors specifies the compressors inbounds of this transport can use to
+// decompress request bodies, based on their Content-Encoding header, and to
+// compress response bodies for callers that accept them, based on their
+// Accept-Encoding header.
+//
+// Requests using an unknown Content-Encoding are rejected, and responses are
+// not compressed by default. Use the Compressor OutboundOption to compress
+// requests.
+func Compressors(compressors ...transport.Compressor) TransportOption {
+	return func(options *transportOptions) {
+		options.compressors = append(options.compressors, compressors...)
+	}
+}
+
+// HealthCheckInterval enables active health checking of peers at the given
+// interval. While a peer is available, the transport periodically calls the
+// health procedure that YARPC dispatchers serve, and marks the peer
+// unavailable after HealthCheckFailures consecutive failed checks. A peer
+// only becomes available again once it passes a health check.
+//
+// Health checks are sent over plain HTTP, or TLS if ClientTLSConfig is given,
+// to the root path of the peer, so they only work with peers that serve YARPC
+// on that path. Outbounds with their own OutboundTLSConfig cannot be used
+// with health checks.
+//
+// Health checks are disabled by default, leaving peers available as long as
+// they accept TCP connections.
+func HealthCheckInterval(d time.Duration) TransportOption {
+	return func(options *transportOptions) {
+		options.healthCheck.Interval = d
+	}
+}
+
+// HealthCheckTimeout specifies the timeout of each health check.
+//
+// The default is 1 second.
+func HealthCheckTimeout(d time.Duration) TransportOption {
+	return func(options *transportOptions) {
+		options.healthCheck.Timeout = d
+	}
+}
+
+// HealthCheckFailures specifies the number of consecutive failed health
+// checks after which a peer is marked unavailable.
+//
+// The default is 3.
+func HealthCheckFailures(n int) TransportOption {
+	return func(options *transportOptions) {
+		options.healthCheck.Failures = n
+	}
+}
+
+// ClientTLSConfig specifies that all outbounds of this transport should dial
+// their peers over TLS with the given configuration, sending requests to
+// https URLs. Health checks are sent over TLS as well.
+//
+// Individual outbounds may use a different configuration with the
+// OutboundTLSConfig option.
+//
+// TLS is disabled by default.
+func ClientTLSConfig(config *tls.Config) TransportOption {
+	return func(options *transportOptions) {
+		options.tlsConfig = config
+	}
+}
+
+// HTTP2 specifies that all outbounds of this transport should send requests
+// over HTTP/2 instead of HTTP/1.1. Requests to http URLs use HTTP/2 over
+// cleartext (h2c) with prior knowledge, so the inbounds of the peers must
+// accept h2c (see H2C). Requests to https URLs negotiate HTTP/2 during the
+// TLS handshake and fail if the peer does not support it.
+//
+// Requests to the same peer are multiplexed over a small number of
+// connections. The KeepAlive and DisableCompression options apply to HTTP/2
+// connections; the options that control idle HTTP/1.1 connections do not.
+//
+// HTTP/2 is disabled by default.
+func HTTP2() TransportOption {
+	return func(options *transportOptions) {
+		options.http2 = true
+	}
+}
+
+// MaxConcurrentStreamsPerConn limits the number of requests that are in
+// flight on each HTTP/2 connection. Requests beyond this limit open another
+// connection to the same peer. This has no effect unless HTTP2 is also
+// given.
+//
+// Defaults to no limit other than the one advertised by the peer; requests
+// beyond that limit wait for a slot on the existing connection.
+func MaxConcurrentStreamsPerConn(n int) TransportOption {
+	return func(options *transportOptions) {
+		options.maxConcurrentStreams = n
+	}
+}
+
 // Hidden option to override the buildHTTPClient function. This is used only
 // for testing.
 func buildClient(f func(*transportOptions) *http.Client) TransportOption {
@@ -222,9 +326,15 @@ func (o *transportOptions) newTransport() *Transport {
 	if logger == nil {
 		logger = zap.NewNop()
 	}
-	return &Transport{
+	compressors := make(map[string]transport.Compressor, len(o.compressors))
+	for _, c := range o.compressors {
+		compressors[c.Name()] = c
+	}
+	clientOptions := *o
+	t := &Transport{
 		once:                lifecycle.NewOnce(),
-		client:              o.buildClient(o),
+		tls:                 o.tlsConfig != nil,
+		http2:               o.http2,
 		connTimeout:         o.connTimeout,
 		connBackoffStrategy: o.connBackoffStrategy,
 		innocenceWindow:     o.innocenceWindow,
@@ -232,10 +342,33 @@ func (o *transportOptions) newTransport() *Transport {
 		peers:               make(map[string]*httpPeer),
 		tracer:              o.tracer,
 		logger:              logger,
+		compressors:         compressors,
+		healthCheck:         o.healthCheck,
+	}
+	t.client = t.trackClient(o.buildClient(o))
+	t.newTLSClient = func(config *tls.Config) *http.Client {
+		options := clientOptions
+		options.tlsConfig = config
+		return t.trackClient(options.buildClient(&options))
+	}
+	return t
+}
+
+// trackClient records the HTTP/2 connection pool of the client, if any, so
+// that its connections are closed with the peers of the transport.
+func (a *Transport) trackClient(client *http.Client) *http.Client {
+	if h2, ok := client.Transport.(*http2Transport); ok {
+		a.lock.Lock()
+		a.http2Transports = append(a.http2Transports, h2)
+		a.lock.Unlock()
 	}
+	return client
 }
 
 func buildHTTPClient(options *transportOptions) *http.Client {
+	if options.http2 {
+		return &http.Client{Transport: newHTTP2Transport(options)}
+	}
 	return &http.Client{
 		Transport: &http.Transport{
 			// options lifted from https://golang.org/src/net/http/transport.go
@@ -252,6 +385,7 @@ func buildHTTPClient(options *transportOptions) *http.Client {
 			DisableKeepAlives:     options.disableKeepAlives,
 			DisableCompression:    options.disableCompression,
 			ResponseHeaderTimeout: options.responseHeaderTimeout,
+			TLSClientConfig:       options.tlsConfig,
 		},
 	}
 }
@@ -266,14 +400,26 @@ type Transport struct {
 	client *http.Client
 	peers  map[string]*httpPeer
 
+	// tls is true if client dials peers over TLS. newTLSClient builds a
+	// client with the same options but a different TLS configuration.
+	tls          bool
+	newTLSClient func(*tls.Config) *http.Client
+
+	// http2 is true if clients send requests over HTTP/2. http2Transports
+	// holds the connection pools of those clients.
+	http2           bool
+	http2Transports []*http2Transport
+
 	connTimeout         time.Duration
 	connBackoffStrategy backoffapi.Strategy
 	connectorsGroup     sync.WaitGroup
 	innocenceWindow     time.Duration
 	jitter              func(int64) int64
+	healthCheck         healthcheck.Options
 
-	tracer opentracing.Tracer
-	logger *zap.Logger
+	tracer      opentracing.Tracer
+	logger      *zap.Logger
+	compressors map[string]transport.Compressor
 }
 
 var _ transport.Transport = (*Transport)(nil)
@@ -289,6 +435,12 @@ func (a *Transport) Start() error {
 func (a *Transport) Stop() error {
 	return a.once.Stop(func() error {
 		a.connectorsGroup.Wait()
+
+		a.lock.Lock()
+		defer a.lock.Unlock()
+		for _, h2 := range a.http2Transports {
+			h2.closeAll()
+		}
 		return nil
 	})
 }
@@ -342,6 +494,9 @@ func (a *Transport) ReleasePeer(pid peer.Identifier, sub peer.Subscriber) error
 	if p.NumSubscribers() == 0 {
 		delete(a.peers, pid.Identifier())
 		p.Release()
+		for _, h2 := range a.http2Transports {
+			h2.closeHost(pid.Identifier())
+		}
 	}
 
 	return nil
@@ -23,6 +23,8 @@ package http
 import (
 	"context"
 	"fmt"
+	"math"
+	"net/http"
 	"strconv"
 	"time"
 
@@ -30,6 +32,22 @@ import (
 	"go.uber.org/yarpc/yarpcerrors"
 )
 
+// parseDeadline clamps the context to the deadlines given by the TTL and
+// gRPC timeout headers of the request, whichever is earlier.
+//
+// Leaves the context unchanged if neither header is present.
+func parseDeadline(ctx context.Context, req *transport.Request, header http.Header) (_ context.Context, cancel func(), _ error) {
+	ctx, cancelTTL, err := parseTTL(ctx, req, popHeader(header, TTLMSHeader))
+	if err != nil {
+		return ctx, cancelTTL, err
+	}
+	ctx, cancelTimeout, err := parseGRPCTimeout(ctx, req, popHeader(header, GRPCTimeoutHeader))
+	return ctx, func() {
+		cancelTimeout()
+		cancelTTL()
+	}, err
+}
+
 // parseTTL takes a context parses the given TTL, clamping the context to that
 // TTL and as a side-effect, tracking any errors encountered while attempting
 // to parse and validate that TTL.
@@ -62,6 +80,52 @@ func parseTTL(ctx context.Context, req *transport.Request, ttl string) (_ contex
 	return ctx, cancel, nil
 }
 
+// _grpcTimeoutUnits maps the unit suffixes of gRPC timeouts to durations.
+var _grpcTimeoutUnits = map[byte]time.Duration{
+	'H': time.Hour,
+	'M': time.Minute,
+	'S': time.Second,
+	'm': time.Millisecond,
+	'u': time.Microsecond,
+	'n': time.Nanosecond,
+}
+
+// parseGRPCTimeout clamps the context to the given timeout, which is in the
+// format of the grpc-timeout header: up to 8 digits followed by a unit.
+//
+// Leaves the context unchanged if the timeout is empty.
+func parseGRPCTimeout(ctx context.Context, req *transport.Request, timeout string) (_ context.Context, cancel func(), _ error) {
+	if timeout == "" {
+		return ctx, func() {}, nil
+	}
+
+	invalid := newInvalidTTLError(req.Service, req.Procedure, timeout)
+	if len(timeout) < 2 || len(timeout) > 9 {
+		return ctx, func() {}, invalid
+	}
+	unit, ok := _grpcTimeoutUnits[timeout[len(timeout)-1]]
+	if !ok {
+		return ctx, func() {}, invalid
+	}
+	digits := timeout[:len(timeout)-1]
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return ctx, func() {}, invalid
+		}
+	}
+	n, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return ctx, func() {}, invalid
+	}
+
+	d := time.Duration(math.MaxInt64)
+	if n < int64(math.MaxInt64/unit) {
+		d = time.Duration(n) * unit
+	}
+	ctx, cancel = context.WithTimeout(ctx, d)
+	return ctx, cancel, nil
+}
+
 func newInvalidTTLError(service string, procedure string, ttl string) error {
 	return yarpcerrors.Newf(yarpcerrors.CodeInvalidArgument, "invalid TTL %q for service %q and procedure %q", ttl, service, procedure)
 }
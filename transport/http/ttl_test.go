@@ -22,9 +22,13 @@ package http
 
 import (
 	"context"
+	"math"
+	"net/http"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/yarpc/api/transport"
 )
 
@@ -74,3 +78,124 @@ func TestParseTTL(t *testing.T) {
 		})
 	}
 }
+
+func TestParseGRPCTimeout(t *testing.T) {
+	req := &transport.Request{
+		Caller:    "caller",
+		Service:   "service",
+		Procedure: "hello",
+		Encoding:  "raw",
+	}
+
+	tests := []struct {
+		timeout string
+		want    time.Duration
+		wantErr bool
+	}{
+		{timeout: "2H", want: 2 * time.Hour},
+		{timeout: "3M", want: 3 * time.Minute},
+		{timeout: "10S", want: 10 * time.Second},
+		{timeout: "1500m", want: 1500 * time.Millisecond},
+		{timeout: "99999999u", want: 99999999 * time.Microsecond},
+		{timeout: "5000000n", want: 5 * time.Millisecond},
+		{timeout: "99999999H", want: math.MaxInt64},
+		{timeout: "100", wantErr: true},
+		{timeout: "m", wantErr: true},
+		{timeout: "-1S", wantErr: true},
+		{timeout: "+1S", wantErr: true},
+		{timeout: "1.5S", wantErr: true},
+		{timeout: "100000000S", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.timeout, func(t *testing.T) {
+			start := time.Now()
+			ctx, cancel, err := parseGRPCTimeout(context.Background(), req, tt.timeout)
+			defer cancel()
+
+			if tt.wantErr {
+				assert.Equal(t, newInvalidTTLError("service", "hello", tt.timeout), err)
+				return
+			}
+			require.NoError(t, err)
+			deadline, ok := ctx.Deadline()
+			require.True(t, ok)
+			if tt.want == math.MaxInt64 {
+				assert.True(t, deadline.Sub(start) > 100*365*24*time.Hour, "deadline must be far in the future")
+				return
+			}
+			assert.InDelta(t, float64(tt.want), float64(deadline.Sub(start)), float64(time.Second))
+		})
+	}
+}
+
+func TestParseDeadline(t *testing.T) {
+	req := &transport.Request{
+		Caller:    "caller",
+		Service:   "service",
+		Procedure: "hello",
+		Encoding:  "raw",
+	}
+
+	tests := []struct {
+		desc    string
+		headers map[string]string
+		want    time.Duration // zero for no deadline
+		wantErr error
+	}{
+		{desc: "no deadline"},
+		{
+			desc:    "ttl",
+			headers: map[string]string{TTLMSHeader: "1000"},
+			want:    time.Second,
+		},
+		{
+			desc:    "grpc timeout",
+			headers: map[string]string{GRPCTimeoutHeader: "2S"},
+			want:    2 * time.Second,
+		},
+		{
+			desc:    "earlier ttl",
+			headers: map[string]string{TTLMSHeader: "1000", GRPCTimeoutHeader: "1M"},
+			want:    time.Second,
+		},
+		{
+			desc:    "earlier grpc timeout",
+			headers: map[string]string{TTLMSHeader: "60000", GRPCTimeoutHeader: "200m"},
+			want:    200 * time.Millisecond,
+		},
+		{
+			desc:    "invalid grpc timeout",
+			headers: map[string]string{TTLMSHeader: "1000", GRPCTimeoutHeader: "soon"},
+			wantErr: newInvalidTTLError("service", "hello", "soon"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			header := http.Header{}
+			for k, v := range tt.headers {
+				header.Set(k, v)
+			}
+
+			start := time.Now()
+			ctx, cancel, err := parseDeadline(context.Background(), req, header)
+			defer cancel()
+
+			if tt.wantErr != nil {
+				assert.Equal(t, tt.wantErr, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Empty(t, header, "deadline headers must be consumed")
+
+			deadline, ok := ctx.Deadline()
+			if tt.want == 0 {
+				assert.False(t, ok, "context must not have a deadline")
+				return
+			}
+			require.True(t, ok, "context must have a deadline")
+			assert.InDelta(t, float64(tt.want), float64(deadline.Sub(start)), float64(100*time.Millisecond))
+		})
+	}
+}
@@ -216,6 +216,7 @@ func TestSimpleRoundTrip(t *testing.T) {
 		responseHeaders transport.Headers
 		responseBody    string
 		responseError   error
+		applicationErr  bool
 
 		wantError func(error)
 	}{
@@ -242,6 +243,21 @@ func TestSimpleRoundTrip(t *testing.T) {
 				assert.True(t, yarpcerrors.FromError(err).Code() == yarpcerrors.CodeInvalidArgument, err.Error())
 			},
 		},
+		{
+			name:        "error details",
+			requestBody: "baz",
+			responseError: yarpcerrors.Newf(yarpcerrors.CodeFailedPrecondition, "quota exceeded").WithDetails(
+				yarpcerrors.Detail{TypeURL: "type.example.com/quota", Value: []byte("1234")},
+			),
+			applicationErr: true,
+			wantError: func(err error) {
+				status := yarpcerrors.FromError(err)
+				assert.Equal(t, yarpcerrors.CodeFailedPrecondition, status.Code(), err.Error())
+				assert.Equal(t, []yarpcerrors.Detail{
+					{TypeURL: "type.example.com/quota", Value: []byte("1234")},
+				}, status.Details())
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -262,6 +278,9 @@ func TestSimpleRoundTrip(t *testing.T) {
 					r.Headers.Del(":authority") // for gRPC
 					assert.True(t, requestMatcher.Matches(r), "request mismatch: received %v", r)
 
+					if tt.applicationErr {
+						w.SetApplicationError()
+					}
 					if tt.responseError != nil {
 						return tt.responseError
 					}
@@ -83,9 +83,14 @@ func (i *ChannelInbound) Start() error {
 	})
 }
 
-// Stop stops the TChannel outbound. This currently does nothing.
+// Stop stops the TChannel inbound. It rejects new streams and waits for the
+// handlers of running streams to return. Unary requests are served until the
+// underlying transport stops.
 func (i *ChannelInbound) Stop() error {
-	return i.once.Stop(nil)
+	return i.once.Stop(func() error {
+		i.transport.streams.stop()
+		return nil
+	})
 }
 
 // IsRunning returns whether the ChannelInbound is running.
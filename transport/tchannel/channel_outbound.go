@@ -32,6 +32,7 @@ import (
 	"go.uber.org/yarpc/pkg/errors"
 	"go.uber.org/yarpc/pkg/lifecycle"
 	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/zap"
 )
 
 var (
@@ -196,7 +197,7 @@ func (o *ChannelOutbound) Call(ctx context.Context, req *transport.Request) (*tr
 		return nil, err
 	}
 
-	err = getResponseError(headers)
+	err = getResponseError(headers, o.transport.logger)
 	deleteReservedHeaders(headers)
 
 	resp := &transport.Response{
@@ -241,7 +242,7 @@ func fromSystemError(err tchannel.SystemError) error {
 	return yarpcerrors.Newf(code, err.Message())
 }
 
-func getResponseError(headers transport.Headers) error {
+func getResponseError(headers transport.Headers, logger *zap.Logger) error {
 	errorCodeString, ok := headers.Get(ErrorCodeHeaderKey)
 	if !ok {
 		return nil
@@ -255,5 +256,7 @@ func getResponseError(headers transport.Headers) error {
 	}
 	errorName, _ := headers.Get(ErrorNameHeaderKey)
 	errorMessage, _ := headers.Get(ErrorMessageHeaderKey)
-	return intyarpcerrors.NewWithNamef(errorCode, errorName, errorMessage)
+	errorDetails, _ := headers.Get(ErrorDetailsHeaderKey)
+	return intyarpcerrors.NewWithNamef(errorCode, errorName, errorMessage).
+		WithDetails(intyarpcerrors.DetailsFromHeader(logger, errorDetails)...)
 }
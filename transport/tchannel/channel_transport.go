@@ -106,6 +106,7 @@ type ChannelTransport struct {
 	router            transport.Router
 	originalHeaders   bool
 	newResponseWriter func(inboundCallResponse, tchannel.Format, headerCase) responseWriter
+	streams           streamGroup
 }
 
 // Channel returns the underlying TChannel "Channel" instance.
@@ -140,7 +141,7 @@ func (t *ChannelTransport) start() error {
 		for s := range services {
 			sc := t.ch.GetSubChannel(s)
 			existing := sc.GetHandlers()
-			sc.SetHandler(handler{existing: existing, router: t.router, tracer: t.tracer, logger: t.logger, newResponseWriter: t.newResponseWriter})
+			sc.SetHandler(handler{existing: existing, router: t.router, tracer: t.tracer, logger: t.logger, newResponseWriter: t.newResponseWriter, streams: &t.streams})
 		}
 	}
 
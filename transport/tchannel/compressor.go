@@ -0,0 +1,82 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel
+
+import (
+	"io"
+
+	"github.com/uber/tchannel-go"
+	"go.uber.org/multierr"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/iopool"
+)
+
+// withEncodingHeaders returns a copy of the given headers that advertises the
+// given compressor for both the request and the response.
+func withEncodingHeaders(headers map[string]string, name string) map[string]string {
+	out := make(map[string]string, len(headers)+2)
+	for k, v := range headers {
+		out[k] = v
+	}
+	out[ContentEncodingHeaderKey] = name
+	out[AcceptEncodingHeaderKey] = name
+	return out
+}
+
+func writeCompressedBody(body io.Reader, call *tchannel.OutboundCall, compressor transport.Compressor) error {
+	w, err := call.Arg3Writer()
+	if err != nil {
+		return err
+	}
+
+	cw, err := compressor.Compress(w)
+	if err != nil {
+		return err
+	}
+	if _, err := iopool.Copy(cw, body); err != nil {
+		return multierr.Append(err, cw.Close())
+	}
+	if err := cw.Close(); err != nil {
+		return err
+	}
+
+	return w.Close()
+}
+
+// decompressedBody reads the decompressed contents of a body and closes both
+// the decompressor and the body.
+type decompressedBody struct {
+	io.ReadCloser
+
+	body io.Closer
+}
+
+func decompress(compressor transport.Compressor, body io.ReadCloser) (io.ReadCloser, error) {
+	r, err := compressor.Decompress(body)
+	if err != nil {
+		return nil, err
+	}
+	return decompressedBody{ReadCloser: r, body: body}, nil
+}
+
+func (b decompressedBody) Close() error {
+	return multierr.Append(b.ReadCloser.Close(), b.body.Close())
+}
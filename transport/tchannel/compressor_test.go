@@ -0,0 +1,127 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/transport/transporttest"
+	yarpcgzip "go.uber.org/yarpc/compressor/gzip"
+	"go.uber.org/yarpc/encoding/raw"
+	"go.uber.org/yarpc/internal/testtime"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// countingCompressor counts the number of payloads it compresses and
+// decompresses.
+type countingCompressor struct {
+	transport.Compressor
+
+	compressed   atomic.Int32
+	decompressed atomic.Int32
+}
+
+func (c *countingCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	c.compressed.Inc()
+	return c.Compressor.Compress(w)
+}
+
+func (c *countingCompressor) Decompress(r io.Reader) (io.ReadCloser, error) {
+	c.decompressed.Inc()
+	return c.Compressor.Decompress(r)
+}
+
+func callEcho(t *testing.T, serverCompressors []transport.Compressor, clientCompressor transport.Compressor, body string) (*transport.Response, error) {
+	it, err := NewTransport(ServiceName("service"), Compressors(serverCompressors...))
+	require.NoError(t, err)
+	i := it.NewInbound()
+	i.SetRouter(transporttest.EchoRouter{})
+	require.NoError(t, i.Start(), "failed to start inbound")
+	require.NoError(t, it.Start(), "failed to start inbound transport")
+	defer func() {
+		assert.NoError(t, it.Stop())
+		assert.NoError(t, i.Stop())
+	}()
+
+	ot, err := NewTransport(ServiceName("caller"))
+	require.NoError(t, err)
+	require.NoError(t, ot.Start(), "failed to start outbound transport")
+	o := ot.NewSingleOutbound(it.ListenAddr(), Compressor(clientCompressor))
+	require.NoError(t, o.Start(), "failed to start outbound")
+	defer func() {
+		assert.NoError(t, ot.Stop())
+		assert.NoError(t, o.Stop())
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*testtime.Millisecond)
+	defer cancel()
+	res, err := o.Call(ctx, &transport.Request{
+		Caller:    "caller",
+		Service:   "service",
+		Encoding:  raw.Encoding,
+		Procedure: "procedure",
+		Headers:   transport.NewHeaders().With("foo", "bar"),
+		Body:      bytes.NewReader([]byte(body)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Read the body before the transports are stopped.
+	resBody, err := ioutil.ReadAll(res.Body)
+	require.NoError(t, err, "failed to read response body")
+	require.NoError(t, res.Body.Close(), "failed to close response body")
+	res.Body = ioutil.NopCloser(bytes.NewReader(resBody))
+	return res, nil
+}
+
+func TestCompressedRoundTrip(t *testing.T) {
+	compressor := &countingCompressor{Compressor: yarpcgzip.New()}
+	payload := strings.Repeat("hello, world! ", 100)
+
+	res, err := callEcho(t, []transport.Compressor{compressor}, compressor, payload)
+	require.NoError(t, err)
+
+	body, err := ioutil.ReadAll(res.Body)
+	require.NoError(t, err)
+	assert.Equal(t, payload, string(body))
+	assert.Empty(t, res.Headers.Items(), "encoding headers must not be visible to users")
+
+	// Requests and responses must both have been compressed.
+	assert.Equal(t, int32(2), compressor.compressed.Load())
+	assert.Equal(t, int32(2), compressor.decompressed.Load())
+}
+
+func TestCompressedRequestUnsupported(t *testing.T) {
+	_, err := callEcho(t, nil, yarpcgzip.New(), "hello")
+	require.Error(t, err)
+	assert.Equal(t, yarpcerrors.CodeInvalidArgument, yarpcerrors.FromError(err).Code())
+	assert.Contains(t, err.Error(), `unsupported content encoding "gzip"`)
+}
@@ -51,10 +51,28 @@ type TransportConfig struct {
 // 	    address: :4040
 //
 // At most one TChannel inbound may be defined in a single YARPC service.
+//
+// The inbound can accept connections over TLS, for example from a proxy that
+// terminates TLS for its callers. See yarpcconfig.InboundTLS for details.
+//
+// 	inbounds:
+// 	  tchannel:
+// 	    address: :4040
+// 	    tls:
+// 	      enabled: true
+// 	      certFile: /etc/certs/server.pem
+// 	      keyFile: /etc/certs/server-key.pem
+//
+// TChannel outbounds cannot dial peers over TLS because TChannel does not
+// support custom dialers, so TChannel inbounds do not support client
+// authentication: the clientAuth and caFile settings are rejected.
 type InboundConfig struct {
 	// Address to listen on. Defaults to ":0" (all network interfaces and a
 	// random OS-assigned port).
 	Address string `config:"address,interpolate"`
+	// Configures the inbound to accept connections over TLS. This field is
+	// optional.
+	TLS yarpcconfig.InboundTLS `config:"tls"`
 }
 
 // OutboundConfig configures a TChannel outbound.
@@ -63,8 +81,20 @@ type InboundConfig struct {
 // 	  myservice:
 // 	    tchannel:
 // 	      peer: 127.0.0.1:4040
+//
+// A TChannel outbound can compress requests with a compressor registered with
+// the Configurator.
+//
+// 	outbounds:
+// 	  myservice:
+// 	    tchannel:
+// 	      peer: 127.0.0.1:4040
+// 	      compressor: gzip
 type OutboundConfig struct {
 	yarpcconfig.PeerChooser
+
+	// Name of the compressor used for request bodies, if any.
+	Compressor string `config:"compressor"`
 }
 
 // TransportSpec returns a TransportSpec for the TChannel unary transport.
@@ -74,6 +104,8 @@ func TransportSpec(opts ...Option) yarpcconfig.TransportSpec {
 		switch opt := o.(type) {
 		case TransportOption:
 			ts.transportOptions = append(ts.transportOptions, opt)
+		case OutboundOption:
+			ts.outboundOptions = append(ts.outboundOptions, opt)
 		default:
 			panic(fmt.Sprintf("unknown option of type %T: %v", o, o))
 		}
@@ -87,14 +119,16 @@ func TransportSpec(opts ...Option) yarpcconfig.TransportSpec {
 // configuration.
 type transportSpec struct {
 	transportOptions []TransportOption
+	outboundOptions  []OutboundOption
 }
 
 func (ts *transportSpec) Spec() yarpcconfig.TransportSpec {
 	return yarpcconfig.TransportSpec{
-		Name:               transportName,
-		BuildTransport:     ts.buildTransport,
-		BuildInbound:       ts.buildInbound,
-		BuildUnaryOutbound: ts.buildUnaryOutbound,
+		Name:                transportName,
+		BuildTransport:      ts.buildTransport,
+		BuildInbound:        ts.buildInbound,
+		BuildUnaryOutbound:  ts.buildUnaryOutbound,
+		BuildStreamOutbound: ts.buildStreamOutbound,
 	}
 }
 
@@ -114,6 +148,7 @@ func (ts *transportSpec) buildTransport(tc *TransportConfig, k *yarpcconfig.Kit)
 		return nil, err
 	}
 	options.connBackoffStrategy = strategy
+	options.compressors = append(options.compressors, k.Compressors()...)
 
 	if options.name != "" {
 		return nil, fmt.Errorf("TChannel TransportSpec does not accept ServiceName")
@@ -144,15 +179,42 @@ func (ts *transportSpec) buildInbound(c *InboundConfig, t transport.Transport, k
 		return nil, fmt.Errorf("at most one TChannel inbound may be specified")
 	}
 
+	if (c.TLS.ClientAuth != "" && c.TLS.ClientAuth != "none") || c.TLS.CAFile != "" {
+		return nil, fmt.Errorf("cannot configure TLS for TChannel inbound: client authentication is not supported")
+	}
+	tlsConfig, err := c.TLS.ServerConfig()
+	if err != nil {
+		return nil, fmt.Errorf("cannot configure TLS for TChannel inbound: %v", err)
+	}
+	if tlsConfig != nil {
+		trans.tlsConfig = tlsConfig
+	}
+
 	trans.addr = c.Address
 	return trans.NewInbound(), nil
 }
 
 func (ts *transportSpec) buildUnaryOutbound(oc *OutboundConfig, t transport.Transport, k *yarpcconfig.Kit) (transport.UnaryOutbound, error) {
+	return ts.buildOutbound(oc, t, k)
+}
+
+func (ts *transportSpec) buildStreamOutbound(oc *OutboundConfig, t transport.Transport, k *yarpcconfig.Kit) (transport.StreamOutbound, error) {
+	return ts.buildOutbound(oc, t, k)
+}
+
+func (ts *transportSpec) buildOutbound(oc *OutboundConfig, t transport.Transport, k *yarpcconfig.Kit) (*Outbound, error) {
 	x := t.(*Transport)
 	chooser, err := oc.BuildPeerChooser(x, hostport.Identify, k)
 	if err != nil {
 		return nil, err
 	}
-	return x.NewOutbound(chooser), nil
+	opts := ts.outboundOptions
+	if oc.Compressor != "" {
+		compressor, err := k.Compressor(oc.Compressor)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, Compressor(compressor))
+	}
+	return x.NewOutbound(chooser, opts...), nil
 }
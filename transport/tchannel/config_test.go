@@ -28,6 +28,7 @@ import (
 	"github.com/stretchr/testify/require"
 	tchanneltest "github.com/uber/tchannel-go/testutils"
 	"go.uber.org/yarpc"
+	yarpcgzip "go.uber.org/yarpc/compressor/gzip"
 	"go.uber.org/yarpc/yarpcconfig"
 )
 
@@ -74,8 +75,9 @@ func TestTransportSpec(t *testing.T) {
 
 		empty bool // whether this test case is empty
 
-		wantErrors    []string
-		wantOutbounds []string
+		wantErrors      []string
+		wantOutbounds   []string
+		wantCompressors map[string]string // outbound name to compressor name
 	}
 
 	inboundTests := []inboundTest{
@@ -101,6 +103,33 @@ func TestTransportSpec(t *testing.T) {
 			cfg:        attrs{"tchannel": attrs{}},
 			wantErrors: []string{"inbound address is required"},
 		},
+		{
+			desc: "inbound tls without key",
+			cfg: attrs{"tchannel": attrs{
+				"address": ":4040",
+				"tls":     attrs{"enabled": true, "certFile": "cert.pem"},
+			}},
+			wantErrors: []string{
+				"cannot configure TLS for TChannel inbound",
+				"both certFile and keyFile are required for TLS",
+			},
+		},
+		{
+			desc: "inbound tls with client authentication",
+			cfg: attrs{"tchannel": attrs{
+				"address": ":4040",
+				"tls": attrs{
+					"enabled":    true,
+					"certFile":   "cert.pem",
+					"keyFile":    "key.pem",
+					"clientAuth": "require-and-verify",
+				},
+			}},
+			wantErrors: []string{
+				"cannot configure TLS for TChannel inbound",
+				"client authentication is not supported",
+			},
+		},
 		{
 			desc: "too many inbounds",
 			cfg: attrs{
@@ -164,6 +193,34 @@ func TestTransportSpec(t *testing.T) {
 				`failed to read attribute "least-pending": wat`,
 			},
 		},
+		{
+			desc: "outbound compressor",
+			cfg: attrs{
+				"myservice": attrs{
+					"tchannel": attrs{
+						"peer":       "127.0.0.1:4040",
+						"compressor": "gzip",
+					},
+				},
+			},
+			wantOutbounds:   []string{"myservice"},
+			wantCompressors: map[string]string{"myservice": "gzip"},
+		},
+		{
+			desc: "outbound unknown compressor",
+			cfg: attrs{
+				"myservice": attrs{
+					"tchannel": attrs{
+						"peer":       "127.0.0.1:4040",
+						"compressor": "zstd",
+					},
+				},
+			},
+			wantErrors: []string{
+				`failed to configure unary outbound for "myservice"`,
+				`no recognized compressor "zstd"; need one of gzip`,
+			},
+		},
 	}
 
 	runTest := func(t *testing.T, inbound inboundTest, outbound outboundTest) {
@@ -178,6 +235,7 @@ func TestTransportSpec(t *testing.T) {
 			env[k] = v
 		}
 		configurator := yarpcconfig.New(yarpcconfig.InterpolationResolver(mapResolver(env)))
+		configurator.MustRegisterCompressor(yarpcgzip.New())
 
 		opts := append(inbound.opts, outbound.opts...)
 		err := configurator.RegisterTransport(TransportSpec(opts...))
@@ -222,6 +280,17 @@ func TestTransportSpec(t *testing.T) {
 		for _, svc := range outbound.wantOutbounds {
 			_, ok := cfg.Outbounds[svc].Unary.(*Outbound)
 			assert.True(t, ok, "expected *Outbound for %q, got %T", svc, cfg.Outbounds[svc].Unary)
+
+			_, ok = cfg.Outbounds[svc].Stream.(*Outbound)
+			assert.True(t, ok, "expected *Outbound for %q stream, got %T", svc, cfg.Outbounds[svc].Stream)
+		}
+
+		for svc, name := range outbound.wantCompressors {
+			o, ok := cfg.Outbounds[svc].Unary.(*Outbound)
+			if assert.True(t, ok, "expected *Outbound for %q, got %T", svc, cfg.Outbounds[svc].Unary) &&
+				assert.NotNil(t, o.compressor, "expected a compressor for %q", svc) {
+				assert.Equal(t, name, o.compressor.Name(), "compressor for %q must match", svc)
+			}
 		}
 
 		d := yarpc.NewDispatcher(cfg)
@@ -19,7 +19,8 @@
 // THE SOFTWARE.
 
 // Package tchannel implements a YARPC transport based on the TChannel
-// protocol. The TChannel transport provides support for Unary RPCs only.
+// protocol. The TChannel transport provides support for Unary RPCs and
+// experimental support for Streaming RPCs.
 //
 // Usage
 //
@@ -60,4 +61,12 @@
 // A TChannel transport may be configured using YARPC's configuration system.
 // See TransportConfig, InboundConfig, and OutboundConfig for details on the
 // different configuration parameters supported by this transport.
+//
+// Streaming
+//
+// Outbounds built with NewOutbound or NewSingleOutbound and inbounds built
+// with NewInbound support Streaming RPCs. A stream is a single TChannel call
+// whose request and response bodies carry the messages of the stream, each
+// sent in its own TChannel frames as soon as it is written. As with all
+// TChannel calls, the context of a stream must have a deadline.
 package tchannel
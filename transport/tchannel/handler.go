@@ -30,6 +30,7 @@ import (
 	"go.uber.org/multierr"
 	"go.uber.org/yarpc/api/transport"
 	"go.uber.org/yarpc/internal/bufferpool"
+	intyarpcerrors "go.uber.org/yarpc/internal/yarpcerrors"
 	"go.uber.org/yarpc/pkg/errors"
 	"go.uber.org/yarpc/yarpcerrors"
 	"go.uber.org/zap"
@@ -79,7 +80,12 @@ type responseWriter interface {
 	Close() error
 	IsApplicationError() bool
 	SetApplicationError()
+	SetCompressor(c transport.Compressor)
 	Write(s []byte) (int, error)
+
+	// startStream sends the response headers for a stream and returns the
+	// writer for its messages. Close ends the stream.
+	startStream() (tchannel.ArgWriter, error)
 }
 
 // tchannelCall wraps a TChannel InboundCall into an inboundCall.
@@ -99,7 +105,11 @@ type handler struct {
 	tracer            opentracing.Tracer
 	headerCase        headerCase
 	logger            *zap.Logger
+	compressors       map[string]transport.Compressor
 	newResponseWriter func(inboundCallResponse, tchannel.Format, headerCase) responseWriter
+
+	// streams tracks the running stream handlers, if set.
+	streams *streamGroup
 }
 
 func (h handler) Handle(ctx ncontext.Context, call *tchannel.InboundCall) {
@@ -142,6 +152,9 @@ func (h handler) handle(ctx context.Context, call inboundCall) {
 		if status.Message() != "" {
 			responseWriter.AddHeader(ErrorMessageHeaderKey, status.Message())
 		}
+		if details := intyarpcerrors.DetailsHeader(h.logger, status); details != "" {
+			responseWriter.AddHeader(ErrorDetailsHeaderKey, details)
+		}
 	}
 	if err := responseWriter.Close(); err != nil {
 		if err := call.Response().SendSystemError(getSystemError(err)); err != nil {
@@ -173,6 +186,16 @@ func (h handler) callHandler(ctx context.Context, call inboundCall, responseWrit
 	if err != nil {
 		return errors.RequestHeadersDecodeError(treq, err)
 	}
+	contentEncoding, _ := headers.Get(ContentEncodingHeaderKey)
+	acceptEncoding, _ := headers.Get(AcceptEncodingHeaderKey)
+	stream, _ := headers.Get(StreamHeaderKey)
+	isStream := stream == streamHeaderValue
+	headers.Del(ContentEncodingHeaderKey)
+	headers.Del(AcceptEncodingHeaderKey)
+	headers.Del(StreamHeaderKey)
+	if compressor, ok := h.compressors[acceptEncoding]; ok {
+		responseWriter.SetCompressor(compressor)
+	}
 	treq.Headers = headers
 
 	if tcall, ok := call.(tchannelCall); ok {
@@ -187,6 +210,19 @@ func (h handler) callHandler(ctx context.Context, call inboundCall, responseWrit
 	defer body.Close()
 	treq.Body = body
 
+	if contentEncoding != "" {
+		compressor, ok := h.compressors[contentEncoding]
+		if !ok {
+			return yarpcerrors.InvalidArgumentErrorf("unsupported content encoding %q", contentEncoding)
+		}
+		decompressed, err := compressor.Decompress(body)
+		if err != nil {
+			return yarpcerrors.InvalidArgumentErrorf("failed to decompress %s request body: %v", contentEncoding, err)
+		}
+		defer decompressed.Close()
+		treq.Body = decompressed
+	}
+
 	if err := transport.ValidateRequest(treq); err != nil {
 		return err
 	}
@@ -208,6 +244,14 @@ func (h handler) callHandler(ctx context.Context, call inboundCall, responseWrit
 	if err := transport.ValidateRequestContext(ctx); err != nil {
 		return err
 	}
+	if isStream != (spec.Type() == transport.Streaming) {
+		if isStream {
+			return yarpcerrors.InvalidArgumentErrorf(
+				"procedure %q of service %q does not support streaming", treq.Procedure, treq.Service)
+		}
+		return yarpcerrors.InvalidArgumentErrorf(
+			"procedure %q of service %q requires a stream request", treq.Procedure, treq.Service)
+	}
 	switch spec.Type() {
 	case transport.Unary:
 		return transport.InvokeUnaryHandler(transport.UnaryInvokeRequest{
@@ -219,6 +263,9 @@ func (h handler) callHandler(ctx context.Context, call inboundCall, responseWrit
 			Logger:         h.logger,
 		})
 
+	case transport.Streaming:
+		return h.handleStream(ctx, treq, spec.Stream(), responseWriter)
+
 	default:
 		return yarpcerrors.Newf(yarpcerrors.CodeUnimplemented, "transport tchannel does not handle %s handlers", spec.Type().String())
 	}
@@ -232,6 +279,10 @@ type handlerWriter struct {
 	response         inboundCallResponse
 	applicationError bool
 	headerCase       headerCase
+	compressor       transport.Compressor
+
+	// Writer for the messages of a stream, if the response is a stream.
+	stream tchannel.ArgWriter
 }
 
 func newHandlerWriter(response inboundCallResponse, format tchannel.Format, headerCase headerCase) responseWriter {
@@ -265,6 +316,10 @@ func (hw *handlerWriter) IsApplicationError() bool {
 	return hw.applicationError
 }
 
+func (hw *handlerWriter) SetCompressor(c transport.Compressor) {
+	hw.compressor = c
+}
+
 func (hw *handlerWriter) Write(s []byte) (int, error) {
 	if hw.failedWith != nil {
 		return 0, hw.failedWith
@@ -281,7 +336,29 @@ func (hw *handlerWriter) Write(s []byte) (int, error) {
 	return n, err
 }
 
+func (hw *handlerWriter) startStream() (tchannel.ArgWriter, error) {
+	hw.AddHeader(StreamHeaderKey, streamHeaderValue)
+	headers := headerMap(hw.headers, hw.headerCase)
+	if err := writeHeaders(hw.format, headers, nil, hw.response.Arg2Writer); err != nil {
+		return nil, err
+	}
+	w, err := hw.response.Arg3Writer()
+	if err != nil {
+		return nil, err
+	}
+	// Flush the headers so that the caller may start reading the stream.
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	hw.stream = w
+	return w, nil
+}
+
 func (hw *handlerWriter) Close() error {
+	if hw.stream != nil {
+		return hw.stream.Close()
+	}
+
 	retErr := hw.failedWith
 	if hw.IsApplicationError() {
 		if err := hw.response.SetApplicationError(); err != nil {
@@ -289,6 +366,10 @@ func (hw *handlerWriter) Close() error {
 		}
 	}
 
+	if hw.compressor != nil && hw.buffer != nil && hw.buffer.Len() > 0 {
+		hw.compressBuffer()
+	}
+
 	headers := headerMap(hw.headers, hw.headerCase)
 	retErr = appendError(retErr, writeHeaders(hw.format, headers, nil, hw.response.Arg2Writer))
 
@@ -309,6 +390,24 @@ func (hw *handlerWriter) Close() error {
 	return retErr
 }
 
+// compressBuffer replaces the buffered body with its compressed form. The
+// body is left uncompressed if compression fails.
+func (hw *handlerWriter) compressBuffer() {
+	compressed := bufferpool.Get()
+	w, err := hw.compressor.Compress(compressed)
+	if err == nil {
+		_, err = w.Write(hw.buffer.Bytes())
+		err = appendError(err, w.Close())
+	}
+	if err != nil {
+		bufferpool.Put(compressed)
+		return
+	}
+	bufferpool.Put(hw.buffer)
+	hw.buffer = compressed
+	hw.AddHeader(ContentEncodingHeaderKey, hw.compressor.Name())
+}
+
 func getSystemError(err error) error {
 	if _, ok := err.(tchannel.SystemError); ok {
 		return err
@@ -39,15 +39,32 @@ const (
 	ErrorNameHeaderKey = "$rpc$-error-name"
 	// ErrorMessageHeaderKey is the response header key for the error message.
 	ErrorMessageHeaderKey = "$rpc$-error-message"
+	// ErrorDetailsHeaderKey is the response header key for the error details.
+	// They are encoded as a google.rpc.Status Protobuf message with only the
+	// details field set, in base64.
+	ErrorDetailsHeaderKey = "$rpc$-error-details"
 	// ServiceHeaderKey is the response header key for the respond service
 	ServiceHeaderKey = "$rpc$-service"
+	// ContentEncodingHeaderKey is the request and response header key for the
+	// name of the compressor used for the body, if any.
+	ContentEncodingHeaderKey = "$rpc$-content-encoding"
+	// AcceptEncodingHeaderKey is the request header key for the name of the
+	// compressor the caller accepts for the response body.
+	AcceptEncodingHeaderKey = "$rpc$-accept-encoding"
+	// StreamHeaderKey is the request and response header key that marks
+	// calls for streaming RPCs.
+	StreamHeaderKey = "$rpc$-stream"
 )
 
 var _reservedHeaderKeys = map[string]struct{}{
-	ErrorCodeHeaderKey:    {},
-	ErrorNameHeaderKey:    {},
-	ErrorMessageHeaderKey: {},
-	ServiceHeaderKey:      {},
+	ErrorCodeHeaderKey:       {},
+	ErrorNameHeaderKey:       {},
+	ErrorMessageHeaderKey:    {},
+	ErrorDetailsHeaderKey:    {},
+	ServiceHeaderKey:         {},
+	ContentEncodingHeaderKey: {},
+	AcceptEncodingHeaderKey:  {},
+	StreamHeaderKey:          {},
 }
 
 func isReservedHeaderKey(key string) bool {
@@ -72,9 +72,14 @@ func (i *Inbound) Start() error {
 	})
 }
 
-// Stop stops the TChannel outbound. This currently does nothing.
+// Stop stops the TChannel inbound. It rejects new streams and waits for the
+// handlers of running streams to return. Unary requests are served until the
+// underlying transport stops.
 func (i *Inbound) Stop() error {
-	return i.once.Stop(nil)
+	return i.once.Stop(func() error {
+		i.transport.streams.stop()
+		return nil
+	})
 }
 
 // IsRunning returns whether the Inbound is running.
@@ -23,6 +23,7 @@ package tchannel
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"io/ioutil"
 	"testing"
 
@@ -33,6 +34,8 @@ import (
 	"go.uber.org/yarpc/api/transport/transporttest"
 	"go.uber.org/yarpc/encoding/raw"
 	"go.uber.org/yarpc/internal/testtime"
+	"go.uber.org/yarpc/internal/testtls"
+	"go.uber.org/yarpc/yarpcconfig"
 )
 
 func TestInboundStartNew(t *testing.T) {
@@ -66,6 +69,37 @@ func TestInboundInvalidAddress(t *testing.T) {
 	defer x.Stop()
 }
 
+func TestInboundTLS(t *testing.T) {
+	s := testtls.NewScenario(t)
+	defer s.Cleanup()
+
+	serverConfig, err := yarpcconfig.InboundTLS{
+		Enabled:  true,
+		CertFile: s.ServerCertFile,
+		KeyFile:  s.ServerKeyFile,
+	}.ServerConfig()
+	require.NoError(t, err)
+
+	x, err := NewTransport(ServiceName("foo"), ListenAddr("127.0.0.1:0"), ServerTLSConfig(serverConfig))
+	require.NoError(t, err)
+	i := x.NewInbound()
+	i.SetRouter(yarpc.NewMapRouter("foo"))
+	require.NoError(t, i.Start())
+	defer i.Stop()
+	require.NoError(t, x.Start())
+	defer x.Stop()
+
+	conn, err := tls.Dial("tcp", x.ListenAddr(), &tls.Config{RootCAs: s.CAs, ServerName: "localhost"})
+	require.NoError(t, err, "TLS handshake failed")
+	defer conn.Close()
+	assert.Equal(t, "server", conn.ConnectionState().PeerCertificates[0].Subject.CommonName)
+}
+
+func TestInboundTLSClientAuth(t *testing.T) {
+	_, err := NewTransport(ServiceName("foo"), ServerTLSConfig(&tls.Config{ClientAuth: tls.RequireAndVerifyClientCert}))
+	assert.Error(t, err, "client authentication must be rejected")
+}
+
 type nophandler struct{}
 
 func (nophandler) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter) error {
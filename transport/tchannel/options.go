@@ -21,18 +21,20 @@
 package tchannel
 
 import (
+	"crypto/tls"
 	"net"
 	"time"
 
 	"github.com/opentracing/opentracing-go"
 	backoffapi "go.uber.org/yarpc/api/backoff"
+	"go.uber.org/yarpc/api/transport"
 	"go.uber.org/yarpc/internal/backoff"
 	"go.uber.org/zap"
 )
 
 // Option allows customizing the YARPC TChannel transport.
-// TransportSpec() accepts any TransportOption, and may in the future also
-// accept inbound and outbound options.
+// TransportSpec() accepts any TransportOption or OutboundOption, and may in
+// the future also accept inbound options.
 type Option interface {
 	tchannelOption()
 }
@@ -53,10 +55,12 @@ type transportOptions struct {
 	logger              *zap.Logger
 	addr                string
 	listener            net.Listener
+	tlsConfig           *tls.Config
 	name                string
 	connTimeout         time.Duration
 	connBackoffStrategy backoffapi.Strategy
 	originalHeaders     bool
+	compressors         []transport.Compressor
 }
 
 // newTransportOptions constructs the default transport options struct
@@ -132,6 +136,22 @@ func Listener(l net.Listener) TransportOption {
 	}
 }
 
+// ServerTLSConfig specifies that the transport should accept connections
+// over TLS with the given configuration. This only applies to NewTransport
+// (will not work with NewChannelTransport).
+//
+// Connections made by the transport to its peers do not use TLS, since
+// TChannel does not support custom dialers. For the same reason, no TChannel
+// outbound can present a client certificate, so NewTransport rejects
+// configurations that request client certificates.
+//
+// TLS is disabled by default.
+func ServerTLSConfig(config *tls.Config) TransportOption {
+	return func(t *transportOptions) {
+		t.tlsConfig = config
+	}
+}
+
 // ServiceName informs the NewChannelTransport constructor which service
 // name to use if it needs to construct a root Channel object, as when called
 // without the WithChannel option.
@@ -183,3 +203,38 @@ func OriginalHeaders() TransportOption {
 		options.originalHeaders = true
 	}
 }
+
+// Compressors specifies the compressors inbounds of this transport can use to
+// decompress request bodies and to compress response bodies for callers that
+// accept them. Compression is negotiated with the $rpc$-content-encoding and
+// $rpc$-accept-encoding headers.
+//
+// Requests using an unknown encoding are rejected, and responses are not
+// compressed by default. Use the Compressor OutboundOption to compress
+// requests.
+//
+// This option has no effect on NewChannelTransport.
+func Compressors(compressors ...transport.Compressor) TransportOption {
+	return func(options *transportOptions) {
+		options.compressors = append(options.compressors, compressors...)
+	}
+}
+
+// OutboundOption customizes the behavior of a TChannel Outbound.
+type OutboundOption func(*Outbound)
+
+// OutboundOption makes all OutboundOptions recognizable as Option so
+// TransportSpec will accept them.
+func (OutboundOption) tchannelOption() {}
+
+// Compressor specifies that the outbound should compress request bodies with
+// the given compressor. The outbound advertises the compressor to inbounds
+// and decompresses responses that use it.
+//
+// Inbounds must know about the compressor, see the Compressors
+// TransportOption. Only YARPC TChannel inbounds support compression.
+func Compressor(compressor transport.Compressor) OutboundOption {
+	return func(o *Outbound) {
+		o.compressor = compressor
+	}
+}
@@ -33,12 +33,14 @@ import (
 	"go.uber.org/yarpc/pkg/errors"
 	"go.uber.org/yarpc/pkg/lifecycle"
 	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/zap"
 )
 
 var (
 	errDoNotUseContextWithHeaders = yarpcerrors.Newf(yarpcerrors.CodeInvalidArgument, "tchannel.ContextWithHeaders is not compatible with YARPC, use yarpc.CallOption instead")
 
 	_ transport.UnaryOutbound              = (*Outbound)(nil)
+	_ transport.StreamOutbound             = (*Outbound)(nil)
 	_ introspection.IntrospectableOutbound = (*Outbound)(nil)
 )
 
@@ -46,26 +48,31 @@ var (
 // It may be constructed using the NewOutbound or NewSingleOutbound methods on
 // the TChannel Transport.
 type Outbound struct {
-	transport *Transport
-	chooser   peer.Chooser
-	once      *lifecycle.Once
+	transport  *Transport
+	chooser    peer.Chooser
+	once       *lifecycle.Once
+	compressor transport.Compressor
 }
 
 // NewOutbound builds a new TChannel outbound that selects a peer for each
 // request using the given peer chooser.
-func (t *Transport) NewOutbound(chooser peer.Chooser) *Outbound {
-	return &Outbound{
+func (t *Transport) NewOutbound(chooser peer.Chooser, opts ...OutboundOption) *Outbound {
+	o := &Outbound{
 		once:      lifecycle.NewOnce(),
 		transport: t,
 		chooser:   chooser,
 	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
 }
 
 // NewSingleOutbound builds a new TChannel outbound always using the peer with
 // the given address.
-func (t *Transport) NewSingleOutbound(addr string) *Outbound {
+func (t *Transport) NewSingleOutbound(addr string, opts ...OutboundOption) *Outbound {
 	chooser := peerchooser.NewSingle(hostport.PeerIdentifier(addr), t)
-	return t.NewOutbound(chooser)
+	return t.NewOutbound(chooser, opts...)
 }
 
 // Chooser returns the outbound's peer chooser.
@@ -88,20 +95,25 @@ func (o *Outbound) Call(ctx context.Context, req *transport.Request) (*transport
 	if err != nil {
 		return nil, toYARPCError(req, err)
 	}
-	res, err := p.Call(ctx, req)
+	res, err := p.call(ctx, req, o.compressor)
 	onFinish(err)
 	return res, toYARPCError(req, err)
 }
 
 // Call sends an RPC to this specific peer.
 func (p *tchannelPeer) Call(ctx context.Context, req *transport.Request) (*transport.Response, error) {
+	return p.call(ctx, req, nil /* compressor */)
+}
+
+func (p *tchannelPeer) call(ctx context.Context, req *transport.Request, compressor transport.Compressor) (*transport.Response, error) {
 	root := p.transport.ch.RootPeers()
 	tp := root.GetOrAdd(p.HostPort())
-	return callWithPeer(ctx, req, tp, p.transport.headerCase)
+	return callWithPeer(ctx, req, tp, p.transport.headerCase, compressor, p.transport.logger)
 }
 
-// callWithPeer sends a request with the chosen peer.
-func callWithPeer(ctx context.Context, req *transport.Request, peer *tchannel.Peer, headerCase headerCase) (*transport.Response, error) {
+// callWithPeer sends a request with the chosen peer, compressing it with the
+// given compressor, if any.
+func callWithPeer(ctx context.Context, req *transport.Request, peer *tchannel.Peer, headerCase headerCase, compressor transport.Compressor, logger *zap.Logger) (*transport.Response, error) {
 	// NB(abg): Under the current API, the local service's name is required
 	// twice: once when constructing the TChannel and then again when
 	// constructing the RPC.
@@ -133,6 +145,9 @@ func callWithPeer(ctx context.Context, req *transport.Request, peer *tchannel.Pe
 		return nil, err
 	}
 	reqHeaders := headerMap(req.Headers, headerCase)
+	if compressor != nil {
+		reqHeaders = withEncodingHeaders(reqHeaders, compressor.Name())
+	}
 
 	// baggage headers are transport implementation details that are stripped out (and stored in the context). Users don't interact with it
 	tracingBaggage := tchannel.InjectOutboundSpan(call.Response(), nil)
@@ -142,7 +157,12 @@ func callWithPeer(ctx context.Context, req *transport.Request, peer *tchannel.Pe
 		return nil, errors.RequestHeadersEncodeError(req, err)
 	}
 
-	if err := writeBody(req.Body, call); err != nil {
+	if compressor != nil {
+		err = writeCompressedBody(req.Body, call, compressor)
+	} else {
+		err = writeBody(req.Body, call)
+	}
+	if err != nil {
 		return nil, err
 	}
 
@@ -170,7 +190,16 @@ func callWithPeer(ctx context.Context, req *transport.Request, peer *tchannel.Pe
 		return nil, err
 	}
 
-	err = getResponseError(headers)
+	if encoding, _ := headers.Get(ContentEncodingHeaderKey); compressor != nil && encoding == compressor.Name() {
+		body, err := decompress(compressor, resBody)
+		if err != nil {
+			_ = resBody.Close()
+			return nil, yarpcerrors.InternalErrorf("failed to decompress %s response body: %v", encoding, err)
+		}
+		resBody = body
+	}
+
+	err = getResponseError(headers, logger)
 	deleteReservedHeaders(headers)
 
 	resp := &transport.Response{
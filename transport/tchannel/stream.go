@@ -0,0 +1,435 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/uber/tchannel-go"
+	"go.uber.org/atomic"
+	"go.uber.org/yarpc/api/transport"
+	intyarpcerrors "go.uber.org/yarpc/internal/yarpcerrors"
+	"go.uber.org/yarpc/pkg/errors"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/zap"
+)
+
+// Messages on a stream are sent in arg3 of a single call and its response.
+// Each message is framed with a 1-byte frame type followed by its length as
+// a 4-byte big-endian integer. Every frame is flushed so that it is sent as
+// soon as it is written.
+const (
+	streamFrameHeaderSize = 5
+
+	// streamMessageFrame holds a single message.
+	streamMessageFrame byte = 0
+
+	// streamErrorFrame holds the error that ended the stream, as encoded
+	// headers. Only servers send error frames, as the last frame of the
+	// response.
+	streamErrorFrame byte = 1
+)
+
+// maxStreamMessageSize is the largest stream message we are willing to
+// receive. This matches the default limit of the gRPC transport.
+const maxStreamMessageSize = 1024 * 1024 * 4
+
+// streamHeaderValue is the value of the StreamHeaderKey header for streams.
+const streamHeaderValue = "true"
+
+// withStreamHeader returns a copy of the given headers that marks the
+// request as a stream.
+func withStreamHeader(headers map[string]string) map[string]string {
+	out := make(map[string]string, len(headers)+1)
+	for k, v := range headers {
+		out[k] = v
+	}
+	out[StreamHeaderKey] = streamHeaderValue
+	return out
+}
+
+func writeStreamFrame(w tchannel.ArgWriter, frameType byte, payload []byte) error {
+	frame := make([]byte, streamFrameHeaderSize+len(payload))
+	frame[0] = frameType
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(payload)))
+	copy(frame[streamFrameHeaderSize:], payload)
+	if _, err := w.Write(frame); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// writeStreamError writes the error frame that ends a stream.
+func writeStreamError(w tchannel.ArgWriter, status *yarpcerrors.Status, logger *zap.Logger) error {
+	text, err := status.Code().MarshalText()
+	if err != nil {
+		// Codes that we don't know can't be understood by the caller either.
+		text = []byte("internal")
+	}
+	headers := map[string]string{ErrorCodeHeaderKey: string(text)}
+	if status.Name() != "" {
+		headers[ErrorNameHeaderKey] = status.Name()
+	}
+	if status.Message() != "" {
+		headers[ErrorMessageHeaderKey] = status.Message()
+	}
+	if details := intyarpcerrors.DetailsHeader(logger, status); details != "" {
+		headers[ErrorDetailsHeaderKey] = details
+	}
+	return writeStreamFrame(w, streamErrorFrame, encodeHeaders(headers))
+}
+
+// readStreamMessage reads the next message of a stream. It returns io.EOF if
+// the stream ended, or the error sent in an error frame.
+func readStreamMessage(r io.Reader, logger *zap.Logger) (*transport.StreamMessage, error) {
+	var header [streamFrameHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > maxStreamMessageSize {
+		return nil, yarpcerrors.ResourceExhaustedErrorf(
+			"stream message of %d bytes exceeds the limit of %d bytes", size, maxStreamMessageSize)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	switch header[0] {
+	case streamMessageFrame:
+		return &transport.StreamMessage{Body: ioutil.NopCloser(bytes.NewReader(payload))}, nil
+	case streamErrorFrame:
+		headers, err := decodeHeaders(bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		if err := getResponseError(headers, logger); err != nil {
+			return nil, err
+		}
+		return nil, yarpcerrors.InternalErrorf("received a stream error frame without an error")
+	default:
+		return nil, yarpcerrors.InternalErrorf("received a stream frame of unknown type %d", header[0])
+	}
+}
+
+// fromStreamError converts errors from TChannel streams into YARPC errors.
+func fromStreamError(err error) error {
+	if err == nil || err == io.EOF || yarpcerrors.IsStatus(err) {
+		return err
+	}
+	if err, ok := err.(tchannel.SystemError); ok {
+		return fromSystemError(err)
+	}
+	return yarpcerrors.FromError(err)
+}
+
+// streamGroup tracks the stream handlers that are running so that inbounds
+// can wait for them to return when they stop.
+type streamGroup struct {
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+	stopped bool
+}
+
+// add records the start of a stream handler. It returns false if the group
+// was stopped, in which case the stream must be rejected.
+func (g *streamGroup) add() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.stopped {
+		return false
+	}
+	g.wg.Add(1)
+	return true
+}
+
+func (g *streamGroup) done() {
+	g.wg.Done()
+}
+
+// stop rejects new stream handlers and waits for the running ones to return.
+func (g *streamGroup) stop() {
+	g.mu.Lock()
+	g.stopped = true
+	g.mu.Unlock()
+	g.wg.Wait()
+}
+
+func (h handler) handleStream(
+	ctx context.Context,
+	treq *transport.Request,
+	streamHandler transport.StreamHandler,
+	responseWriter responseWriter,
+) error {
+	if h.streams != nil {
+		if !h.streams.add() {
+			return yarpcerrors.UnavailableErrorf("tchannel inbound is stopping")
+		}
+		defer h.streams.done()
+	}
+
+	w, err := responseWriter.startStream()
+	if err != nil {
+		return err
+	}
+
+	stream := &serverStream{
+		ctx:    ctx,
+		req:    &transport.StreamRequest{Meta: treq.ToRequestMeta()},
+		reader: treq.Body,
+		writer: w,
+		logger: h.logger,
+	}
+	tServerStream, err := transport.NewServerStream(stream)
+	if err != nil {
+		return err
+	}
+
+	err = transport.InvokeStreamHandler(transport.StreamInvokeRequest{
+		Stream:  tServerStream,
+		Handler: streamHandler,
+		Logger:  h.logger,
+	})
+	if err == nil {
+		return nil
+	}
+	return writeStreamError(w, yarpcerrors.FromError(errors.WrapHandlerError(err, treq.Service, treq.Procedure)), h.logger)
+}
+
+type serverStream struct {
+	ctx    context.Context
+	req    *transport.StreamRequest
+	reader io.Reader
+	writer tchannel.ArgWriter
+	logger *zap.Logger
+}
+
+func (ss *serverStream) Context() context.Context {
+	return ss.ctx
+}
+
+func (ss *serverStream) Request() *transport.StreamRequest {
+	return ss.req
+}
+
+func (ss *serverStream) SendMessage(_ context.Context, m *transport.StreamMessage) error {
+	// TODO pool buffers for performance.
+	msg, err := ioutil.ReadAll(m.Body)
+	_ = m.Body.Close()
+	if err != nil {
+		return yarpcerrors.FromError(err)
+	}
+	return fromStreamError(writeStreamFrame(ss.writer, streamMessageFrame, msg))
+}
+
+func (ss *serverStream) ReceiveMessage(context.Context) (*transport.StreamMessage, error) {
+	msg, err := readStreamMessage(ss.reader, ss.logger)
+	return msg, fromStreamError(err)
+}
+
+// CallStream implements transport.StreamOutbound#CallStream.
+//
+// Messages are sent in arg3 of a single TChannel call and received in arg3
+// of its response. As with all TChannel calls, the context must have a
+// deadline, which bounds the lifetime of the stream. Stream messages are not
+// compressed by the outbound's Compressor.
+func (o *Outbound) CallStream(ctx context.Context, req *transport.StreamRequest) (*transport.ClientStream, error) {
+	if req == nil || req.Meta == nil {
+		return nil, yarpcerrors.InvalidArgumentErrorf("stream request requires a request metadata")
+	}
+	treq := req.Meta.ToRequest()
+	if err := o.once.WaitUntilRunning(ctx); err != nil {
+		return nil, intyarpcerrors.AnnotateWithInfo(yarpcerrors.FromError(err), "error waiting for tchannel outbound to start for service: %s", treq.Service)
+	}
+	if _, ok := ctx.(tchannel.ContextWithHeaders); ok {
+		return nil, errDoNotUseContextWithHeaders
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		return nil, yarpcerrors.InvalidArgumentErrorf("tchannel streams require a context deadline")
+	}
+	p, onFinish, err := o.getPeerForRequest(ctx, treq)
+	if err != nil {
+		return nil, toYARPCError(treq, err)
+	}
+	stream, err := p.callStream(ctx, req)
+	if err != nil {
+		onFinish(err)
+		return nil, toYARPCError(treq, err)
+	}
+	// The peer is released once the stream ends, which the caller observes
+	// through ReceiveMessage.
+	stream.onFinish = onFinish
+	tClientStream, err := transport.NewClientStream(stream)
+	if err != nil {
+		_ = stream.Close(ctx)
+		stream.finish(err)
+		return nil, err
+	}
+	return tClientStream, nil
+}
+
+func (p *tchannelPeer) callStream(ctx context.Context, req *transport.StreamRequest) (*clientStream, error) {
+	root := p.transport.ch.RootPeers()
+	tp := root.GetOrAdd(p.HostPort())
+	return callStreamWithPeer(ctx, req, tp, p.transport.headerCase, p.transport.logger)
+}
+
+// callStreamWithPeer opens a stream with the chosen peer. It returns once
+// the peer has accepted the stream.
+func callStreamWithPeer(ctx context.Context, req *transport.StreamRequest, peer *tchannel.Peer, headerCase headerCase, logger *zap.Logger) (*clientStream, error) {
+	treq := req.Meta.ToRequest()
+	format := tchannel.Format(treq.Encoding)
+	call, err := peer.BeginCall(ctx, treq.Service, treq.Procedure, &tchannel.CallOptions{
+		Format:          format,
+		ShardKey:        treq.ShardKey,
+		RoutingKey:      treq.RoutingKey,
+		RoutingDelegate: treq.RoutingDelegate,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	reqHeaders := withStreamHeader(headerMap(treq.Headers, headerCase))
+	tracingBaggage := tchannel.InjectOutboundSpan(call.Response(), nil)
+	if err := writeHeaders(format, reqHeaders, tracingBaggage, call.Arg2Writer); err != nil {
+		return nil, errors.RequestHeadersEncodeError(treq, err)
+	}
+
+	// Flush arg3 to start the call before any messages are sent.
+	writer, err := call.Arg3Writer()
+	if err != nil {
+		return nil, err
+	}
+	if err := writer.Flush(); err != nil {
+		return nil, err
+	}
+
+	res := call.Response()
+	headers, err := readHeaders(format, res.Arg2Reader)
+	if err != nil {
+		if err, ok := err.(tchannel.SystemError); ok {
+			return nil, fromSystemError(err)
+		}
+		return nil, errors.ResponseHeadersDecodeError(treq, err)
+	}
+	reader, err := res.Arg3Reader()
+	if err != nil {
+		return nil, err
+	}
+
+	respService, _ := headers.Get(ServiceHeaderKey) // validateServiceName handles empty strings
+	if err := validateServiceName(treq.Service, respService); err != nil {
+		_ = reader.Close()
+		return nil, err
+	}
+	if err := getResponseError(headers, logger); err != nil {
+		_ = reader.Close()
+		return nil, err
+	}
+	if stream, _ := headers.Get(StreamHeaderKey); stream != streamHeaderValue {
+		_ = reader.Close()
+		return nil, yarpcerrors.UnimplementedErrorf(
+			"procedure %q of service %q did not respond with a stream", treq.Procedure, treq.Service)
+	}
+
+	return &clientStream{
+		ctx:    ctx,
+		req:    req,
+		writer: writer,
+		reader: reader,
+		logger: logger,
+	}, nil
+}
+
+type clientStream struct {
+	ctx    context.Context
+	req    *transport.StreamRequest
+	writer tchannel.ArgWriter
+	reader tchannel.ArgReader
+	logger *zap.Logger
+
+	// onFinish releases the peer of the stream once the stream has ended.
+	onFinish func(error)
+
+	// Whether the caller has closed the stream or the stream has ended.
+	sendClosed atomic.Bool
+	recvClosed atomic.Bool
+}
+
+func (cs *clientStream) Context() context.Context {
+	return cs.ctx
+}
+
+func (cs *clientStream) Request() *transport.StreamRequest {
+	return cs.req
+}
+
+func (cs *clientStream) SendMessage(_ context.Context, m *transport.StreamMessage) error {
+	if cs.sendClosed.Load() || cs.recvClosed.Load() { // If the stream is closed, we should not be sending messages on it.
+		return io.EOF
+	}
+	// TODO pool buffers for performance.
+	msg, err := ioutil.ReadAll(m.Body)
+	_ = m.Body.Close()
+	if err != nil {
+		return yarpcerrors.FromError(err)
+	}
+	return fromStreamError(writeStreamFrame(cs.writer, streamMessageFrame, msg))
+}
+
+func (cs *clientStream) ReceiveMessage(context.Context) (*transport.StreamMessage, error) {
+	msg, err := readStreamMessage(cs.reader, cs.logger)
+	if err != nil {
+		err = fromStreamError(err)
+		cs.finish(err)
+	}
+	return msg, err
+}
+
+func (cs *clientStream) Close(context.Context) error {
+	if cs.sendClosed.Swap(true) {
+		return nil
+	}
+	return fromStreamError(cs.writer.Close())
+}
+
+// finish marks the stream as ended with the given error, releasing its peer.
+// Only the first call has an effect.
+func (cs *clientStream) finish(err error) {
+	if cs.recvClosed.Swap(true) {
+		return
+	}
+	if err == io.EOF {
+		err = nil
+	}
+	if cs.onFinish != nil {
+		cs.onFinish(err)
+	}
+}
@@ -0,0 +1,458 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	peerchooser "go.uber.org/yarpc/peer"
+	"go.uber.org/yarpc/peer/hostport"
+	"go.uber.org/yarpc/transport/tchannel"
+	. "go.uber.org/yarpc/x/yarpctest"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+func TestStreaming(t *testing.T) {
+	p := NewPortProvider(t)
+	tests := []struct {
+		name     string
+		services Lifecycle
+		requests Action
+	}{
+		{
+			name: "stream requests",
+			services: Lifecycles(
+				TChannelService(
+					Name("myservice"),
+					p.NamedPort("1"),
+					Proc(
+						Name("proc"),
+						EchoStreamHandler(),
+					),
+				),
+			),
+			requests: ConcurrentAction(
+				RepeatAction(
+					TChannelStreamRequest(
+						p.NamedPort("1"),
+						Service("myservice"),
+						Procedure("proc"),
+						ClientStreamActions(
+							SendStreamMsg("test"),
+							RecvStreamMsg("test"),
+							SendStreamMsg("test2"),
+							RecvStreamMsg("test2"),
+							CloseStream(),
+						),
+					),
+					10,
+				),
+				3,
+			),
+		},
+		{
+			name: "stream close from client",
+			services: Lifecycles(
+				TChannelService(
+					Name("myservice"),
+					p.NamedPort("2"),
+					Proc(
+						Name("proc"),
+						OrderedStreamHandler(
+							RecvStreamMsg("test"),
+							SendStreamMsg("test1"),
+							RecvStreamMsg("test2"),
+							SendStreamMsg("test3"),
+							RecvStreamErr(io.EOF.Error()),
+							SendStreamMsg("test4"),
+							StreamHandlerError(io.EOF),
+						),
+					),
+				),
+			),
+			requests: Actions(
+				TChannelStreamRequest(
+					p.NamedPort("2"),
+					Service("myservice"),
+					Procedure("proc"),
+					ClientStreamActions(
+						SendStreamMsg("test"),
+						RecvStreamMsg("test1"),
+						SendStreamMsg("test2"),
+						RecvStreamMsg("test3"),
+						CloseStream(),
+						RecvStreamMsg("test4"),
+					),
+				),
+			),
+		},
+		{
+			name: "stream close from server",
+			services: Lifecycles(
+				TChannelService(
+					Name("myservice"),
+					p.NamedPort("3"),
+					Proc(
+						Name("proc"),
+						OrderedStreamHandler(
+							RecvStreamMsg("test"),
+							SendStreamMsg("test1"),
+							RecvStreamMsg("test2"),
+							SendStreamMsg("test3"),
+						), // End of Stream
+					),
+				),
+			),
+			requests: Actions(
+				TChannelStreamRequest(
+					p.NamedPort("3"),
+					Service("myservice"),
+					Procedure("proc"),
+					ClientStreamActions(
+						SendStreamMsg("test"),
+						RecvStreamMsg("test1"),
+						SendStreamMsg("test2"),
+						RecvStreamMsg("test3"),
+						RecvStreamErr(io.EOF.Error()),
+					),
+				),
+			),
+		},
+		{
+			name: "stream close from server with error",
+			services: Lifecycles(
+				TChannelService(
+					Name("myservice"),
+					p.NamedPort("4"),
+					Proc(
+						Name("proc"),
+						OrderedStreamHandler(
+							RecvStreamMsg("test"),
+							SendStreamMsg("test1"),
+							RecvStreamMsg("test2"),
+							SendStreamMsg("test3"),
+							StreamHandlerError(yarpcerrors.InternalErrorf("myerroooooor")),
+						),
+					),
+				),
+			),
+			requests: Actions(
+				TChannelStreamRequest(
+					p.NamedPort("4"),
+					Service("myservice"),
+					Procedure("proc"),
+					ClientStreamActions(
+						SendStreamMsg("test"),
+						RecvStreamMsg("test1"),
+						SendStreamMsg("test2"),
+						RecvStreamMsg("test3"),
+						RecvStreamErr(yarpcerrors.InternalErrorf("myerroooooor").Error()),
+					),
+				),
+			),
+		},
+		{
+			name: "stream recv after close",
+			services: Lifecycles(
+				TChannelService(
+					Name("myservice"),
+					p.NamedPort("5"),
+					Proc(
+						Name("proc"),
+						OrderedStreamHandler(
+							RecvStreamMsg("test"),
+							RecvStreamErr(io.EOF.Error()),
+							SendStreamMsg("test1"),
+							SendStreamMsg("test2"),
+							SendStreamMsg("test3"),
+							StreamHandlerError(yarpcerrors.InternalErrorf("test")),
+						),
+					),
+				),
+			),
+			requests: Actions(
+				TChannelStreamRequest(
+					p.NamedPort("5"),
+					Service("myservice"),
+					Procedure("proc"),
+					ClientStreamActions(
+						SendStreamMsg("test"),
+						CloseStream(),
+						SendStreamMsgAndExpectError("lala", io.EOF.Error()),
+						RecvStreamMsg("test1"),
+						RecvStreamMsg("test2"),
+						RecvStreamMsg("test3"),
+						RecvStreamErr(yarpcerrors.InternalErrorf("test").Error()),
+					),
+				),
+			),
+		},
+		{
+			name: "stream header test",
+			services: Lifecycles(
+				TChannelService(
+					Name("myservice"),
+					p.NamedPort("6"),
+					Proc(
+						Name("proc"),
+						OrderedStreamHandler(
+							WantHeader("req_key", "req_val"),
+							WantHeader("req_key2", "req_val2"),
+							RecvStreamMsg("test"),
+						), // End of Stream
+					),
+				),
+			),
+			requests: Actions(
+				TChannelStreamRequest(
+					p.NamedPort("6"),
+					Service("myservice"),
+					Procedure("proc"),
+					WithHeader("req_key", "req_val"),
+					WithHeader("req_key2", "req_val2"),
+					ClientStreamActions(
+						SendStreamMsg("test"),
+						RecvStreamErr(io.EOF.Error()),
+					),
+				),
+			),
+		},
+		{
+			name: "stream invalid request",
+			services: Lifecycles(
+				TChannelService(
+					Name("myservice"),
+					p.NamedPort("7"),
+				),
+			),
+			requests: Actions(
+				TChannelStreamRequest(
+					p.NamedPort("7"),
+					Service("myservice"),
+					Procedure("proc"),
+					// TChannel reports unimplemented procedures as bad requests.
+					WantStreamError(yarpcerrors.InvalidArgumentErrorf("unrecognized procedure \"proc\" for service \"myservice\"").Error()),
+				),
+			),
+		},
+		{
+			name: "stream invalid client decode",
+			services: Lifecycles(
+				TChannelService(
+					Name("myservice"),
+					p.NamedPort("8"),
+					Proc(
+						Name("proc"),
+						EchoStreamHandler(),
+					),
+				),
+			),
+			requests: Actions(
+				TChannelStreamRequest(
+					p.NamedPort("8"),
+					Service("myservice"),
+					Procedure("proc"),
+					ClientStreamActions(
+						SendStreamDecodeErrorAndExpectError(errors.New("nooooo"), "nooooo", "unknown"),
+						CloseStream(),
+					),
+				),
+			),
+		},
+		{
+			name: "stream invalid client decode yarpcerr",
+			services: Lifecycles(
+				TChannelService(
+					Name("myservice"),
+					p.NamedPort("9"),
+					Proc(
+						Name("proc"),
+						EchoStreamHandler(),
+					),
+				),
+			),
+			requests: Actions(
+				TChannelStreamRequest(
+					p.NamedPort("9"),
+					Service("myservice"),
+					Procedure("proc"),
+					ClientStreamActions(
+						SendStreamDecodeErrorAndExpectError(yarpcerrors.InternalErrorf("test"), yarpcerrors.InternalErrorf("test").Error()),
+						CloseStream(),
+					),
+				),
+			),
+		},
+		{
+			name: "server invalid send read",
+			services: Lifecycles(
+				TChannelService(
+					Name("myservice"),
+					p.NamedPort("12"),
+					Proc(
+						Name("proc"),
+						OrderedStreamHandler(
+							SendStreamDecodeErrorAndExpectError(yarpcerrors.InternalErrorf("test"), yarpcerrors.InternalErrorf("test").Error()),
+						),
+					),
+				),
+			),
+			requests: Actions(
+				TChannelStreamRequest(
+					p.NamedPort("12"),
+					Service("myservice"),
+					Procedure("proc"),
+					ClientStreamActions(
+						RecvStreamErr(io.EOF.Error()),
+					),
+				),
+			),
+		},
+		{
+			name: "stream to unary procedure",
+			services: Lifecycles(
+				TChannelService(
+					Name("myservice"),
+					p.NamedPort("13"),
+					Proc(
+						Name("proc"),
+						EchoHandler(),
+					),
+				),
+			),
+			requests: Actions(
+				TChannelStreamRequest(
+					p.NamedPort("13"),
+					Service("myservice"),
+					Procedure("proc"),
+					WantStreamError(yarpcerrors.InvalidArgumentErrorf(`procedure "proc" of service "myservice" does not support streaming`).Error()),
+				),
+			),
+		},
+		{
+			name: "unary request to stream procedure",
+			services: Lifecycles(
+				TChannelService(
+					Name("myservice"),
+					p.NamedPort("14"),
+					Proc(
+						Name("proc"),
+						EchoStreamHandler(),
+					),
+				),
+			),
+			requests: Actions(
+				TChannelRequest(
+					p.NamedPort("14"),
+					Service("myservice"),
+					Procedure("proc"),
+					WantError(`procedure "proc" of service "myservice" requires a stream request`),
+				),
+			),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, tt.services.Start(t))
+			tt.requests.Run(t)
+			require.NoError(t, tt.services.Stop(t))
+		})
+	}
+}
+
+// finishRecorder counts how many times the peers it chooses are released.
+type finishRecorder struct {
+	peer.Chooser
+
+	finished atomic.Int32
+}
+
+func (r *finishRecorder) Choose(ctx context.Context, req *transport.Request) (peer.Peer, func(error), error) {
+	p, onFinish, err := r.Chooser.Choose(ctx, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	return p, func(err error) {
+		r.finished.Inc()
+		onFinish(err)
+	}, nil
+}
+
+func TestStreamReleasesPeerWhenStreamEnds(t *testing.T) {
+	p := NewPortProvider(t)
+	port := p.NamedPort("1")
+	service := TChannelService(
+		Name("myservice"),
+		port,
+		Proc(Name("proc"), EchoStreamHandler()),
+	)
+	require.NoError(t, service.Start(t))
+	defer func() { assert.NoError(t, service.Stop(t)) }()
+
+	trans, err := tchannel.NewTransport(tchannel.ServiceName("caller"))
+	require.NoError(t, err)
+	chooser := &finishRecorder{
+		Chooser: peerchooser.NewSingle(hostport.PeerIdentifier(fmt.Sprintf("127.0.0.1:%d", port.Port)), trans),
+	}
+	out := trans.NewOutbound(chooser)
+	require.NoError(t, trans.Start())
+	defer func() { assert.NoError(t, trans.Stop()) }()
+	require.NoError(t, out.Start())
+	defer func() { assert.NoError(t, out.Stop()) }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	stream, err := out.CallStream(ctx, &transport.StreamRequest{Meta: &transport.RequestMeta{
+		Caller:    "caller",
+		Service:   "myservice",
+		Encoding:  "raw",
+		Procedure: "proc",
+	}})
+	require.NoError(t, err)
+
+	require.NoError(t, stream.SendMessage(ctx, &transport.StreamMessage{
+		Body: ioutil.NopCloser(bytes.NewBufferString("hello")),
+	}))
+	msg, err := stream.ReceiveMessage(ctx)
+	require.NoError(t, err)
+	_ = msg.Body.Close()
+	assert.Equal(t, int32(0), chooser.finished.Load(), "peer must be held while the stream is open")
+
+	require.NoError(t, stream.Close(ctx))
+	_, err = stream.ReceiveMessage(ctx)
+	require.Error(t, err, "stream must end once closed")
+	_, err = stream.ReceiveMessage(ctx)
+	require.Error(t, err)
+	assert.Equal(t, int32(1), chooser.finished.Load(), "peer must be released once when the stream ends")
+}
@@ -21,6 +21,7 @@
 package tchannel
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net"
 	"sync"
@@ -59,6 +60,7 @@ type Transport struct {
 	name              string
 	addr              string
 	listener          net.Listener
+	tlsConfig         *tls.Config
 	newResponseWriter func(inboundCallResponse, tchannel.Format, headerCase) responseWriter
 
 	connTimeout            time.Duration
@@ -67,6 +69,8 @@ type Transport struct {
 	connectorsGroup        sync.WaitGroup
 	connBackoffStrategy    backoffapi.Strategy
 	headerCase             headerCase
+	compressors            map[string]transport.Compressor
+	streams                streamGroup
 
 	peers map[string]*tchannelPeer
 }
@@ -88,6 +92,9 @@ func NewTransport(opts ...TransportOption) (*Transport, error) {
 	if options.ch != nil {
 		return nil, fmt.Errorf("NewTransport does not accept WithChannel, use NewChannelTransport")
 	}
+	if options.tlsConfig != nil && options.tlsConfig.ClientAuth != tls.NoClientCert {
+		return nil, fmt.Errorf("ServerTLSConfig does not support client authentication with TChannel")
+	}
 
 	return options.newTransport(), nil
 }
@@ -101,17 +108,23 @@ func (o transportOptions) newTransport() *Transport {
 	if o.originalHeaders {
 		headerCase = originalHeaderCase
 	}
+	compressors := make(map[string]transport.Compressor, len(o.compressors))
+	for _, c := range o.compressors {
+		compressors[c.Name()] = c
+	}
 	return &Transport{
 		once:                lifecycle.NewOnce(),
 		name:                o.name,
 		addr:                o.addr,
 		listener:            o.listener,
+		tlsConfig:           o.tlsConfig,
 		connTimeout:         o.connTimeout,
 		connBackoffStrategy: o.connBackoffStrategy,
 		peers:               make(map[string]*tchannelPeer),
 		tracer:              o.tracer,
 		logger:              logger,
 		headerCase:          headerCase,
+		compressors:         compressors,
 		newResponseWriter:   newHandlerWriter,
 	}
 }
@@ -204,7 +217,9 @@ func (t *Transport) start() error {
 			tracer:            t.tracer,
 			headerCase:        t.headerCase,
 			logger:            t.logger,
+			compressors:       t.compressors,
 			newResponseWriter: t.newResponseWriter,
+			streams:           &t.streams,
 		},
 		OnPeerStatusChanged: t.onPeerStatusChanged,
 	}
@@ -214,21 +229,27 @@ func (t *Transport) start() error {
 	}
 	t.ch = ch
 
-	if t.listener != nil {
-		if err := t.ch.Serve(t.listener); err != nil {
-			return err
-		}
-	} else {
-		// Default to ListenIP if addr wasn't given.
-		addr := t.addr
-		if addr == "" {
-			listenIP, err := tchannel.ListenIP()
+	if t.listener != nil || t.tlsConfig != nil {
+		listener := t.listener
+		if listener == nil {
+			addr, err := t.listenAddr()
 			if err != nil {
 				return err
 			}
-
-			addr = listenIP.String() + ":0"
-			// TODO(abg): Find a way to export this to users
+			if listener, err = net.Listen("tcp", addr); err != nil {
+				return err
+			}
+		}
+		if t.tlsConfig != nil {
+			listener = tls.NewListener(listener, t.tlsConfig)
+		}
+		if err := t.ch.Serve(listener); err != nil {
+			return err
+		}
+	} else {
+		addr, err := t.listenAddr()
+		if err != nil {
+			return err
 		}
 
 		// TODO(abg): If addr was just the port (":4040"), we want to use
@@ -243,6 +264,20 @@ func (t *Transport) start() error {
 	return nil
 }
 
+// listenAddr returns the address the transport should listen on, defaulting
+// to ListenIP if addr wasn't given.
+func (t *Transport) listenAddr() (string, error) {
+	if t.addr != "" {
+		return t.addr, nil
+	}
+	listenIP, err := tchannel.ListenIP()
+	if err != nil {
+		return "", err
+	}
+	// TODO(abg): Find a way to export this to users
+	return listenIP.String() + ":0", nil
+}
+
 // Stop stops the TChannel transport. It starts rejecting incoming requests
 // and draining connections before closing them.
 // In a future version of YARPC, Stop will block until the underlying channel
@@ -0,0 +1,143 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/yarpcconfig"
+)
+
+// Config describes how to build authentication middleware.
+//
+// Inbound middleware validates JWTs against a local key set:
+//
+//  jwks-file: /etc/myservice/jwks.json
+//  issuer: https://auth.example.com
+//  clock-skew: 30s
+//  allow-unauthenticated: [health]
+//
+// Outbound middleware attaches a token read from a file:
+//
+//  token-file: /var/run/secrets/token
+//  refresh-before: 1m
+//  max-age: 5m
+//
+// jwks-file is required for inbound middleware and token-file is required
+// for outbound middleware.
+type Config struct {
+	JWKSFile             string        `config:"jwks-file"`
+	Issuer               string        `config:"issuer"`
+	ClockSkew            time.Duration `config:"clock-skew"`
+	AllowUnauthenticated []string      `config:"allow-unauthenticated"`
+
+	TokenFile     string        `config:"token-file"`
+	RefreshBefore time.Duration `config:"refresh-before"`
+	MaxAge        time.Duration `config:"max-age"`
+}
+
+// Spec returns a configuration specification for the authentication
+// middleware, making it possible to enable it for all inbounds and
+// outbounds.
+//
+//  cfg := yarpcconfig.New()
+//  cfg.MustRegisterMiddleware(auth.Spec())
+//
+// This requires a valid token on all inbound requests and attaches a token
+// to all outbound requests:
+//
+//  middleware:
+//    inbound:
+//      - auth:
+//          jwks-file: /etc/myservice/jwks.json
+//    outbound:
+//      - auth:
+//          token-file: /var/run/secrets/token
+//
+// See Config for the full set of attributes.
+func Spec() yarpcconfig.MiddlewareSpec {
+	return yarpcconfig.MiddlewareSpec{
+		Name: _name,
+		BuildUnaryInbound: func(cfg Config, k *yarpcconfig.Kit) (middleware.UnaryInbound, error) {
+			return cfg.inbound()
+		},
+		BuildOnewayInbound: func(cfg Config, k *yarpcconfig.Kit) (middleware.OnewayInbound, error) {
+			return cfg.inbound()
+		},
+		BuildStreamInbound: func(cfg Config, k *yarpcconfig.Kit) (middleware.StreamInbound, error) {
+			return cfg.inbound()
+		},
+		BuildUnaryOutbound: func(cfg Config, k *yarpcconfig.Kit) (middleware.UnaryOutbound, error) {
+			return cfg.outbound()
+		},
+		BuildOnewayOutbound: func(cfg Config, k *yarpcconfig.Kit) (middleware.OnewayOutbound, error) {
+			return cfg.outbound()
+		},
+		BuildStreamOutbound: func(cfg Config, k *yarpcconfig.Kit) (middleware.StreamOutbound, error) {
+			return cfg.outbound()
+		},
+	}
+}
+
+func (c Config) inbound() (*InboundMiddleware, error) {
+	if c.JWKSFile == "" {
+		return nil, errors.New("jwks-file is required for inbound authentication")
+	}
+	if c.ClockSkew < 0 {
+		return nil, errors.New("clock-skew must not be negative")
+	}
+
+	var opts []JWTOption
+	if c.Issuer != "" {
+		opts = append(opts, Issuer(c.Issuer))
+	}
+	if c.ClockSkew > 0 {
+		opts = append(opts, ClockSkew(c.ClockSkew))
+	}
+	v, err := NewJWTValidator(c.JWKSFile, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return NewInbound(v, AllowUnauthenticated(c.AllowUnauthenticated...)), nil
+}
+
+func (c Config) outbound() (*OutboundMiddleware, error) {
+	if c.TokenFile == "" {
+		return nil, errors.New("token-file is required for outbound authentication")
+	}
+	if c.RefreshBefore < 0 {
+		return nil, errors.New("refresh-before must not be negative")
+	}
+	if c.MaxAge < 0 {
+		return nil, errors.New("max-age must not be negative")
+	}
+
+	var opts []CacheOption
+	if c.RefreshBefore > 0 {
+		opts = append(opts, RefreshBefore(c.RefreshBefore))
+	}
+	if c.MaxAge > 0 {
+		opts = append(opts, MaxAge(c.MaxAge))
+	}
+	return NewOutbound(NewCachingProvider(TokenFile(c.TokenFile), opts...)), nil
+}
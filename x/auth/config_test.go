@@ -0,0 +1,167 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package auth
+
+import (
+	"context"
+	"crypto/elliptic"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/whitespace"
+	"go.uber.org/yarpc/yarpcconfig"
+)
+
+func TestSpec(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	key := newECKey(t, "es256", "ES256", elliptic.P256())
+	jwksFile := writeJWKS(t, dir, key)
+	tokenFile := filepath.Join(dir, "token")
+	token := key.sign(t, map[string]interface{}{
+		"iss": "https://auth.example.com",
+		"aud": "keyvalue",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	require.NoError(t, ioutil.WriteFile(tokenFile, []byte(token), 0600))
+
+	tests := []struct {
+		desc     string
+		inbound  string
+		outbound string
+		wantErr  string
+	}{
+		{
+			desc: "all attributes",
+			inbound: whitespace.Expand(fmt.Sprintf(`
+				jwks-file: %v
+				issuer: https://auth.example.com
+				clock-skew: 30s
+				allow-unauthenticated: [health]
+			`, jwksFile)),
+			outbound: whitespace.Expand(fmt.Sprintf(`
+				token-file: %v
+				refresh-before: 30s
+				max-age: 1m
+			`, tokenFile)),
+		},
+		{
+			desc:     "minimal",
+			inbound:  "jwks-file: " + jwksFile,
+			outbound: "token-file: " + tokenFile,
+		},
+		{
+			desc:     "no key set",
+			inbound:  "issuer: https://auth.example.com",
+			outbound: "token-file: " + tokenFile,
+			wantErr:  "jwks-file is required for inbound authentication",
+		},
+		{
+			desc:     "missing key set",
+			inbound:  "jwks-file: " + filepath.Join(dir, "missing.json"),
+			outbound: "token-file: " + tokenFile,
+			wantErr:  "failed to read JWKS file",
+		},
+		{
+			desc:     "negative clock skew",
+			inbound:  "jwks-file: " + jwksFile + "\nclock-skew: -1s",
+			outbound: "token-file: " + tokenFile,
+			wantErr:  "clock-skew must not be negative",
+		},
+		{
+			desc:     "no token file",
+			inbound:  "jwks-file: " + jwksFile,
+			outbound: "refresh-before: 1m",
+			wantErr:  "token-file is required for outbound authentication",
+		},
+		{
+			desc:     "negative refresh",
+			inbound:  "jwks-file: " + jwksFile,
+			outbound: "token-file: " + tokenFile + "\nrefresh-before: -1s",
+			wantErr:  "refresh-before must not be negative",
+		},
+		{
+			desc:     "negative max age",
+			inbound:  "jwks-file: " + jwksFile,
+			outbound: "token-file: " + tokenFile + "\nmax-age: -1s",
+			wantErr:  "max-age must not be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			cfg := yarpcconfig.New()
+			cfg.MustRegisterMiddleware(Spec())
+
+			give := "middleware:\n" +
+				"  inbound:\n    - auth:\n" + indent(tt.inbound, "        ") + "\n" +
+				"  outbound:\n    - auth:\n" + indent(tt.outbound, "        ")
+			c, err := cfg.LoadConfigFromYAML("keyvalue", strings.NewReader(give))
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			inbound, ok := c.InboundMiddleware.Unary.(*InboundMiddleware)
+			require.True(t, ok, "expected inbound middleware, got %T", c.InboundMiddleware.Unary)
+			assert.IsType(t, &InboundMiddleware{}, c.InboundMiddleware.Oneway)
+			assert.IsType(t, &InboundMiddleware{}, c.InboundMiddleware.Stream)
+
+			outbound, ok := c.OutboundMiddleware.Unary.(*OutboundMiddleware)
+			require.True(t, ok, "expected outbound middleware, got %T", c.OutboundMiddleware.Unary)
+			assert.IsType(t, &OutboundMiddleware{}, c.OutboundMiddleware.Oneway)
+			assert.IsType(t, &OutboundMiddleware{}, c.OutboundMiddleware.Stream)
+
+			// The token attached by the outbound middleware must be
+			// accepted by the inbound middleware.
+			out := &recordingOutbound{}
+			_, err = outbound.Call(context.Background(), &transport.Request{Service: "keyvalue"}, out)
+			require.NoError(t, err)
+			require.Len(t, out.headers, 1)
+
+			h := &claimsHandler{}
+			req := &transport.Request{Service: "keyvalue", Procedure: "get", Headers: out.headers[0]}
+			require.NoError(t, inbound.Handle(context.Background(), req, nil, h))
+			require.Len(t, h.claims, 1)
+			assert.Equal(t, "https://auth.example.com", h.claims[0].Issuer)
+		})
+	}
+}
+
+func indent(s, prefix string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
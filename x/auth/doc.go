@@ -0,0 +1,59 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package auth provides middleware that authenticates requests with bearer
+// tokens.
+//
+// Outbound middleware attaches a token from a CredentialProvider to the
+// authorization header of every request. NewCachingProvider reuses tokens
+// until shortly before they expire, and TokenFile reads tokens written by
+// another process.
+//
+// 	provider := auth.NewCachingProvider(auth.TokenFile("/var/run/secrets/token"))
+// 	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+// 		Name: "myservice",
+// 		OutboundMiddleware: yarpc.OutboundMiddleware{
+// 			Unary: auth.NewOutbound(provider),
+// 		},
+// 		// ...
+// 	})
+//
+// Inbound middleware checks the token of every request with a Validator
+// before calling the handler, and makes the claims of the token available
+// through ClaimsFromContext. JWTValidator verifies JSON Web Tokens against a
+// local JSON Web Key Set and requires them to list the service in their
+// audience.
+//
+// 	validator, err := auth.NewJWTValidator("/etc/myservice/jwks.json")
+// 	if err != nil {
+// 		log.Fatal(err)
+// 	}
+// 	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+// 		Name: "myservice",
+// 		InboundMiddleware: yarpc.InboundMiddleware{
+// 			Unary: auth.NewInbound(validator, auth.AllowUnauthenticated("health")),
+// 		},
+// 		// ...
+// 	})
+//
+// Requests without a valid token are rejected with CodeUnauthenticated, and
+// requests with a valid token meant for another service are rejected with
+// CodePermissionDenied.
+package auth
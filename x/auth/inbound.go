@@ -0,0 +1,194 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package auth
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// Claims are the verified claims of a bearer token.
+type Claims struct {
+	Subject   string
+	Issuer    string
+	Audience  []string
+	ExpiresAt time.Time
+	NotBefore time.Time
+	IssuedAt  time.Time
+
+	// Raw holds all claims of the token as decoded from JSON, including the
+	// registered claims above.
+	Raw map[string]interface{}
+}
+
+type claimsKey struct{}
+
+// WithClaims returns a copy of the context that carries the given claims.
+func WithClaims(ctx context.Context, c Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, c)
+}
+
+// ClaimsFromContext returns the claims of the token that authenticated the
+// current request, if any.
+//
+// 	if claims, ok := auth.ClaimsFromContext(ctx); ok {
+// 		log.Printf("request from %v", claims.Subject)
+// 	}
+func ClaimsFromContext(ctx context.Context) (Claims, bool) {
+	c, ok := ctx.Value(claimsKey{}).(Claims)
+	return c, ok
+}
+
+// Validator verifies the bearer tokens of inbound requests.
+type Validator interface {
+	// Validate verifies the given token for a request and returns its
+	// claims.
+	//
+	// Errors should be yarpcerrors with CodeUnauthenticated for tokens
+	// that cannot be trusted and CodePermissionDenied for trusted tokens
+	// that do not grant access to the request. Other errors are reported
+	// with CodeUnauthenticated.
+	Validate(ctx context.Context, req *transport.RequestMeta, token string) (Claims, error)
+}
+
+// InboundOption customizes the behavior of the inbound authentication
+// middleware.
+type InboundOption func(*inboundOptions)
+
+type inboundOptions struct {
+	unauthenticated map[string]struct{}
+}
+
+// AllowUnauthenticated lets requests to the named procedures through without
+// a bearer token, for example to keep health checks working. Tokens sent to
+// these procedures are still validated.
+func AllowUnauthenticated(procedures ...string) InboundOption {
+	return func(opts *inboundOptions) {
+		for _, p := range procedures {
+			opts.unauthenticated[p] = struct{}{}
+		}
+	}
+}
+
+// InboundMiddleware is a unary, oneway and stream inbound middleware that
+// requires requests to carry a valid bearer token. The claims of the token
+// are available to handlers through ClaimsFromContext.
+type InboundMiddleware struct {
+	validator Validator
+	opts      inboundOptions
+}
+
+var (
+	_ middleware.UnaryInbound  = (*InboundMiddleware)(nil)
+	_ middleware.OnewayInbound = (*InboundMiddleware)(nil)
+	_ middleware.StreamInbound = (*InboundMiddleware)(nil)
+)
+
+// NewInbound builds a new inbound middleware that validates bearer tokens
+// with the given validator.
+func NewInbound(v Validator, opts ...InboundOption) *InboundMiddleware {
+	options := inboundOptions{
+		unauthenticated: make(map[string]struct{}),
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &InboundMiddleware{validator: v, opts: options}
+}
+
+// Handle implements middleware.UnaryInbound.
+func (m *InboundMiddleware) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter, h transport.UnaryHandler) error {
+	ctx, err := m.authenticate(ctx, req.ToRequestMeta())
+	if err != nil {
+		return err
+	}
+	return h.Handle(ctx, req, resw)
+}
+
+// HandleOneway implements middleware.OnewayInbound.
+func (m *InboundMiddleware) HandleOneway(ctx context.Context, req *transport.Request, h transport.OnewayHandler) error {
+	ctx, err := m.authenticate(ctx, req.ToRequestMeta())
+	if err != nil {
+		return err
+	}
+	return h.HandleOneway(ctx, req)
+}
+
+// HandleStream implements middleware.StreamInbound.
+func (m *InboundMiddleware) HandleStream(s *transport.ServerStream, h transport.StreamHandler) error {
+	ctx, err := m.authenticate(s.Context(), s.Request().Meta)
+	if err != nil {
+		return err
+	}
+	if ctx == s.Context() {
+		return h.HandleStream(s)
+	}
+
+	wrapped, err := transport.NewServerStream(claimsStream{ServerStream: s, ctx: ctx})
+	if err != nil {
+		return err
+	}
+	return h.HandleStream(wrapped)
+}
+
+// authenticate validates the bearer token of the request and returns a
+// context carrying its claims.
+func (m *InboundMiddleware) authenticate(ctx context.Context, req *transport.RequestMeta) (context.Context, error) {
+	header, ok := req.Headers.Get(AuthorizationHeader)
+	if !ok {
+		if _, ok := m.opts.unauthenticated[req.Procedure]; ok {
+			return ctx, nil
+		}
+		return ctx, yarpcerrors.UnauthenticatedErrorf(
+			"procedure %q of service %q requires a bearer token", req.Procedure, req.Service)
+	}
+
+	if len(header) < len(_bearerPrefix) || !strings.EqualFold(header[:len(_bearerPrefix)], _bearerPrefix) {
+		return ctx, yarpcerrors.UnauthenticatedErrorf("%v header must use the Bearer scheme", AuthorizationHeader)
+	}
+	token := strings.TrimSpace(header[len(_bearerPrefix):])
+
+	claims, err := m.validator.Validate(ctx, req, token)
+	if err != nil {
+		if yarpcerrors.IsStatus(err) {
+			return ctx, err
+		}
+		return ctx, yarpcerrors.UnauthenticatedErrorf("invalid bearer token: %v", err)
+	}
+	return WithClaims(ctx, claims), nil
+}
+
+// claimsStream overrides the context of a server stream so that handlers
+// can see the claims of its token.
+type claimsStream struct {
+	*transport.ServerStream
+
+	ctx context.Context
+}
+
+func (s claimsStream) Context() context.Context {
+	return s.ctx
+}
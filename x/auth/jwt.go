@@ -0,0 +1,543 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256" // for crypto.SHA256
+	_ "crypto/sha512" // for crypto.SHA384 and crypto.SHA512
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/clock"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// _minReloadInterval is the shortest time between two checks of the key set
+// file for tokens signed by unknown keys, so that such tokens cannot make
+// every request read the file.
+const _minReloadInterval = 10 * time.Second
+
+// _minRSABits is the size of the smallest RSA keys trusted to sign tokens.
+const _minRSABits = 2048
+
+// JWTOption customizes the behavior of a JWTValidator.
+type JWTOption func(*jwtOptions)
+
+type jwtOptions struct {
+	issuer    string
+	clockSkew time.Duration
+	clock     clock.Clock
+}
+
+// Issuer requires tokens to be issued by the named issuer.
+//
+// By default, tokens from any issuer are accepted as long as they are signed
+// by one of the trusted keys.
+func Issuer(iss string) JWTOption {
+	return func(opts *jwtOptions) {
+		opts.issuer = iss
+	}
+}
+
+// ClockSkew sets how far the clocks of token issuers may drift from the
+// local clock when checking expiry and not-before times.
+//
+// Defaults to no tolerance.
+func ClockSkew(d time.Duration) JWTOption {
+	return func(opts *jwtOptions) {
+		opts.clockSkew = d
+	}
+}
+
+// withJWTClock overrides the clock used to check expiry times. This is used
+// for testing.
+func withJWTClock(c clock.Clock) JWTOption {
+	return func(opts *jwtOptions) {
+		opts.clock = c
+	}
+}
+
+// JWTValidator is a Validator for JSON Web Tokens signed by keys from a
+// local JSON Web Key Set file.
+//
+// Tokens must be signed with RS256, RS384, RS512, PS256, PS384, PS512,
+// ES256, ES384 or ES512, must have an expiry, and must list the service they
+// are sent to in their audience. Tokens meant for other services are
+// rejected with CodePermissionDenied; all other problems are reported with
+// CodeUnauthenticated.
+//
+// The key set is read again when a token refers to an unknown key and the
+// file has changed, at most once every ten seconds, so keys can be rotated
+// without restarting.
+type JWTValidator struct {
+	path string
+	opts jwtOptions
+
+	mu        sync.RWMutex
+	keys      []jwk
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+var _ Validator = (*JWTValidator)(nil)
+
+// NewJWTValidator builds a JWTValidator that trusts the keys in the given
+// JSON Web Key Set file.
+func NewJWTValidator(jwksFile string, opts ...JWTOption) (*JWTValidator, error) {
+	options := jwtOptions{clock: clock.NewReal()}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	v := &JWTValidator{path: jwksFile, opts: options}
+	if err := v.reload(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Validate implements Validator.
+func (v *JWTValidator) Validate(ctx context.Context, req *transport.RequestMeta, token string) (Claims, error) {
+	claims, err := v.verify(token)
+	if err != nil {
+		return Claims{}, yarpcerrors.UnauthenticatedErrorf("invalid bearer token: %v", err)
+	}
+	for _, aud := range claims.Audience {
+		if aud == req.Service {
+			return claims, nil
+		}
+	}
+	return Claims{}, yarpcerrors.PermissionDeniedErrorf("bearer token is not intended for service %q", req.Service)
+}
+
+// verify checks the signature and lifetime of the token and returns its
+// claims.
+func (v *JWTValidator) verify(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Claims{}, errors.New("malformed token")
+	}
+
+	var header struct {
+		Alg  string   `json:"alg"`
+		Kid  string   `json:"kid"`
+		Crit []string `json:"crit"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return Claims{}, fmt.Errorf("malformed header: %v", err)
+	}
+	alg, ok := _algorithms[header.Alg]
+	if !ok {
+		return Claims{}, fmt.Errorf("unsupported signing algorithm %q", header.Alg)
+	}
+	if len(header.Crit) > 0 {
+		return Claims{}, fmt.Errorf("unsupported critical header parameters %q", header.Crit)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Claims{}, fmt.Errorf("malformed signature: %v", err)
+	}
+
+	keys := v.keysFor(header.Kid)
+	if len(keys) == 0 {
+		return Claims{}, fmt.Errorf("unknown signing key %q", header.Kid)
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	verified := false
+	for _, k := range keys {
+		if (k.alg == "" || k.alg == header.Alg) && alg.verify(k.key, signed, sig) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return Claims{}, errors.New("signature verification failed")
+	}
+
+	claims, err := decodeClaims(parts[1])
+	if err != nil {
+		return Claims{}, err
+	}
+
+	now := v.opts.clock.Now()
+	skew := v.opts.clockSkew
+	if claims.ExpiresAt.IsZero() {
+		return Claims{}, errors.New("token has no expiry")
+	}
+	if !now.Before(claims.ExpiresAt.Add(skew)) {
+		return Claims{}, fmt.Errorf("token expired at %v", claims.ExpiresAt.UTC())
+	}
+	if !claims.NotBefore.IsZero() && now.Add(skew).Before(claims.NotBefore) {
+		return Claims{}, fmt.Errorf("token is not valid before %v", claims.NotBefore.UTC())
+	}
+	if v.opts.issuer != "" && claims.Issuer != v.opts.issuer {
+		return Claims{}, fmt.Errorf("untrusted issuer %q", claims.Issuer)
+	}
+	return claims, nil
+}
+
+// keysFor returns the keys that may have signed a token with the given key
+// ID, reloading the key set if none match and the file has changed.
+func (v *JWTValidator) keysFor(kid string) []jwk {
+	v.mu.RLock()
+	keys := matchKeys(v.keys, kid)
+	v.mu.RUnlock()
+	if len(keys) > 0 || !v.shouldCheck() {
+		return keys
+	}
+
+	// A failed reload keeps the previous keys.
+	_ = v.reload()
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return matchKeys(v.keys, kid)
+}
+
+// shouldCheck returns whether the key set file may be checked for changes,
+// and if so, records that it is being checked.
+func (v *JWTValidator) shouldCheck() bool {
+	now := v.opts.clock.Now()
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if now.Sub(v.checkedAt) < _minReloadInterval {
+		return false
+	}
+	v.checkedAt = now
+	return true
+}
+
+func matchKeys(keys []jwk, kid string) []jwk {
+	if kid == "" {
+		return keys
+	}
+	for _, k := range keys {
+		if k.id == kid {
+			return []jwk{k}
+		}
+	}
+	return nil
+}
+
+// reload reads the key set again if the file changed since it was last
+// read. The file is read without holding the lock so that requests signed
+// by known keys are not held up.
+func (v *JWTValidator) reload() error {
+	info, err := os.Stat(v.path)
+	if err != nil {
+		return fmt.Errorf("failed to read JWKS file %q: %v", v.path, err)
+	}
+	v.mu.RLock()
+	unchanged := v.keys != nil && info.ModTime().Equal(v.modTime)
+	v.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	f, err := os.Open(v.path)
+	if err != nil {
+		return fmt.Errorf("failed to read JWKS file %q: %v", v.path, err)
+	}
+	defer f.Close()
+
+	keys, err := parseJWKS(f)
+	if err != nil {
+		return fmt.Errorf("failed to parse JWKS file %q: %v", v.path, err)
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.keys = keys
+	v.modTime = info.ModTime()
+	return nil
+}
+
+// jwk is a public key from a JSON Web Key Set.
+type jwk struct {
+	id  string
+	alg string
+	key crypto.PublicKey
+}
+
+type jwkJSON struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+var _curves = map[string]elliptic.Curve{
+	"P-256": elliptic.P256(),
+	"P-384": elliptic.P384(),
+	"P-521": elliptic.P521(),
+}
+
+// parseJWKS reads the signing keys of a JSON Web Key Set. Keys of types
+// other than RSA and EC, and keys not meant for signatures, are ignored.
+func parseJWKS(r io.Reader) ([]jwk, error) {
+	var set struct {
+		Keys []jwkJSON `json:"keys"`
+	}
+	if err := json.NewDecoder(r).Decode(&set); err != nil {
+		return nil, err
+	}
+
+	keys := make([]jwk, 0, len(set.Keys))
+	for i, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		var key crypto.PublicKey
+		switch k.Kty {
+		case "RSA":
+			n, err := decodeBigInt(k.N)
+			if err != nil {
+				return nil, fmt.Errorf("keys[%d]: invalid modulus: %v", i, err)
+			}
+			e, err := decodeBigInt(k.E)
+			if err != nil {
+				return nil, fmt.Errorf("keys[%d]: invalid exponent: %v", i, err)
+			}
+			if !e.IsInt64() || e.Int64() < 2 || e.Int64() > math.MaxInt32 {
+				return nil, fmt.Errorf("keys[%d]: invalid exponent %v", i, e)
+			}
+			if n.BitLen() < _minRSABits {
+				return nil, fmt.Errorf("keys[%d]: RSA key of %d bits is too small, need at least %d", i, n.BitLen(), _minRSABits)
+			}
+			key = &rsa.PublicKey{N: n, E: int(e.Int64())}
+		case "EC":
+			curve, ok := _curves[k.Crv]
+			if !ok {
+				return nil, fmt.Errorf("keys[%d]: unsupported curve %q", i, k.Crv)
+			}
+			x, err := decodeBigInt(k.X)
+			if err != nil {
+				return nil, fmt.Errorf("keys[%d]: invalid x coordinate: %v", i, err)
+			}
+			y, err := decodeBigInt(k.Y)
+			if err != nil {
+				return nil, fmt.Errorf("keys[%d]: invalid y coordinate: %v", i, err)
+			}
+			if !curve.IsOnCurve(x, y) {
+				return nil, fmt.Errorf("keys[%d]: point is not on curve %v", i, k.Crv)
+			}
+			key = &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+		default:
+			continue
+		}
+		keys = append(keys, jwk{id: k.Kid, alg: k.Alg, key: key})
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no signing keys found")
+	}
+	return keys, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("value is empty")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// algorithm verifies signatures for one JWS algorithm.
+type algorithm struct {
+	hash  crypto.Hash
+	pss   bool
+	curve elliptic.Curve // nil for RSA algorithms
+}
+
+var _algorithms = map[string]algorithm{
+	"RS256": {hash: crypto.SHA256},
+	"RS384": {hash: crypto.SHA384},
+	"RS512": {hash: crypto.SHA512},
+	"PS256": {hash: crypto.SHA256, pss: true},
+	"PS384": {hash: crypto.SHA384, pss: true},
+	"PS512": {hash: crypto.SHA512, pss: true},
+	"ES256": {hash: crypto.SHA256, curve: elliptic.P256()},
+	"ES384": {hash: crypto.SHA384, curve: elliptic.P384()},
+	"ES512": {hash: crypto.SHA512, curve: elliptic.P521()},
+}
+
+func (a algorithm) verify(key crypto.PublicKey, signed, sig []byte) bool {
+	h := a.hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if a.curve != nil {
+			return false
+		}
+		if a.pss {
+			opts := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}
+			return rsa.VerifyPSS(k, a.hash, digest, sig, opts) == nil
+		}
+		return rsa.VerifyPKCS1v15(k, a.hash, digest, sig) == nil
+	case *ecdsa.PublicKey:
+		if a.curve == nil || k.Curve != a.curve {
+			return false
+		}
+		// ECDSA signatures are the fixed-size big-endian r and s values.
+		size := (a.curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		return ecdsa.Verify(k, digest, r, s)
+	default:
+		return false
+	}
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// parseUnverifiedClaims decodes the claims of a JWT without verifying it.
+func parseUnverifiedClaims(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Claims{}, errors.New("malformed token")
+	}
+	return decodeClaims(parts[1])
+}
+
+func decodeClaims(seg string) (Claims, error) {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return Claims{}, fmt.Errorf("malformed claims: %v", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var raw map[string]interface{}
+	if err := dec.Decode(&raw); err != nil {
+		return Claims{}, fmt.Errorf("malformed claims: %v", err)
+	}
+
+	c := Claims{Raw: raw}
+	if c.Subject, err = stringClaim(raw, "sub"); err != nil {
+		return Claims{}, err
+	}
+	if c.Issuer, err = stringClaim(raw, "iss"); err != nil {
+		return Claims{}, err
+	}
+	if c.Audience, err = audienceClaim(raw); err != nil {
+		return Claims{}, err
+	}
+	if c.ExpiresAt, err = timeClaim(raw, "exp"); err != nil {
+		return Claims{}, err
+	}
+	if c.NotBefore, err = timeClaim(raw, "nbf"); err != nil {
+		return Claims{}, err
+	}
+	if c.IssuedAt, err = timeClaim(raw, "iat"); err != nil {
+		return Claims{}, err
+	}
+	return c, nil
+}
+
+func stringClaim(raw map[string]interface{}, name string) (string, error) {
+	v, ok := raw[name]
+	if !ok {
+		return "", nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("claim %q must be a string", name)
+	}
+	return s, nil
+}
+
+// audienceClaim decodes the aud claim, which is either a single string or
+// a list of strings.
+func audienceClaim(raw map[string]interface{}) ([]string, error) {
+	switch v := raw["aud"].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		aud := make([]string, len(v))
+		for i, a := range v {
+			s, ok := a.(string)
+			if !ok {
+				return nil, errors.New(`claim "aud" must be a string or a list of strings`)
+			}
+			aud[i] = s
+		}
+		return aud, nil
+	default:
+		return nil, errors.New(`claim "aud" must be a string or a list of strings`)
+	}
+}
+
+// timeClaim decodes a NumericDate claim, which counts seconds since the
+// epoch.
+func timeClaim(raw map[string]interface{}, name string) (time.Time, error) {
+	v, ok := raw[name]
+	if !ok {
+		return time.Time{}, nil
+	}
+	n, ok := v.(json.Number)
+	if !ok {
+		return time.Time{}, fmt.Errorf("claim %q must be a number", name)
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("claim %q must be a number: %v", name, err)
+	}
+	sec, frac := math.Modf(f)
+	return time.Unix(int64(sec), int64(frac*1e9)), nil
+}
@@ -0,0 +1,423 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/clock"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// testKey is a signing key along with the metadata published for it.
+type testKey struct {
+	kid    string
+	alg    string
+	signer crypto.Signer
+}
+
+func newRSAKey(t *testing.T, kid, alg string) testKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return testKey{kid: kid, alg: alg, signer: key}
+}
+
+func newECKey(t *testing.T, kid, alg string, curve elliptic.Curve) testKey {
+	key, err := ecdsa.GenerateKey(curve, rand.Reader)
+	require.NoError(t, err)
+	return testKey{kid: kid, alg: alg, signer: key}
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func (k testKey) jwk() map[string]string {
+	m := map[string]string{"kid": k.kid}
+	switch pub := k.signer.Public().(type) {
+	case *rsa.PublicKey:
+		m["kty"] = "RSA"
+		m["n"] = b64(pub.N.Bytes())
+		m["e"] = b64(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		m["kty"] = "EC"
+		m["crv"] = pub.Curve.Params().Name
+		m["x"] = b64(pub.X.Bytes())
+		m["y"] = b64(pub.Y.Bytes())
+	}
+	return m
+}
+
+// sign builds a token with the given claims signed by the key.
+func (k testKey) sign(t *testing.T, claims map[string]interface{}) string {
+	return k.signWithHeader(t, map[string]interface{}{"alg": k.alg, "kid": k.kid}, claims)
+}
+
+func (k testKey) signWithHeader(t *testing.T, header, claims map[string]interface{}) string {
+	h, err := json.Marshal(header)
+	require.NoError(t, err)
+	c, err := json.Marshal(claims)
+	require.NoError(t, err)
+	signed := b64(h) + "." + b64(c)
+
+	alg := _algorithms[k.alg]
+	hash := alg.hash.New()
+	hash.Write([]byte(signed))
+	digest := hash.Sum(nil)
+
+	var sig []byte
+	switch key := k.signer.(type) {
+	case *rsa.PrivateKey:
+		if alg.pss {
+			sig, err = rsa.SignPSS(rand.Reader, key, alg.hash, digest, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		} else {
+			sig, err = rsa.SignPKCS1v15(rand.Reader, key, alg.hash, digest)
+		}
+		require.NoError(t, err)
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest)
+		require.NoError(t, err)
+		size := (key.Curve.Params().BitSize + 7) / 8
+		sig = make([]byte, 2*size)
+		rb, sb := r.Bytes(), s.Bytes()
+		copy(sig[size-len(rb):size], rb)
+		copy(sig[2*size-len(sb):], sb)
+	}
+	return signed + "." + b64(sig)
+}
+
+// writeJWKS writes a key set with the public halves of the given keys and
+// returns its path.
+func writeJWKS(t *testing.T, dir string, keys ...testKey) string {
+	set := struct {
+		Keys []map[string]string `json:"keys"`
+	}{}
+	for _, k := range keys {
+		set.Keys = append(set.Keys, k.jwk())
+	}
+	b, err := json.Marshal(set)
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, "jwks.json")
+	require.NoError(t, ioutil.WriteFile(path, b, 0600))
+	return path
+}
+
+func tempDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "yarpcauth")
+	require.NoError(t, err)
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func TestJWTValidator(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	rs256 := newRSAKey(t, "rs256", "RS256")
+	ps384 := newRSAKey(t, "ps384", "PS384")
+	es256 := newECKey(t, "es256", "ES256", elliptic.P256())
+	es384 := newECKey(t, "es384", "ES384", elliptic.P384())
+	unknown := newRSAKey(t, "unknown", "RS256")
+	impostor := newRSAKey(t, "rs256", "RS256")
+	path := writeJWKS(t, dir, rs256, ps384, es256, es384)
+
+	now := time.Unix(1500000000, 0)
+	clk := clock.NewFake()
+	clk.Set(now)
+	v, err := NewJWTValidator(path, Issuer("https://auth.example.com"), ClockSkew(time.Minute), withJWTClock(clk))
+	require.NoError(t, err)
+
+	claims := func(overrides map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"iss": "https://auth.example.com",
+			"sub": "frontend",
+			"aud": "keyvalue",
+			"exp": now.Add(time.Hour).Unix(),
+		}
+		for k, v := range overrides {
+			if v == nil {
+				delete(c, k)
+			} else {
+				c[k] = v
+			}
+		}
+		return c
+	}
+
+	tests := []struct {
+		desc      string
+		token     string
+		wantCode  yarpcerrors.Code
+		wantError string
+	}{
+		{desc: "RS256", token: rs256.sign(t, claims(nil))},
+		{desc: "PS384", token: ps384.sign(t, claims(nil))},
+		{desc: "ES256", token: es256.sign(t, claims(nil))},
+		{desc: "ES384", token: es384.sign(t, claims(nil))},
+		{
+			desc: "no key ID",
+			token: es256.signWithHeader(t,
+				map[string]interface{}{"alg": "ES256"},
+				claims(nil)),
+		},
+		{
+			desc:  "audience list",
+			token: rs256.sign(t, claims(map[string]interface{}{"aud": []string{"users", "keyvalue"}})),
+		},
+		{
+			desc:  "expired within clock skew",
+			token: rs256.sign(t, claims(map[string]interface{}{"exp": now.Add(-30 * time.Second).Unix()})),
+		},
+		{
+			desc:      "expired",
+			token:     rs256.sign(t, claims(map[string]interface{}{"exp": now.Add(-time.Hour).Unix()})),
+			wantCode:  yarpcerrors.CodeUnauthenticated,
+			wantError: "invalid bearer token: token expired at 2017-07-14 01:40:00 +0000 UTC",
+		},
+		{
+			desc:      "no expiry",
+			token:     rs256.sign(t, claims(map[string]interface{}{"exp": nil})),
+			wantCode:  yarpcerrors.CodeUnauthenticated,
+			wantError: "invalid bearer token: token has no expiry",
+		},
+		{
+			desc:      "not yet valid",
+			token:     rs256.sign(t, claims(map[string]interface{}{"nbf": now.Add(time.Hour).Unix()})),
+			wantCode:  yarpcerrors.CodeUnauthenticated,
+			wantError: "invalid bearer token: token is not valid before 2017-07-14 03:40:00 +0000 UTC",
+		},
+		{
+			desc:      "untrusted issuer",
+			token:     rs256.sign(t, claims(map[string]interface{}{"iss": "https://evil.example.com"})),
+			wantCode:  yarpcerrors.CodeUnauthenticated,
+			wantError: `invalid bearer token: untrusted issuer "https://evil.example.com"`,
+		},
+		{
+			desc:      "other audience",
+			token:     rs256.sign(t, claims(map[string]interface{}{"aud": "users"})),
+			wantCode:  yarpcerrors.CodePermissionDenied,
+			wantError: `bearer token is not intended for service "keyvalue"`,
+		},
+		{
+			desc:      "no audience",
+			token:     rs256.sign(t, claims(map[string]interface{}{"aud": nil})),
+			wantCode:  yarpcerrors.CodePermissionDenied,
+			wantError: `bearer token is not intended for service "keyvalue"`,
+		},
+		{
+			desc:      "unknown key",
+			token:     unknown.sign(t, claims(nil)),
+			wantCode:  yarpcerrors.CodeUnauthenticated,
+			wantError: `invalid bearer token: unknown signing key "unknown"`,
+		},
+		{
+			desc:      "wrong key",
+			token:     impostor.sign(t, claims(nil)),
+			wantCode:  yarpcerrors.CodeUnauthenticated,
+			wantError: "invalid bearer token: signature verification failed",
+		},
+		{
+			desc: "algorithm does not match key",
+			token: rs256.signWithHeader(t,
+				map[string]interface{}{"alg": "ES256", "kid": "rs256"},
+				claims(nil)),
+			wantCode:  yarpcerrors.CodeUnauthenticated,
+			wantError: "invalid bearer token: signature verification failed",
+		},
+		{
+			desc:      "unsigned",
+			token:     b64([]byte(`{"alg":"none"}`)) + "." + b64([]byte(`{"aud":"keyvalue"}`)) + ".",
+			wantCode:  yarpcerrors.CodeUnauthenticated,
+			wantError: `invalid bearer token: unsupported signing algorithm "none"`,
+		},
+		{
+			desc: "critical header",
+			token: rs256.signWithHeader(t,
+				map[string]interface{}{"alg": "RS256", "kid": "rs256", "crit": []string{"exp"}},
+				claims(nil)),
+			wantCode:  yarpcerrors.CodeUnauthenticated,
+			wantError: `invalid bearer token: unsupported critical header parameters ["exp"]`,
+		},
+		{
+			desc:      "malformed",
+			token:     "not-a-token",
+			wantCode:  yarpcerrors.CodeUnauthenticated,
+			wantError: "invalid bearer token: malformed token",
+		},
+		{
+			desc:      "malformed claims",
+			token:     rs256.sign(t, claims(map[string]interface{}{"sub": 42})),
+			wantCode:  yarpcerrors.CodeUnauthenticated,
+			wantError: `invalid bearer token: claim "sub" must be a string`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			got, err := v.Validate(context.Background(), &transport.RequestMeta{Service: "keyvalue"}, tt.token)
+			if tt.wantCode != yarpcerrors.CodeOK {
+				require.Error(t, err)
+				status := yarpcerrors.FromError(err)
+				assert.Equal(t, tt.wantCode, status.Code())
+				assert.Equal(t, tt.wantError, status.Message())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "frontend", got.Subject)
+			assert.Equal(t, "https://auth.example.com", got.Issuer)
+			assert.Contains(t, got.Audience, "keyvalue")
+			assert.Equal(t, "frontend", got.Raw["sub"])
+		})
+	}
+}
+
+func TestJWTValidatorReloadsKeys(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	oldKey := newECKey(t, "old", "ES256", elliptic.P256())
+	newKey := newECKey(t, "new", "ES256", elliptic.P256())
+	path := writeJWKS(t, dir, oldKey)
+
+	clk := clock.NewFake()
+	clk.Set(time.Now())
+	v, err := NewJWTValidator(path, withJWTClock(clk))
+	require.NoError(t, err)
+
+	claims := map[string]interface{}{
+		"aud": "keyvalue",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	req := &transport.RequestMeta{Service: "keyvalue"}
+
+	_, err = v.Validate(context.Background(), req, newKey.sign(t, claims))
+	assert.Error(t, err, "new key must not be trusted yet")
+
+	writeJWKS(t, dir, newKey)
+	future := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(path, future, future))
+
+	_, err = v.Validate(context.Background(), req, newKey.sign(t, claims))
+	assert.Error(t, err, "key set must not be checked again within the reload interval")
+
+	clk.Add(_minReloadInterval)
+	_, err = v.Validate(context.Background(), req, newKey.sign(t, claims))
+	assert.NoError(t, err, "new key must be trusted after rotation")
+
+	// Removing the file keeps the keys that were already loaded.
+	require.NoError(t, os.Remove(path))
+	_, err = v.Validate(context.Background(), req, newKey.sign(t, claims))
+	assert.NoError(t, err)
+	_, err = v.Validate(context.Background(), req, oldKey.sign(t, claims))
+	assert.Error(t, err)
+}
+
+func TestNewJWTValidatorErrors(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	tests := []struct {
+		desc    string
+		give    string
+		wantErr string
+	}{
+		{
+			desc:    "malformed",
+			give:    `{"keys": [`,
+			wantErr: "failed to parse JWKS file",
+		},
+		{
+			desc:    "no keys",
+			give:    `{"keys": []}`,
+			wantErr: "no signing keys found",
+		},
+		{
+			desc:    "only encryption keys",
+			give:    `{"keys": [{"kty": "RSA", "use": "enc", "n": "AQAB", "e": "AQAB"}]}`,
+			wantErr: "no signing keys found",
+		},
+		{
+			desc:    "unsupported key type",
+			give:    `{"keys": [{"kty": "oct", "k": "c2VjcmV0"}]}`,
+			wantErr: "no signing keys found",
+		},
+		{
+			desc:    "invalid modulus",
+			give:    `{"keys": [{"kty": "RSA", "n": "", "e": "AQAB"}]}`,
+			wantErr: "keys[0]: invalid modulus: value is empty",
+		},
+		{
+			desc:    "invalid exponent",
+			give:    `{"keys": [{"kty": "RSA", "n": "AQAB", "e": "AQ"}]}`,
+			wantErr: "keys[0]: invalid exponent 1",
+		},
+		{
+			desc:    "small RSA key",
+			give:    fmt.Sprintf(`{"keys": [{"kty": "RSA", "n": "%s", "e": "AQAB"}]}`, strings.Repeat("_", 172)),
+			wantErr: "keys[0]: RSA key of 1032 bits is too small, need at least 2048",
+		},
+		{
+			desc:    "unsupported curve",
+			give:    `{"keys": [{"kty": "EC", "crv": "P-192", "x": "AQ", "y": "AQ"}]}`,
+			wantErr: `keys[0]: unsupported curve "P-192"`,
+		},
+		{
+			desc:    "point not on curve",
+			give:    `{"keys": [{"kty": "EC", "crv": "P-256", "x": "AQ", "y": "AQ"}]}`,
+			wantErr: "keys[0]: point is not on curve P-256",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			path := filepath.Join(dir, "jwks.json")
+			require.NoError(t, ioutil.WriteFile(path, []byte(tt.give), 0600))
+
+			_, err := NewJWTValidator(path)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := NewJWTValidator(filepath.Join(dir, "missing.json"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to read JWKS file")
+	})
+}
@@ -0,0 +1,278 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/transport/transporttest"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// recordingOutbound records the headers of the requests it sends.
+type recordingOutbound struct {
+	transport.Outbound
+
+	headers []transport.Headers
+}
+
+func (o *recordingOutbound) Call(_ context.Context, req *transport.Request) (*transport.Response, error) {
+	o.headers = append(o.headers, req.Headers)
+	return &transport.Response{}, nil
+}
+
+func (o *recordingOutbound) CallOneway(_ context.Context, req *transport.Request) (transport.Ack, error) {
+	o.headers = append(o.headers, req.Headers)
+	return nil, nil
+}
+
+func (o *recordingOutbound) CallStream(_ context.Context, req *transport.StreamRequest) (*transport.ClientStream, error) {
+	o.headers = append(o.headers, req.Meta.Headers)
+	return nil, nil
+}
+
+// claimsHandler records the claims seen by handlers.
+type claimsHandler struct {
+	claims []Claims
+	called int
+}
+
+func (h *claimsHandler) record(ctx context.Context) {
+	h.called++
+	if c, ok := ClaimsFromContext(ctx); ok {
+		h.claims = append(h.claims, c)
+	}
+}
+
+func (h *claimsHandler) Handle(ctx context.Context, _ *transport.Request, _ transport.ResponseWriter) error {
+	h.record(ctx)
+	return nil
+}
+
+func (h *claimsHandler) HandleOneway(ctx context.Context, _ *transport.Request) error {
+	h.record(ctx)
+	return nil
+}
+
+func (h *claimsHandler) HandleStream(s *transport.ServerStream) error {
+	h.record(s.Context())
+	return nil
+}
+
+type fakeStream struct {
+	transport.Stream
+
+	ctx context.Context
+	req *transport.StreamRequest
+}
+
+func (s fakeStream) Context() context.Context          { return s.ctx }
+func (s fakeStream) Request() *transport.StreamRequest { return s.req }
+
+// staticValidator accepts the token "good", rejects "forbidden" with a
+// permission error and fails for everything else.
+type staticValidator struct{}
+
+func (staticValidator) Validate(_ context.Context, req *transport.RequestMeta, token string) (Claims, error) {
+	switch token {
+	case "good":
+		return Claims{Subject: "frontend", Audience: []string{req.Service}}, nil
+	case "forbidden":
+		return Claims{}, yarpcerrors.PermissionDeniedErrorf("not for %v", req.Service)
+	default:
+		return Claims{}, errors.New("bad token")
+	}
+}
+
+func request(procedure string, headers transport.Headers) *transport.Request {
+	return &transport.Request{
+		Caller:    "caller",
+		Service:   "service",
+		Encoding:  "raw",
+		Procedure: procedure,
+		Headers:   headers,
+	}
+}
+
+func TestOutbound(t *testing.T) {
+	mw := NewOutbound(CredentialProviderFunc(func(_ context.Context, service string) (Token, error) {
+		return Token{Value: "token-for-" + service}, nil
+	}))
+
+	headers := transport.NewHeaders().With("X-Tenant", "acme").With("Authorization", "stale")
+	req := request("getValue", headers)
+	out := &recordingOutbound{}
+
+	_, err := mw.Call(context.Background(), req, out)
+	require.NoError(t, err)
+	_, err = mw.CallOneway(context.Background(), req, out)
+	require.NoError(t, err)
+	_, err = mw.CallStream(context.Background(), &transport.StreamRequest{Meta: req.ToRequestMeta()}, out)
+	require.NoError(t, err)
+
+	require.Len(t, out.headers, 3)
+	for _, h := range out.headers {
+		assert.Equal(t, map[string]string{
+			"x-tenant":      "acme",
+			"authorization": "Bearer token-for-service",
+		}, h.Items())
+		assert.Len(t, h.OriginalItems(), 2)
+	}
+
+	v, _ := headers.Get("authorization")
+	assert.Equal(t, "stale", v, "request headers must not be changed")
+}
+
+func TestOutboundProviderError(t *testing.T) {
+	mw := NewOutbound(CredentialProviderFunc(func(context.Context, string) (Token, error) {
+		return Token{}, errors.New("great sadness")
+	}))
+	req := request("getValue", transport.Headers{})
+	out := &recordingOutbound{}
+
+	_, err := mw.Call(context.Background(), req, out)
+	assert.Equal(t, yarpcerrors.UnauthenticatedErrorf(`failed to obtain credentials for service "service": great sadness`), err)
+	_, err = mw.CallOneway(context.Background(), req, out)
+	assert.Equal(t, yarpcerrors.CodeUnauthenticated, yarpcerrors.FromError(err).Code())
+	_, err = mw.CallStream(context.Background(), &transport.StreamRequest{Meta: req.ToRequestMeta()}, out)
+	assert.Equal(t, yarpcerrors.CodeUnauthenticated, yarpcerrors.FromError(err).Code())
+
+	assert.Empty(t, out.headers, "requests must not be sent")
+}
+
+func TestInbound(t *testing.T) {
+	mw := NewInbound(staticValidator{}, AllowUnauthenticated("health"))
+
+	tests := []struct {
+		desc       string
+		procedure  string
+		header     string
+		wantCode   yarpcerrors.Code
+		wantError  string
+		wantClaims bool
+	}{
+		{
+			desc:       "valid token",
+			procedure:  "getValue",
+			header:     "Bearer good",
+			wantClaims: true,
+		},
+		{
+			desc:       "lowercase scheme",
+			procedure:  "getValue",
+			header:     "bearer good",
+			wantClaims: true,
+		},
+		{
+			desc:      "no token",
+			procedure: "getValue",
+			wantCode:  yarpcerrors.CodeUnauthenticated,
+			wantError: `procedure "getValue" of service "service" requires a bearer token`,
+		},
+		{
+			desc:      "other scheme",
+			procedure: "getValue",
+			header:    "Basic Zm9vOmJhcg==",
+			wantCode:  yarpcerrors.CodeUnauthenticated,
+			wantError: "authorization header must use the Bearer scheme",
+		},
+		{
+			desc:      "invalid token",
+			procedure: "getValue",
+			header:    "Bearer bad",
+			wantCode:  yarpcerrors.CodeUnauthenticated,
+			wantError: "invalid bearer token: bad token",
+		},
+		{
+			desc:      "forbidden token",
+			procedure: "getValue",
+			header:    "Bearer forbidden",
+			wantCode:  yarpcerrors.CodePermissionDenied,
+			wantError: "not for service",
+		},
+		{
+			desc:      "unauthenticated procedure",
+			procedure: "health",
+		},
+		{
+			desc:       "unauthenticated procedure with token",
+			procedure:  "health",
+			header:     "Bearer good",
+			wantClaims: true,
+		},
+		{
+			desc:      "unauthenticated procedure with invalid token",
+			procedure: "health",
+			header:    "Bearer bad",
+			wantCode:  yarpcerrors.CodeUnauthenticated,
+			wantError: "invalid bearer token: bad token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			headers := transport.NewHeaders()
+			if tt.header != "" {
+				headers = headers.With("Authorization", tt.header)
+			}
+			req := request(tt.procedure, headers)
+			ctx := context.Background()
+			s, err := transport.NewServerStream(fakeStream{
+				ctx: ctx,
+				req: &transport.StreamRequest{Meta: req.ToRequestMeta()},
+			})
+			require.NoError(t, err)
+
+			h := &claimsHandler{}
+			errs := []error{
+				mw.Handle(ctx, req, &transporttest.FakeResponseWriter{}, h),
+				mw.HandleOneway(ctx, req, h),
+				mw.HandleStream(s, h),
+			}
+			for _, err := range errs {
+				if tt.wantCode == yarpcerrors.CodeOK {
+					assert.NoError(t, err)
+					continue
+				}
+				status := yarpcerrors.FromError(err)
+				assert.Equal(t, tt.wantCode, status.Code())
+				assert.Equal(t, tt.wantError, status.Message())
+			}
+
+			if tt.wantCode != yarpcerrors.CodeOK {
+				assert.Zero(t, h.called, "handlers must not be called")
+				return
+			}
+			assert.Equal(t, 3, h.called, "handlers must be called")
+			if tt.wantClaims {
+				want := Claims{Subject: "frontend", Audience: []string{"service"}}
+				assert.Equal(t, []Claims{want, want, want}, h.claims)
+			} else {
+				assert.Empty(t, h.claims)
+			}
+		})
+	}
+}
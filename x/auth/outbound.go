@@ -0,0 +1,111 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package auth
+
+import (
+	"context"
+
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+const (
+	_name = "auth"
+
+	// AuthorizationHeader is the request header that carries bearer tokens.
+	AuthorizationHeader = "authorization"
+
+	_bearerPrefix = "Bearer "
+)
+
+// OutboundMiddleware is a unary, oneway and stream outbound middleware that
+// attaches a bearer token to every request.
+type OutboundMiddleware struct {
+	provider CredentialProvider
+}
+
+var (
+	_ middleware.UnaryOutbound  = (*OutboundMiddleware)(nil)
+	_ middleware.OnewayOutbound = (*OutboundMiddleware)(nil)
+	_ middleware.StreamOutbound = (*OutboundMiddleware)(nil)
+)
+
+// NewOutbound builds a new outbound middleware that attaches the tokens of
+// the given provider to requests. The provider is asked for a token on every
+// request; wrap it with NewCachingProvider to reuse tokens.
+//
+// Requests for which the provider fails are rejected with
+// CodeUnauthenticated without being sent.
+func NewOutbound(p CredentialProvider) *OutboundMiddleware {
+	return &OutboundMiddleware{provider: p}
+}
+
+// Call implements middleware.UnaryOutbound.
+func (m *OutboundMiddleware) Call(ctx context.Context, req *transport.Request, out transport.UnaryOutbound) (*transport.Response, error) {
+	headers, err := m.authorize(ctx, req.Service, req.Headers)
+	if err != nil {
+		return nil, err
+	}
+	r := *req
+	r.Headers = headers
+	return out.Call(ctx, &r)
+}
+
+// CallOneway implements middleware.OnewayOutbound.
+func (m *OutboundMiddleware) CallOneway(ctx context.Context, req *transport.Request, out transport.OnewayOutbound) (transport.Ack, error) {
+	headers, err := m.authorize(ctx, req.Service, req.Headers)
+	if err != nil {
+		return nil, err
+	}
+	r := *req
+	r.Headers = headers
+	return out.CallOneway(ctx, &r)
+}
+
+// CallStream implements middleware.StreamOutbound.
+func (m *OutboundMiddleware) CallStream(ctx context.Context, req *transport.StreamRequest, out transport.StreamOutbound) (*transport.ClientStream, error) {
+	headers, err := m.authorize(ctx, req.Meta.Service, req.Meta.Headers)
+	if err != nil {
+		return nil, err
+	}
+	meta := *req.Meta
+	meta.Headers = headers
+	return out.CallStream(ctx, &transport.StreamRequest{Meta: &meta})
+}
+
+// authorize returns a copy of the given headers with a token for the named
+// service. The original headers are left untouched because the same request
+// may be sent more than once.
+func (m *OutboundMiddleware) authorize(ctx context.Context, service string, headers transport.Headers) (transport.Headers, error) {
+	tok, err := m.provider.Token(ctx, service)
+	if err != nil {
+		return headers, yarpcerrors.UnauthenticatedErrorf("failed to obtain credentials for service %q: %v", service, err)
+	}
+
+	h := transport.NewHeadersWithCapacity(headers.Len() + 1)
+	for k, v := range headers.OriginalItems() {
+		if transport.CanonicalizeHeaderKey(k) != AuthorizationHeader {
+			h = h.With(k, v)
+		}
+	}
+	return h.With(AuthorizationHeader, _bearerPrefix+tok.Value), nil
+}
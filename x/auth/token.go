@@ -0,0 +1,195 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package auth
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/yarpc/internal/clock"
+)
+
+const (
+	_defaultRefreshBefore = time.Minute
+	_defaultMaxAge        = 5 * time.Minute
+)
+
+// Token is a bearer token presented to a service.
+type Token struct {
+	// Value is the encoded token sent in the authorization header.
+	Value string
+
+	// Expiry is the time after which the token is no longer valid. The zero
+	// value means that the expiry is unknown.
+	Expiry time.Time
+}
+
+// CredentialProvider provides the tokens that outbound requests present to
+// the services they call.
+type CredentialProvider interface {
+	// Token returns a token for requests to the named service.
+	Token(ctx context.Context, service string) (Token, error)
+}
+
+// CredentialProviderFunc is a function that implements CredentialProvider.
+type CredentialProviderFunc func(ctx context.Context, service string) (Token, error)
+
+// Token calls f.
+func (f CredentialProviderFunc) Token(ctx context.Context, service string) (Token, error) {
+	return f(ctx, service)
+}
+
+// TokenFile returns a CredentialProvider that reads the token for every
+// service from the given file. The file is read on every call, so it is
+// usually wrapped with NewCachingProvider. This suits tokens that are
+// written and rotated by a sidecar.
+//
+// If the token is a JWT, its expiry is taken from its exp claim.
+func TokenFile(path string) CredentialProvider {
+	return CredentialProviderFunc(func(context.Context, string) (Token, error) {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return Token{}, fmt.Errorf("failed to read token file %q: %v", path, err)
+		}
+		value := strings.TrimSpace(string(b))
+		if value == "" {
+			return Token{}, fmt.Errorf("token file %q is empty", path)
+		}
+
+		tok := Token{Value: value}
+		if claims, err := parseUnverifiedClaims(value); err == nil {
+			tok.Expiry = claims.ExpiresAt
+		}
+		return tok, nil
+	})
+}
+
+// CacheOption customizes the behavior of a caching CredentialProvider.
+type CacheOption func(*cacheOptions)
+
+type cacheOptions struct {
+	refreshBefore time.Duration
+	maxAge        time.Duration
+	clock         clock.Clock
+}
+
+// RefreshBefore sets how long before its expiry a cached token is replaced.
+//
+// Defaults to one minute.
+func RefreshBefore(d time.Duration) CacheOption {
+	return func(opts *cacheOptions) {
+		opts.refreshBefore = d
+	}
+}
+
+// MaxAge sets how long tokens without a known expiry are cached.
+//
+// Defaults to five minutes.
+func MaxAge(d time.Duration) CacheOption {
+	return func(opts *cacheOptions) {
+		opts.maxAge = d
+	}
+}
+
+// withCacheClock overrides the clock used to age cached tokens. This is used
+// for testing.
+func withCacheClock(c clock.Clock) CacheOption {
+	return func(opts *cacheOptions) {
+		opts.clock = c
+	}
+}
+
+// NewCachingProvider returns a CredentialProvider that caches the tokens of
+// the given provider for each service.
+//
+// Cached tokens are replaced once they are within RefreshBefore of their
+// expiry. If replacing a token fails, the cached token keeps being used
+// until it expires. Tokens without a known expiry are not used after
+// MaxAge.
+func NewCachingProvider(p CredentialProvider, opts ...CacheOption) CredentialProvider {
+	options := cacheOptions{
+		refreshBefore: _defaultRefreshBefore,
+		maxAge:        _defaultMaxAge,
+		clock:         clock.NewReal(),
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &cachingProvider{
+		provider: p,
+		opts:     options,
+		entries:  make(map[string]*cacheEntry),
+	}
+}
+
+type cachingProvider struct {
+	provider CredentialProvider
+	opts     cacheOptions
+
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+// cacheEntry holds the token for one service. Its lock is held while the
+// token is fetched so that concurrent requests share a single fetch.
+type cacheEntry struct {
+	mu        sync.Mutex
+	token     Token
+	refreshAt time.Time
+}
+
+func (p *cachingProvider) Token(ctx context.Context, service string) (Token, error) {
+	p.mu.Lock()
+	e, ok := p.entries[service]
+	if !ok {
+		e = &cacheEntry{}
+		p.entries[service] = e
+	}
+	p.mu.Unlock()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := p.opts.clock.Now()
+	if e.token.Value != "" && now.Before(e.refreshAt) {
+		return e.token, nil
+	}
+
+	tok, err := p.provider.Token(ctx, service)
+	if err != nil {
+		if e.token.Value != "" && now.Before(e.token.Expiry) {
+			return e.token, nil
+		}
+		return Token{}, err
+	}
+
+	e.token = tok
+	if tok.Expiry.IsZero() {
+		e.refreshAt = now.Add(p.opts.maxAge)
+	} else {
+		e.refreshAt = tok.Expiry.Add(-p.opts.refreshBefore)
+	}
+	return tok, nil
+}
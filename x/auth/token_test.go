@@ -0,0 +1,152 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/internal/clock"
+)
+
+// countingProvider hands out numbered tokens that expire after ttl, or
+// fails if err is set.
+type countingProvider struct {
+	clock *clock.FakeClock
+	ttl   time.Duration
+
+	mu    sync.Mutex
+	calls int
+	err   error
+}
+
+func (p *countingProvider) Token(_ context.Context, service string) (Token, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.calls++
+	if p.err != nil {
+		return Token{}, p.err
+	}
+	tok := Token{Value: fmt.Sprintf("%v-%d", service, p.calls)}
+	if p.ttl > 0 {
+		tok.Expiry = p.clock.Now().Add(p.ttl)
+	}
+	return tok, nil
+}
+
+func TestCachingProvider(t *testing.T) {
+	clk := clock.NewFake()
+	p := &countingProvider{clock: clk, ttl: 10 * time.Minute}
+	cache := NewCachingProvider(p, RefreshBefore(time.Minute), withCacheClock(clk))
+	ctx := context.Background()
+
+	tok, err := cache.Token(ctx, "users")
+	require.NoError(t, err)
+	assert.Equal(t, "users-1", tok.Value)
+
+	clk.Add(8 * time.Minute)
+	tok, err = cache.Token(ctx, "users")
+	require.NoError(t, err)
+	assert.Equal(t, "users-1", tok.Value, "token must be cached")
+
+	tok, err = cache.Token(ctx, "catalog")
+	require.NoError(t, err)
+	assert.Equal(t, "catalog-2", tok.Value, "services must have their own tokens")
+
+	clk.Add(time.Minute)
+	tok, err = cache.Token(ctx, "users")
+	require.NoError(t, err)
+	assert.Equal(t, "users-3", tok.Value, "token must be refreshed before it expires")
+
+	// Failed refreshes fall back to the cached token until it expires.
+	p.err = errors.New("great sadness")
+	clk.Add(9*time.Minute + 30*time.Second)
+	tok, err = cache.Token(ctx, "users")
+	require.NoError(t, err)
+	assert.Equal(t, "users-3", tok.Value)
+
+	clk.Add(30 * time.Second)
+	_, err = cache.Token(ctx, "users")
+	assert.EqualError(t, err, "great sadness")
+	assert.Equal(t, 5, p.calls)
+}
+
+func TestCachingProviderMaxAge(t *testing.T) {
+	clk := clock.NewFake()
+	p := &countingProvider{clock: clk}
+	cache := NewCachingProvider(p, MaxAge(time.Minute), withCacheClock(clk))
+	ctx := context.Background()
+
+	tok, err := cache.Token(ctx, "users")
+	require.NoError(t, err)
+	assert.Equal(t, "users-1", tok.Value)
+
+	clk.Add(59 * time.Second)
+	tok, err = cache.Token(ctx, "users")
+	require.NoError(t, err)
+	assert.Equal(t, "users-1", tok.Value)
+
+	// Tokens without an expiry are not used after MaxAge, even if
+	// refreshing them fails.
+	p.err = errors.New("great sadness")
+	clk.Add(time.Second)
+	_, err = cache.Token(ctx, "users")
+	assert.EqualError(t, err, "great sadness")
+}
+
+func TestTokenFile(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	path := filepath.Join(dir, "token")
+	ctx := context.Background()
+
+	p := TokenFile(path)
+	_, err := p.Token(ctx, "users")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to read token file")
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(" \n"), 0600))
+	_, err = p.Token(ctx, "users")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is empty")
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("opaque-token\n"), 0600))
+	tok, err := p.Token(ctx, "users")
+	require.NoError(t, err)
+	assert.Equal(t, Token{Value: "opaque-token"}, tok)
+
+	key := newRSAKey(t, "rs256", "RS256")
+	jwt := key.sign(t, map[string]interface{}{"aud": "users", "exp": 1500000000})
+	require.NoError(t, ioutil.WriteFile(path, []byte(jwt+"\n"), 0600))
+	tok, err = p.Token(ctx, "users")
+	require.NoError(t, err)
+	assert.Equal(t, jwt, tok.Value)
+	assert.True(t, time.Unix(1500000000, 0).Equal(tok.Expiry), "expiry must be read from the token")
+}
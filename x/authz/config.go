@@ -0,0 +1,135 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package authz
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/yarpcconfig"
+)
+
+// Config describes how to build an authorization middleware.
+//
+//  procedures:
+//    KeyValue::setValue:
+//      - spiffe://example.com/admin
+//  default:
+//    - spiffe://example.com/*
+//  callers:
+//    frontend:
+//      - spiffe://example.com/frontend
+//
+// Procedures that are not listed under procedures may be called by the
+// identities listed under default only.
+type Config struct {
+	Procedures map[string][]string `config:"procedures"`
+	Default    []string            `config:"default"`
+	Callers    map[string][]string `config:"callers"`
+}
+
+// Spec returns a configuration specification for the authorization
+// middleware, making it possible to enable it for all unary, oneway and
+// stream inbounds.
+//
+//  cfg := yarpcconfig.New()
+//  cfg.MustRegisterMiddleware(authz.Spec())
+//
+// This enables the authorization middleware:
+//
+//  middleware:
+//    inbound:
+//      - authz:
+//          default: ["*"]
+//
+// See Config for the full set of attributes.
+func Spec() yarpcconfig.MiddlewareSpec {
+	build := func(cfg Config) (*Middleware, error) {
+		opts, err := cfg.options()
+		if err != nil {
+			return nil, err
+		}
+		return New(opts...), nil
+	}
+
+	return yarpcconfig.MiddlewareSpec{
+		Name: _name,
+		BuildUnaryInbound: func(cfg Config, k *yarpcconfig.Kit) (middleware.UnaryInbound, error) {
+			return build(cfg)
+		},
+		BuildOnewayInbound: func(cfg Config, k *yarpcconfig.Kit) (middleware.OnewayInbound, error) {
+			return build(cfg)
+		},
+		BuildStreamInbound: func(cfg Config, k *yarpcconfig.Kit) (middleware.StreamInbound, error) {
+			return build(cfg)
+		},
+	}
+}
+
+func (c Config) options() ([]Option, error) {
+	if err := validatePatterns("default", c.Default); err != nil {
+		return nil, err
+	}
+	opts := []Option{AllowDefault(c.Default...)}
+
+	// Sorted so that errors are reported deterministically.
+	for _, procedure := range sortedKeys(c.Procedures) {
+		identities := c.Procedures[procedure]
+		if err := validatePatterns(fmt.Sprintf("procedures[%q]", procedure), identities); err != nil {
+			return nil, err
+		}
+		opts = append(opts, AllowProcedure(procedure, identities...))
+	}
+	for _, caller := range sortedKeys(c.Callers) {
+		identities := c.Callers[caller]
+		if len(identities) == 0 {
+			return nil, fmt.Errorf("callers[%q] must list at least one identity", caller)
+		}
+		if err := validatePatterns(fmt.Sprintf("callers[%q]", caller), identities); err != nil {
+			return nil, err
+		}
+		opts = append(opts, BindCaller(caller, identities...))
+	}
+	return opts, nil
+}
+
+func validatePatterns(field string, patterns []string) error {
+	for _, p := range patterns {
+		if p == "" {
+			return fmt.Errorf("%v: identities must not be empty", field)
+		}
+		if i := strings.Index(p, "*"); i >= 0 && i != len(p)-1 {
+			return fmt.Errorf(`%v: invalid identity %q: "*" is only allowed at the end`, field, p)
+		}
+	}
+	return nil
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
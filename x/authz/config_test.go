@@ -0,0 +1,122 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package authz
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/internal/whitespace"
+	"go.uber.org/yarpc/yarpcconfig"
+)
+
+func TestSpec(t *testing.T) {
+	tests := []struct {
+		desc     string
+		give     string
+		wantOpts options
+		wantErr  string
+	}{
+		{
+			desc:     "default",
+			give:     `default: ["*"]`,
+			wantOpts: New(AllowDefault("*")).opts,
+		},
+		{
+			desc: "all attributes",
+			give: whitespace.Expand(`
+				procedures:
+				  KeyValue::setValue:
+				    - spiffe://example.com/admin
+				    - spiffe://example.com/ops/*
+				default:
+				  - spiffe://example.com/*
+				callers:
+				  frontend: [spiffe://example.com/frontend]
+			`),
+			wantOpts: New(
+				AllowProcedure("KeyValue::setValue", "spiffe://example.com/admin", "spiffe://example.com/ops/*"),
+				AllowDefault("spiffe://example.com/*"),
+				BindCaller("frontend", "spiffe://example.com/frontend"),
+			).opts,
+		},
+		{
+			desc:    "wildcard in the middle",
+			give:    `default: ["spiffe://*/admin"]`,
+			wantErr: `default: invalid identity "spiffe://*/admin": "*" is only allowed at the end`,
+		},
+		{
+			desc: "empty identity",
+			give: whitespace.Expand(`
+				procedures:
+				  KeyValue::setValue: [""]
+			`),
+			wantErr: `procedures["KeyValue::setValue"]: identities must not be empty`,
+		},
+		{
+			desc: "caller without identities",
+			give: whitespace.Expand(`
+				callers:
+				  frontend: []
+			`),
+			wantErr: `callers["frontend"] must list at least one identity`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			cfg := yarpcconfig.New()
+			cfg.MustRegisterMiddleware(Spec())
+
+			give := "middleware:\n  inbound:\n    - authz:\n" + indent(tt.give, "        ")
+			c, err := cfg.LoadConfigFromYAML("myservice", strings.NewReader(give))
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			unary, ok := c.InboundMiddleware.Unary.(*Middleware)
+			require.True(t, ok, "expected authorizer, got %T", c.InboundMiddleware.Unary)
+			oneway, ok := c.InboundMiddleware.Oneway.(*Middleware)
+			require.True(t, ok, "expected authorizer, got %T", c.InboundMiddleware.Oneway)
+			stream, ok := c.InboundMiddleware.Stream.(*Middleware)
+			require.True(t, ok, "expected authorizer, got %T", c.InboundMiddleware.Stream)
+
+			for _, mw := range []*Middleware{unary, oneway, stream} {
+				assert.Equal(t, tt.wantOpts, mw.opts)
+			}
+		})
+	}
+}
+
+func indent(s, prefix string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
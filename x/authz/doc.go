@@ -0,0 +1,55 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package authz provides inbound middleware that authorizes requests based
+// on the verified identity of their caller.
+//
+// Inbounds that require mutual TLS attach the identity from the client
+// certificate to each request: its SPIFFE ID if it has one, or the common
+// name of its subject otherwise. The middleware checks this identity
+// against allow lists for each procedure. Unlike the caller name, which
+// callers may set to any value, the identity cannot be forged.
+//
+// 	authorizer := authz.New(
+// 		authz.AllowProcedure("KeyValue::setValue", "spiffe://example.com/admin"),
+// 		authz.AllowDefault("spiffe://example.com/*"),
+// 	)
+// 	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+// 		Name: "keyvalue",
+// 		InboundMiddleware: yarpc.InboundMiddleware{
+// 			Unary:  authorizer,
+// 			Oneway: authorizer,
+// 			Stream: authorizer,
+// 		},
+// 		// ...
+// 	})
+//
+// Identities in allow lists match exactly, unless they end with "*", in
+// which case they match all identities with the given prefix. A lone "*"
+// allows any authenticated caller.
+//
+// The middleware can also bind caller names to identities, so that only the
+// given peers may send requests that claim to come from a caller.
+//
+// 	authz.BindCaller("frontend", "spiffe://example.com/frontend")
+//
+// Requests without a verified identity fail with CodeUnauthenticated, and
+// requests that are not allowed fail with CodePermissionDenied.
+package authz
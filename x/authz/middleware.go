@@ -0,0 +1,162 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package authz
+
+import (
+	"context"
+	"strings"
+
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+const _name = "authz"
+
+// Option customizes the behavior of the authorization middleware.
+type Option func(*options)
+
+type options struct {
+	procedures   map[string][]string
+	defaultAllow []string
+	callers      map[string][]string
+}
+
+// AllowProcedure allows the given identities to call the named procedure.
+// Procedures with their own allow list ignore the default allow list.
+//
+// AllowProcedure may be given more than once for the same procedure, in
+// which case the identities are combined.
+func AllowProcedure(procedure string, identities ...string) Option {
+	return func(opts *options) {
+		opts.procedures[procedure] = append(opts.procedures[procedure], identities...)
+	}
+}
+
+// AllowDefault allows the given identities to call procedures that do not
+// have an allow list of their own.
+//
+// Defaults to no identities, which rejects all calls to such procedures.
+func AllowDefault(identities ...string) Option {
+	return func(opts *options) {
+		opts.defaultAllow = append(opts.defaultAllow, identities...)
+	}
+}
+
+// BindCaller requires requests that claim to come from the named caller to
+// come from one of the given identities. Requests from other callers are not
+// affected.
+func BindCaller(caller string, identities ...string) Option {
+	return func(opts *options) {
+		opts.callers[caller] = append(opts.callers[caller], identities...)
+	}
+}
+
+// Middleware is a unary, oneway and stream inbound middleware that rejects
+// requests from callers that are not allowed to make them.
+type Middleware struct {
+	opts options
+}
+
+var (
+	_ middleware.UnaryInbound  = (*Middleware)(nil)
+	_ middleware.OnewayInbound = (*Middleware)(nil)
+	_ middleware.StreamInbound = (*Middleware)(nil)
+)
+
+// New builds a new authorization middleware with the given options.
+func New(opts ...Option) *Middleware {
+	options := options{
+		procedures: make(map[string][]string),
+		callers:    make(map[string][]string),
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &Middleware{opts: options}
+}
+
+// Handle handles the request with the given handler if its caller is
+// allowed to make it.
+func (m *Middleware) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter, h transport.UnaryHandler) error {
+	if err := m.authorize(ctx, req); err != nil {
+		return err
+	}
+	return h.Handle(ctx, req, resw)
+}
+
+// HandleOneway handles the request with the given handler if its caller is
+// allowed to make it.
+func (m *Middleware) HandleOneway(ctx context.Context, req *transport.Request, h transport.OnewayHandler) error {
+	if err := m.authorize(ctx, req); err != nil {
+		return err
+	}
+	return h.HandleOneway(ctx, req)
+}
+
+// HandleStream handles the stream with the given handler if its caller is
+// allowed to open it.
+func (m *Middleware) HandleStream(s *transport.ServerStream, h transport.StreamHandler) error {
+	if err := m.authorize(s.Context(), s.Request().Meta.ToRequest()); err != nil {
+		return err
+	}
+	return h.HandleStream(s)
+}
+
+// authorize returns an error if the caller of the request is not
+// authenticated, or not allowed to make the request.
+func (m *Middleware) authorize(ctx context.Context, req *transport.Request) error {
+	id, ok := transport.PeerIdentityFromContext(ctx)
+	if !ok {
+		return yarpcerrors.UnauthenticatedErrorf(
+			"procedure %q of service %q requires an authenticated caller", req.Procedure, req.Service)
+	}
+	name := id.Name()
+
+	if allowed, ok := m.opts.callers[req.Caller]; ok && !matchAny(allowed, name) {
+		return yarpcerrors.PermissionDeniedErrorf(
+			"peer %q may not send requests as caller %q", name, req.Caller)
+	}
+
+	allowed, ok := m.opts.procedures[req.Procedure]
+	if !ok {
+		allowed = m.opts.defaultAllow
+	}
+	if !matchAny(allowed, name) {
+		return yarpcerrors.PermissionDeniedErrorf(
+			"peer %q may not call procedure %q of service %q", name, req.Procedure, req.Service)
+	}
+	return nil
+}
+
+// matchAny returns true if the identity matches any of the patterns.
+func matchAny(patterns []string, id string) bool {
+	for _, p := range patterns {
+		if strings.HasSuffix(p, "*") {
+			if strings.HasPrefix(id, p[:len(p)-1]) {
+				return true
+			}
+		} else if p == id {
+			return true
+		}
+	}
+	return false
+}
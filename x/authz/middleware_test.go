@@ -0,0 +1,195 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package authz
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/transport/transporttest"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+type handler struct{ called int }
+
+func (h *handler) Handle(context.Context, *transport.Request, transport.ResponseWriter) error {
+	h.called++
+	return nil
+}
+
+func (h *handler) HandleOneway(context.Context, *transport.Request) error {
+	h.called++
+	return nil
+}
+
+func (h *handler) HandleStream(*transport.ServerStream) error {
+	h.called++
+	return nil
+}
+
+type fakeStream struct {
+	transport.Stream
+
+	ctx context.Context
+	req *transport.StreamRequest
+}
+
+func (s fakeStream) Context() context.Context          { return s.ctx }
+func (s fakeStream) Request() *transport.StreamRequest { return s.req }
+
+func withIdentity(name string) context.Context {
+	return transport.WithPeerIdentity(context.Background(), transport.PeerIdentity{SPIFFEID: name})
+}
+
+func request(caller, procedure string) *transport.Request {
+	return &transport.Request{
+		Caller:    caller,
+		Service:   "service",
+		Encoding:  "raw",
+		Procedure: procedure,
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	mw := New(
+		AllowProcedure("setValue", "spiffe://example.com/admin"),
+		AllowProcedure("setValue", "spiffe://example.com/ops/*"),
+		AllowProcedure("health"),
+		AllowDefault("spiffe://example.com/*"),
+		BindCaller("frontend", "spiffe://example.com/frontend"),
+	)
+
+	tests := []struct {
+		desc      string
+		ctx       context.Context
+		caller    string
+		procedure string
+		wantCode  yarpcerrors.Code
+	}{
+		{
+			desc:      "unauthenticated",
+			ctx:       context.Background(),
+			caller:    "admin",
+			procedure: "getValue",
+			wantCode:  yarpcerrors.CodeUnauthenticated,
+		},
+		{
+			desc:      "default allow list",
+			ctx:       withIdentity("spiffe://example.com/backend"),
+			caller:    "backend",
+			procedure: "getValue",
+		},
+		{
+			desc:      "outside default allow list",
+			ctx:       withIdentity("spiffe://other.com/backend"),
+			caller:    "backend",
+			procedure: "getValue",
+			wantCode:  yarpcerrors.CodePermissionDenied,
+		},
+		{
+			desc:      "procedure allow list",
+			ctx:       withIdentity("spiffe://example.com/admin"),
+			caller:    "admin",
+			procedure: "setValue",
+		},
+		{
+			desc:      "procedure allow list prefix",
+			ctx:       withIdentity("spiffe://example.com/ops/alice"),
+			caller:    "alice",
+			procedure: "setValue",
+		},
+		{
+			desc:      "procedure allow list overrides default",
+			ctx:       withIdentity("spiffe://example.com/backend"),
+			caller:    "backend",
+			procedure: "setValue",
+			wantCode:  yarpcerrors.CodePermissionDenied,
+		},
+		{
+			desc:      "empty procedure allow list",
+			ctx:       withIdentity("spiffe://example.com/admin"),
+			caller:    "admin",
+			procedure: "health",
+			wantCode:  yarpcerrors.CodePermissionDenied,
+		},
+		{
+			desc:      "bound caller",
+			ctx:       withIdentity("spiffe://example.com/frontend"),
+			caller:    "frontend",
+			procedure: "getValue",
+		},
+		{
+			desc:      "impersonated caller",
+			ctx:       withIdentity("spiffe://example.com/backend"),
+			caller:    "frontend",
+			procedure: "getValue",
+			wantCode:  yarpcerrors.CodePermissionDenied,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			req := request(tt.caller, tt.procedure)
+			s, err := transport.NewServerStream(fakeStream{
+				ctx: tt.ctx,
+				req: &transport.StreamRequest{Meta: req.ToRequestMeta()},
+			})
+			require.NoError(t, err)
+
+			h := new(handler)
+			errs := []error{
+				mw.Handle(tt.ctx, req, &transporttest.FakeResponseWriter{}, h),
+				mw.HandleOneway(tt.ctx, req, h),
+				mw.HandleStream(s, h),
+			}
+			for _, err := range errs {
+				if tt.wantCode == yarpcerrors.CodeOK {
+					assert.NoError(t, err)
+				} else {
+					assert.Equal(t, tt.wantCode, yarpcerrors.FromError(err).Code(), "unexpected error: %v", err)
+				}
+			}
+			if tt.wantCode == yarpcerrors.CodeOK {
+				assert.Equal(t, 3, h.called, "handlers must be called")
+			} else {
+				assert.Zero(t, h.called, "handlers must not be called")
+			}
+		})
+	}
+}
+
+func TestAuthorizeErrorMessages(t *testing.T) {
+	mw := New(BindCaller("frontend", "spiffe://example.com/frontend"))
+	h := new(handler)
+
+	err := mw.Handle(context.Background(), request("frontend", "getValue"), &transporttest.FakeResponseWriter{}, h)
+	assert.Equal(t, `procedure "getValue" of service "service" requires an authenticated caller`, yarpcerrors.FromError(err).Message())
+
+	ctx := withIdentity("spiffe://example.com/backend")
+	err = mw.Handle(ctx, request("frontend", "getValue"), &transporttest.FakeResponseWriter{}, h)
+	assert.Equal(t, `peer "spiffe://example.com/backend" may not send requests as caller "frontend"`, yarpcerrors.FromError(err).Message())
+
+	err = mw.Handle(ctx, request("backend", "getValue"), &transporttest.FakeResponseWriter{}, h)
+	assert.Equal(t, `peer "spiffe://example.com/backend" may not call procedure "getValue" of service "service"`, yarpcerrors.FromError(err).Message())
+}
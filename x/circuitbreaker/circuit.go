@@ -0,0 +1,176 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package circuitbreaker
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// state is the state of a circuit.
+type state int
+
+const (
+	// Requests are allowed through and their outcome is recorded.
+	closed state = iota
+
+	// Requests fail immediately.
+	open
+
+	// A limited number of probe requests are allowed through to decide
+	// whether the circuit should be closed again.
+	halfOpen
+)
+
+func (s state) String() string {
+	switch s {
+	case closed:
+		return "closed"
+	case open:
+		return "open"
+	case halfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("state(%d)", int(s))
+	}
+}
+
+// circuit tracks the outcome of requests for a single procedure.
+type circuit struct {
+	opts *options
+
+	mu    sync.Mutex
+	state state
+
+	// Outcomes in the current window while closed.
+	windowStart time.Time
+	requests    int
+	failures    int
+
+	// Time at which the circuit was last opened.
+	openedAt time.Time
+
+	// Probes in flight and succeeded while half-open.
+	probes    int
+	successes int
+}
+
+func newCircuit(opts *options) *circuit {
+	return &circuit{opts: opts, windowStart: opts.clock.Now()}
+}
+
+// allow reports whether a request may be sent. If so, the caller MUST call
+// record with the returned state once the request finishes.
+func (c *circuit) allow() (state, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.opts.clock.Now()
+	switch c.state {
+	case closed:
+		if now.Sub(c.windowStart) >= c.opts.window {
+			c.resetWindow(now)
+		}
+		return closed, true
+
+	case open:
+		if now.Sub(c.openedAt) < c.opts.openTimeout {
+			return open, false
+		}
+		c.state = halfOpen
+		c.probes = 0
+		c.successes = 0
+		fallthrough
+
+	case halfOpen:
+		if c.probes >= c.opts.probes {
+			return halfOpen, false
+		}
+		c.probes++
+		return halfOpen, true
+	}
+
+	return c.state, false
+}
+
+// record records the outcome of a request that was allowed while the
+// circuit was in the given state.
+func (c *circuit) record(admitted state, failed bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Outcomes of requests that were admitted before the last transition
+	// tell us nothing about the current state.
+	if admitted != c.state {
+		return
+	}
+
+	now := c.opts.clock.Now()
+	switch c.state {
+	case closed:
+		c.requests++
+		if failed {
+			c.failures++
+		}
+		if c.requests >= c.opts.minRequests &&
+			float64(c.failures) >= c.opts.failureRatio*float64(c.requests) {
+			c.trip(now)
+		}
+
+	case halfOpen:
+		if failed {
+			c.trip(now)
+			return
+		}
+		c.successes++
+		if c.successes >= c.opts.probes {
+			c.state = closed
+			c.resetWindow(now)
+		}
+	}
+}
+
+func (c *circuit) trip(now time.Time) {
+	c.state = open
+	c.openedAt = now
+}
+
+func (c *circuit) resetWindow(now time.Time) {
+	c.windowStart = now
+	c.requests = 0
+	c.failures = 0
+}
+
+// describe returns a human-readable description of the circuit's state.
+func (c *circuit) describe() (state, string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch c.state {
+	case open:
+		return c.state, fmt.Sprintf("open since %v", c.openedAt.Format(time.RFC3339))
+	case halfOpen:
+		return c.state, fmt.Sprintf("half-open, %d of %d probes succeeded", c.successes, c.opts.probes)
+	default:
+		return c.state, fmt.Sprintf("closed, %d of %d requests failed", c.failures, c.requests)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package circuitbreaker
+
+import (
+	"fmt"
+	"time"
+
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/yarpcconfig"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// Config describes how to build a circuit breaker middleware. Attributes
+// that are left unset use the defaults documented on the corresponding
+// options.
+//
+//  failureRatio: 0.5
+//  minRequests: 20
+//  window: 10s
+//  openTimeout: 5s
+//  probes: 1
+//  failureCodes: [unavailable, deadline-exceeded]
+type Config struct {
+	FailureRatio float64       `config:"failureRatio"`
+	MinRequests  int           `config:"minRequests"`
+	Window       time.Duration `config:"window"`
+	OpenTimeout  time.Duration `config:"openTimeout"`
+	Probes       int           `config:"probes"`
+	FailureCodes []string      `config:"failureCodes"`
+}
+
+// Spec returns a configuration specification for the circuit breaker
+// middleware, making it possible to enable it for all unary and oneway
+// outbounds.
+//
+//  cfg := yarpcconfig.New()
+//  cfg.MustRegisterMiddleware(circuitbreaker.Spec())
+//
+// This enables the circuit breaker middleware:
+//
+//  middleware:
+//    outbound:
+//      - circuit-breaker:
+//          failureRatio: 0.25
+//          openTimeout: 30s
+//
+// See Config for the full set of attributes.
+func Spec() yarpcconfig.MiddlewareSpec {
+	build := func(cfg Config) (*Middleware, error) {
+		opts, err := cfg.options()
+		if err != nil {
+			return nil, err
+		}
+		return New(opts...), nil
+	}
+
+	return yarpcconfig.MiddlewareSpec{
+		Name: _name,
+		BuildUnaryOutbound: func(cfg Config, k *yarpcconfig.Kit) (middleware.UnaryOutbound, error) {
+			return build(cfg)
+		},
+		BuildOnewayOutbound: func(cfg Config, k *yarpcconfig.Kit) (middleware.OnewayOutbound, error) {
+			return build(cfg)
+		},
+	}
+}
+
+func (c Config) options() ([]Option, error) {
+	var opts []Option
+
+	if c.FailureRatio < 0 || c.FailureRatio > 1 {
+		return nil, fmt.Errorf("failureRatio must be between 0 and 1, got %v", c.FailureRatio)
+	}
+	if c.FailureRatio > 0 {
+		opts = append(opts, FailureRatio(c.FailureRatio))
+	}
+	if c.MinRequests > 0 {
+		opts = append(opts, MinRequests(c.MinRequests))
+	}
+	if c.Window > 0 {
+		opts = append(opts, Window(c.Window))
+	}
+	if c.OpenTimeout > 0 {
+		opts = append(opts, OpenTimeout(c.OpenTimeout))
+	}
+	if c.Probes > 0 {
+		opts = append(opts, Probes(c.Probes))
+	}
+	if len(c.FailureCodes) > 0 {
+		codes := make([]yarpcerrors.Code, len(c.FailureCodes))
+		for i, name := range c.FailureCodes {
+			if err := codes[i].UnmarshalText([]byte(name)); err != nil {
+				return nil, err
+			}
+		}
+		opts = append(opts, FailureCodes(codes...))
+	}
+
+	return opts, nil
+}
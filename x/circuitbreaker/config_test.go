@@ -0,0 +1,114 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package circuitbreaker
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/internal/whitespace"
+	"go.uber.org/yarpc/yarpcconfig"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+func TestSpec(t *testing.T) {
+	tests := []struct {
+		desc     string
+		give     string
+		wantOpts options
+		wantErr  string
+	}{
+		{
+			desc:     "defaults",
+			give:     `{}`,
+			wantOpts: New().opts,
+		},
+		{
+			desc: "all attributes",
+			give: whitespace.Expand(`
+				failureRatio: 0.25
+				minRequests: 5
+				window: 1m
+				openTimeout: 30s
+				probes: 3
+				failureCodes: [unavailable, internal]
+			`),
+			wantOpts: New(
+				FailureRatio(0.25),
+				MinRequests(5),
+				Window(time.Minute),
+				OpenTimeout(30*time.Second),
+				Probes(3),
+				FailureCodes(yarpcerrors.CodeUnavailable, yarpcerrors.CodeInternal),
+			).opts,
+		},
+		{
+			desc:    "invalid ratio",
+			give:    `failureRatio: 2`,
+			wantErr: "failureRatio must be between 0 and 1, got 2",
+		},
+		{
+			desc:    "invalid code",
+			give:    `failureCodes: [sadness]`,
+			wantErr: "unknown code",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			cfg := yarpcconfig.New()
+			cfg.MustRegisterMiddleware(Spec())
+
+			give := "middleware:\n  outbound:\n    - circuit-breaker:\n" + indent(tt.give, "        ")
+			c, err := cfg.LoadConfigFromYAML("myservice", strings.NewReader(give))
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			unary, ok := c.OutboundMiddleware.Unary.(*Middleware)
+			require.True(t, ok, "expected circuit breaker, got %T", c.OutboundMiddleware.Unary)
+			oneway, ok := c.OutboundMiddleware.Oneway.(*Middleware)
+			require.True(t, ok, "expected circuit breaker, got %T", c.OutboundMiddleware.Oneway)
+
+			for _, mw := range []*Middleware{unary, oneway} {
+				got := mw.opts
+				got.clock, tt.wantOpts.clock = nil, nil
+				assert.Equal(t, tt.wantOpts, got)
+			}
+		})
+	}
+}
+
+func indent(s, prefix string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
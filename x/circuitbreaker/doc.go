@@ -0,0 +1,48 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package circuitbreaker provides outbound middleware that stops sending
+// requests to procedures that are failing.
+//
+// The middleware tracks the outcome of requests separately for each
+// service and procedure. Each of these has a circuit which starts closed,
+// allowing all requests through. When the ratio of failed requests within a
+// window reaches a threshold, the circuit opens and requests fail
+// immediately with CodeUnavailable, without reaching the transport. After a
+// timeout, the circuit becomes half-open and lets a small number of probe
+// requests through. The circuit closes again if all probes succeed, and
+// re-opens if any of them fails.
+//
+// 	breaker := circuitbreaker.New(
+// 		circuitbreaker.FailureRatio(0.5),
+// 		circuitbreaker.OpenTimeout(10*time.Second),
+// 	)
+// 	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+// 		Name: "myservice",
+// 		OutboundMiddleware: yarpc.OutboundMiddleware{
+// 			Unary:  breaker,
+// 			Oneway: breaker,
+// 		},
+// 		// ...
+// 	})
+//
+// The state of the circuits for each outbound is reported through the
+// dispatcher's introspection, and appears on the x/debug pages.
+package circuitbreaker
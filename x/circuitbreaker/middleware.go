@@ -0,0 +1,267 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package circuitbreaker
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/clock"
+	"go.uber.org/yarpc/internal/introspection"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+const _name = "circuit-breaker"
+
+// _defaultFailureCodes are the error codes counted as failures by default.
+// These indicate that the remote service is unhealthy or overloaded rather
+// than that the request itself was invalid.
+var _defaultFailureCodes = []yarpcerrors.Code{
+	yarpcerrors.CodeUnknown,
+	yarpcerrors.CodeDeadlineExceeded,
+	yarpcerrors.CodeResourceExhausted,
+	yarpcerrors.CodeInternal,
+	yarpcerrors.CodeUnavailable,
+}
+
+// Option customizes the behavior of the circuit breaker middleware.
+type Option func(*options)
+
+type options struct {
+	failureRatio float64
+	minRequests  int
+	window       time.Duration
+	openTimeout  time.Duration
+	probes       int
+	failureCodes map[yarpcerrors.Code]struct{}
+	clock        clock.Clock
+}
+
+// FailureRatio sets the ratio of failed requests, between 0 and 1, at which a
+// circuit opens.
+//
+// Defaults to 0.5.
+func FailureRatio(ratio float64) Option {
+	return func(opts *options) {
+		opts.failureRatio = ratio
+	}
+}
+
+// MinRequests sets the minimum number of requests that must be made within a
+// window before the circuit may open.
+//
+// Defaults to 20.
+func MinRequests(n int) Option {
+	return func(opts *options) {
+		opts.minRequests = n
+	}
+}
+
+// Window sets the duration over which failures are counted while a circuit
+// is closed. The counts are reset at the start of each window.
+//
+// Defaults to 10 seconds.
+func Window(d time.Duration) Option {
+	return func(opts *options) {
+		opts.window = d
+	}
+}
+
+// OpenTimeout sets how long a circuit stays open before probe requests are
+// let through.
+//
+// Defaults to 5 seconds.
+func OpenTimeout(d time.Duration) Option {
+	return func(opts *options) {
+		opts.openTimeout = d
+	}
+}
+
+// Probes sets the number of requests let through while a circuit is
+// half-open. The circuit closes once all of them succeed.
+//
+// Defaults to 1.
+func Probes(n int) Option {
+	return func(opts *options) {
+		opts.probes = n
+	}
+}
+
+// FailureCodes sets the error codes that are counted as failures. Errors
+// with any other code are counted as successes.
+//
+// Defaults to CodeUnknown, CodeDeadlineExceeded, CodeResourceExhausted,
+// CodeInternal and CodeUnavailable.
+func FailureCodes(codes ...yarpcerrors.Code) Option {
+	return func(opts *options) {
+		opts.failureCodes = make(map[yarpcerrors.Code]struct{}, len(codes))
+		for _, code := range codes {
+			opts.failureCodes[code] = struct{}{}
+		}
+	}
+}
+
+// withClock overrides the clock used to time windows and timeouts. This is
+// used for testing.
+func withClock(c clock.Clock) Option {
+	return func(opts *options) {
+		opts.clock = c
+	}
+}
+
+type circuitKey struct {
+	service   string
+	procedure string
+}
+
+// Middleware is a unary and oneway outbound middleware that fails requests
+// fast while the circuit for their service and procedure is open.
+type Middleware struct {
+	opts options
+
+	mu       sync.RWMutex
+	circuits map[circuitKey]*circuit
+}
+
+var (
+	_ middleware.UnaryOutbound                       = (*Middleware)(nil)
+	_ middleware.OnewayOutbound                      = (*Middleware)(nil)
+	_ introspection.IntrospectableOutboundMiddleware = (*Middleware)(nil)
+)
+
+// New builds a new circuit breaker middleware with the given options.
+func New(opts ...Option) *Middleware {
+	options := options{
+		failureRatio: 0.5,
+		minRequests:  20,
+		window:       10 * time.Second,
+		openTimeout:  5 * time.Second,
+		probes:       1,
+		clock:        clock.NewReal(),
+	}
+	FailureCodes(_defaultFailureCodes...)(&options)
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &Middleware{
+		opts:     options,
+		circuits: make(map[circuitKey]*circuit),
+	}
+}
+
+// Call sends the request through the given outbound unless the circuit for
+// its procedure is open.
+func (m *Middleware) Call(ctx context.Context, request *transport.Request, out transport.UnaryOutbound) (*transport.Response, error) {
+	c := m.circuit(request)
+	admitted, ok := c.allow()
+	if !ok {
+		return nil, openError(request)
+	}
+
+	res, err := out.Call(ctx, request)
+	c.record(admitted, m.failed(err))
+	return res, err
+}
+
+// CallOneway sends the request through the given outbound unless the
+// circuit for its procedure is open.
+func (m *Middleware) CallOneway(ctx context.Context, request *transport.Request, out transport.OnewayOutbound) (transport.Ack, error) {
+	c := m.circuit(request)
+	admitted, ok := c.allow()
+	if !ok {
+		return nil, openError(request)
+	}
+
+	ack, err := out.CallOneway(ctx, request)
+	c.record(admitted, m.failed(err))
+	return ack, err
+}
+
+// IntrospectOutbound reports the state of the circuits for all procedures of
+// the given service.
+func (m *Middleware) IntrospectOutbound(service string) []introspection.MiddlewareStatus {
+	m.mu.RLock()
+	var keys []circuitKey
+	for key := range m.circuits {
+		if key.service == service {
+			keys = append(keys, key)
+		}
+	}
+	circuits := make([]*circuit, len(keys))
+	sort.Slice(keys, func(i, j int) bool { return keys[i].procedure < keys[j].procedure })
+	for i, key := range keys {
+		circuits[i] = m.circuits[key]
+	}
+	m.mu.RUnlock()
+
+	counts := make(map[state]int)
+	entries := make([]introspection.MiddlewareEntryStatus, len(keys))
+	for i, c := range circuits {
+		s, desc := c.describe()
+		counts[s]++
+		entries[i] = introspection.MiddlewareEntryStatus{Key: keys[i].procedure, State: desc}
+	}
+
+	return []introspection.MiddlewareStatus{{
+		Name:    _name,
+		State:   fmt.Sprintf("%d open, %d half-open, %d closed", counts[open], counts[halfOpen], counts[closed]),
+		Entries: entries,
+	}}
+}
+
+func (m *Middleware) circuit(req *transport.Request) *circuit {
+	key := circuitKey{service: req.Service, procedure: req.Procedure}
+
+	m.mu.RLock()
+	c, ok := m.circuits[key]
+	m.mu.RUnlock()
+	if ok {
+		return c
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if c, ok := m.circuits[key]; ok {
+		return c
+	}
+	c = newCircuit(&m.opts)
+	m.circuits[key] = c
+	return c
+}
+
+func (m *Middleware) failed(err error) bool {
+	if err == nil {
+		return false
+	}
+	_, ok := m.opts.failureCodes[yarpcerrors.FromError(err).Code()]
+	return ok
+}
+
+func openError(req *transport.Request) error {
+	return yarpcerrors.UnavailableErrorf(
+		"circuit breaker is open for procedure %q of service %q", req.Procedure, req.Service)
+}
@@ -0,0 +1,219 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package circuitbreaker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/clock"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// fakeOutbound is a unary and oneway outbound which fails with err and
+// counts the requests it received.
+type fakeOutbound struct {
+	transport.Outbound
+
+	err   error
+	calls int
+}
+
+func (o *fakeOutbound) Call(context.Context, *transport.Request) (*transport.Response, error) {
+	o.calls++
+	if o.err != nil {
+		return nil, o.err
+	}
+	return &transport.Response{}, nil
+}
+
+func (o *fakeOutbound) CallOneway(context.Context, *transport.Request) (transport.Ack, error) {
+	o.calls++
+	return nil, o.err
+}
+
+func newRequest(procedure string) *transport.Request {
+	return &transport.Request{
+		Caller:    "caller",
+		Service:   "service",
+		Procedure: procedure,
+		Encoding:  "raw",
+	}
+}
+
+func TestCircuitTransitions(t *testing.T) {
+	fakeClock := clock.NewFake()
+	mw := New(
+		MinRequests(4),
+		FailureRatio(0.5),
+		Window(time.Minute),
+		OpenTimeout(10*time.Second),
+		Probes(2),
+		withClock(fakeClock),
+	)
+
+	var (
+		ctx         = context.Background()
+		healthy     = &fakeOutbound{}
+		unavailable = &fakeOutbound{err: yarpcerrors.UnavailableErrorf("great sadness")}
+		req         = newRequest("procedure")
+	)
+
+	call := func(out *fakeOutbound) error {
+		_, err := mw.Call(ctx, req, out)
+		return err
+	}
+
+	// Closed: two failures out of three requests are below the minimum
+	// number of requests.
+	require.NoError(t, call(healthy))
+	require.Error(t, call(unavailable))
+	require.Error(t, call(unavailable))
+	assert.Equal(t, "closed", circuitState(t, mw, "procedure"))
+
+	// The fourth request trips the circuit.
+	require.NoError(t, call(healthy))
+	assert.Equal(t, "open", circuitState(t, mw, "procedure"))
+
+	// Open: requests fail fast without reaching the outbound.
+	err := call(healthy)
+	assert.True(t, yarpcerrors.IsUnavailable(err), "expected unavailable error, got %v", err)
+	assert.Equal(t, 2, healthy.calls, "request must not reach the outbound")
+
+	// Half-open: a failed probe opens the circuit again.
+	fakeClock.Add(10 * time.Second)
+	require.Error(t, call(unavailable))
+	assert.Equal(t, "open", circuitState(t, mw, "procedure"))
+
+	// Half-open: all probes must succeed to close the circuit.
+	fakeClock.Add(10 * time.Second)
+	require.NoError(t, call(healthy))
+	assert.Equal(t, "half-open", circuitState(t, mw, "procedure"))
+	require.NoError(t, call(healthy))
+	assert.Equal(t, "closed", circuitState(t, mw, "procedure"))
+}
+
+func TestCircuitHalfOpenLimitsProbes(t *testing.T) {
+	fakeClock := clock.NewFake()
+	mw := New(MinRequests(1), OpenTimeout(time.Second), Probes(1), withClock(fakeClock))
+
+	c := mw.circuit(newRequest("procedure"))
+	admitted, ok := c.allow()
+	require.True(t, ok)
+	c.record(admitted, true)
+
+	fakeClock.Add(time.Second)
+	probe, ok := c.allow()
+	require.True(t, ok, "first probe must be allowed")
+	_, ok = c.allow()
+	assert.False(t, ok, "second concurrent probe must be rejected")
+
+	c.record(probe, false)
+	_, ok = c.allow()
+	assert.True(t, ok, "circuit must be closed after a successful probe")
+}
+
+func TestCircuitWindowReset(t *testing.T) {
+	fakeClock := clock.NewFake()
+	mw := New(MinRequests(2), FailureRatio(1), Window(time.Second), withClock(fakeClock))
+
+	unavailable := &fakeOutbound{err: yarpcerrors.UnavailableErrorf("great sadness")}
+	req := newRequest("procedure")
+
+	_, err := mw.Call(context.Background(), req, unavailable)
+	require.Error(t, err)
+
+	// The failure from the previous window is forgotten.
+	fakeClock.Add(time.Second)
+	_, err = mw.Call(context.Background(), req, unavailable)
+	require.Error(t, err)
+	assert.Equal(t, "closed", circuitState(t, mw, "procedure"))
+
+	_, err = mw.Call(context.Background(), req, unavailable)
+	require.Error(t, err)
+	assert.Equal(t, "open", circuitState(t, mw, "procedure"))
+}
+
+func TestFailureCodes(t *testing.T) {
+	mw := New(MinRequests(1), FailureCodes(yarpcerrors.CodeInternal))
+	req := newRequest("procedure")
+
+	_, err := mw.Call(context.Background(), req, &fakeOutbound{err: yarpcerrors.UnavailableErrorf("ignored")})
+	require.Error(t, err)
+	assert.Equal(t, "closed", circuitState(t, mw, "procedure"))
+
+	_, err = mw.Call(context.Background(), req, &fakeOutbound{err: yarpcerrors.InternalErrorf("counted")})
+	require.Error(t, err)
+	assert.Equal(t, "open", circuitState(t, mw, "procedure"))
+}
+
+func TestOneway(t *testing.T) {
+	mw := New(MinRequests(1))
+	req := newRequest("procedure")
+
+	unavailable := &fakeOutbound{err: yarpcerrors.UnavailableErrorf("great sadness")}
+	_, err := mw.CallOneway(context.Background(), req, unavailable)
+	require.Error(t, err)
+
+	_, err = mw.CallOneway(context.Background(), req, unavailable)
+	assert.True(t, yarpcerrors.IsUnavailable(err), "expected unavailable error, got %v", err)
+	assert.Equal(t, 1, unavailable.calls, "request must not reach the outbound")
+}
+
+func TestIntrospectOutbound(t *testing.T) {
+	mw := New(MinRequests(1))
+	ctx := context.Background()
+
+	_, err := mw.Call(ctx, newRequest("b"), &fakeOutbound{err: yarpcerrors.UnavailableErrorf("great sadness")})
+	require.Error(t, err)
+	_, err = mw.Call(ctx, newRequest("a"), &fakeOutbound{})
+	require.NoError(t, err)
+
+	other := newRequest("c")
+	other.Service = "other"
+	_, err = mw.Call(ctx, other, &fakeOutbound{})
+	require.NoError(t, err)
+
+	statuses := mw.IntrospectOutbound("service")
+	require.Len(t, statuses, 1)
+	assert.Equal(t, "circuit-breaker", statuses[0].Name)
+	assert.Equal(t, "1 open, 0 half-open, 1 closed", statuses[0].State)
+	require.Len(t, statuses[0].Entries, 2)
+	assert.Equal(t, "a", statuses[0].Entries[0].Key)
+	assert.Equal(t, "closed, 0 of 1 requests failed", statuses[0].Entries[0].State)
+	assert.Equal(t, "b", statuses[0].Entries[1].Key)
+	assert.Contains(t, statuses[0].Entries[1].State, "open since")
+}
+
+// circuitState returns the state of the circuit for the given procedure.
+func circuitState(t *testing.T, mw *Middleware, procedure string) string {
+	mw.mu.RLock()
+	c, ok := mw.circuits[circuitKey{service: "service", procedure: procedure}]
+	mw.mu.RUnlock()
+	require.True(t, ok, "no circuit for procedure %q", procedure)
+
+	s, _ := c.describe()
+	return s.String()
+}
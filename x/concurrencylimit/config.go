@@ -0,0 +1,130 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package concurrencylimit
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/yarpcconfig"
+)
+
+// Config describes how to build a concurrency limiting middleware.
+//
+//  maxConcurrency: 100
+//  maxConcurrencyPerCaller: 20
+//  adaptive:
+//    latencyThreshold: 50ms
+//    minConcurrency: 10
+//    backoffRatio: 0.9
+//
+// At least one of maxConcurrency and maxConcurrencyPerCaller must be set.
+// Adaptive limiting is enabled if the adaptive section is present, and
+// requires maxConcurrency.
+type Config struct {
+	MaxConcurrency          int             `config:"maxConcurrency"`
+	MaxConcurrencyPerCaller int             `config:"maxConcurrencyPerCaller"`
+	Adaptive                *AdaptiveConfig `config:"adaptive"`
+}
+
+// AdaptiveConfig configures adaptive limiting. Attributes that are left
+// unset use the defaults documented on the corresponding options.
+type AdaptiveConfig struct {
+	LatencyThreshold time.Duration `config:"latencyThreshold"`
+	MinConcurrency   int           `config:"minConcurrency"`
+	BackoffRatio     float64       `config:"backoffRatio"`
+}
+
+// Spec returns a configuration specification for the concurrency limiting
+// middleware, making it possible to enable it for all unary and stream
+// inbounds.
+//
+//  cfg := yarpcconfig.New()
+//  cfg.MustRegisterMiddleware(concurrencylimit.Spec())
+//
+// This enables the concurrency limiting middleware:
+//
+//  middleware:
+//    inbound:
+//      - concurrency-limit:
+//          maxConcurrency: 100
+//
+// Unary requests and streams are limited separately. See Config for the
+// full set of attributes.
+func Spec() yarpcconfig.MiddlewareSpec {
+	build := func(cfg Config) (*Middleware, error) {
+		opts, err := cfg.options()
+		if err != nil {
+			return nil, err
+		}
+		return New(opts...), nil
+	}
+
+	return yarpcconfig.MiddlewareSpec{
+		Name: _name,
+		BuildUnaryInbound: func(cfg Config, k *yarpcconfig.Kit) (middleware.UnaryInbound, error) {
+			return build(cfg)
+		},
+		BuildStreamInbound: func(cfg Config, k *yarpcconfig.Kit) (middleware.StreamInbound, error) {
+			return build(cfg)
+		},
+	}
+}
+
+func (c Config) options() ([]Option, error) {
+	if c.MaxConcurrency <= 0 && c.MaxConcurrencyPerCaller <= 0 {
+		return nil, errors.New("at least one of maxConcurrency and maxConcurrencyPerCaller is required")
+	}
+
+	opts := []Option{
+		MaxConcurrency(c.MaxConcurrency),
+		MaxConcurrencyPerCaller(c.MaxConcurrencyPerCaller),
+	}
+
+	a := c.Adaptive
+	if a == nil {
+		return opts, nil
+	}
+	if c.MaxConcurrency <= 0 {
+		return nil, errors.New("adaptive limiting requires maxConcurrency")
+	}
+	if a.LatencyThreshold <= 0 {
+		return nil, errors.New("adaptive.latencyThreshold is required")
+	}
+	if a.MinConcurrency > c.MaxConcurrency {
+		return nil, fmt.Errorf("adaptive.minConcurrency (%v) must not exceed maxConcurrency (%v)",
+			a.MinConcurrency, c.MaxConcurrency)
+	}
+	if a.BackoffRatio < 0 || a.BackoffRatio >= 1 {
+		return nil, fmt.Errorf("adaptive.backoffRatio must be between 0 and 1, got %v", a.BackoffRatio)
+	}
+
+	opts = append(opts, Adaptive(a.LatencyThreshold))
+	if a.MinConcurrency > 0 {
+		opts = append(opts, MinConcurrency(a.MinConcurrency))
+	}
+	if a.BackoffRatio > 0 {
+		opts = append(opts, BackoffRatio(a.BackoffRatio))
+	}
+	return opts, nil
+}
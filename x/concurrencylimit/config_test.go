@@ -0,0 +1,146 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package concurrencylimit
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/internal/whitespace"
+	"go.uber.org/yarpc/yarpcconfig"
+)
+
+func TestSpec(t *testing.T) {
+	tests := []struct {
+		desc     string
+		give     string
+		wantOpts options
+		wantErr  string
+	}{
+		{
+			desc:     "static",
+			give:     `maxConcurrency: 100`,
+			wantOpts: New(MaxConcurrency(100)).opts,
+		},
+		{
+			desc: "adaptive",
+			give: whitespace.Expand(`
+				maxConcurrency: 100
+				maxConcurrencyPerCaller: 20
+				adaptive:
+				  latencyThreshold: 50ms
+				  minConcurrency: 10
+				  backoffRatio: 0.8
+			`),
+			wantOpts: New(
+				MaxConcurrency(100),
+				MaxConcurrencyPerCaller(20),
+				Adaptive(50*time.Millisecond),
+				MinConcurrency(10),
+				BackoffRatio(0.8),
+			).opts,
+		},
+		{
+			desc:    "no limits",
+			give:    `{}`,
+			wantErr: "at least one of maxConcurrency and maxConcurrencyPerCaller is required",
+		},
+		{
+			desc: "adaptive without maxConcurrency",
+			give: whitespace.Expand(`
+				maxConcurrencyPerCaller: 20
+				adaptive:
+				  latencyThreshold: 50ms
+			`),
+			wantErr: "adaptive limiting requires maxConcurrency",
+		},
+		{
+			desc: "adaptive without threshold",
+			give: whitespace.Expand(`
+				maxConcurrency: 20
+				adaptive:
+				  minConcurrency: 5
+			`),
+			wantErr: "adaptive.latencyThreshold is required",
+		},
+		{
+			desc: "minConcurrency above maxConcurrency",
+			give: whitespace.Expand(`
+				maxConcurrency: 20
+				adaptive:
+				  latencyThreshold: 50ms
+				  minConcurrency: 30
+			`),
+			wantErr: "adaptive.minConcurrency (30) must not exceed maxConcurrency (20)",
+		},
+		{
+			desc: "invalid backoff ratio",
+			give: whitespace.Expand(`
+				maxConcurrency: 20
+				adaptive:
+				  latencyThreshold: 50ms
+				  backoffRatio: 1.5
+			`),
+			wantErr: "adaptive.backoffRatio must be between 0 and 1, got 1.5",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			cfg := yarpcconfig.New()
+			cfg.MustRegisterMiddleware(Spec())
+
+			give := "middleware:\n  inbound:\n    - concurrency-limit:\n" + indent(tt.give, "        ")
+			c, err := cfg.LoadConfigFromYAML("myservice", strings.NewReader(give))
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			unary, ok := c.InboundMiddleware.Unary.(*Middleware)
+			require.True(t, ok, "expected concurrency limiter, got %T", c.InboundMiddleware.Unary)
+			stream, ok := c.InboundMiddleware.Stream.(*Middleware)
+			require.True(t, ok, "expected concurrency limiter, got %T", c.InboundMiddleware.Stream)
+			assert.Nil(t, c.InboundMiddleware.Oneway)
+
+			for _, mw := range []*Middleware{unary, stream} {
+				got := mw.opts
+				got.clock, tt.wantOpts.clock = nil, nil
+				assert.Equal(t, tt.wantOpts, got)
+			}
+		})
+	}
+}
+
+func indent(s, prefix string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package concurrencylimit provides inbound middleware that sheds load by
+// limiting the number of requests handled concurrently.
+//
+// The middleware caps the number of in-flight requests for each procedure
+// and, optionally, for each caller across all procedures. Requests that
+// would exceed either cap are rejected immediately with
+// CodeResourceExhausted instead of queueing behind requests that are
+// already being handled.
+//
+// 	limiter := concurrencylimit.New(
+// 		concurrencylimit.MaxConcurrency(100),
+// 		concurrencylimit.MaxConcurrencyPerCaller(20),
+// 	)
+// 	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+// 		Name: "myservice",
+// 		InboundMiddleware: yarpc.InboundMiddleware{
+// 			Unary:  limiter,
+// 			Stream: limiter,
+// 		},
+// 		// ...
+// 	})
+//
+// In adaptive mode, the per-procedure limit starts at MaxConcurrency and
+// follows an additive-increase/multiplicative-decrease (AIMD) scheme: it
+// shrinks whenever a unary request takes longer than a latency threshold or
+// times out, and grows back by one for each fast request made while the
+// procedure is busy.
+//
+// 	limiter := concurrencylimit.New(
+// 		concurrencylimit.MaxConcurrency(100),
+// 		concurrencylimit.Adaptive(50*time.Millisecond),
+// 	)
+//
+// Streams count against the limits for as long as their handler runs but
+// do not affect the adaptive limit.
+//
+// Shed requests are counted by the dispatcher's observability middleware
+// in the shed_requests metric for their edge.
+package concurrencylimit
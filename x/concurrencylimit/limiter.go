@@ -0,0 +1,109 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package concurrencylimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// limiter tracks the in-flight requests against a single limit.
+type limiter struct {
+	mu       sync.Mutex
+	inflight int
+	limit    float64
+
+	// retired is set once the limiter has been removed from its map.
+	// Requests that raced with the removal must look up a new limiter.
+	retired bool
+}
+
+func newLimiter(limit int) *limiter {
+	return &limiter{limit: float64(limit)}
+}
+
+// acquire reserves a slot if the limit has not been reached and reports
+// the number of requests that were already in flight.
+//
+// acquire always fails on a retired limiter; callers that may race with
+// retire must check for that with isRetired.
+func (l *limiter) acquire() (inflight int, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.retired || l.inflight >= int(l.limit) {
+		return l.inflight, false
+	}
+	inflight = l.inflight
+	l.inflight++
+	return inflight, true
+}
+
+func (l *limiter) release() {
+	l.mu.Lock()
+	l.inflight--
+	l.mu.Unlock()
+}
+
+// retire marks the limiter as retired if no requests are in flight,
+// reporting whether it did so.
+func (l *limiter) retire() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.inflight > 0 {
+		return false
+	}
+	l.retired = true
+	return true
+}
+
+func (l *limiter) isRetired() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.retired
+}
+
+// adapt adjusts the limit based on the outcome of a request that was
+// admitted with the given number of requests already in flight.
+//
+// The limit is cut by the backoff ratio if the request was slow. Otherwise,
+// it grows by one if the procedure was at least half busy; idle procedures
+// don't tell us anything about how much more load they can take.
+func (l *limiter) adapt(opts *options, inflight int, latency time.Duration, timedOut bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if timedOut || latency > opts.latencyThreshold {
+		l.limit = math.Max(float64(opts.minConcurrency), l.limit*opts.backoffRatio)
+		return
+	}
+	if float64(inflight*2) >= l.limit {
+		l.limit = math.Min(float64(opts.maxConcurrency), l.limit+1)
+	}
+}
+
+func (l *limiter) currentLimit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit)
+}
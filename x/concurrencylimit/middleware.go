@@ -0,0 +1,314 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package concurrencylimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/clock"
+	"go.uber.org/yarpc/internal/observability"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+const _name = "concurrency-limit"
+
+// _minSweepSize is the number of caller limiters below which we don't
+// bother removing idle ones.
+const _minSweepSize = 1024
+
+// Option customizes the behavior of the concurrency limiting middleware.
+type Option func(*options)
+
+type options struct {
+	maxConcurrency          int
+	maxConcurrencyPerCaller int
+	adaptive                bool
+	latencyThreshold        time.Duration
+	minConcurrency          int
+	backoffRatio            float64
+	clock                   clock.Clock
+}
+
+// MaxConcurrency sets the maximum number of requests handled concurrently
+// for each procedure. In adaptive mode, this is the initial and the largest
+// limit.
+//
+// Defaults to 0, which does not limit procedures.
+func MaxConcurrency(n int) Option {
+	return func(opts *options) {
+		opts.maxConcurrency = n
+	}
+}
+
+// MaxConcurrencyPerCaller sets the maximum number of requests handled
+// concurrently for each caller, across all procedures. This prevents a
+// single caller from using up the capacity of the service.
+//
+// Defaults to 0, which does not limit callers.
+func MaxConcurrencyPerCaller(n int) Option {
+	return func(opts *options) {
+		opts.maxConcurrencyPerCaller = n
+	}
+}
+
+// Adaptive enables adaptive limiting of procedures. The limit for a
+// procedure shrinks whenever a request takes longer than the given latency
+// threshold or fails with CodeDeadlineExceeded.
+//
+// Adaptive limiting has no effect unless MaxConcurrency is also set.
+func Adaptive(latencyThreshold time.Duration) Option {
+	return func(opts *options) {
+		opts.adaptive = true
+		opts.latencyThreshold = latencyThreshold
+	}
+}
+
+// MinConcurrency sets the limit below which adaptive limiting will not
+// shrink the limit of a procedure.
+//
+// Defaults to 1.
+func MinConcurrency(n int) Option {
+	return func(opts *options) {
+		opts.minConcurrency = n
+	}
+}
+
+// BackoffRatio sets the ratio, between 0 and 1, by which adaptive limiting
+// multiplies the limit of a procedure when a request is slow.
+//
+// Defaults to 0.9.
+func BackoffRatio(ratio float64) Option {
+	return func(opts *options) {
+		opts.backoffRatio = ratio
+	}
+}
+
+// withClock overrides the clock used to measure latency. This is used for
+// testing.
+func withClock(c clock.Clock) Option {
+	return func(opts *options) {
+		opts.clock = c
+	}
+}
+
+type procedureKey struct {
+	service   string
+	procedure string
+}
+
+// Middleware is a unary and stream inbound middleware that rejects requests
+// once too many are in flight.
+type Middleware struct {
+	opts options
+
+	mu         sync.RWMutex
+	procedures map[procedureKey]*limiter
+	callers    map[string]*limiter
+
+	// sweepAt is the number of caller limiters at which we next remove
+	// idle ones.
+	sweepAt int
+}
+
+var (
+	_ middleware.UnaryInbound  = (*Middleware)(nil)
+	_ middleware.StreamInbound = (*Middleware)(nil)
+)
+
+// New builds a new concurrency limiting middleware with the given options.
+func New(opts ...Option) *Middleware {
+	options := options{
+		minConcurrency: 1,
+		backoffRatio:   0.9,
+		clock:          clock.NewReal(),
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &Middleware{
+		opts:       options,
+		procedures: make(map[procedureKey]*limiter),
+		callers:    make(map[string]*limiter),
+		sweepAt:    _minSweepSize,
+	}
+}
+
+// Handle handles the request with the given handler unless too many
+// requests are already in flight for its procedure or caller.
+func (m *Middleware) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter, h transport.UnaryHandler) error {
+	proc, caller, inflight, err := m.acquire(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer m.release(proc, caller)
+
+	start := m.opts.clock.Now()
+	err = h.Handle(ctx, req, resw)
+	if proc != nil && m.opts.adaptive {
+		timedOut := err != nil && yarpcerrors.FromError(err).Code() == yarpcerrors.CodeDeadlineExceeded
+		proc.adapt(&m.opts, inflight, m.opts.clock.Now().Sub(start), timedOut)
+	}
+	return err
+}
+
+// HandleStream handles the stream with the given handler unless too many
+// requests are already in flight for its procedure or caller.
+func (m *Middleware) HandleStream(s *transport.ServerStream, h transport.StreamHandler) error {
+	proc, caller, _, err := m.acquire(s.Context(), s.Request().Meta.ToRequest())
+	if err != nil {
+		return err
+	}
+	defer m.release(proc, caller)
+
+	return h.HandleStream(s)
+}
+
+// Limit returns the current concurrency limit for the given procedure of
+// the given service, or 0 if the procedure is not limited.
+func (m *Middleware) Limit(service, procedure string) int {
+	if m.opts.maxConcurrency <= 0 {
+		return 0
+	}
+
+	m.mu.RLock()
+	l, ok := m.procedures[procedureKey{service: service, procedure: procedure}]
+	m.mu.RUnlock()
+	if !ok {
+		return m.opts.maxConcurrency
+	}
+	return l.currentLimit()
+}
+
+// acquire reserves a slot for the request with the limiters for its
+// procedure and caller, returning a ResourceExhausted error if either is
+// full. The limiters are nil if the corresponding limit is not enabled.
+func (m *Middleware) acquire(ctx context.Context, req *transport.Request) (proc, caller *limiter, inflight int, err error) {
+	if m.opts.maxConcurrencyPerCaller > 0 {
+		var ok bool
+		if caller, ok = m.acquireCaller(req.Caller); !ok {
+			observability.MarkShed(ctx)
+			return nil, nil, 0, yarpcerrors.ResourceExhaustedErrorf(
+				"too many concurrent requests from caller %q", req.Caller)
+		}
+	}
+
+	if m.opts.maxConcurrency > 0 {
+		proc = m.procedureLimiter(req)
+		var ok bool
+		if inflight, ok = proc.acquire(); !ok {
+			if caller != nil {
+				caller.release()
+			}
+			observability.MarkShed(ctx)
+			return nil, nil, 0, yarpcerrors.ResourceExhaustedErrorf(
+				"too many concurrent requests for procedure %q of service %q", req.Procedure, req.Service)
+		}
+	}
+
+	return proc, caller, inflight, nil
+}
+
+func (m *Middleware) release(proc, caller *limiter) {
+	if proc != nil {
+		proc.release()
+	}
+	if caller != nil {
+		caller.release()
+	}
+}
+
+func (m *Middleware) procedureLimiter(req *transport.Request) *limiter {
+	key := procedureKey{service: req.Service, procedure: req.Procedure}
+
+	m.mu.RLock()
+	l, ok := m.procedures[key]
+	m.mu.RUnlock()
+	if ok {
+		return l
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if l, ok := m.procedures[key]; ok {
+		return l
+	}
+	l = newLimiter(m.opts.maxConcurrency)
+	m.procedures[key] = l
+	return l
+}
+
+// acquireCaller reserves a slot with the limiter for the given caller.
+func (m *Middleware) acquireCaller(name string) (*limiter, bool) {
+	for {
+		l := m.callerLimiter(name)
+		if _, ok := l.acquire(); ok {
+			return l, true
+		}
+		// The limiter may have been swept between the lookup and the
+		// acquire, in which case its replacement decides.
+		if !l.isRetired() {
+			return nil, false
+		}
+	}
+}
+
+func (m *Middleware) callerLimiter(name string) *limiter {
+	m.mu.RLock()
+	l, ok := m.callers[name]
+	m.mu.RUnlock()
+	if ok {
+		return l
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if l, ok := m.callers[name]; ok {
+		return l
+	}
+	if len(m.callers) >= m.sweepAt {
+		m.sweep()
+	}
+	l = newLimiter(m.opts.maxConcurrencyPerCaller)
+	m.callers[name] = l
+	return l
+}
+
+// sweep drops caller limiters with no requests in flight. These would be
+// re-created in the same state if needed, so this only bounds memory use
+// when callers send many distinct caller names.
+//
+// Must be called with the lock held.
+func (m *Middleware) sweep() {
+	for name, l := range m.callers {
+		if l.retire() {
+			delete(m.callers, name)
+		}
+	}
+	m.sweepAt = 2 * len(m.callers)
+	if m.sweepAt < _minSweepSize {
+		m.sweepAt = _minSweepSize
+	}
+}
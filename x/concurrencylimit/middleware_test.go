@@ -0,0 +1,298 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package concurrencylimit
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/transport/transporttest"
+	"go.uber.org/yarpc/internal/clock"
+	"go.uber.org/yarpc/internal/observability"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/zap"
+)
+
+// blockingHandler blocks each request until it is released.
+type blockingHandler struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func newBlockingHandler() *blockingHandler {
+	return &blockingHandler{
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+}
+
+func (h *blockingHandler) Handle(context.Context, *transport.Request, transport.ResponseWriter) error {
+	h.started <- struct{}{}
+	<-h.release
+	return nil
+}
+
+func (h *blockingHandler) HandleStream(*transport.ServerStream) error {
+	h.started <- struct{}{}
+	<-h.release
+	return nil
+}
+
+// slowHandler advances the clock by a fixed latency for each request.
+type slowHandler struct {
+	clock   *clock.FakeClock
+	latency time.Duration
+	err     error
+}
+
+func (h slowHandler) Handle(context.Context, *transport.Request, transport.ResponseWriter) error {
+	h.clock.Add(h.latency)
+	return h.err
+}
+
+type fakeStream struct {
+	transport.Stream
+
+	ctx context.Context
+	req *transport.StreamRequest
+}
+
+func (s fakeStream) Context() context.Context          { return s.ctx }
+func (s fakeStream) Request() *transport.StreamRequest { return s.req }
+
+func request(caller, procedure string) *transport.Request {
+	return &transport.Request{
+		Caller:    caller,
+		Service:   "service",
+		Encoding:  "raw",
+		Procedure: procedure,
+	}
+}
+
+// handleAsync starts handling the request in the background and waits
+// until it has reached the handler.
+func handleAsync(t *testing.T, mw *Middleware, h *blockingHandler, req *transport.Request) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		done <- mw.Handle(context.Background(), req, &transporttest.FakeResponseWriter{}, h)
+	}()
+	select {
+	case <-h.started:
+	case err := <-done:
+		t.Fatalf("request for %q from %q was not admitted: %v", req.Procedure, req.Caller, err)
+	}
+	return done
+}
+
+func assertShed(t *testing.T, err error) {
+	require.Error(t, err, "expected request to be rejected")
+	assert.Equal(t, yarpcerrors.CodeResourceExhausted, yarpcerrors.FromError(err).Code(),
+		"unexpected error code: %v", err)
+}
+
+func TestMaxConcurrency(t *testing.T) {
+	mw := New(MaxConcurrency(2))
+	h := newBlockingHandler()
+
+	first := handleAsync(t, mw, h, request("caller", "foo"))
+	second := handleAsync(t, mw, h, request("caller", "foo"))
+
+	err := mw.Handle(context.Background(), request("caller", "foo"), &transporttest.FakeResponseWriter{}, h)
+	assertShed(t, err)
+	assert.Contains(t, err.Error(), `procedure "foo" of service "service"`)
+
+	// Other procedures are limited separately.
+	other := handleAsync(t, mw, h, request("caller", "bar"))
+
+	h.release <- struct{}{}
+	require.NoError(t, <-first)
+
+	third := handleAsync(t, mw, h, request("caller", "foo"))
+
+	close(h.release)
+	for _, done := range []<-chan error{second, other, third} {
+		assert.NoError(t, <-done)
+	}
+}
+
+func TestMaxConcurrencyPerCaller(t *testing.T) {
+	mw := New(MaxConcurrencyPerCaller(1))
+	h := newBlockingHandler()
+
+	first := handleAsync(t, mw, h, request("alice", "foo"))
+
+	// The caller limit applies across procedures.
+	err := mw.Handle(context.Background(), request("alice", "bar"), &transporttest.FakeResponseWriter{}, h)
+	assertShed(t, err)
+	assert.Contains(t, err.Error(), `caller "alice"`)
+
+	second := handleAsync(t, mw, h, request("bob", "foo"))
+
+	close(h.release)
+	assert.NoError(t, <-first)
+	assert.NoError(t, <-second)
+}
+
+func TestIdleCallersSwept(t *testing.T) {
+	mw := New(MaxConcurrencyPerCaller(1))
+	h := newBlockingHandler()
+	idle := slowHandler{clock: clock.NewFake()}
+	handle := func(caller string) error {
+		return mw.Handle(context.Background(), request(caller, "foo"), &transporttest.FakeResponseWriter{}, idle)
+	}
+
+	busy := handleAsync(t, mw, h, request("busy", "foo"))
+	for i := 0; i < _minSweepSize-1; i++ {
+		require.NoError(t, handle(fmt.Sprintf("caller-%d", i)))
+	}
+
+	// The next new caller sweeps all callers without requests in flight.
+	require.NoError(t, handle("another"))
+	mw.mu.RLock()
+	assert.Len(t, mw.callers, 2)
+	mw.mu.RUnlock()
+
+	// Callers with requests in flight are still limited.
+	assertShed(t, handle("busy"))
+
+	close(h.release)
+	assert.NoError(t, <-busy)
+	assert.NoError(t, handle("busy"))
+}
+
+func TestCallerSlotReleasedWhenProcedureFull(t *testing.T) {
+	mw := New(MaxConcurrency(1), MaxConcurrencyPerCaller(1))
+	h := newBlockingHandler()
+
+	first := handleAsync(t, mw, h, request("alice", "foo"))
+
+	err := mw.Handle(context.Background(), request("bob", "foo"), &transporttest.FakeResponseWriter{}, h)
+	assertShed(t, err)
+
+	// bob's rejected request must not hold on to his caller slot.
+	second := handleAsync(t, mw, h, request("bob", "bar"))
+
+	close(h.release)
+	assert.NoError(t, <-first)
+	assert.NoError(t, <-second)
+}
+
+func TestStreamConcurrency(t *testing.T) {
+	mw := New(MaxConcurrency(1))
+	h := newBlockingHandler()
+
+	newStream := func() *transport.ServerStream {
+		s, err := transport.NewServerStream(fakeStream{
+			ctx: context.Background(),
+			req: &transport.StreamRequest{Meta: request("caller", "foo").ToRequestMeta()},
+		})
+		require.NoError(t, err)
+		return s
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- mw.HandleStream(newStream(), h) }()
+	<-h.started
+
+	assertShed(t, mw.HandleStream(newStream(), h))
+
+	close(h.release)
+	assert.NoError(t, <-done)
+	go func() { done <- mw.HandleStream(newStream(), h) }()
+	<-h.started
+	assert.NoError(t, <-done)
+}
+
+func TestAdaptive(t *testing.T) {
+	fakeClock := clock.NewFake()
+	mw := New(
+		MaxConcurrency(4),
+		Adaptive(10*time.Millisecond),
+		MinConcurrency(2),
+		BackoffRatio(0.5),
+		withClock(fakeClock),
+	)
+	handle := func(h transport.UnaryHandler) error {
+		return mw.Handle(context.Background(), request("caller", "foo"), &transporttest.FakeResponseWriter{}, h)
+	}
+
+	assert.Equal(t, 4, mw.Limit("service", "foo"))
+
+	require.NoError(t, handle(slowHandler{clock: fakeClock, latency: 5 * time.Millisecond}))
+	assert.Equal(t, 4, mw.Limit("service", "foo"), "fast requests on an idle procedure must not change the limit")
+
+	require.NoError(t, handle(slowHandler{clock: fakeClock, latency: 20 * time.Millisecond}))
+	assert.Equal(t, 2, mw.Limit("service", "foo"), "slow requests must shrink the limit")
+
+	timeout := yarpcerrors.DeadlineExceededErrorf("too slow")
+	require.Equal(t, timeout, handle(slowHandler{clock: fakeClock, err: timeout}))
+	assert.Equal(t, 2, mw.Limit("service", "foo"), "limit must not shrink below the minimum")
+
+	// A fast request made while the procedure is busy grows the limit.
+	h := newBlockingHandler()
+	busy := make(chan error, 1)
+	go func() { busy <- handle(h) }()
+	<-h.started
+	require.NoError(t, handle(slowHandler{clock: fakeClock, latency: time.Millisecond}))
+	assert.Equal(t, 3, mw.Limit("service", "foo"))
+
+	close(h.release)
+	require.NoError(t, <-busy)
+	assert.Equal(t, 3, mw.Limit("service", "foo"), "idle requests must not grow the limit")
+}
+
+func TestLimitDisabled(t *testing.T) {
+	mw := New(MaxConcurrencyPerCaller(1))
+	assert.Equal(t, 0, mw.Limit("service", "foo"))
+}
+
+func TestShedMetrics(t *testing.T) {
+	root := metrics.New()
+	observer := observability.NewMiddleware(zap.NewNop(), root.Scope(), observability.NewNopContextExtractor())
+	mw := New(MaxConcurrency(1))
+	h := newBlockingHandler()
+
+	handler := middleware.ApplyUnaryInbound(h, mw)
+	done := make(chan error, 1)
+	go func() {
+		done <- observer.Handle(context.Background(), request("caller", "foo"), &transporttest.FakeResponseWriter{}, handler)
+	}()
+	<-h.started
+
+	assertShed(t, observer.Handle(context.Background(), request("caller", "foo"), &transporttest.FakeResponseWriter{}, handler))
+	close(h.release)
+	require.NoError(t, <-done)
+
+	var shed int64 = -1
+	for _, c := range root.Snapshot().Counters {
+		if c.Name == "shed_requests" {
+			shed = c.Value
+		}
+	}
+	assert.Equal(t, int64(1), shed, "unexpected number of shed requests")
+}
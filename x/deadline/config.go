@@ -0,0 +1,77 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package deadline
+
+import (
+	"errors"
+	"time"
+
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/yarpcconfig"
+)
+
+// Config describes how to build a deadline middleware.
+//
+//  slack: 5ms
+//
+// All attributes are optional.
+type Config struct {
+	Slack time.Duration `config:"slack"`
+}
+
+// Spec returns a configuration specification for the deadline middleware,
+// making it possible to enable it for all unary, oneway and stream
+// inbounds.
+//
+//  cfg := yarpcconfig.New()
+//  cfg.MustRegisterMiddleware(deadline.Spec())
+//
+// This rejects requests with 5 milliseconds or less left before their
+// deadline and shortens the deadline of all other requests by 5
+// milliseconds:
+//
+//  middleware:
+//    inbound:
+//      - deadline:
+//          slack: 5ms
+//
+// See Config for the full set of attributes.
+func Spec() yarpcconfig.MiddlewareSpec {
+	build := func(cfg Config) (*Middleware, error) {
+		if cfg.Slack < 0 {
+			return nil, errors.New("slack must not be negative")
+		}
+		return New(Slack(cfg.Slack)), nil
+	}
+
+	return yarpcconfig.MiddlewareSpec{
+		Name: _name,
+		BuildUnaryInbound: func(cfg Config, k *yarpcconfig.Kit) (middleware.UnaryInbound, error) {
+			return build(cfg)
+		},
+		BuildOnewayInbound: func(cfg Config, k *yarpcconfig.Kit) (middleware.OnewayInbound, error) {
+			return build(cfg)
+		},
+		BuildStreamInbound: func(cfg Config, k *yarpcconfig.Kit) (middleware.StreamInbound, error) {
+			return build(cfg)
+		},
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package deadline provides inbound middleware that budgets the time left
+// for requests.
+//
+// Every transport turns the deadline sent by the caller into the deadline of
+// the request's context. HTTP inbounds understand both the Context-TTL-MS
+// and Grpc-Timeout headers, gRPC inbounds understand both the grpc-timeout
+// and context-ttl-ms headers, and TChannel inbounds use the TTL of the call.
+// When a request carries more than one deadline, the earliest applies.
+//
+// The middleware in this package shortens that deadline by a network slack,
+// leaving time for the response to reach the caller, and rejects requests
+// whose deadline leaves no time to handle them with CodeDeadlineExceeded
+// before their handler is invoked. Because outbound requests made by the
+// handler inherit the shortened deadline, the slack is subtracted again on
+// each hop.
+//
+// 	budget := deadline.New(deadline.Slack(5 * time.Millisecond))
+// 	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+// 		Name: "myservice",
+// 		InboundMiddleware: yarpc.InboundMiddleware{
+// 			Unary:  budget,
+// 			Oneway: budget,
+// 			Stream: budget,
+// 		},
+// 		// ...
+// 	})
+//
+// Requests without a deadline, such as most streams, are not affected.
+package deadline
@@ -0,0 +1,149 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package deadline
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+const _name = "deadline"
+
+// Option customizes the behavior of the deadline middleware.
+type Option func(*options)
+
+type options struct {
+	slack time.Duration
+}
+
+// Slack sets the time that is subtracted from the deadline of each request
+// to account for sending the response back to the caller. Requests with no
+// more than this much time left are rejected.
+//
+// Defaults to no slack, which only rejects requests whose deadline has
+// already passed.
+func Slack(d time.Duration) Option {
+	return func(opts *options) {
+		opts.slack = d
+	}
+}
+
+// Middleware is a unary, oneway and stream inbound middleware that shortens
+// the deadline of requests by the network slack and rejects requests with no
+// time left.
+type Middleware struct {
+	opts options
+}
+
+var (
+	_ middleware.UnaryInbound  = (*Middleware)(nil)
+	_ middleware.OnewayInbound = (*Middleware)(nil)
+	_ middleware.StreamInbound = (*Middleware)(nil)
+)
+
+// New builds a new deadline middleware.
+func New(opts ...Option) *Middleware {
+	var options options
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &Middleware{opts: options}
+}
+
+// Handle implements middleware.UnaryInbound.
+func (m *Middleware) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter, h transport.UnaryHandler) error {
+	ctx, cancel, err := m.budget(ctx, req.Service, req.Procedure)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	return h.Handle(ctx, req, resw)
+}
+
+// HandleOneway implements middleware.OnewayInbound.
+func (m *Middleware) HandleOneway(ctx context.Context, req *transport.Request, h transport.OnewayHandler) error {
+	ctx, cancel, err := m.budget(ctx, req.Service, req.Procedure)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	return h.HandleOneway(ctx, req)
+}
+
+// HandleStream implements middleware.StreamInbound.
+func (m *Middleware) HandleStream(s *transport.ServerStream, h transport.StreamHandler) error {
+	meta := s.Request().Meta
+	ctx, cancel, err := m.budget(s.Context(), meta.Service, meta.Procedure)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	if ctx == s.Context() {
+		return h.HandleStream(s)
+	}
+
+	wrapped, err := transport.NewServerStream(budgetStream{ServerStream: s, ctx: ctx})
+	if err != nil {
+		return err
+	}
+	return h.HandleStream(wrapped)
+}
+
+// budget returns a context whose deadline leaves the network slack, or an
+// error if the request has no time left.
+func (m *Middleware) budget(ctx context.Context, service, procedure string) (_ context.Context, cancel func(), _ error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return ctx, func() {}, nil
+	}
+
+	remaining := time.Until(deadline)
+	if remaining <= 0 {
+		return ctx, func() {}, yarpcerrors.DeadlineExceededErrorf(
+			"request to procedure %q of service %q arrived after its deadline", procedure, service)
+	}
+	if remaining <= m.opts.slack {
+		return ctx, func() {}, yarpcerrors.DeadlineExceededErrorf(
+			"request to procedure %q of service %q arrived with %v left, which does not cover the network slack of %v",
+			procedure, service, remaining, m.opts.slack)
+	}
+	if m.opts.slack == 0 {
+		return ctx, func() {}, nil
+	}
+	ctx, cancel = context.WithDeadline(ctx, deadline.Add(-m.opts.slack))
+	return ctx, cancel, nil
+}
+
+// budgetStream overrides the context of a server stream with one that
+// leaves the network slack.
+type budgetStream struct {
+	*transport.ServerStream
+
+	ctx context.Context
+}
+
+func (s budgetStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,189 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package deadline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/transport/transporttest"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// deadlineHandler records the deadlines seen by handlers.
+type deadlineHandler struct {
+	deadlines []time.Time
+	called    int
+}
+
+func (h *deadlineHandler) record(ctx context.Context) {
+	h.called++
+	if d, ok := ctx.Deadline(); ok {
+		h.deadlines = append(h.deadlines, d)
+	}
+}
+
+func (h *deadlineHandler) Handle(ctx context.Context, _ *transport.Request, _ transport.ResponseWriter) error {
+	h.record(ctx)
+	return nil
+}
+
+func (h *deadlineHandler) HandleOneway(ctx context.Context, _ *transport.Request) error {
+	h.record(ctx)
+	return nil
+}
+
+func (h *deadlineHandler) HandleStream(s *transport.ServerStream) error {
+	h.record(s.Context())
+	return nil
+}
+
+type fakeStream struct {
+	transport.Stream
+
+	ctx context.Context
+	req *transport.StreamRequest
+}
+
+func (s fakeStream) Context() context.Context          { return s.ctx }
+func (s fakeStream) Request() *transport.StreamRequest { return s.req }
+
+func request() *transport.Request {
+	return &transport.Request{
+		Caller:    "caller",
+		Service:   "service",
+		Encoding:  "raw",
+		Procedure: "hello",
+	}
+}
+
+// handleAll sends the request through the unary, oneway and stream paths of
+// the middleware.
+func handleAll(t *testing.T, mw *Middleware, ctx context.Context, h *deadlineHandler) []error {
+	req := request()
+	s, err := transport.NewServerStream(fakeStream{
+		ctx: ctx,
+		req: &transport.StreamRequest{Meta: req.ToRequestMeta()},
+	})
+	require.NoError(t, err)
+
+	return []error{
+		mw.Handle(ctx, req, &transporttest.FakeResponseWriter{}, h),
+		mw.HandleOneway(ctx, req, h),
+		mw.HandleStream(s, h),
+	}
+}
+
+func TestSlack(t *testing.T) {
+	mw := New(Slack(time.Second))
+
+	deadline := time.Now().Add(time.Minute)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	h := &deadlineHandler{}
+	for _, err := range handleAll(t, mw, ctx, h) {
+		assert.NoError(t, err)
+	}
+
+	want := deadline.Add(-time.Second)
+	require.Len(t, h.deadlines, 3)
+	for _, got := range h.deadlines {
+		assert.True(t, want.Equal(got), "deadline must leave the slack: want %v, got %v", want, got)
+	}
+}
+
+func TestNoSlack(t *testing.T) {
+	mw := New()
+
+	deadline := time.Now().Add(time.Minute)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	h := &deadlineHandler{}
+	for _, err := range handleAll(t, mw, ctx, h) {
+		assert.NoError(t, err)
+	}
+
+	require.Len(t, h.deadlines, 3)
+	for _, got := range h.deadlines {
+		assert.True(t, deadline.Equal(got), "deadline must not change: want %v, got %v", deadline, got)
+	}
+}
+
+func TestNoDeadline(t *testing.T) {
+	mw := New(Slack(time.Second))
+
+	h := &deadlineHandler{}
+	for _, err := range handleAll(t, mw, context.Background(), h) {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 3, h.called)
+	assert.Empty(t, h.deadlines, "requests without deadline must not get one")
+}
+
+func TestRejectsExhaustedBudget(t *testing.T) {
+	tests := []struct {
+		desc      string
+		slack     time.Duration
+		remaining time.Duration
+		wantError string
+	}{
+		{
+			desc:      "expired",
+			remaining: -time.Second,
+			wantError: `request to procedure "hello" of service "service" arrived after its deadline`,
+		},
+		{
+			desc:      "expired with slack",
+			slack:     time.Second,
+			remaining: -time.Second,
+			wantError: `request to procedure "hello" of service "service" arrived after its deadline`,
+		},
+		{
+			desc:      "within slack",
+			slack:     time.Minute,
+			remaining: time.Second,
+			wantError: `request to procedure "hello" of service "service" arrived with `,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			mw := New(Slack(tt.slack))
+			ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(tt.remaining))
+			defer cancel()
+
+			h := &deadlineHandler{}
+			for _, err := range handleAll(t, mw, ctx, h) {
+				require.Error(t, err)
+				status := yarpcerrors.FromError(err)
+				assert.Equal(t, yarpcerrors.CodeDeadlineExceeded, status.Code())
+				assert.Contains(t, status.Message(), tt.wantError)
+			}
+			assert.Zero(t, h.called, "handlers must not be called")
+		})
+	}
+}
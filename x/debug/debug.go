@@ -136,6 +136,7 @@ var (
 			<th>Endpoint</th>
 			<th>State</th>
 			<th colspan="3">Chooser</th>
+			<th>Middleware</th>
 		</tr>
 		<tr>
 			<th></th>
@@ -146,6 +147,7 @@ var (
 			<th>Name</th>
 			<th>State</th>
 			<th>Peers</th>
+			<th></th>
 		</tr>
 		</thead>
 		<tbody>
@@ -166,6 +168,19 @@ var (
 				{{end}}
 				</ul>
 			</td>
+			<td>
+				<ul>
+				{{range .Middleware}}
+					<li>{{.Name}} ({{.State}})
+						<ul>
+						{{range .Entries}}
+							<li>{{.Key}} ({{.State}})</li>
+						{{end}}
+						</ul>
+					</li>
+				{{end}}
+				</ul>
+			</td>
 		</tr>
 		</tbody>
 		{{end}}
@@ -0,0 +1,107 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hedge
+
+import (
+	"context"
+	"sync"
+
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/introspection"
+)
+
+// _maxChooseAttempts is the number of times the chooser asks the underlying
+// chooser for a peer that has not been used by another attempt before
+// settling for one that has.
+const _maxChooseAttempts = 3
+
+type attemptsKey struct{}
+
+// attempts tracks the peers chosen for the attempts of a hedged request.
+type attempts struct {
+	mu    sync.Mutex
+	peers map[string]struct{}
+}
+
+func withAttempts(ctx context.Context) context.Context {
+	return context.WithValue(ctx, attemptsKey{}, &attempts{peers: make(map[string]struct{}, 2)})
+}
+
+func (a *attempts) used(id string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, ok := a.peers[id]
+	return ok
+}
+
+func (a *attempts) add(id string) {
+	a.mu.Lock()
+	a.peers[id] = struct{}{}
+	a.mu.Unlock()
+}
+
+// Chooser is a peer chooser that avoids sending the attempts of a hedged
+// request to the same peer.
+type Chooser struct {
+	peer.Chooser
+}
+
+var _ introspection.IntrospectableChooser = (*Chooser)(nil)
+
+// NewChooser wraps the given peer chooser so that hedged attempts are sent
+// to different peers where possible. Requests that are not hedged are
+// passed through unchanged.
+func NewChooser(c peer.Chooser) *Chooser {
+	return &Chooser{Chooser: c}
+}
+
+// Choose chooses a peer from the underlying chooser. For the attempts of a
+// hedged request, peers already used by another attempt are skipped unless
+// the underlying chooser keeps returning them.
+func (c *Chooser) Choose(ctx context.Context, req *transport.Request) (peer.Peer, func(error), error) {
+	a, ok := ctx.Value(attemptsKey{}).(*attempts)
+	if !ok {
+		return c.Chooser.Choose(ctx, req)
+	}
+
+	for i := 0; ; i++ {
+		p, onFinish, err := c.Chooser.Choose(ctx, req)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		id := p.Identifier()
+		if !a.used(id) || i+1 >= _maxChooseAttempts {
+			a.add(id)
+			return p, onFinish, nil
+		}
+		onFinish(nil)
+	}
+}
+
+// Introspect returns the status of the underlying chooser, if available.
+func (c *Chooser) Introspect() introspection.ChooserStatus {
+	if ic, ok := c.Chooser.(introspection.IntrospectableChooser); ok {
+		return ic.Introspect()
+	}
+	return introspection.ChooserStatus{Name: "Introspection not available"}
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hedge
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/peer/peertest"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/introspection"
+)
+
+// sequenceChooser returns peers in the given order, repeating the last one.
+type sequenceChooser struct {
+	peer.Chooser
+
+	ids      []string
+	finished int
+}
+
+func (c *sequenceChooser) Choose(context.Context, *transport.Request) (peer.Peer, func(error), error) {
+	id := c.ids[0]
+	if len(c.ids) > 1 {
+		c.ids = c.ids[1:]
+	}
+	p := peertest.NewLightMockPeer(peertest.MockPeerIdentifier(id), peer.Available)
+	return p, func(error) { c.finished++ }, nil
+}
+
+func TestChooser(t *testing.T) {
+	tests := []struct {
+		desc         string
+		ids          []string
+		hedged       bool
+		want         []string
+		wantFinished int
+	}{
+		{
+			desc: "not hedged",
+			ids:  []string{"a", "a"},
+			want: []string{"a", "a"},
+		},
+		{
+			desc:         "skips used peers",
+			ids:          []string{"a", "a", "b"},
+			hedged:       true,
+			want:         []string{"a", "b"},
+			wantFinished: 1,
+		},
+		{
+			desc:         "settles for used peer",
+			ids:          []string{"a"},
+			hedged:       true,
+			want:         []string{"a", "a"},
+			wantFinished: _maxChooseAttempts - 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			underlying := &sequenceChooser{ids: tt.ids}
+			chooser := NewChooser(underlying)
+
+			ctx := context.Background()
+			if tt.hedged {
+				ctx = withAttempts(ctx)
+			}
+
+			var got []string
+			for range tt.want {
+				p, _, err := chooser.Choose(ctx, &transport.Request{})
+				require.NoError(t, err)
+				got = append(got, p.Identifier())
+			}
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantFinished, underlying.finished, "unexpected number of released peers")
+		})
+	}
+}
+
+func TestChooserIntrospect(t *testing.T) {
+	chooser := NewChooser(&sequenceChooser{ids: []string{"a"}})
+	assert.Equal(t, introspection.ChooserStatus{Name: "Introspection not available"}, chooser.Introspect())
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hedge
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/yarpcconfig"
+)
+
+// Config describes how to build a hedging middleware.
+//
+//  delay: 50ms
+//  percentile: 95
+//  budget: 0.1
+//  idempotent:
+//    - service: users
+//      procedures: [getUser, listUsers]
+//    - service: catalog
+//
+// Delay is required. Entries under idempotent without procedures mark all
+// procedures of the service as idempotent.
+type Config struct {
+	Delay      time.Duration      `config:"delay"`
+	Percentile float64            `config:"percentile"`
+	Budget     float64            `config:"budget"`
+	Idempotent []IdempotentConfig `config:"idempotent"`
+}
+
+// IdempotentConfig marks procedures of a service as idempotent.
+type IdempotentConfig struct {
+	Service    string   `config:"service"`
+	Procedures []string `config:"procedures"`
+}
+
+// Spec returns a configuration specification for the hedging middleware,
+// making it possible to enable it for all unary outbounds.
+//
+//  cfg := yarpcconfig.New()
+//  cfg.MustRegisterMiddleware(hedge.Spec())
+//
+// This hedges all requests to the users service that take longer than
+// 50 milliseconds:
+//
+//  middleware:
+//    outbound:
+//      - hedge:
+//          delay: 50ms
+//          idempotent:
+//            - service: users
+//
+// See Config for the full set of attributes. Outbounds still need to use
+// NewChooser to send hedged attempts to different peers.
+func Spec() yarpcconfig.MiddlewareSpec {
+	return yarpcconfig.MiddlewareSpec{
+		Name: _name,
+		BuildUnaryOutbound: func(cfg Config, k *yarpcconfig.Kit) (middleware.UnaryOutbound, error) {
+			opts, err := cfg.options()
+			if err != nil {
+				return nil, err
+			}
+			return New(cfg.Delay, opts...), nil
+		},
+	}
+}
+
+func (c Config) options() ([]Option, error) {
+	if c.Delay <= 0 {
+		return nil, errors.New("delay is required")
+	}
+	if c.Percentile < 0 || c.Percentile >= 100 {
+		return nil, fmt.Errorf("percentile must be between 0 and 100, got %v", c.Percentile)
+	}
+
+	if c.Budget < 0 || c.Budget > 1 {
+		return nil, fmt.Errorf("budget must be between 0 and 1, got %v", c.Budget)
+	}
+
+	var opts []Option
+	if c.Percentile > 0 {
+		opts = append(opts, Percentile(c.Percentile))
+	}
+	if c.Budget > 0 {
+		opts = append(opts, Budget(c.Budget))
+	}
+	for i, idem := range c.Idempotent {
+		if idem.Service == "" {
+			return nil, fmt.Errorf("idempotent[%d]: service is required", i)
+		}
+		opts = append(opts, IdempotentProcedures(idem.Service, idem.Procedures...))
+	}
+	return opts, nil
+}
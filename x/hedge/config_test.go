@@ -0,0 +1,126 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hedge
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/internal/whitespace"
+	"go.uber.org/yarpc/yarpcconfig"
+)
+
+func TestSpec(t *testing.T) {
+	tests := []struct {
+		desc      string
+		give      string
+		wantDelay time.Duration
+		wantOpts  options
+		wantErr   string
+	}{
+		{
+			desc: "full",
+			give: whitespace.Expand(`
+				delay: 50ms
+				percentile: 95
+				budget: 0.2
+				idempotent:
+				  - service: users
+				    procedures: [getUser, listUsers]
+				  - service: catalog
+			`),
+			wantDelay: 50 * time.Millisecond,
+			wantOpts: New(0,
+				Percentile(95),
+				Budget(0.2),
+				IdempotentProcedures("users", "getUser", "listUsers"),
+				IdempotentProcedures("catalog"),
+			).opts,
+		},
+		{
+			desc:    "missing delay",
+			give:    `percentile: 95`,
+			wantErr: "delay is required",
+		},
+		{
+			desc: "invalid percentile",
+			give: whitespace.Expand(`
+				delay: 50ms
+				percentile: 100
+			`),
+			wantErr: "percentile must be between 0 and 100, got 100",
+		},
+		{
+			desc: "invalid budget",
+			give: whitespace.Expand(`
+				delay: 50ms
+				budget: 2
+			`),
+			wantErr: "budget must be between 0 and 1, got 2",
+		},
+		{
+			desc: "missing service",
+			give: whitespace.Expand(`
+				delay: 50ms
+				idempotent:
+				  - procedures: [get]
+			`),
+			wantErr: "idempotent[0]: service is required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			cfg := yarpcconfig.New()
+			cfg.MustRegisterMiddleware(Spec())
+
+			give := "middleware:\n  outbound:\n    - hedge:\n" + indent(tt.give, "        ")
+			c, err := cfg.LoadConfigFromYAML("myservice", strings.NewReader(give))
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			mw, ok := c.OutboundMiddleware.Unary.(*Middleware)
+			require.True(t, ok, "expected hedging middleware, got %T", c.OutboundMiddleware.Unary)
+			assert.Equal(t, tt.wantDelay, mw.delay)
+
+			got := mw.opts
+			got.clock, tt.wantOpts.clock = nil, nil
+			assert.Equal(t, tt.wantOpts, got)
+		})
+	}
+}
+
+func indent(s, prefix string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
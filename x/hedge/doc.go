@@ -0,0 +1,53 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package hedge provides outbound middleware that hedges unary requests to
+// idempotent procedures.
+//
+// When a request has not received a response within a delay, the
+// middleware sends a second copy of it. Whichever attempt replies first is
+// used and the other one is cancelled. This bounds the tail latency caused
+// by individual slow hosts at the cost of a small amount of extra load.
+//
+// The delay is either fixed or, with the Percentile option, follows a
+// percentile of the recently observed latency of each procedure. Only
+// procedures registered with IdempotentProcedures are hedged since the
+// server may handle both attempts. The Budget option caps the ratio of
+// requests that are hedged so that hedging cannot double the load on a
+// service that is already struggling.
+//
+// 	hedger := hedge.New(50*time.Millisecond,
+// 		hedge.Percentile(95),
+// 		hedge.IdempotentProcedures("users", "getUser", "listUsers"),
+// 	)
+// 	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+// 		Name: "myservice",
+// 		OutboundMiddleware: yarpc.OutboundMiddleware{
+// 			Unary: hedger,
+// 		},
+// 		// ...
+// 	})
+//
+// To make sure that the hedged attempt goes to a different peer than the
+// first one, wrap the peer chooser of the outbound with NewChooser.
+//
+// 	chooser := hedge.NewChooser(roundrobin.New(transport))
+// 	outbound := transport.NewOutbound(chooser)
+package hedge
@@ -0,0 +1,90 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hedge
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// _latencyWindow is the number of recent latencies kept per procedure.
+	_latencyWindow = 200
+	// _minLatencySamples is the number of latencies needed before the
+	// percentile is used instead of the fixed delay.
+	_minLatencySamples = 20
+	// _recomputeEvery is the number of new latencies after which the
+	// percentile is recomputed.
+	_recomputeEvery = 20
+)
+
+// latencies keeps a window of recent latencies for a procedure and derives
+// a percentile from them.
+type latencies struct {
+	mu       sync.Mutex
+	samples  []time.Duration
+	next     int
+	pending  int
+	computed time.Duration
+}
+
+func newLatencies() *latencies {
+	return &latencies{samples: make([]time.Duration, 0, _latencyWindow)}
+}
+
+func (l *latencies) record(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.samples) < _latencyWindow {
+		l.samples = append(l.samples, d)
+	} else {
+		l.samples[l.next] = d
+		l.next = (l.next + 1) % _latencyWindow
+	}
+	l.pending++
+}
+
+// percentile returns the given percentile of the recorded latencies, or
+// false if too few have been recorded.
+func (l *latencies) percentile(p float64) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.samples) < _minLatencySamples {
+		return 0, false
+	}
+	if l.pending >= _recomputeEvery || l.computed == 0 {
+		sorted := make([]time.Duration, len(l.samples))
+		copy(sorted, l.samples)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+		i := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+		if i < 0 {
+			i = 0
+		}
+		l.computed = sorted[i]
+		l.pending = 0
+	}
+	return l.computed, true
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hedge
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLatencies(t *testing.T) {
+	l := newLatencies()
+	for i := 1; i < _minLatencySamples; i++ {
+		l.record(time.Duration(i) * time.Millisecond)
+	}
+	_, ok := l.percentile(50)
+	assert.False(t, ok, "expected too few samples")
+
+	for i := _minLatencySamples; i <= 100; i++ {
+		l.record(time.Duration(i) * time.Millisecond)
+	}
+	d, ok := l.percentile(95)
+	assert.True(t, ok)
+	assert.Equal(t, 95*time.Millisecond, d)
+
+	// The percentile is only recomputed after enough new samples.
+	for i := 0; i < _recomputeEvery-1; i++ {
+		l.record(time.Second)
+	}
+	d, _ = l.percentile(95)
+	assert.Equal(t, 95*time.Millisecond, d)
+	l.record(time.Second)
+	d, _ = l.percentile(95)
+	assert.Equal(t, time.Second, d)
+
+	// Old samples fall out of the window.
+	for i := 0; i < _latencyWindow; i++ {
+		l.record(time.Millisecond)
+	}
+	d, _ = l.percentile(99)
+	assert.Equal(t, time.Millisecond, d)
+}
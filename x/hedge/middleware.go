@@ -0,0 +1,300 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hedge
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/clock"
+	"go.uber.org/yarpc/internal/replay"
+)
+
+const _name = "hedge"
+
+const (
+	// _defaultBudget is the default ratio of requests that may be hedged.
+	_defaultBudget = 0.1
+	// _budgetReserve is the number of hedges that may be sent at once
+	// before the budget has to be replenished by new requests.
+	_budgetReserve = 10
+)
+
+// Option customizes the behavior of the hedging middleware.
+type Option func(*options)
+
+type options struct {
+	percentile float64
+	budget     float64
+	services   map[string]struct{}
+	procedures map[procedureKey]struct{}
+	clock      clock.Clock
+}
+
+type procedureKey struct {
+	service   string
+	procedure string
+}
+
+// Percentile makes the hedging delay for each procedure follow the given
+// percentile, between 0 and 100, of its recent latencies. For example, with
+// a percentile of 95, about one in twenty requests is hedged. The fixed
+// delay is used until enough latencies have been observed.
+//
+// Defaults to always using the fixed delay.
+func Percentile(p float64) Option {
+	return func(opts *options) {
+		opts.percentile = p
+	}
+}
+
+// Budget limits hedging to the given ratio, between 0 and 1, of requests to
+// idempotent procedures. Once the budget is spent, requests are not hedged
+// until enough new requests have been made. This keeps hedging from doubling
+// the load on a service that is already slow.
+//
+// Defaults to 0.1, which allows hedging one in ten requests.
+func Budget(ratio float64) Option {
+	return func(opts *options) {
+		opts.budget = ratio
+	}
+}
+
+// IdempotentProcedures marks procedures of the given service as idempotent,
+// allowing requests to them to be hedged. If no procedures are given, all
+// procedures of the service are marked.
+//
+// Requests to procedures that are not marked are never hedged.
+func IdempotentProcedures(service string, procedures ...string) Option {
+	return func(opts *options) {
+		if len(procedures) == 0 {
+			opts.services[service] = struct{}{}
+			return
+		}
+		for _, p := range procedures {
+			opts.procedures[procedureKey{service: service, procedure: p}] = struct{}{}
+		}
+	}
+}
+
+// withClock overrides the clock used to time the hedging delay. This is
+// used for testing.
+func withClock(c clock.Clock) Option {
+	return func(opts *options) {
+		opts.clock = c
+	}
+}
+
+// Middleware is a unary outbound middleware that hedges requests to
+// idempotent procedures.
+type Middleware struct {
+	delay time.Duration
+	opts  options
+
+	budget *budget
+
+	mu        sync.RWMutex
+	latencies map[procedureKey]*latencies
+}
+
+var _ middleware.UnaryOutbound = (*Middleware)(nil)
+
+// New builds a new hedging middleware which sends a second attempt for
+// requests that have not received a response within the given delay.
+func New(delay time.Duration, opts ...Option) *Middleware {
+	options := options{
+		budget:     _defaultBudget,
+		services:   make(map[string]struct{}),
+		procedures: make(map[procedureKey]struct{}),
+		clock:      clock.NewReal(),
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &Middleware{
+		delay:     delay,
+		opts:      options,
+		budget:    newBudget(options.budget),
+		latencies: make(map[procedureKey]*latencies),
+	}
+}
+
+type result struct {
+	attempt int
+	res     *transport.Response
+	err     error
+	started time.Time
+}
+
+// Call sends the request through the given outbound, followed by a second
+// attempt if the procedure is idempotent, the first attempt is slow, and the
+// hedging budget allows it. The first response to arrive is returned.
+func (m *Middleware) Call(ctx context.Context, request *transport.Request, out transport.UnaryOutbound) (*transport.Response, error) {
+	key := procedureKey{service: request.Service, procedure: request.Procedure}
+	if !m.idempotent(key) {
+		return out.Call(ctx, request)
+	}
+
+	// Both attempts need their own copy of the body.
+	body, err := replay.ReadBody(request.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var lat *latencies
+	delay := m.delay
+	if m.opts.percentile > 0 {
+		lat = m.latenciesFor(key)
+		if d, ok := lat.percentile(m.opts.percentile); ok {
+			delay = d
+		}
+	}
+
+	m.budget.deposit()
+
+	ctx = withAttempts(ctx)
+	results := make(chan result, 2)
+	var (
+		cancels []context.CancelFunc
+		starts  []time.Time
+		done    []bool
+	)
+	send := func() {
+		attemptCtx, cancel := context.WithCancel(ctx)
+		attempt := len(cancels)
+		started := m.opts.clock.Now()
+		cancels = append(cancels, cancel)
+		starts = append(starts, started)
+		done = append(done, false)
+		attemptReq := *request
+		attemptReq.Body = bytes.NewReader(body)
+		go func() {
+			res, err := out.Call(attemptCtx, &attemptReq)
+			results <- result{attempt: attempt, res: res, err: err, started: started}
+		}()
+	}
+
+	hedgeC := make(chan struct{})
+	timer := m.opts.clock.AfterFunc(delay, func() { close(hedgeC) })
+	defer timer.Stop()
+
+	send()
+	pending := 1
+	for {
+		select {
+		case <-hedgeC:
+			hedgeC = nil
+			if ctx.Err() == nil && m.budget.withdraw() {
+				send()
+				pending++
+			}
+
+		case r := <-results:
+			pending--
+			done[r.attempt] = true
+			cancel := cancels[r.attempt]
+			// Failed attempts count towards the percentile too, or the
+			// delay would only reflect the fastest responses.
+			m.recordLatency(lat, r.started)
+			if r.err != nil {
+				cancel()
+				// Give the other attempt a chance to succeed. If the first
+				// attempt failed before the delay, the request is not hedged.
+				if pending > 0 {
+					continue
+				}
+				return nil, r.err
+			}
+
+			if pending > 0 {
+				for i, c := range cancels {
+					if done[i] {
+						continue
+					}
+					// An attempt that started before the winner is slower
+					// than it, and the time it has taken so far is the best
+					// we will learn about its latency. Attempts that started
+					// later are cut short and tell us nothing.
+					if starts[i].Before(r.started) {
+						m.recordLatency(lat, starts[i])
+					}
+					c()
+				}
+				go discard(results, pending)
+			}
+			if r.res != nil && r.res.Body != nil {
+				// The response body may still be read under the attempt's
+				// context so we can release it only once the body is closed.
+				r.res.Body = replay.CancelOnClose(r.res.Body, cancel)
+			} else {
+				cancel()
+			}
+			return r.res, nil
+		}
+	}
+}
+
+func (m *Middleware) idempotent(key procedureKey) bool {
+	if _, ok := m.opts.services[key.service]; ok {
+		return true
+	}
+	_, ok := m.opts.procedures[key]
+	return ok
+}
+
+func (m *Middleware) latenciesFor(key procedureKey) *latencies {
+	m.mu.RLock()
+	l, ok := m.latencies[key]
+	m.mu.RUnlock()
+	if ok {
+		return l
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if l, ok := m.latencies[key]; ok {
+		return l
+	}
+	l = newLatencies()
+	m.latencies[key] = l
+	return l
+}
+
+func (m *Middleware) recordLatency(lat *latencies, started time.Time) {
+	if lat != nil {
+		lat.record(m.opts.clock.Now().Sub(started))
+	}
+}
+
+// discard releases the responses of attempts that lost the race as they
+// come in. These attempts have already been cancelled.
+func discard(results <-chan result, pending int) {
+	for ; pending > 0; pending-- {
+		if r := <-results; r.res != nil && r.res.Body != nil {
+			r.res.Body.Close()
+		}
+	}
+}
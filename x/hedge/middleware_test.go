@@ -0,0 +1,343 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hedge
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/clock"
+)
+
+type attemptFunc func(ctx context.Context, body string) (*transport.Response, error)
+
+// fakeOutbound runs the function for each attempt in order, reporting when
+// each attempt starts.
+type fakeOutbound struct {
+	transport.Outbound
+
+	mu       sync.Mutex
+	attempts []attemptFunc
+	calls    int
+	started  chan int
+}
+
+func newFakeOutbound(attempts ...attemptFunc) *fakeOutbound {
+	return &fakeOutbound{attempts: attempts, started: make(chan int, len(attempts))}
+}
+
+func (o *fakeOutbound) Call(ctx context.Context, req *transport.Request) (*transport.Response, error) {
+	o.mu.Lock()
+	i := o.calls
+	o.calls++
+	o.mu.Unlock()
+
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	o.started <- i
+	return o.attempts[i](ctx, string(body))
+}
+
+func (o *fakeOutbound) numCalls() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.calls
+}
+
+func respond(name string) attemptFunc {
+	return func(_ context.Context, body string) (*transport.Response, error) {
+		return &transport.Response{Body: ioutil.NopCloser(strings.NewReader(name + ": " + body))}, nil
+	}
+}
+
+func fail(err error) attemptFunc {
+	return func(context.Context, string) (*transport.Response, error) {
+		return nil, err
+	}
+}
+
+// blockUntil blocks until the channel is closed and then behaves like the
+// given attempt. It fails early if the attempt is cancelled, closing the
+// cancelled channel.
+func blockUntil(release <-chan struct{}, cancelled chan<- struct{}, then attemptFunc) attemptFunc {
+	return func(ctx context.Context, body string) (*transport.Response, error) {
+		select {
+		case <-release:
+			return then(ctx, body)
+		case <-ctx.Done():
+			close(cancelled)
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func request(procedure string) *transport.Request {
+	return &transport.Request{
+		Service:   "service",
+		Procedure: procedure,
+		Body:      strings.NewReader("hello"),
+	}
+}
+
+func readResponse(t *testing.T, res *transport.Response) string {
+	body, err := ioutil.ReadAll(res.Body)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+	return string(body)
+}
+
+func TestNotIdempotent(t *testing.T) {
+	fakeClock := clock.NewFake()
+	mw := New(time.Millisecond, IdempotentProcedures("service", "get"), withClock(fakeClock))
+	out := newFakeOutbound(respond("first"))
+
+	res, err := mw.Call(context.Background(), request("put"), out)
+	require.NoError(t, err)
+	assert.Equal(t, "first: hello", readResponse(t, res))
+	assert.Equal(t, 1, out.numCalls())
+}
+
+func TestFastResponse(t *testing.T) {
+	fakeClock := clock.NewFake()
+	mw := New(time.Millisecond, IdempotentProcedures("service"), withClock(fakeClock))
+	out := newFakeOutbound(respond("first"))
+
+	res, err := mw.Call(context.Background(), request("get"), out)
+	require.NoError(t, err)
+	assert.Equal(t, "first: hello", readResponse(t, res))
+
+	fakeClock.Add(time.Second)
+	assert.Equal(t, 1, out.numCalls(), "fast requests must not be hedged")
+}
+
+func TestErrorBeforeDelay(t *testing.T) {
+	fakeClock := clock.NewFake()
+	mw := New(time.Millisecond, IdempotentProcedures("service"), withClock(fakeClock))
+	out := newFakeOutbound(fail(errors.New("great sadness")))
+
+	_, err := mw.Call(context.Background(), request("get"), out)
+	assert.EqualError(t, err, "great sadness")
+	assert.Equal(t, 1, out.numCalls())
+}
+
+// callAndHedge makes a hedged call in the background and triggers the hedge
+// once the first attempt has started.
+func callAndHedge(t *testing.T, out *fakeOutbound) (*transport.Response, error) {
+	fakeClock := clock.NewFake()
+	mw := New(10*time.Millisecond, IdempotentProcedures("service", "get"), withClock(fakeClock))
+
+	type result struct {
+		res *transport.Response
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		res, err := mw.Call(context.Background(), request("get"), out)
+		done <- result{res, err}
+	}()
+
+	assert.Equal(t, 0, <-out.started)
+	fakeClock.Add(10 * time.Millisecond)
+	assert.Equal(t, 1, <-out.started)
+
+	r := <-done
+	return r.res, r.err
+}
+
+func TestHedgeWins(t *testing.T) {
+	release, cancelled := make(chan struct{}), make(chan struct{})
+	out := newFakeOutbound(
+		blockUntil(release, cancelled, respond("first")),
+		respond("second"),
+	)
+
+	res, err := callAndHedge(t, out)
+	require.NoError(t, err)
+	assert.Equal(t, "second: hello", readResponse(t, res))
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("first attempt was not cancelled")
+	}
+}
+
+func TestFirstWinsAfterHedge(t *testing.T) {
+	releaseFirst, cancelledFirst := make(chan struct{}), make(chan struct{})
+	releaseSecond, cancelledSecond := make(chan struct{}), make(chan struct{})
+	out := newFakeOutbound(
+		blockUntil(releaseFirst, cancelledFirst, respond("first")),
+		blockUntil(releaseSecond, cancelledSecond, respond("second")),
+	)
+
+	go func() {
+		// Wait for both attempts to have started before letting the first
+		// one respond.
+		for out.numCalls() < 2 {
+			time.Sleep(time.Millisecond)
+		}
+		close(releaseFirst)
+	}()
+
+	res, err := callAndHedge(t, out)
+	require.NoError(t, err)
+	assert.Equal(t, "first: hello", readResponse(t, res))
+
+	select {
+	case <-cancelledSecond:
+	case <-time.After(time.Second):
+		t.Fatal("second attempt was not cancelled")
+	}
+}
+
+func TestFirstFailsAfterHedge(t *testing.T) {
+	releaseFirst, cancelledFirst := make(chan struct{}), make(chan struct{})
+	releaseSecond, cancelledSecond := make(chan struct{}), make(chan struct{})
+	out := newFakeOutbound(
+		blockUntil(releaseFirst, cancelledFirst, fail(errors.New("first failed"))),
+		blockUntil(releaseSecond, cancelledSecond, respond("second")),
+	)
+
+	go func() {
+		for out.numCalls() < 2 {
+			time.Sleep(time.Millisecond)
+		}
+		close(releaseFirst)
+		time.Sleep(time.Millisecond)
+		close(releaseSecond)
+	}()
+
+	res, err := callAndHedge(t, out)
+	require.NoError(t, err)
+	assert.Equal(t, "second: hello", readResponse(t, res))
+}
+
+func TestBothFail(t *testing.T) {
+	releaseFirst, cancelledFirst := make(chan struct{}), make(chan struct{})
+	out := newFakeOutbound(
+		blockUntil(releaseFirst, cancelledFirst, fail(errors.New("first failed"))),
+		fail(errors.New("second failed")),
+	)
+
+	go func() {
+		for out.numCalls() < 2 {
+			time.Sleep(time.Millisecond)
+		}
+		time.Sleep(time.Millisecond)
+		close(releaseFirst)
+	}()
+
+	_, err := callAndHedge(t, out)
+	assert.EqualError(t, err, "first failed", "expected the error of the last attempt")
+}
+
+func TestPercentileDelay(t *testing.T) {
+	fakeClock := clock.NewFake()
+	mw := New(time.Second, Percentile(50), IdempotentProcedures("service"), withClock(fakeClock))
+
+	// Attempts take 10ms each, so the median latency is 10ms.
+	slow := func(context.Context, string) (*transport.Response, error) {
+		fakeClock.Add(10 * time.Millisecond)
+		return &transport.Response{}, nil
+	}
+	for i := 0; i < _minLatencySamples; i++ {
+		_, err := mw.Call(context.Background(), request("get"), newFakeOutbound(slow))
+		require.NoError(t, err)
+	}
+
+	release, cancelled := make(chan struct{}), make(chan struct{})
+	out := newFakeOutbound(blockUntil(release, cancelled, respond("first")), respond("second"))
+	done := make(chan error, 1)
+	go func() {
+		_, err := mw.Call(context.Background(), request("get"), out)
+		done <- err
+	}()
+
+	<-out.started
+	fakeClock.Add(10 * time.Millisecond)
+	assert.Equal(t, 1, <-out.started, "expected a hedge after the median latency")
+	assert.NoError(t, <-done)
+}
+
+func TestBudgetExhausted(t *testing.T) {
+	fakeClock := clock.NewFake()
+	mw := New(10*time.Millisecond, IdempotentProcedures("service"), withClock(fakeClock))
+	mw.budget.tokens = 0
+
+	release, cancelled := make(chan struct{}), make(chan struct{})
+	out := newFakeOutbound(blockUntil(release, cancelled, respond("first")), respond("second"))
+	done := make(chan error, 1)
+	go func() {
+		res, err := mw.Call(context.Background(), request("get"), out)
+		if err == nil {
+			assert.Equal(t, "first: hello", readResponse(t, res))
+		}
+		done <- err
+	}()
+
+	<-out.started
+	fakeClock.Add(10 * time.Millisecond)
+	// Give the middleware a chance to (incorrectly) send the hedge.
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	require.NoError(t, <-done)
+	assert.Equal(t, 1, out.numCalls(), "expected no hedge without budget")
+}
+
+func TestPercentileIncludesFailures(t *testing.T) {
+	fakeClock := clock.NewFake()
+	mw := New(time.Second, Percentile(50), IdempotentProcedures("service"), withClock(fakeClock))
+
+	// Half the attempts fail slowly. These must count towards the
+	// percentile.
+	fast := func(context.Context, string) (*transport.Response, error) {
+		fakeClock.Add(time.Millisecond)
+		return &transport.Response{}, nil
+	}
+	slowFailure := func(context.Context, string) (*transport.Response, error) {
+		fakeClock.Add(100 * time.Millisecond)
+		return nil, errors.New("great sadness")
+	}
+	for i := 0; i < _minLatencySamples; i++ {
+		if i%2 == 0 {
+			_, err := mw.Call(context.Background(), request("get"), newFakeOutbound(slowFailure))
+			require.Error(t, err)
+		} else {
+			_, err := mw.Call(context.Background(), request("get"), newFakeOutbound(fast))
+			require.NoError(t, err)
+		}
+	}
+
+	d, ok := mw.latenciesFor(procedureKey{service: "service", procedure: "get"}).percentile(90)
+	require.True(t, ok)
+	assert.Equal(t, 100*time.Millisecond, d)
+}
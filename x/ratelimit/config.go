@@ -0,0 +1,132 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/yarpcconfig"
+)
+
+const _headerKeyPrefix = "header:"
+
+// Config describes how to build a rate limiting middleware.
+//
+//  rate: 100
+//  burst: 20
+//  keyBy: [caller, procedure, shardKey, "header:x-tenant"]
+//  waitForToken: true
+//
+// Rate is the number of requests allowed per second for each bucket and is
+// required. KeyBy lists the request attributes that buckets are keyed by:
+// caller, procedure, shardKey, or header:<name> for the value of a header.
+// WaitForToken may only be used for outbound middleware.
+type Config struct {
+	Rate         float64  `config:"rate"`
+	Burst        int      `config:"burst"`
+	KeyBy        []string `config:"keyBy"`
+	WaitForToken bool     `config:"waitForToken"`
+}
+
+// Spec returns a configuration specification for the rate limiting
+// middleware, making it possible to enable it for all inbounds or
+// outbounds.
+//
+//  cfg := yarpcconfig.New()
+//  cfg.MustRegisterMiddleware(ratelimit.Spec())
+//
+// This enforces a quota of 100 requests per second for each caller:
+//
+//  middleware:
+//    inbound:
+//      - rate-limit:
+//          rate: 100
+//          keyBy: [caller]
+//
+// Each RPC type has its own set of buckets. See Config for the full set of
+// attributes.
+func Spec() yarpcconfig.MiddlewareSpec {
+	buildInbound := func(cfg Config) (*Middleware, error) {
+		if cfg.WaitForToken {
+			return nil, errors.New("waitForToken is only supported for outbound middleware")
+		}
+		return cfg.build()
+	}
+	buildOutbound := func(cfg Config) (*Middleware, error) {
+		return cfg.build()
+	}
+
+	return yarpcconfig.MiddlewareSpec{
+		Name: _name,
+		BuildUnaryInbound: func(cfg Config, k *yarpcconfig.Kit) (middleware.UnaryInbound, error) {
+			return buildInbound(cfg)
+		},
+		BuildOnewayInbound: func(cfg Config, k *yarpcconfig.Kit) (middleware.OnewayInbound, error) {
+			return buildInbound(cfg)
+		},
+		BuildStreamInbound: func(cfg Config, k *yarpcconfig.Kit) (middleware.StreamInbound, error) {
+			return buildInbound(cfg)
+		},
+		BuildUnaryOutbound: func(cfg Config, k *yarpcconfig.Kit) (middleware.UnaryOutbound, error) {
+			return buildOutbound(cfg)
+		},
+		BuildOnewayOutbound: func(cfg Config, k *yarpcconfig.Kit) (middleware.OnewayOutbound, error) {
+			return buildOutbound(cfg)
+		},
+		BuildStreamOutbound: func(cfg Config, k *yarpcconfig.Kit) (middleware.StreamOutbound, error) {
+			return buildOutbound(cfg)
+		},
+	}
+}
+
+func (c Config) build() (*Middleware, error) {
+	if c.Rate <= 0 {
+		return nil, fmt.Errorf("rate must be positive, got %v", c.Rate)
+	}
+
+	var opts []Option
+	if c.Burst > 0 {
+		opts = append(opts, Burst(c.Burst))
+	}
+	for _, k := range c.KeyBy {
+		switch {
+		case k == "caller":
+			opts = append(opts, PerCaller())
+		case k == "procedure":
+			opts = append(opts, PerProcedure())
+		case k == "shardKey":
+			opts = append(opts, PerShardKey())
+		case strings.HasPrefix(k, _headerKeyPrefix) && len(k) > len(_headerKeyPrefix):
+			opts = append(opts, PerHeader(strings.TrimPrefix(k, _headerKeyPrefix)))
+		default:
+			return nil, fmt.Errorf(
+				`unknown key %q: must be one of caller, procedure, shardKey or "header:<name>"`, k)
+		}
+	}
+	if c.WaitForToken {
+		opts = append(opts, WaitForToken())
+	}
+
+	return New(c.Rate, opts...), nil
+}
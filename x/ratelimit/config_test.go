@@ -0,0 +1,129 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ratelimit
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/yarpcconfig"
+)
+
+func TestSpec(t *testing.T) {
+	tests := []struct {
+		desc      string
+		give      string
+		wantRate  float64
+		wantBurst int
+		wantKeys  []string
+		wantWait  bool
+		wantErr   string
+	}{
+		{
+			desc:      "inbound",
+			give:      "inbound:\n  - rate-limit:\n      rate: 2.5\n",
+			wantRate:  2.5,
+			wantBurst: 3,
+		},
+		{
+			desc:      "inbound with keys",
+			give:      "inbound:\n  - rate-limit:\n      rate: 100\n      burst: 10\n      keyBy: [caller, procedure, shardKey, \"header:x-tenant\"]\n",
+			wantRate:  100,
+			wantBurst: 10,
+			wantKeys:  []string{"caller", "procedure", "shard key", `header "x-tenant"`},
+		},
+		{
+			desc:      "outbound waiting for tokens",
+			give:      "outbound:\n  - rate-limit:\n      rate: 10\n      waitForToken: true\n",
+			wantRate:  10,
+			wantBurst: 10,
+			wantWait:  true,
+		},
+		{
+			desc:    "missing rate",
+			give:    "inbound:\n  - rate-limit:\n      burst: 10\n",
+			wantErr: "rate must be positive, got 0",
+		},
+		{
+			desc:    "unknown key",
+			give:    "inbound:\n  - rate-limit:\n      rate: 10\n      keyBy: [tenant]\n",
+			wantErr: `unknown key "tenant"`,
+		},
+		{
+			desc:    "empty header key",
+			give:    "inbound:\n  - rate-limit:\n      rate: 10\n      keyBy: [\"header:\"]\n",
+			wantErr: `unknown key "header:"`,
+		},
+		{
+			desc:    "inbound waiting for tokens",
+			give:    "inbound:\n  - rate-limit:\n      rate: 10\n      waitForToken: true\n",
+			wantErr: "waitForToken is only supported for outbound middleware",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			cfg := yarpcconfig.New()
+			cfg.MustRegisterMiddleware(Spec())
+
+			give := "middleware:\n" + indent(tt.give, "  ")
+			c, err := cfg.LoadConfigFromYAML("myservice", strings.NewReader(give))
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			var all []interface{}
+			if strings.HasPrefix(tt.give, "inbound") {
+				all = []interface{}{c.InboundMiddleware.Unary, c.InboundMiddleware.Oneway, c.InboundMiddleware.Stream}
+			} else {
+				all = []interface{}{c.OutboundMiddleware.Unary, c.OutboundMiddleware.Oneway, c.OutboundMiddleware.Stream}
+			}
+			for _, v := range all {
+				mw, ok := v.(*Middleware)
+				require.True(t, ok, "expected rate limiter, got %T", v)
+				assert.Equal(t, tt.wantRate, mw.rate)
+				assert.Equal(t, tt.wantBurst, mw.opts.burst)
+				assert.Equal(t, tt.wantWait, mw.opts.wait)
+
+				var keys []string
+				for _, k := range mw.opts.keys {
+					keys = append(keys, k.name)
+				}
+				assert.Equal(t, tt.wantKeys, keys)
+			}
+		})
+	}
+}
+
+func indent(s, prefix string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package ratelimit provides inbound and outbound middleware that limits
+// the rate of requests using token buckets.
+//
+// Each bucket holds up to a burst of tokens and is refilled at a fixed rate.
+// Every request takes a token from its bucket. By default, all requests
+// share a single bucket; the PerCaller, PerProcedure, PerShardKey and
+// PerHeader options give each distinct combination of those values its own
+// bucket, which makes it possible to enforce quotas for each caller or
+// tenant.
+//
+// 	limiter := ratelimit.New(100, ratelimit.Burst(20), ratelimit.PerCaller())
+// 	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+// 		Name: "myservice",
+// 		InboundMiddleware: yarpc.InboundMiddleware{
+// 			Unary: limiter,
+// 		},
+// 		// ...
+// 	})
+//
+// Inbound requests that find their bucket empty are rejected immediately
+// with CodeResourceExhausted and counted as shed requests by the
+// dispatcher's observability middleware. Outbound requests are also
+// rejected immediately by default. With the WaitForToken option, outbound
+// requests instead wait for a token as long as one becomes available before
+// their deadline.
+package ratelimit
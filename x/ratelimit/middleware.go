@@ -0,0 +1,329 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/clock"
+	"go.uber.org/yarpc/internal/digester"
+	"go.uber.org/yarpc/internal/observability"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+const _name = "rate-limit"
+
+// _minSweepSize is the number of buckets below which we don't bother
+// removing idle buckets.
+const _minSweepSize = 1024
+
+// Option customizes the behavior of the rate limiting middleware.
+type Option func(*options)
+
+type options struct {
+	burst int
+	keys  []key
+	wait  bool
+	clock clock.Clock
+}
+
+// key extracts one part of the bucket key from a request.
+type key struct {
+	name string
+	get  func(*transport.Request) string
+}
+
+// Burst sets the number of tokens each bucket holds, which is the number of
+// requests that may be made at once after a period of inactivity.
+//
+// Defaults to the rate rounded up, so that a full bucket holds one second's
+// worth of requests. Values below 1 are treated as 1 since a request needs a
+// whole token.
+func Burst(n int) Option {
+	return func(opts *options) {
+		opts.burst = n
+	}
+}
+
+// PerCaller gives each caller its own bucket.
+func PerCaller() Option {
+	return withKey("caller", func(req *transport.Request) string { return req.Caller })
+}
+
+// PerProcedure gives each procedure its own bucket.
+func PerProcedure() Option {
+	return withKey("procedure", func(req *transport.Request) string { return req.Procedure })
+}
+
+// PerShardKey gives each shard key its own bucket.
+func PerShardKey() Option {
+	return withKey("shard key", func(req *transport.Request) string { return req.ShardKey })
+}
+
+// PerHeader gives each value of the given header its own bucket. Requests
+// without the header share a bucket.
+func PerHeader(name string) Option {
+	return withKey(fmt.Sprintf("header %q", name), func(req *transport.Request) string {
+		v, _ := req.Headers.Get(name)
+		return v
+	})
+}
+
+func withKey(name string, get func(*transport.Request) string) Option {
+	return func(opts *options) {
+		opts.keys = append(opts.keys, key{name: name, get: get})
+	}
+}
+
+// WaitForToken makes outbound requests wait for a token instead of failing
+// immediately when their bucket is empty. Requests still fail immediately if
+// no token will become available before their deadline.
+//
+// This has no effect on inbound requests, which are always rejected
+// immediately.
+func WaitForToken() Option {
+	return func(opts *options) {
+		opts.wait = true
+	}
+}
+
+// withClock overrides the clock used to refill buckets. This is used for
+// testing.
+func withClock(c clock.Clock) Option {
+	return func(opts *options) {
+		opts.clock = c
+	}
+}
+
+// Middleware is an inbound and outbound middleware for all RPC types that
+// limits the rate of requests.
+type Middleware struct {
+	rate float64
+	opts options
+
+	mu      sync.RWMutex
+	buckets map[string]*bucket
+	sweepAt int
+}
+
+var (
+	_ middleware.UnaryInbound   = (*Middleware)(nil)
+	_ middleware.OnewayInbound  = (*Middleware)(nil)
+	_ middleware.StreamInbound  = (*Middleware)(nil)
+	_ middleware.UnaryOutbound  = (*Middleware)(nil)
+	_ middleware.OnewayOutbound = (*Middleware)(nil)
+	_ middleware.StreamOutbound = (*Middleware)(nil)
+)
+
+// New builds a new rate limiting middleware that allows the given number of
+// requests per second for each bucket.
+//
+// A rate of zero or less denies all requests.
+func New(rate float64, opts ...Option) *Middleware {
+	options := options{
+		burst: int(math.Max(1, math.Ceil(rate))),
+		clock: clock.NewReal(),
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.burst < 1 {
+		options.burst = 1
+	}
+
+	return &Middleware{
+		rate:    rate,
+		opts:    options,
+		buckets: make(map[string]*bucket),
+		sweepAt: _minSweepSize,
+	}
+}
+
+// Handle handles the request with the given handler if its bucket has a
+// token.
+func (m *Middleware) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter, h transport.UnaryHandler) error {
+	if err := m.admit(ctx, req); err != nil {
+		return err
+	}
+	return h.Handle(ctx, req, resw)
+}
+
+// HandleOneway handles the request with the given handler if its bucket has
+// a token.
+func (m *Middleware) HandleOneway(ctx context.Context, req *transport.Request, h transport.OnewayHandler) error {
+	if err := m.admit(ctx, req); err != nil {
+		return err
+	}
+	return h.HandleOneway(ctx, req)
+}
+
+// HandleStream handles the stream with the given handler if its bucket has
+// a token.
+func (m *Middleware) HandleStream(s *transport.ServerStream, h transport.StreamHandler) error {
+	if err := m.admit(s.Context(), s.Request().Meta.ToRequest()); err != nil {
+		return err
+	}
+	return h.HandleStream(s)
+}
+
+// Call sends the request through the given outbound once its bucket has a
+// token.
+func (m *Middleware) Call(ctx context.Context, req *transport.Request, out transport.UnaryOutbound) (*transport.Response, error) {
+	if err := m.take(ctx, req); err != nil {
+		return nil, err
+	}
+	return out.Call(ctx, req)
+}
+
+// CallOneway sends the request through the given outbound once its bucket
+// has a token.
+func (m *Middleware) CallOneway(ctx context.Context, req *transport.Request, out transport.OnewayOutbound) (transport.Ack, error) {
+	if err := m.take(ctx, req); err != nil {
+		return nil, err
+	}
+	return out.CallOneway(ctx, req)
+}
+
+// CallStream opens the stream through the given outbound once its bucket
+// has a token.
+func (m *Middleware) CallStream(ctx context.Context, req *transport.StreamRequest, out transport.StreamOutbound) (*transport.ClientStream, error) {
+	if err := m.take(ctx, req.Meta.ToRequest()); err != nil {
+		return nil, err
+	}
+	return out.CallStream(ctx, req)
+}
+
+// admit takes a token for an inbound request without waiting.
+func (m *Middleware) admit(ctx context.Context, req *transport.Request) error {
+	if m.rate <= 0 {
+		observability.MarkShed(ctx)
+		return m.limitedError(req)
+	}
+
+	now := m.opts.clock.Now()
+	if _, ok := m.bucket(now, req).reserve(now, m.rate, m.opts.burst, 0); !ok {
+		observability.MarkShed(ctx)
+		return m.limitedError(req)
+	}
+	return nil
+}
+
+// take takes a token for an outbound request, waiting for it if allowed.
+func (m *Middleware) take(ctx context.Context, req *transport.Request) error {
+	if m.rate <= 0 {
+		// No token will ever become available, so there's no point waiting.
+		return m.limitedError(req)
+	}
+
+	now := m.opts.clock.Now()
+
+	var maxWait time.Duration
+	if m.opts.wait {
+		maxWait = time.Duration(math.MaxInt64)
+		if deadline, ok := ctx.Deadline(); ok {
+			maxWait = deadline.Sub(now)
+		}
+	}
+
+	wait, ok := m.bucket(now, req).reserve(now, m.rate, m.opts.burst, maxWait)
+	if !ok {
+		return m.limitedError(req)
+	}
+	if wait <= 0 {
+		return nil
+	}
+
+	select {
+	case <-m.opts.clock.After(wait):
+		return nil
+	case <-ctx.Done():
+		// The reserved token is forfeited. Returning it would let the next
+		// request jump ahead of requests that are already waiting.
+		if ctx.Err() == context.DeadlineExceeded {
+			return yarpcerrors.DeadlineExceededErrorf("timed out waiting for rate limit token: %v", ctx.Err())
+		}
+		return yarpcerrors.CancelledErrorf("cancelled while waiting for rate limit token: %v", ctx.Err())
+	}
+}
+
+func (m *Middleware) limitedError(req *transport.Request) error {
+	if len(m.opts.keys) == 0 {
+		return yarpcerrors.ResourceExhaustedErrorf("rate limit exceeded")
+	}
+
+	parts := make([]string, len(m.opts.keys))
+	for i, k := range m.opts.keys {
+		parts[i] = fmt.Sprintf("%v %q", k.name, k.get(req))
+	}
+	return yarpcerrors.ResourceExhaustedErrorf("rate limit exceeded for %v", strings.Join(parts, ", "))
+}
+
+func (m *Middleware) bucket(now time.Time, req *transport.Request) *bucket {
+	d := digester.New()
+	for _, k := range m.opts.keys {
+		d.Add(k.get(req))
+	}
+	key := string(d.Digest())
+	d.Free()
+
+	m.mu.RLock()
+	b, ok := m.buckets[key]
+	m.mu.RUnlock()
+	if ok {
+		return b
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if b, ok := m.buckets[key]; ok {
+		return b
+	}
+	if len(m.buckets) >= m.sweepAt {
+		m.sweep(now)
+	}
+	b = newBucket(now, m.opts.burst)
+	m.buckets[key] = b
+	return b
+}
+
+// sweep drops buckets that have refilled completely. These would be
+// re-created in the same state if needed, so this only bounds memory use
+// when keys have a high cardinality, such as shard keys.
+//
+// Must be called with the lock held.
+func (m *Middleware) sweep(now time.Time) {
+	for key, b := range m.buckets {
+		if b.full(now, m.rate, m.opts.burst) {
+			delete(m.buckets, key)
+		}
+	}
+	m.sweepAt = 2 * len(m.buckets)
+	if m.sweepAt < _minSweepSize {
+		m.sweepAt = _minSweepSize
+	}
+}
@@ -0,0 +1,277 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/transport/transporttest"
+	"go.uber.org/yarpc/internal/clock"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+type nopHandler struct{}
+
+func (nopHandler) Handle(context.Context, *transport.Request, transport.ResponseWriter) error {
+	return nil
+}
+
+func (nopHandler) HandleOneway(context.Context, *transport.Request) error {
+	return nil
+}
+
+type nopOutbound struct {
+	transport.Outbound
+}
+
+func (nopOutbound) Call(context.Context, *transport.Request) (*transport.Response, error) {
+	return &transport.Response{}, nil
+}
+
+func (nopOutbound) CallOneway(context.Context, *transport.Request) (transport.Ack, error) {
+	return nil, nil
+}
+
+func request() *transport.Request {
+	return &transport.Request{
+		Caller:    "caller",
+		Service:   "service",
+		Procedure: "procedure",
+		ShardKey:  "shard",
+		Headers:   transport.NewHeaders().With("x-tenant", "tenant"),
+	}
+}
+
+func handle(mw *Middleware, req *transport.Request) error {
+	return mw.Handle(context.Background(), req, &transporttest.FakeResponseWriter{}, nopHandler{})
+}
+
+func assertLimited(t *testing.T, err error) {
+	require.Error(t, err, "expected request to be rejected")
+	assert.Equal(t, yarpcerrors.CodeResourceExhausted, yarpcerrors.FromError(err).Code(),
+		"unexpected error code: %v", err)
+}
+
+func TestInboundRateLimit(t *testing.T) {
+	fakeClock := clock.NewFake()
+	mw := New(2, withClock(fakeClock))
+
+	require.NoError(t, handle(mw, request()))
+	require.NoError(t, handle(mw, request()))
+	assertLimited(t, handle(mw, request()))
+	assertLimited(t, mw.HandleOneway(context.Background(), request(), nopHandler{}))
+
+	fakeClock.Add(500 * time.Millisecond)
+	require.NoError(t, handle(mw, request()))
+	assertLimited(t, handle(mw, request()))
+
+	// Buckets never hold more than a burst of tokens.
+	fakeClock.Add(time.Hour)
+	require.NoError(t, handle(mw, request()))
+	require.NoError(t, handle(mw, request()))
+	assertLimited(t, handle(mw, request()))
+}
+
+func TestBurst(t *testing.T) {
+	fakeClock := clock.NewFake()
+	mw := New(1, Burst(3), withClock(fakeClock))
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, handle(mw, request()), "request %d", i)
+	}
+	assertLimited(t, handle(mw, request()))
+}
+
+func TestZeroRateDeniesAll(t *testing.T) {
+	fakeClock := clock.NewFake()
+	mw := New(0, Burst(0), WaitForToken(), withClock(fakeClock))
+
+	assertLimited(t, handle(mw, request()))
+	_, err := mw.Call(context.Background(), request(), nopOutbound{})
+	assertLimited(t, err)
+
+	fakeClock.Add(time.Hour)
+	assertLimited(t, handle(mw, request()))
+}
+
+func TestKeys(t *testing.T) {
+	tests := []struct {
+		desc    string
+		opt     Option
+		change  func(*transport.Request)
+		wantErr string
+	}{
+		{
+			desc:    "caller",
+			opt:     PerCaller(),
+			change:  func(r *transport.Request) { r.Caller = "other" },
+			wantErr: `rate limit exceeded for caller "caller"`,
+		},
+		{
+			desc:    "procedure",
+			opt:     PerProcedure(),
+			change:  func(r *transport.Request) { r.Procedure = "other" },
+			wantErr: `rate limit exceeded for procedure "procedure"`,
+		},
+		{
+			desc:    "shard key",
+			opt:     PerShardKey(),
+			change:  func(r *transport.Request) { r.ShardKey = "other" },
+			wantErr: `rate limit exceeded for shard key "shard"`,
+		},
+		{
+			desc:    "header",
+			opt:     PerHeader("X-Tenant"),
+			change:  func(r *transport.Request) { r.Headers = transport.NewHeaders().With("x-tenant", "other") },
+			wantErr: `rate limit exceeded for header "X-Tenant" "tenant"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			mw := New(1, tt.opt, withClock(clock.NewFake()))
+
+			require.NoError(t, handle(mw, request()))
+			err := handle(mw, request())
+			assertLimited(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+
+			other := request()
+			tt.change(other)
+			require.NoError(t, handle(mw, other), "expected a separate bucket")
+		})
+	}
+
+	t.Run("combined", func(t *testing.T) {
+		mw := New(1, PerCaller(), PerProcedure(), withClock(clock.NewFake()))
+
+		require.NoError(t, handle(mw, request()))
+		err := handle(mw, request())
+		assertLimited(t, err)
+		assert.Contains(t, err.Error(), `rate limit exceeded for caller "caller", procedure "procedure"`)
+
+		other := request()
+		other.Procedure = "other"
+		require.NoError(t, handle(mw, other))
+	})
+
+	t.Run("global", func(t *testing.T) {
+		mw := New(1, withClock(clock.NewFake()))
+
+		require.NoError(t, handle(mw, request()))
+		other := request()
+		other.Caller = "other"
+		assertLimited(t, handle(mw, other))
+	})
+}
+
+func TestOutboundFailFast(t *testing.T) {
+	mw := New(1, withClock(clock.NewFake()))
+
+	_, err := mw.Call(context.Background(), request(), nopOutbound{})
+	require.NoError(t, err)
+	_, err = mw.Call(context.Background(), request(), nopOutbound{})
+	assertLimited(t, err)
+	_, err = mw.CallOneway(context.Background(), request(), nopOutbound{})
+	assertLimited(t, err)
+}
+
+func TestOutboundWaitForToken(t *testing.T) {
+	fakeClock := clock.NewFake()
+	mw := New(10, Burst(1), WaitForToken(), withClock(fakeClock))
+
+	_, err := mw.Call(context.Background(), request(), nopOutbound{})
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := mw.Call(context.Background(), request(), nopOutbound{})
+		done <- err
+	}()
+
+	for i := 0; ; i++ {
+		require.True(t, i < 1000, "request did not complete")
+		select {
+		case err := <-done:
+			require.NoError(t, err)
+			return
+		default:
+			fakeClock.Add(10 * time.Millisecond)
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+func TestOutboundWaitBeyondDeadline(t *testing.T) {
+	fakeClock := clock.NewFake()
+	mw := New(1, WaitForToken(), withClock(fakeClock))
+
+	_, err := mw.Call(context.Background(), request(), nopOutbound{})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithDeadline(context.Background(), fakeClock.Now().Add(100*time.Millisecond))
+	defer cancel()
+	_, err = mw.Call(ctx, request(), nopOutbound{})
+	assertLimited(t, err)
+}
+
+func TestOutboundWaitCancelled(t *testing.T) {
+	mw := New(1, WaitForToken(), withClock(clock.NewFake()))
+
+	_, err := mw.Call(context.Background(), request(), nopOutbound{})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = mw.Call(ctx, request(), nopOutbound{})
+	require.Error(t, err)
+	assert.Equal(t, yarpcerrors.CodeCancelled, yarpcerrors.FromError(err).Code())
+}
+
+func TestSweepIdleBuckets(t *testing.T) {
+	fakeClock := clock.NewFake()
+	mw := New(1, PerShardKey(), withClock(fakeClock))
+
+	req := request()
+	for i := 0; i < _minSweepSize; i++ {
+		req.ShardKey = fmt.Sprint(i)
+		require.NoError(t, handle(mw, req))
+	}
+	assert.Len(t, mw.buckets, _minSweepSize)
+
+	// Keep one bucket busy so that it survives the sweep.
+	fakeClock.Add(time.Second)
+	req.ShardKey = "0"
+	require.NoError(t, handle(mw, req))
+
+	req.ShardKey = "new"
+	require.NoError(t, handle(mw, req))
+	assert.Len(t, mw.buckets, 2)
+
+	req.ShardKey = "0"
+	assertLimited(t, handle(mw, req))
+}
@@ -0,0 +1,176 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package retry
+
+import (
+	"fmt"
+
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/yarpcconfig"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// Config describes how to build a retry middleware.
+//
+// Policies are declared by name and referenced from the default policy and
+// from the per-service and per-procedure overrides.
+//
+//  policies:
+//    fast:
+//      maxAttempts: 3
+//      backoff:
+//        exponential:
+//          first: 10ms
+//          max: 100ms
+//    patient:
+//      maxAttempts: 5
+//      retryableCodes: [unavailable, resource-exhausted]
+//  default: fast
+//  overrides:
+//    - service: keyvalue
+//      with: patient
+//    - service: keyvalue
+//      procedure: set
+//      with: fast
+type Config struct {
+	Policies  map[string]PolicyConfig `config:"policies"`
+	Default   string                  `config:"default"`
+	Overrides []OverrideConfig        `config:"overrides"`
+}
+
+// PolicyConfig describes a single retry policy.
+type PolicyConfig struct {
+	// Maximum number of attempts, including the first one.
+	MaxAttempts uint `config:"maxAttempts"`
+
+	// Backoff strategy used between attempts. Defaults to an exponential
+	// backoff.
+	Backoff yarpcconfig.Backoff `config:"backoff"`
+
+	// Error codes which may be retried, e.g. "unavailable". Defaults to
+	// "unavailable" and "deadline-exceeded".
+	RetryableCodes []string `config:"retryableCodes"`
+}
+
+// OverrideConfig applies a named policy to all requests made to a service,
+// or to a single procedure of that service.
+type OverrideConfig struct {
+	Service   string `config:"service"`
+	Procedure string `config:"procedure"`
+	With      string `config:"with"`
+}
+
+// Spec returns a configuration specification for the retry middleware,
+// making it possible to enable retries for all unary outbounds.
+//
+//  cfg := yarpcconfig.New()
+//  cfg.MustRegisterMiddleware(retry.Spec())
+//
+// This enables the retry middleware:
+//
+//  middleware:
+//    outbound:
+//      - retry:
+//          policies:
+//            default:
+//              maxAttempts: 3
+//          default: default
+//
+// See Config for the full set of attributes.
+func Spec() yarpcconfig.MiddlewareSpec {
+	return yarpcconfig.MiddlewareSpec{
+		Name: "retry",
+		BuildUnaryOutbound: func(cfg Config, k *yarpcconfig.Kit) (middleware.UnaryOutbound, error) {
+			provider, err := cfg.policyProvider()
+			if err != nil {
+				return nil, err
+			}
+			return NewUnaryMiddleware(WithPolicyProvider(provider)), nil
+		},
+	}
+}
+
+func (c Config) policyProvider() (*ProcedurePolicyProvider, error) {
+	policies := make(map[string]*Policy, len(c.Policies))
+	for name, pc := range c.Policies {
+		policy, err := pc.policy()
+		if err != nil {
+			return nil, fmt.Errorf("invalid retry policy %q: %v", name, err)
+		}
+		policies[name] = policy
+	}
+
+	lookup := func(name string) (*Policy, error) {
+		policy, ok := policies[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown retry policy %q", name)
+		}
+		return policy, nil
+	}
+
+	provider := NewProcedurePolicyProvider()
+	if c.Default != "" {
+		policy, err := lookup(c.Default)
+		if err != nil {
+			return nil, err
+		}
+		provider.SetDefault(policy)
+	}
+
+	for _, o := range c.Overrides {
+		if o.Service == "" {
+			return nil, fmt.Errorf("retry policy override for %q must specify a service", o.With)
+		}
+		policy, err := lookup(o.With)
+		if err != nil {
+			return nil, err
+		}
+		if o.Procedure == "" {
+			provider.RegisterService(o.Service, policy)
+		} else {
+			provider.RegisterServiceProcedure(o.Service, o.Procedure, policy)
+		}
+	}
+
+	return provider, nil
+}
+
+func (c PolicyConfig) policy() (*Policy, error) {
+	strategy, err := c.Backoff.Strategy()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []PolicyOption{
+		MaxAttempts(c.MaxAttempts),
+		BackoffStrategy(strategy),
+	}
+	if len(c.RetryableCodes) > 0 {
+		codes := make([]yarpcerrors.Code, len(c.RetryableCodes))
+		for i, name := range c.RetryableCodes {
+			if err := codes[i].UnmarshalText([]byte(name)); err != nil {
+				return nil, err
+			}
+		}
+		opts = append(opts, RetryableCodes(codes...))
+	}
+	return NewPolicy(opts...), nil
+}
@@ -0,0 +1,154 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package retry
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/whitespace"
+	"go.uber.org/yarpc/yarpcconfig"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+func TestSpec(t *testing.T) {
+	cfg := yarpcconfig.New()
+	cfg.MustRegisterMiddleware(Spec())
+
+	c, err := cfg.LoadConfigFromYAML("myservice", strings.NewReader(whitespace.Expand(`
+		middleware:
+			outbound:
+				- retry:
+						policies:
+							fast:
+								maxAttempts: 3
+								backoff:
+									exponential:
+										first: 10ms
+										max: 100ms
+							patient:
+								maxAttempts: 5
+								retryableCodes: [unavailable, resource-exhausted]
+						default: fast
+						overrides:
+							- service: keyvalue
+							  with: patient
+							- service: keyvalue
+							  procedure: set
+							  with: fast
+	`)))
+	require.NoError(t, err)
+
+	mw, ok := c.OutboundMiddleware.Unary.(*OutboundMiddleware)
+	require.True(t, ok, "expected retry middleware, got %T", c.OutboundMiddleware.Unary)
+
+	policy := func(service, procedure string) *Policy {
+		return mw.provider.Policy(nil, &transport.Request{Service: service, Procedure: procedure})
+	}
+
+	fast := policy("other", "get")
+	require.NotNil(t, fast)
+	assert.Equal(t, uint(3), fast.opts.maxAttempts)
+	assert.True(t, fast.retryable(yarpcerrors.UnavailableErrorf("")))
+	assert.True(t, fast.retryable(yarpcerrors.DeadlineExceededErrorf("")))
+
+	patient := policy("keyvalue", "get")
+	require.NotNil(t, patient)
+	assert.Equal(t, uint(5), patient.opts.maxAttempts)
+	assert.True(t, patient.retryable(yarpcerrors.ResourceExhaustedErrorf("")))
+	assert.False(t, patient.retryable(yarpcerrors.DeadlineExceededErrorf("")))
+
+	assert.True(t, fast == policy("keyvalue", "set"), "expected procedure override")
+}
+
+func TestSpecErrors(t *testing.T) {
+	tests := []struct {
+		desc    string
+		give    string
+		wantErr string
+	}{
+		{
+			desc: "unknown default",
+			give: `
+				default: missing
+			`,
+			wantErr: `unknown retry policy "missing"`,
+		},
+		{
+			desc: "unknown override",
+			give: `
+				policies:
+					fast: {maxAttempts: 2}
+				overrides:
+					- service: foo
+					  with: slow
+			`,
+			wantErr: `unknown retry policy "slow"`,
+		},
+		{
+			desc: "override without service",
+			give: `
+				policies:
+					fast: {maxAttempts: 2}
+				overrides:
+					- procedure: foo
+					  with: fast
+			`,
+			wantErr: "must specify a service",
+		},
+		{
+			desc: "unknown code",
+			give: `
+				policies:
+					fast:
+						maxAttempts: 2
+						retryableCodes: [sadness]
+			`,
+			wantErr: `invalid retry policy "fast"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			cfg := yarpcconfig.New()
+			cfg.MustRegisterMiddleware(Spec())
+
+			give := "middleware:\n  outbound:\n    - retry:\n" +
+				indent(whitespace.Expand(tt.give), "        ")
+			_, err := cfg.LoadConfigFromYAML("myservice", strings.NewReader(give))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func indent(s, prefix string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,51 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package retry provides a unary outbound middleware that retries failed
+// requests according to per-service and per-procedure policies.
+//
+// Each Policy describes the maximum number of attempts for a request, the
+// backoff strategy to use between attempts and the set of error codes that
+// are safe to retry. Policies are looked up for each request by a
+// PolicyProvider; the ProcedurePolicyProvider matches requests by service
+// and procedure name.
+//
+// 	provider := retry.NewProcedurePolicyProvider()
+// 	provider.SetDefault(retry.NewPolicy(retry.MaxAttempts(2)))
+// 	provider.RegisterServiceProcedure("keyvalue", "get", retry.NewPolicy(
+// 		retry.MaxAttempts(3),
+// 		retry.BackoffStrategy(strategy),
+// 	))
+//
+// 	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+// 		Name: "myservice",
+// 		OutboundMiddleware: yarpc.OutboundMiddleware{
+// 			Unary: retry.NewUnaryMiddleware(retry.WithPolicyProvider(provider)),
+// 		},
+// 		// ...
+// 	})
+//
+// Request bodies are buffered in memory so that they can be replayed on each
+// attempt, and every attempt receives an equal share of the time remaining
+// before the request's deadline.
+//
+// The middleware may also be configured with yarpcconfig by registering
+// Spec with the Configurator. See Spec for details.
+package retry
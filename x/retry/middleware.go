@@ -0,0 +1,152 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package retry
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/replay"
+)
+
+// MiddlewareOption customizes the behavior of the retry middleware.
+type MiddlewareOption func(*middlewareOptions)
+
+type middlewareOptions struct {
+	provider PolicyProvider
+}
+
+// WithPolicyProvider sets the PolicyProvider used to select the retry policy
+// for each request.
+//
+// Requests are not retried if no PolicyProvider is specified.
+func WithPolicyProvider(provider PolicyProvider) MiddlewareOption {
+	return func(opts *middlewareOptions) {
+		opts.provider = provider
+	}
+}
+
+// OutboundMiddleware is a unary outbound middleware that retries failed
+// requests according to the policy selected for each request.
+type OutboundMiddleware struct {
+	provider PolicyProvider
+}
+
+var _ middleware.UnaryOutbound = (*OutboundMiddleware)(nil)
+
+// NewUnaryMiddleware builds a new retry middleware with the given options.
+func NewUnaryMiddleware(opts ...MiddlewareOption) *OutboundMiddleware {
+	var options middlewareOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &OutboundMiddleware{provider: options.provider}
+}
+
+// Call sends the request through the given outbound, retrying it according
+// to the request's policy.
+func (m *OutboundMiddleware) Call(ctx context.Context, request *transport.Request, out transport.UnaryOutbound) (*transport.Response, error) {
+	var policy *Policy
+	if m.provider != nil {
+		policy = m.provider.Policy(ctx, request)
+	}
+	if policy == nil || policy.opts.maxAttempts <= 1 {
+		return out.Call(ctx, request)
+	}
+
+	// The body is consumed by the transport so we need a copy of it to
+	// replay the request on subsequent attempts.
+	body, err := replay.ReadBody(request.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		maxAttempts = policy.opts.maxAttempts
+		boff        = policy.opts.backoff.Backoff()
+		lastErr     error
+	)
+	for attempt := uint(0); attempt < maxAttempts; attempt++ {
+		if attempt > 0 {
+			if !wait(ctx, boff.Duration(attempt-1)) {
+				return nil, lastErr
+			}
+		}
+
+		attemptCtx, cancel := attemptContext(ctx, maxAttempts-attempt)
+		attemptReq := *request
+		attemptReq.Body = bytes.NewReader(body)
+
+		res, err := out.Call(attemptCtx, &attemptReq)
+		if err == nil {
+			if res != nil && res.Body != nil {
+				// The response body may still be read under the attempt's
+				// context so we can release it only once the body is closed.
+				res.Body = replay.CancelOnClose(res.Body, cancel)
+			} else {
+				cancel()
+			}
+			return res, nil
+		}
+		cancel()
+
+		lastErr = err
+		if ctx.Err() != nil || !policy.retryable(err) {
+			return nil, err
+		}
+	}
+	return nil, lastErr
+}
+
+// attemptContext derives a context for a single attempt which receives an
+// equal share of the time remaining before the deadline of the given context.
+func attemptContext(ctx context.Context, attemptsLeft uint) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok || attemptsLeft <= 1 {
+		return context.WithCancel(ctx)
+	}
+	share := time.Until(deadline) / time.Duration(attemptsLeft)
+	return context.WithTimeout(ctx, share)
+}
+
+// wait blocks for the given duration. It returns false without waiting if
+// the context would expire before then, or if the context is cancelled while
+// waiting.
+func wait(ctx context.Context, d time.Duration) bool {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= d {
+		return false
+	}
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
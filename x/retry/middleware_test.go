@@ -0,0 +1,238 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package retry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/backoff"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/testtime"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// fakeOutbound is a unary outbound which records the body and deadline of
+// each request and responds with the next result in line.
+type fakeOutbound struct {
+	transport.UnaryOutbound
+
+	errs      []error
+	bodies    []string
+	deadlines []time.Time
+}
+
+func (o *fakeOutbound) Call(ctx context.Context, req *transport.Request) (*transport.Response, error) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	o.bodies = append(o.bodies, string(body))
+	deadline, _ := ctx.Deadline()
+	o.deadlines = append(o.deadlines, deadline)
+
+	attempt := len(o.bodies) - 1
+	if attempt < len(o.errs) && o.errs[attempt] != nil {
+		return nil, o.errs[attempt]
+	}
+	return &transport.Response{Body: ioutil.NopCloser(bytes.NewReader([]byte("ok")))}, nil
+}
+
+func newRequest() *transport.Request {
+	return &transport.Request{
+		Caller:    "caller",
+		Service:   "service",
+		Procedure: "procedure",
+		Encoding:  "raw",
+		Body:      bytes.NewReader([]byte("hello")),
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	unavailable := yarpcerrors.UnavailableErrorf("unavailable")
+	invalid := yarpcerrors.InvalidArgumentErrorf("invalid")
+
+	tests := []struct {
+		desc         string
+		policy       *Policy
+		errs         []error
+		wantErr      error
+		wantAttempts int
+	}{
+		{
+			desc:         "no policy",
+			errs:         []error{unavailable},
+			wantErr:      unavailable,
+			wantAttempts: 1,
+		},
+		{
+			desc:         "single attempt",
+			policy:       NewPolicy(),
+			errs:         []error{unavailable},
+			wantErr:      unavailable,
+			wantAttempts: 1,
+		},
+		{
+			desc:         "success after retries",
+			policy:       NewPolicy(MaxAttempts(3)),
+			errs:         []error{unavailable, unavailable},
+			wantAttempts: 3,
+		},
+		{
+			desc:         "attempts exhausted",
+			policy:       NewPolicy(MaxAttempts(3)),
+			errs:         []error{unavailable, unavailable, unavailable},
+			wantErr:      unavailable,
+			wantAttempts: 3,
+		},
+		{
+			desc:         "non-retryable code",
+			policy:       NewPolicy(MaxAttempts(3)),
+			errs:         []error{invalid},
+			wantErr:      invalid,
+			wantAttempts: 1,
+		},
+		{
+			desc:         "custom retryable codes",
+			policy:       NewPolicy(MaxAttempts(3), RetryableCodes(yarpcerrors.CodeInvalidArgument)),
+			errs:         []error{invalid, unavailable},
+			wantErr:      unavailable,
+			wantAttempts: 2,
+		},
+		{
+			desc:         "non-yarpc error",
+			policy:       NewPolicy(MaxAttempts(3)),
+			errs:         []error{errors.New("great sadness")},
+			wantErr:      errors.New("great sadness"),
+			wantAttempts: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			provider := NewProcedurePolicyProvider()
+			provider.SetDefault(tt.policy)
+			mw := NewUnaryMiddleware(WithPolicyProvider(provider))
+
+			ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+			defer cancel()
+
+			out := &fakeOutbound{errs: tt.errs}
+			res, err := mw.Call(ctx, newRequest(), out)
+
+			assert.Len(t, out.bodies, tt.wantAttempts, "unexpected number of attempts")
+			for _, body := range out.bodies {
+				assert.Equal(t, "hello", body, "request body must be replayed on each attempt")
+			}
+
+			if tt.wantErr != nil {
+				assert.Equal(t, tt.wantErr, err)
+				return
+			}
+
+			require.NoError(t, err)
+			body, err := ioutil.ReadAll(res.Body)
+			require.NoError(t, err)
+			assert.Equal(t, "ok", string(body))
+			assert.NoError(t, res.Body.Close())
+		})
+	}
+}
+
+func TestMiddlewareAttemptDeadlines(t *testing.T) {
+	provider := NewProcedurePolicyProvider()
+	provider.SetDefault(NewPolicy(MaxAttempts(3)))
+	mw := NewUnaryMiddleware(WithPolicyProvider(provider))
+
+	timeout := 3 * testtime.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+
+	unavailable := yarpcerrors.UnavailableErrorf("unavailable")
+	out := &fakeOutbound{errs: []error{unavailable, unavailable, unavailable}}
+	_, err := mw.Call(ctx, newRequest(), out)
+	require.Error(t, err)
+	require.Len(t, out.deadlines, 3)
+
+	// The first attempt gets a third of the time, the second attempt half of
+	// what remains and the last attempt all of it. The fake outbound fails
+	// immediately so nearly all of the time remains for the second attempt.
+	assert.WithinDuration(t, deadline.Add(-2*timeout/3), out.deadlines[0], timeout/10)
+	assert.WithinDuration(t, deadline.Add(-timeout/2), out.deadlines[1], timeout/10)
+	assert.Equal(t, deadline, out.deadlines[2])
+}
+
+func TestMiddlewareBackoffExceedsDeadline(t *testing.T) {
+	provider := NewProcedurePolicyProvider()
+	provider.SetDefault(NewPolicy(MaxAttempts(3), BackoffStrategy(constantBackoff(time.Hour))))
+	mw := NewUnaryMiddleware(WithPolicyProvider(provider))
+
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+
+	unavailable := yarpcerrors.UnavailableErrorf("unavailable")
+	out := &fakeOutbound{errs: []error{unavailable, unavailable}}
+	_, err := mw.Call(ctx, newRequest(), out)
+	assert.Equal(t, unavailable, err)
+	assert.Len(t, out.bodies, 1, "must not retry if the backoff exceeds the deadline")
+}
+
+func TestProcedurePolicyProvider(t *testing.T) {
+	defaultPolicy := NewPolicy(MaxAttempts(2))
+	servicePolicy := NewPolicy(MaxAttempts(3))
+	procedurePolicy := NewPolicy(MaxAttempts(4))
+
+	provider := NewProcedurePolicyProvider()
+	provider.SetDefault(defaultPolicy)
+	provider.RegisterService("foo", servicePolicy)
+	provider.RegisterServiceProcedure("foo", "bar", procedurePolicy)
+
+	tests := []struct {
+		service   string
+		procedure string
+		want      *Policy
+	}{
+		{service: "foo", procedure: "bar", want: procedurePolicy},
+		{service: "foo", procedure: "baz", want: servicePolicy},
+		{service: "bar", procedure: "bar", want: defaultPolicy},
+	}
+
+	for _, tt := range tests {
+		got := provider.Policy(context.Background(), &transport.Request{
+			Service:   tt.service,
+			Procedure: tt.procedure,
+		})
+		assert.True(t, tt.want == got, "unexpected policy for %v::%v", tt.service, tt.procedure)
+	}
+}
+
+type constantBackoff time.Duration
+
+func (b constantBackoff) Backoff() backoff.Backoff { return b }
+
+func (b constantBackoff) Duration(uint) time.Duration { return time.Duration(b) }
@@ -0,0 +1,173 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package retry
+
+import (
+	"context"
+
+	"go.uber.org/yarpc/api/backoff"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// _defaultRetryableCodes are the error codes retried by policies that do not
+// specify their own. Both indicate that the request may not have reached, or
+// been processed by, the remote service.
+var _defaultRetryableCodes = []yarpcerrors.Code{
+	yarpcerrors.CodeUnavailable,
+	yarpcerrors.CodeDeadlineExceeded,
+}
+
+// PolicyOption customizes the behavior of a retry Policy.
+type PolicyOption func(*policyOptions)
+
+type policyOptions struct {
+	maxAttempts    uint
+	backoff        backoff.Strategy
+	retryableCodes map[yarpcerrors.Code]struct{}
+}
+
+// MaxAttempts sets the maximum number of times a request will be attempted,
+// including the first attempt. Values lower than two disable retries.
+//
+// Defaults to 1.
+func MaxAttempts(attempts uint) PolicyOption {
+	return func(opts *policyOptions) {
+		opts.maxAttempts = attempts
+	}
+}
+
+// BackoffStrategy sets the strategy used to determine how long to wait
+// between attempts.
+//
+// Defaults to backoff.None.
+func BackoffStrategy(strategy backoff.Strategy) PolicyOption {
+	return func(opts *policyOptions) {
+		opts.backoff = strategy
+	}
+}
+
+// RetryableCodes sets the error codes that may be retried. Errors with any
+// other code are returned to the caller immediately.
+//
+// Defaults to CodeUnavailable and CodeDeadlineExceeded.
+func RetryableCodes(codes ...yarpcerrors.Code) PolicyOption {
+	return func(opts *policyOptions) {
+		opts.retryableCodes = make(map[yarpcerrors.Code]struct{}, len(codes))
+		for _, code := range codes {
+			opts.retryableCodes[code] = struct{}{}
+		}
+	}
+}
+
+// Policy defines how requests are retried.
+type Policy struct {
+	opts policyOptions
+}
+
+// NewPolicy creates a new retry Policy with the given options.
+func NewPolicy(opts ...PolicyOption) *Policy {
+	options := policyOptions{
+		maxAttempts: 1,
+		backoff:     backoff.None,
+	}
+	RetryableCodes(_defaultRetryableCodes...)(&options)
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &Policy{opts: options}
+}
+
+// retryable returns true if the given error may be retried under this policy.
+func (p *Policy) retryable(err error) bool {
+	if !yarpcerrors.IsStatus(err) {
+		return false
+	}
+	_, ok := p.opts.retryableCodes[yarpcerrors.FromError(err).Code()]
+	return ok
+}
+
+// PolicyProvider selects the retry policy for outgoing requests.
+type PolicyProvider interface {
+	// Policy returns the retry policy for the given request, or nil if the
+	// request must not be retried.
+	Policy(context.Context, *transport.Request) *Policy
+}
+
+type serviceProcedure struct {
+	service   string
+	procedure string
+}
+
+// ProcedurePolicyProvider is a PolicyProvider that selects policies based on
+// the service and procedure of the request.
+//
+// The most specific registered policy wins: a policy registered for the
+// service and procedure takes precedence over a policy registered for the
+// service, which takes precedence over the default policy.
+//
+// ProcedurePolicyProvider is not safe for concurrent modification. All
+// policies must be registered before the provider is used by a middleware.
+type ProcedurePolicyProvider struct {
+	defaultPolicy     *Policy
+	servicePolicies   map[string]*Policy
+	procedurePolicies map[serviceProcedure]*Policy
+}
+
+var _ PolicyProvider = (*ProcedurePolicyProvider)(nil)
+
+// NewProcedurePolicyProvider creates a new ProcedurePolicyProvider without
+// any policies. Requests are not retried until policies are registered.
+func NewProcedurePolicyProvider() *ProcedurePolicyProvider {
+	return &ProcedurePolicyProvider{
+		servicePolicies:   make(map[string]*Policy),
+		procedurePolicies: make(map[serviceProcedure]*Policy),
+	}
+}
+
+// SetDefault sets the policy used for requests that do not match any other
+// registered policy.
+func (p *ProcedurePolicyProvider) SetDefault(policy *Policy) {
+	p.defaultPolicy = policy
+}
+
+// RegisterService sets the policy used for all requests to the given
+// service, unless a policy was registered for the specific procedure.
+func (p *ProcedurePolicyProvider) RegisterService(service string, policy *Policy) {
+	p.servicePolicies[service] = policy
+}
+
+// RegisterServiceProcedure sets the policy used for requests to the given
+// procedure of the given service.
+func (p *ProcedurePolicyProvider) RegisterServiceProcedure(service, procedure string, policy *Policy) {
+	p.procedurePolicies[serviceProcedure{service: service, procedure: procedure}] = policy
+}
+
+// Policy returns the most specific policy registered for the request.
+func (p *ProcedurePolicyProvider) Policy(_ context.Context, req *transport.Request) *Policy {
+	if policy, ok := p.procedurePolicies[serviceProcedure{service: req.Service, procedure: req.Procedure}]; ok {
+		return policy
+	}
+	if policy, ok := p.servicePolicies[req.Service]; ok {
+		return policy
+	}
+	return p.defaultPolicy
+}
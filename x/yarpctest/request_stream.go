@@ -30,6 +30,8 @@ import (
 	"github.com/stretchr/testify/require"
 	"go.uber.org/yarpc/api/transport"
 	"go.uber.org/yarpc/transport/grpc"
+	"go.uber.org/yarpc/transport/http"
+	"go.uber.org/yarpc/transport/tchannel"
 	"go.uber.org/yarpc/x/yarpctest/api"
 )
 
@@ -62,6 +64,65 @@ func GRPCStreamRequest(options ...api.ClientStreamRequestOption) api.Action {
 	})
 }
 
+// HTTPStreamRequest creates a new http stream request.
+func HTTPStreamRequest(options ...api.ClientStreamRequestOption) api.Action {
+	return api.ActionFunc(func(t testing.TB) {
+		opts := api.NewClientStreamRequestOpts()
+		for _, option := range options {
+			option.ApplyClientStreamRequest(&opts)
+		}
+
+		trans := http.NewTransport()
+		out := trans.NewSingleOutbound(fmt.Sprintf("http://127.0.0.1:%d/", opts.Port))
+
+		require.NoError(t, trans.Start())
+		defer func() { assert.NoError(t, trans.Stop()) }()
+
+		require.NoError(t, out.Start())
+		defer func() { assert.NoError(t, out.Stop()) }()
+
+		err := callStream(t, out, opts.GiveRequest, opts.StreamActions)
+		if len(opts.WantErrMsgs) > 0 {
+			require.Error(t, err)
+			for _, wantErrMsg := range opts.WantErrMsgs {
+				require.Contains(t, err.Error(), wantErrMsg)
+			}
+			return
+		}
+		require.NoError(t, err)
+	})
+}
+
+// TChannelStreamRequest creates a new tchannel stream request.
+func TChannelStreamRequest(options ...api.ClientStreamRequestOption) api.Action {
+	return api.ActionFunc(func(t testing.TB) {
+		opts := api.NewClientStreamRequestOpts()
+		for _, option := range options {
+			option.ApplyClientStreamRequest(&opts)
+		}
+
+		trans, err := tchannel.NewTransport(tchannel.ServiceName(opts.GiveRequest.Meta.Caller))
+		require.NoError(t, err)
+		out := trans.NewSingleOutbound(fmt.Sprintf("127.0.0.1:%d", opts.Port))
+
+		require.NoError(t, trans.Start())
+		defer func() { assert.NoError(t, trans.Stop()) }()
+
+		require.NoError(t, out.Start())
+		defer func() { assert.NoError(t, out.Stop()) }()
+
+		err = callStream(t, out, opts.GiveRequest, opts.StreamActions)
+		if len(opts.WantErrMsgs) > 0 {
+			require.Error(t, err)
+			for _, wantErrMsg := range opts.WantErrMsgs {
+				require.Contains(t, err.Error(), wantErrMsg)
+			}
+			return
+		}
+		require.NoError(t, err)
+	})
+}
+
 func callStream(
 	t testing.TB,
 	out transport.StreamOutbound,
@@ -25,6 +25,7 @@ import (
 
 	"go.uber.org/multierr"
 	"go.uber.org/yarpc"
+	"go.uber.org/yarpc/api/middleware"
 	"go.uber.org/yarpc/api/transport"
 	"go.uber.org/yarpc/internal/config"
 )
@@ -46,6 +47,16 @@ type buildableOutbound struct {
 	Value         *buildable
 }
 
+// buildableMiddleware holds the decoded configuration of a middleware for
+// each RPC type. Entries are nil for RPC types not supported by the
+// middleware.
+type buildableMiddleware struct {
+	Name   string
+	Unary  *buildable
+	Oneway *buildable
+	Stream *buildable
+}
+
 type builder struct {
 	Name string
 	kit  *Kit
@@ -57,6 +68,10 @@ type builder struct {
 	transports map[string]*buildable
 	inbounds   []buildableInbound
 	clients    map[string]*buildableOutbounds
+
+	// Middleware in the order in which it was specified.
+	inboundMiddleware  []buildableMiddleware
+	outboundMiddleware []buildableMiddleware
 }
 
 func newBuilder(name string, kit *Kit) *builder {
@@ -140,9 +155,123 @@ func (b *builder) Build() (yarpc.Config, error) {
 		cfg.Outbounds = outbounds
 	}
 
+	inboundMiddleware, err := buildInboundMiddleware(b.inboundMiddleware, b.kit)
+	if err != nil {
+		errs = multierr.Append(errs, err)
+	}
+	cfg.InboundMiddleware = inboundMiddleware
+
+	outboundMiddleware, err := buildOutboundMiddleware(b.outboundMiddleware, b.kit)
+	if err != nil {
+		errs = multierr.Append(errs, err)
+	}
+	cfg.OutboundMiddleware = outboundMiddleware
+
 	return cfg, errs
 }
 
+// buildInboundMiddleware builds and chains the given inbound middleware in
+// order. Fields of the result are left nil for RPC types that have no
+// middleware.
+func buildInboundMiddleware(items []buildableMiddleware, k *Kit) (yarpc.InboundMiddleware, error) {
+	var (
+		unary  []middleware.UnaryInbound
+		oneway []middleware.OnewayInbound
+		stream []middleware.StreamInbound
+		errs   error
+	)
+
+	for _, m := range items {
+		if m.Unary != nil {
+			result, err := m.Unary.Build(k)
+			if err != nil {
+				errs = multierr.Append(errs, fmt.Errorf("failed to build unary inbound middleware %q: %v", m.Name, err))
+			} else {
+				unary = append(unary, result.(middleware.UnaryInbound))
+			}
+		}
+		if m.Oneway != nil {
+			result, err := m.Oneway.Build(k)
+			if err != nil {
+				errs = multierr.Append(errs, fmt.Errorf("failed to build oneway inbound middleware %q: %v", m.Name, err))
+			} else {
+				oneway = append(oneway, result.(middleware.OnewayInbound))
+			}
+		}
+		if m.Stream != nil {
+			result, err := m.Stream.Build(k)
+			if err != nil {
+				errs = multierr.Append(errs, fmt.Errorf("failed to build stream inbound middleware %q: %v", m.Name, err))
+			} else {
+				stream = append(stream, result.(middleware.StreamInbound))
+			}
+		}
+	}
+
+	var mw yarpc.InboundMiddleware
+	if len(unary) > 0 {
+		mw.Unary = yarpc.UnaryInboundMiddleware(unary...)
+	}
+	if len(oneway) > 0 {
+		mw.Oneway = yarpc.OnewayInboundMiddleware(oneway...)
+	}
+	if len(stream) > 0 {
+		mw.Stream = yarpc.StreamInboundMiddleware(stream...)
+	}
+	return mw, errs
+}
+
+// buildOutboundMiddleware builds and chains the given outbound middleware in
+// order. Fields of the result are left nil for RPC types that have no
+// middleware.
+func buildOutboundMiddleware(items []buildableMiddleware, k *Kit) (yarpc.OutboundMiddleware, error) {
+	var (
+		unary  []middleware.UnaryOutbound
+		oneway []middleware.OnewayOutbound
+		stream []middleware.StreamOutbound
+		errs   error
+	)
+
+	for _, m := range items {
+		if m.Unary != nil {
+			result, err := m.Unary.Build(k)
+			if err != nil {
+				errs = multierr.Append(errs, fmt.Errorf("failed to build unary outbound middleware %q: %v", m.Name, err))
+			} else {
+				unary = append(unary, result.(middleware.UnaryOutbound))
+			}
+		}
+		if m.Oneway != nil {
+			result, err := m.Oneway.Build(k)
+			if err != nil {
+				errs = multierr.Append(errs, fmt.Errorf("failed to build oneway outbound middleware %q: %v", m.Name, err))
+			} else {
+				oneway = append(oneway, result.(middleware.OnewayOutbound))
+			}
+		}
+		if m.Stream != nil {
+			result, err := m.Stream.Build(k)
+			if err != nil {
+				errs = multierr.Append(errs, fmt.Errorf("failed to build stream outbound middleware %q: %v", m.Name, err))
+			} else {
+				stream = append(stream, result.(middleware.StreamOutbound))
+			}
+		}
+	}
+
+	var mw yarpc.OutboundMiddleware
+	if len(unary) > 0 {
+		mw.Unary = yarpc.UnaryOutboundMiddleware(unary...)
+	}
+	if len(oneway) > 0 {
+		mw.Oneway = yarpc.OnewayOutboundMiddleware(oneway...)
+	}
+	if len(stream) > 0 {
+		mw.Stream = yarpc.StreamOutboundMiddleware(stream...)
+	}
+	return mw, errs
+}
+
 // buildTransport builds a Transport from the given value. This will panic if
 // the output type is not a Transport.
 func buildTransport(cv *buildable, k *Kit) (transport.Transport, error) {
@@ -323,6 +452,58 @@ func (b *builder) AddStreamOutbound(
 	return nil
 }
 
+func (b *builder) AddInboundMiddleware(spec *compiledMiddlewareSpec, attrs config.AttributeMap) error {
+	if !spec.SupportsInbound() {
+		return fmt.Errorf("middleware %q does not support inbound requests", spec.Name)
+	}
+
+	m, err := decodeMiddleware(spec.Name, spec.UnaryInbound, spec.OnewayInbound, spec.StreamInbound, attrs, b.kit)
+	if err != nil {
+		return fmt.Errorf("failed to decode inbound middleware configuration: %v", err)
+	}
+
+	b.inboundMiddleware = append(b.inboundMiddleware, m)
+	return nil
+}
+
+func (b *builder) AddOutboundMiddleware(spec *compiledMiddlewareSpec, attrs config.AttributeMap) error {
+	if !spec.SupportsOutbound() {
+		return fmt.Errorf("middleware %q does not support outbound requests", spec.Name)
+	}
+
+	m, err := decodeMiddleware(spec.Name, spec.UnaryOutbound, spec.OnewayOutbound, spec.StreamOutbound, attrs, b.kit)
+	if err != nil {
+		return fmt.Errorf("failed to decode outbound middleware configuration: %v", err)
+	}
+
+	b.outboundMiddleware = append(b.outboundMiddleware, m)
+	return nil
+}
+
+// decodeMiddleware decodes the given attributes for each of the RPC types
+// supported by a middleware.
+func decodeMiddleware(name string, unary, oneway, stream *configSpec, attrs config.AttributeMap, k *Kit) (buildableMiddleware, error) {
+	m := buildableMiddleware{Name: name}
+
+	var err error
+	if unary != nil {
+		if m.Unary, err = unary.Decode(attrs, config.InterpolateWith(k.resolver)); err != nil {
+			return m, err
+		}
+	}
+	if oneway != nil {
+		if m.Oneway, err = oneway.Decode(attrs, config.InterpolateWith(k.resolver)); err != nil {
+			return m, err
+		}
+	}
+	if stream != nil {
+		if m.Stream, err = stream.Decode(attrs, config.InterpolateWith(k.resolver)); err != nil {
+			return m, err
+		}
+	}
+	return m, nil
+}
+
 func (b *builder) needTransport(spec *compiledTransportSpec) {
 	b.needTransports[spec.Name] = spec
 }
@@ -128,6 +128,8 @@ func (pc PeerChooser) Empty() bool {
 // The Kit received by the Build*Outbound function MUST be passed to
 // BuildPeerChooser as-is.
 func (pc PeerChooser) BuildPeerChooser(transport peer.Transport, identify func(string) peer.Identifier, kit *Kit) (peer.Chooser, error) {
+	kit = kit.withIdentify(identify)
+
 	// Establish a peer selection strategy.
 	switch {
 	case pc.Peer != "":
@@ -29,6 +29,7 @@ import (
 
 	"go.uber.org/multierr"
 	"go.uber.org/yarpc"
+	"go.uber.org/yarpc/api/transport"
 	"go.uber.org/yarpc/internal/config"
 	"go.uber.org/yarpc/internal/interpolate"
 	"gopkg.in/yaml.v2"
@@ -36,15 +37,18 @@ import (
 
 // Configurator helps build Dispatchers using runtime configuration.
 //
-// A new Configurator does not know about any transports, peer lists, or peer
-// list updaters. Inform it about them by using the RegisterTransport,
-// RegisterPeerList, and RegisterPeerListUpdater functions, or their Must*
+// A new Configurator does not know about any transports, peer lists, peer
+// list updaters, middleware, or compressors. Inform it about them by using
+// the RegisterTransport, RegisterPeerList, RegisterPeerListUpdater,
+// RegisterMiddleware, and RegisterCompressor functions, or their Must*
 // variants.
 type Configurator struct {
 	knownTransports       map[string]*compiledTransportSpec
 	knownPeerChoosers     map[string]*compiledPeerChooserSpec
 	knownPeerLists        map[string]*compiledPeerListSpec
 	knownPeerListUpdaters map[string]*compiledPeerListUpdaterSpec
+	knownMiddleware       map[string]*compiledMiddlewareSpec
+	knownCompressors      map[string]transport.Compressor
 	resolver              interpolate.VariableResolver
 }
 
@@ -56,6 +60,8 @@ func New(opts ...Option) *Configurator {
 		knownPeerChoosers:     make(map[string]*compiledPeerChooserSpec),
 		knownPeerLists:        make(map[string]*compiledPeerListSpec),
 		knownPeerListUpdaters: make(map[string]*compiledPeerListUpdaterSpec),
+		knownMiddleware:       make(map[string]*compiledMiddlewareSpec),
+		knownCompressors:      make(map[string]transport.Compressor),
 		resolver:              os.LookupEnv,
 	}
 
@@ -204,6 +210,72 @@ func (c *Configurator) MustRegisterPeerListUpdater(s PeerListUpdaterSpec) {
 	}
 }
 
+// RegisterMiddleware registers a MiddlewareSpec with the given Configurator,
+// teaching it how to build middleware of this kind from configuration.
+//
+// Returns an error if the MiddlewareSpec is invalid. Use MustRegisterMiddleware
+// to panic if the registration fails.
+//
+// If a middleware with the same name already exists, it will be replaced.
+//
+// See MiddlewareSpec for details on how to integrate your own middleware with
+// the system.
+func (c *Configurator) RegisterMiddleware(s MiddlewareSpec) error {
+	if s.Name == "" {
+		return errors.New("name is required")
+	}
+
+	spec, err := compileMiddlewareSpec(&s)
+	if err != nil {
+		return fmt.Errorf("invalid MiddlewareSpec for %q: %v", s.Name, err)
+	}
+
+	c.knownMiddleware[s.Name] = spec
+	return nil
+}
+
+// MustRegisterMiddleware registers the given MiddlewareSpec with the
+// Configurator. This function panics if the MiddlewareSpec is invalid.
+func (c *Configurator) MustRegisterMiddleware(s MiddlewareSpec) {
+	if err := c.RegisterMiddleware(s); err != nil {
+		panic(err)
+	}
+}
+
+// RegisterCompressor registers a Compressor with the given Configurator,
+// making it available to transports built from configuration.
+//
+// Transports use all registered compressors to decompress requests and
+// responses, and to compress responses for callers that accept them.
+// Outbounds compress their requests with the compressor named by their
+// configuration, if any.
+//
+//   outbounds:
+//     myservice:
+//       grpc:
+//         address: ":8080"
+//         compressor: gzip
+//
+// Returns an error if the compressor has no name. Use MustRegisterCompressor
+// to panic if the registration fails.
+//
+// If a compressor with the same name already exists, it will be replaced.
+func (c *Configurator) RegisterCompressor(z transport.Compressor) error {
+	if z.Name() == "" {
+		return errors.New("name is required")
+	}
+	c.knownCompressors[z.Name()] = z
+	return nil
+}
+
+// MustRegisterCompressor registers the given Compressor with the
+// Configurator. This function panics if the Compressor is invalid.
+func (c *Configurator) MustRegisterCompressor(z transport.Compressor) {
+	if err := c.RegisterCompressor(z); err != nil {
+		panic(err)
+	}
+}
+
 // LoadConfigFromYAML loads a yarpc.Config from YAML data. Use LoadConfig if
 // you have already parsed a map[string]interface{} or
 // map[interface{}]interface{}.
@@ -273,6 +345,18 @@ func (c *Configurator) load(serviceName string, cfg *yarpcConfig) (_ yarpc.Confi
 		}
 	}
 
+	for _, m := range cfg.Middleware.Inbound {
+		if e := c.loadInboundMiddlewareInto(b, m); e != nil {
+			err = multierr.Append(err, e)
+		}
+	}
+
+	for _, m := range cfg.Middleware.Outbound {
+		if e := c.loadOutboundMiddlewareInto(b, m); e != nil {
+			err = multierr.Append(err, e)
+		}
+	}
+
 	if err != nil {
 		return yarpc.Config{}, err
 	}
@@ -348,6 +432,34 @@ func (c *Configurator) loadTransportInto(b *builder, name string, attrs config.A
 	return b.AddTransportConfig(spec, attrs)
 }
 
+func (c *Configurator) loadInboundMiddlewareInto(b *builder, m middlewareItem) error {
+	spec, err := c.middlewareSpec(m.Name)
+	if err != nil {
+		return fmt.Errorf("failed to load inbound middleware: %v", err)
+	}
+
+	return b.AddInboundMiddleware(spec, m.Attributes)
+}
+
+func (c *Configurator) loadOutboundMiddlewareInto(b *builder, m middlewareItem) error {
+	spec, err := c.middlewareSpec(m.Name)
+	if err != nil {
+		return fmt.Errorf("failed to load outbound middleware: %v", err)
+	}
+
+	return b.AddOutboundMiddleware(spec, m.Attributes)
+}
+
+// Returns the compiled spec for the middleware with the given name or an
+// error
+func (c *Configurator) middlewareSpec(name string) (*compiledMiddlewareSpec, error) {
+	spec, ok := c.knownMiddleware[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown middleware %q", name)
+	}
+	return spec, nil
+}
+
 // Returns the compiled spec for the transport with the given name or an error
 func (c *Configurator) spec(name string) (*compiledTransportSpec, error) {
 	spec, ok := c.knownTransports[name]
@@ -21,6 +21,8 @@
 package yarpcconfig
 
 import (
+	"context"
+	"errors"
 	"reflect"
 	"strings"
 	"testing"
@@ -30,6 +32,8 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/yarpc"
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
 	"go.uber.org/yarpc/api/transport/transporttest"
 	"go.uber.org/yarpc/internal/interpolate"
 	"go.uber.org/yarpc/internal/whitespace"
@@ -68,6 +72,19 @@ func TestConfiguratorRegisterErrors(t *testing.T) {
 	err = New().RegisterPeerListUpdater(PeerListUpdaterSpec{Name: "test"})
 	require.Error(t, err, "expected failure")
 	assert.Contains(t, err.Error(), "invalid PeerListUpdaterSpec for \"test\":")
+
+	require.Panics(t, func() { New().MustRegisterMiddleware(MiddlewareSpec{}) })
+	err = New().RegisterMiddleware(MiddlewareSpec{})
+	require.Error(t, err, "expected failure")
+	assert.Contains(t, err.Error(), "name is required")
+	err = New().RegisterMiddleware(MiddlewareSpec{Name: "test"})
+	require.Error(t, err, "expected failure")
+	assert.Contains(t, err.Error(), "invalid MiddlewareSpec for \"test\":")
+
+	require.Panics(t, func() { New().MustRegisterCompressor(namedCompressor("")) })
+	err = New().RegisterCompressor(namedCompressor(""))
+	require.Error(t, err, "expected failure")
+	assert.Contains(t, err.Error(), "name is required")
 }
 
 func TestConfigurator(t *testing.T) {
@@ -1290,6 +1307,164 @@ func TestConfigurator(t *testing.T) {
 	}
 }
 
+// tagMiddleware is a unary inbound and outbound middleware that records its
+// tag in the order in which it was called.
+type tagMiddleware struct {
+	tag  string
+	seen *[]string
+}
+
+func (m tagMiddleware) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter, h transport.UnaryHandler) error {
+	*m.seen = append(*m.seen, m.tag)
+	return h.Handle(ctx, req, resw)
+}
+
+func (m tagMiddleware) Call(ctx context.Context, req *transport.Request, out transport.UnaryOutbound) (*transport.Response, error) {
+	*m.seen = append(*m.seen, m.tag)
+	return out.Call(ctx, req)
+}
+
+func TestConfiguratorMiddleware(t *testing.T) {
+	type tagConfig struct {
+		Tag string `config:"tag,interpolate"`
+	}
+
+	var seen []string
+	buildInbound := func(c tagConfig, k *Kit) (middleware.UnaryInbound, error) {
+		if c.Tag == "" {
+			return nil, errors.New("tag is required")
+		}
+		return tagMiddleware{tag: "in-" + c.Tag, seen: &seen}, nil
+	}
+	buildOutbound := func(c *tagConfig, k *Kit) (middleware.UnaryOutbound, error) {
+		if c.Tag == "" {
+			return nil, errors.New("tag is required")
+		}
+		return tagMiddleware{tag: "out-" + c.Tag, seen: &seen}, nil
+	}
+
+	newConfigurator := func() *Configurator {
+		cfg := New(InterpolationResolver(mapVariableResolver(map[string]string{"TAG": "b"})))
+		cfg.MustRegisterMiddleware(MiddlewareSpec{
+			Name:               "tag",
+			BuildUnaryInbound:  buildInbound,
+			BuildUnaryOutbound: buildOutbound,
+		})
+		cfg.MustRegisterMiddleware(MiddlewareSpec{
+			Name:               "outbound-tag",
+			BuildUnaryOutbound: buildOutbound,
+		})
+		return cfg
+	}
+
+	t.Run("success", func(t *testing.T) {
+		seen = nil
+		cfg, err := newConfigurator().LoadConfigFromYAML("foo", strings.NewReader(whitespace.Expand(`
+			middleware:
+				inbound:
+					- tag: {tag: a}
+					- tag: {tag: "${TAG}"}
+				outbound:
+					- outbound-tag: {tag: c}
+					- tag: {tag: d}
+		`)))
+		require.NoError(t, err, "expected success")
+
+		assert.Nil(t, cfg.InboundMiddleware.Oneway, "expected no oneway inbound middleware")
+		assert.Nil(t, cfg.InboundMiddleware.Stream, "expected no stream inbound middleware")
+		assert.Nil(t, cfg.OutboundMiddleware.Oneway, "expected no oneway outbound middleware")
+		assert.Nil(t, cfg.OutboundMiddleware.Stream, "expected no stream outbound middleware")
+
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+
+		h := transporttest.NewMockUnaryHandler(mockCtrl)
+		h.EXPECT().Handle(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+		require.NoError(t, cfg.InboundMiddleware.Unary.Handle(context.Background(), &transport.Request{}, nil, h))
+
+		o := transporttest.NewMockUnaryOutbound(mockCtrl)
+		o.EXPECT().Call(gomock.Any(), gomock.Any()).Return(&transport.Response{}, nil)
+		_, err = cfg.OutboundMiddleware.Unary.Call(context.Background(), &transport.Request{}, o)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"in-a", "in-b", "out-c", "out-d"}, seen, "middleware must be applied in order")
+	})
+
+	tests := []struct {
+		desc    string
+		give    string
+		wantErr []string
+	}{
+		{
+			desc: "unknown middleware",
+			give: `
+				middleware:
+					outbound:
+						- retry: {}
+			`,
+			wantErr: []string{
+				"failed to load outbound middleware",
+				`unknown middleware "retry"`,
+			},
+		},
+		{
+			desc: "unsupported direction",
+			give: `
+				middleware:
+					inbound:
+						- outbound-tag: {tag: a}
+			`,
+			wantErr: []string{
+				`middleware "outbound-tag" does not support inbound requests`,
+			},
+		},
+		{
+			desc: "too many names",
+			give: `
+				middleware:
+					inbound:
+						- tag: {tag: a}
+						  outbound-tag: {tag: b}
+			`,
+			wantErr: []string{
+				"exactly one middleware name must be specified per item, found 2",
+			},
+		},
+		{
+			desc: "decode error",
+			give: `
+				middleware:
+					inbound:
+						- tag: {tag: [a]}
+			`,
+			wantErr: []string{
+				"failed to decode inbound middleware configuration",
+			},
+		},
+		{
+			desc: "build error",
+			give: `
+				middleware:
+					outbound:
+						- tag: {}
+			`,
+			wantErr: []string{
+				`failed to build unary outbound middleware "tag": tag is required`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			_, err := newConfigurator().LoadConfigFromYAML("foo", strings.NewReader(whitespace.Expand(tt.give)))
+			require.Error(t, err, "expected failure")
+			for _, msg := range tt.wantErr {
+				assert.Contains(t, err.Error(), msg)
+			}
+		})
+	}
+}
+
 func mapVariableResolver(m map[string]string) interpolate.VariableResolver {
 	return func(name string) (value string, ok bool) {
 		value, ok = m[name]
@@ -32,6 +32,36 @@ type yarpcConfig struct {
 	Inbounds   inbounds                       `config:"inbounds"`
 	Outbounds  clientConfigs                  `config:"outbounds"`
 	Transports map[string]config.AttributeMap `config:"transports"`
+	Middleware middlewareConfig               `config:"middleware"`
+}
+
+type middlewareConfig struct {
+	Inbound  []middlewareItem `config:"inbound"`
+	Outbound []middlewareItem `config:"outbound"`
+}
+
+type middlewareItem struct {
+	Name       string
+	Attributes config.AttributeMap
+}
+
+func (m *middlewareItem) Decode(into mapdecode.Into) error {
+	var cfg map[string]config.AttributeMap
+	if err := into(&cfg); err != nil {
+		return fmt.Errorf("failed to decode middleware: %v", err)
+	}
+
+	if len(cfg) != 1 {
+		return fmt.Errorf("failed to decode middleware: "+
+			"exactly one middleware name must be specified per item, found %d", len(cfg))
+	}
+
+	for k, attrs := range cfg {
+		m.Name = k
+		m.Attributes = attrs
+	}
+
+	return nil
 }
 
 type inbounds []inbound
@@ -29,7 +29,7 @@
 // different transports, peer lists, etc. that you want to use. You can inform
 // the Configurator about the different transports, peer lists, etc. by
 // registering them using RegisterTransport, RegisterPeerChooser,
-// RegisterPeerList, and RegisterPeerListUpdater.
+// RegisterPeerList, RegisterPeerListUpdater, and RegisterMiddleware.
 //
 // 	cfg := config.New()
 // 	cfg.MustRegisterTransport(http.TransportSpec())
@@ -78,7 +78,7 @@
 // as long as the information provided is the same.
 //
 // The configuration accepts the following top-level attributes: transports,
-// inbounds, and outbounds.
+// inbounds, outbounds, and middleware.
 //
 // 	inbounds:
 // 	  # ...
@@ -86,9 +86,11 @@
 // 	  # ...
 // 	transports:
 // 	  # ...
+// 	middleware:
+// 	  # ...
 //
-// See the following sections for details on the transports, inbounds, and
-// outbounds keys in the configuration.
+// See the following sections for details on the transports, inbounds,
+// outbounds, and middleware keys in the configuration.
 //
 // Inbound Configuration
 //
@@ -241,6 +243,26 @@
 // (For details on the configuration parameters of individual transport types,
 // check the documentation for the corresponding transport package.)
 //
+// Middleware Configuration
+//
+// The 'middleware' attribute configures middleware that is applied to all
+// requests received by inbounds and sent through outbounds. Middleware must
+// be registered with the Configurator using RegisterMiddleware before it can
+// be referenced by name. The 'inbound' and 'outbound' lists are applied in
+// the order in which they are specified, with the first entry being the
+// outermost middleware.
+//
+// 	middleware:
+// 	  inbound:
+// 	    - mymiddleware:
+// 	        someattribute: ...
+// 	  outbound:
+// 	    - retry:
+// 	        # ...
+//
+// (For details on the configuration parameters of individual middleware,
+// check the documentation for the corresponding middleware package.)
+//
 // Customizing Configuration
 //
 // When building your own TransportSpec, PeerListSpec, or PeerListUpdaterSpec,
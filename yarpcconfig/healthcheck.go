@@ -0,0 +1,47 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcconfig
+
+import "time"
+
+// HealthCheck configures active health checking of the peers of a
+// transport. While enabled, the transport periodically calls the health
+// procedure served by the dispatcher of each available peer, and marks the
+// peer unavailable after a number of consecutive failed checks.
+//
+//  healthCheck:
+//    interval: 5s
+//    timeout: 1s
+//    failures: 3
+//
+// Health checks are disabled unless an interval is given. They only work
+// with peers that run YARPC dispatchers.
+type HealthCheck struct {
+	// Interval between health checks of an available peer.
+	Interval time.Duration `config:"interval"`
+
+	// Timeout of each health check. Defaults to 1 second.
+	Timeout time.Duration `config:"timeout"`
+
+	// Failures is the number of consecutive failed health checks after
+	// which a peer is marked unavailable. Defaults to 3.
+	Failures int `config:"failures"`
+}
@@ -27,7 +27,10 @@ import (
 	"sort"
 	"strings"
 
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
 	"go.uber.org/yarpc/internal/interpolate"
+	"go.uber.org/yarpc/peer/hostport"
 )
 
 // Kit is an opaque object that carries context for the Configurator. Build
@@ -42,6 +45,10 @@ type Kit struct {
 
 	// TransportSpec currently being used. This may or may not be set.
 	transportSpec *compiledTransportSpec
+
+	// Function used by the outbound being built to identify peers. This may
+	// or may not be set.
+	identify func(string) peer.Identifier
 }
 
 // Returns a shallow copy of this Kit with spec set to the given value.
@@ -51,10 +58,62 @@ func (k *Kit) withTransportSpec(spec *compiledTransportSpec) *Kit {
 	return &newK
 }
 
+// Returns a shallow copy of this Kit with identify set to the given value.
+func (k *Kit) withIdentify(identify func(string) peer.Identifier) *Kit {
+	newK := *k
+	newK.identify = identify
+	return &newK
+}
+
 // ServiceName returns the name of the service for which components are being
 // built.
 func (k *Kit) ServiceName() string { return k.name }
 
+// Compressor returns the compressor registered with the given name, or an
+// error if no such compressor has been registered.
+func (k *Kit) Compressor(name string) (transport.Compressor, error) {
+	if z, ok := k.c.knownCompressors[name]; ok {
+		return z, nil
+	}
+
+	msg := fmt.Sprintf("no recognized compressor %q", name)
+	if available := k.compressorNames(); len(available) > 0 {
+		msg = fmt.Sprintf("%s; need one of %s", msg, strings.Join(available, ", "))
+	}
+
+	return nil, errors.New(msg)
+}
+
+// Compressors returns all registered compressors, ordered by name.
+func (k *Kit) Compressors() []transport.Compressor {
+	names := k.compressorNames()
+	compressors := make([]transport.Compressor, 0, len(names))
+	for _, name := range names {
+		compressors = append(compressors, k.c.knownCompressors[name])
+	}
+	return compressors
+}
+
+func (k *Kit) compressorNames() (names []string) {
+	for name := range k.c.knownCompressors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// Identify converts a peer address into a peer identifier the way the
+// outbound being built does. Peer chooser specs that build nested peer
+// choosers pass it to PeerChooser.BuildPeerChooser for each of them.
+//
+// Outside of building a peer chooser, it identifies peers by host and port.
+func (k *Kit) Identify(addr string) peer.Identifier {
+	if k.identify == nil {
+		return hostport.Identify(addr)
+	}
+	return k.identify(addr)
+}
+
 var _typeOfKit = reflect.TypeOf((*Kit)(nil))
 
 func (k *Kit) maybePeerChooserSpec(name string) *compiledPeerChooserSpec {
@@ -21,9 +21,14 @@
 package yarpcconfig
 
 import (
+	"io"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/peer/hostport"
 )
 
 func TestKitWithTransportSpec(t *testing.T) {
@@ -40,3 +45,42 @@ func TestKitWithTransportSpec(t *testing.T) {
 	assert.Equal(t, "foo", root.ServiceName())
 	assert.Equal(t, "bar", child.ServiceName())
 }
+
+func TestKitIdentify(t *testing.T) {
+	root := &Kit{name: "foo"}
+	assert.Equal(t, hostport.Identify("127.0.0.1:80"), root.Identify("127.0.0.1:80"))
+
+	child := root.withIdentify(func(addr string) peer.Identifier {
+		return hostport.Identify("identified-" + addr)
+	})
+	assert.Nil(t, root.identify, "identify must be nil")
+	assert.Equal(t, hostport.Identify("identified-127.0.0.1:80"), child.Identify("127.0.0.1:80"))
+}
+
+type namedCompressor string
+
+func (c namedCompressor) Name() string { return string(c) }
+
+func (namedCompressor) Compress(io.Writer) (io.WriteCloser, error) { panic("not implemented") }
+
+func (namedCompressor) Decompress(io.Reader) (io.ReadCloser, error) { panic("not implemented") }
+
+func TestKitCompressors(t *testing.T) {
+	c := New()
+	k := &Kit{c: c, name: "foo"}
+
+	assert.Empty(t, k.Compressors())
+	_, err := k.Compressor("gzip")
+	assert.EqualError(t, err, `no recognized compressor "gzip"`)
+
+	c.MustRegisterCompressor(namedCompressor("snappy"))
+	c.MustRegisterCompressor(namedCompressor("gzip"))
+
+	z, err := k.Compressor("gzip")
+	require.NoError(t, err)
+	assert.Equal(t, namedCompressor("gzip"), z)
+	assert.Equal(t, []transport.Compressor{namedCompressor("gzip"), namedCompressor("snappy")}, k.Compressors())
+
+	_, err = k.Compressor("zstd")
+	assert.EqualError(t, err, `no recognized compressor "zstd"; need one of gzip, snappy`)
+}
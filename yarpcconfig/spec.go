@@ -28,6 +28,7 @@ import (
 
 	"github.com/uber-go/mapdecode"
 	"go.uber.org/multierr"
+	"go.uber.org/yarpc/api/middleware"
 	"go.uber.org/yarpc/api/peer"
 	"go.uber.org/yarpc/api/transport"
 	"go.uber.org/yarpc/internal/config"
@@ -244,6 +245,58 @@ type PeerListUpdaterSpec struct {
 	BuildPeerListUpdater interface{}
 }
 
+// MiddlewareSpec specifies the configuration parameters for a middleware.
+// These specifications are registered against a Configurator to teach it how
+// to parse the configuration for that middleware and build instances of it.
+//
+// For example, a retry middleware could be registered and then enabled for
+// all outbound requests.
+//
+// 	middleware:
+// 	  outbound:
+// 	    - retry:
+// 	        maxAttempts: 3
+//
+// Every MiddlewareSpec MUST provide at least one Build* function.
+type MiddlewareSpec struct {
+	// Name of the middleware.
+	Name string
+
+	// The following are functions in the shapes,
+	//
+	// 	func(C, *config.Kit) (middleware.UnaryInbound, error)
+	// 	func(C, *config.Kit) (middleware.OnewayInbound, error)
+	// 	func(C, *config.Kit) (middleware.StreamInbound, error)
+	//
+	// Where C is a struct or pointer to a struct defining the configuration
+	// parameters for the middleware.
+	//
+	// Any of these may be nil to indicate that the middleware does not
+	// support inbound requests of that RPC type. The middleware may be
+	// listed under `middleware.inbound` only if at least one of these is
+	// provided.
+	BuildUnaryInbound  interface{}
+	BuildOnewayInbound interface{}
+	BuildStreamInbound interface{}
+
+	// The following are functions in the shapes,
+	//
+	// 	func(C, *config.Kit) (middleware.UnaryOutbound, error)
+	// 	func(C, *config.Kit) (middleware.OnewayOutbound, error)
+	// 	func(C, *config.Kit) (middleware.StreamOutbound, error)
+	//
+	// Where C is a struct or pointer to a struct defining the configuration
+	// parameters for the middleware.
+	//
+	// Any of these may be nil to indicate that the middleware does not
+	// support outbound requests of that RPC type. The middleware may be
+	// listed under `middleware.outbound` only if at least one of these is
+	// provided.
+	BuildUnaryOutbound  interface{}
+	BuildOnewayOutbound interface{}
+	BuildStreamOutbound interface{}
+}
+
 var (
 	_typeOfError           = reflect.TypeOf((*error)(nil)).Elem()
 	_typeOfTransport       = reflect.TypeOf((*transport.Transport)(nil)).Elem()
@@ -255,6 +308,13 @@ var (
 	_typeOfPeerChooserList = reflect.TypeOf((*peer.ChooserList)(nil)).Elem()
 	_typeOfPeerChooser     = reflect.TypeOf((*peer.Chooser)(nil)).Elem()
 	_typeOfBinder          = reflect.TypeOf((*peer.Binder)(nil)).Elem()
+
+	_typeOfUnaryInboundMiddleware   = reflect.TypeOf((*middleware.UnaryInbound)(nil)).Elem()
+	_typeOfOnewayInboundMiddleware  = reflect.TypeOf((*middleware.OnewayInbound)(nil)).Elem()
+	_typeOfStreamInboundMiddleware  = reflect.TypeOf((*middleware.StreamInbound)(nil)).Elem()
+	_typeOfUnaryOutboundMiddleware  = reflect.TypeOf((*middleware.UnaryOutbound)(nil)).Elem()
+	_typeOfOnewayOutboundMiddleware = reflect.TypeOf((*middleware.OnewayOutbound)(nil)).Elem()
+	_typeOfStreamOutboundMiddleware = reflect.TypeOf((*middleware.StreamOutbound)(nil)).Elem()
 )
 
 // Compiled internal representation of a user-specified TransportSpec.
@@ -681,6 +741,98 @@ func compilePeerListUpdaterConfig(name string, build interface{}) (*configSpec,
 	return &configSpec{inputType: t.In(0), factory: v}, nil
 }
 
+// Compiled internal representation of a user-specified MiddlewareSpec.
+//
+// The following are non-nil only if the middleware supports that specific
+// functionality.
+type compiledMiddlewareSpec struct {
+	Name string
+
+	UnaryInbound  *configSpec
+	OnewayInbound *configSpec
+	StreamInbound *configSpec
+
+	UnaryOutbound  *configSpec
+	OnewayOutbound *configSpec
+	StreamOutbound *configSpec
+}
+
+func (s *compiledMiddlewareSpec) SupportsInbound() bool {
+	return s.UnaryInbound != nil || s.OnewayInbound != nil || s.StreamInbound != nil
+}
+
+func (s *compiledMiddlewareSpec) SupportsOutbound() bool {
+	return s.UnaryOutbound != nil || s.OnewayOutbound != nil || s.StreamOutbound != nil
+}
+
+func compileMiddlewareSpec(spec *MiddlewareSpec) (*compiledMiddlewareSpec, error) {
+	out := compiledMiddlewareSpec{Name: spec.Name}
+
+	if spec.Name == "" {
+		return nil, errors.New("Name is required")
+	}
+
+	var err error
+
+	// Helper to compile the optional build functions and collect errors
+	compile := func(build interface{}, name string, outputType reflect.Type) *configSpec {
+		if build == nil {
+			return nil
+		}
+		cs, e := compileMiddlewareConfig(build, outputType)
+		if e != nil {
+			err = multierr.Append(err, fmt.Errorf("invalid %v: %v", name, e))
+		}
+		return cs
+	}
+
+	out.UnaryInbound = compile(spec.BuildUnaryInbound, "BuildUnaryInbound", _typeOfUnaryInboundMiddleware)
+	out.OnewayInbound = compile(spec.BuildOnewayInbound, "BuildOnewayInbound", _typeOfOnewayInboundMiddleware)
+	out.StreamInbound = compile(spec.BuildStreamInbound, "BuildStreamInbound", _typeOfStreamInboundMiddleware)
+	out.UnaryOutbound = compile(spec.BuildUnaryOutbound, "BuildUnaryOutbound", _typeOfUnaryOutboundMiddleware)
+	out.OnewayOutbound = compile(spec.BuildOnewayOutbound, "BuildOnewayOutbound", _typeOfOnewayOutboundMiddleware)
+	out.StreamOutbound = compile(spec.BuildStreamOutbound, "BuildStreamOutbound", _typeOfStreamOutboundMiddleware)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !out.SupportsInbound() && !out.SupportsOutbound() {
+		return nil, errors.New("at least one Build function is required")
+	}
+
+	return &out, nil
+}
+
+func compileMiddlewareConfig(build interface{}, outputType reflect.Type) (*configSpec, error) {
+	v := reflect.ValueOf(build)
+	t := v.Type()
+
+	var err error
+	switch {
+	case t.Kind() != reflect.Func:
+		err = errors.New("must be a function")
+	case t.NumIn() != 2:
+		err = fmt.Errorf("must accept exactly two arguments, found %v", t.NumIn())
+	case !isDecodable(t.In(0)):
+		err = fmt.Errorf("must accept a struct or struct pointer as its first argument, found %v", t.In(0))
+	case t.In(1) != _typeOfKit:
+		err = fmt.Errorf("must accept a %v as its second argument, found %v", _typeOfKit, t.In(1))
+	case t.NumOut() != 2:
+		err = fmt.Errorf("must return exactly two results, found %v", t.NumOut())
+	case t.Out(0) != outputType:
+		err = fmt.Errorf("must return a %v as its first result, found %v", outputType, t.Out(0))
+	case t.Out(1) != _typeOfError:
+		err = fmt.Errorf("must return an error as its second result, found %v", t.Out(1))
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", t, err)
+	}
+
+	return &configSpec{inputType: t.In(0), factory: v}, nil
+}
+
 // Validated representation of a configuration function specified by the user.
 type configSpec struct {
 	// Type of object expected by the factory function
@@ -28,6 +28,7 @@ import (
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/yarpc/api/middleware"
 	"go.uber.org/yarpc/api/peer"
 	"go.uber.org/yarpc/api/transport"
 	"go.uber.org/yarpc/internal/config"
@@ -959,6 +960,129 @@ func TestCompilePeerListUpdaterSpec(t *testing.T) {
 	}
 }
 
+func TestCompileMiddlewareSpec(t *testing.T) {
+	tests := []struct {
+		desc            string
+		spec            MiddlewareSpec
+		wantName        string
+		wantSupportsIn  bool
+		wantSupportsOut bool
+		wantErr         string
+	}{
+		{
+			desc:    "missing name",
+			wantErr: "Name is required",
+		},
+		{
+			desc: "missing Build functions",
+			spec: MiddlewareSpec{
+				Name: "retry",
+			},
+			wantErr: "at least one Build function is required",
+		},
+		{
+			desc: "not a function",
+			spec: MiddlewareSpec{
+				Name:               "much sadness",
+				BuildUnaryOutbound: 10,
+			},
+			wantErr: "invalid BuildUnaryOutbound: int: must be a function",
+		},
+		{
+			desc: "wrong number of arguments",
+			spec: MiddlewareSpec{
+				Name:              "much sadness",
+				BuildUnaryInbound: func(a struct{}) {},
+			},
+			wantErr: "invalid BuildUnaryInbound: func(struct {}): must accept exactly two arguments, found 1",
+		},
+		{
+			desc: "wrong kind of first argument",
+			spec: MiddlewareSpec{
+				Name:               "much sadness",
+				BuildOnewayInbound: func(a, b int) {},
+			},
+			wantErr: "invalid BuildOnewayInbound: func(int, int): must accept a struct or struct pointer as its first argument, found int",
+		},
+		{
+			desc: "wrong kind of second argument",
+			spec: MiddlewareSpec{
+				Name:               "much sadness",
+				BuildStreamInbound: func(a struct{}, b int) {},
+			},
+			wantErr: "invalid BuildStreamInbound: func(struct {}, int): must accept a *yarpcconfig.Kit as its second argument, found int",
+		},
+		{
+			desc: "wrong number of returns",
+			spec: MiddlewareSpec{
+				Name:                "much sadness",
+				BuildOnewayOutbound: func(a struct{}, b *Kit) {},
+			},
+			wantErr: "invalid BuildOnewayOutbound: func(struct {}, *yarpcconfig.Kit): must return exactly two results, found 0",
+		},
+		{
+			desc: "wrong type of first return",
+			spec: MiddlewareSpec{
+				Name: "much sadness",
+				BuildStreamOutbound: func(a struct{}, b *Kit) (middleware.UnaryOutbound, error) {
+					return nil, nil
+				},
+			},
+			wantErr: "invalid BuildStreamOutbound: func(struct {}, *yarpcconfig.Kit) (middleware.UnaryOutbound, error): must return a middleware.StreamOutbound as its first result, found middleware.UnaryOutbound",
+		},
+		{
+			desc: "wrong type of second return",
+			spec: MiddlewareSpec{
+				Name: "much sadness",
+				BuildUnaryOutbound: func(a struct{}, b *Kit) (middleware.UnaryOutbound, int) {
+					return nil, 0
+				},
+			},
+			wantErr: "invalid BuildUnaryOutbound: func(struct {}, *yarpcconfig.Kit) (middleware.UnaryOutbound, int): must return an error as its second result, found int",
+		},
+		{
+			desc: "inbound only",
+			spec: MiddlewareSpec{
+				Name: "such gladness",
+				BuildUnaryInbound: func(a struct{}, b *Kit) (middleware.UnaryInbound, error) {
+					return nil, nil
+				},
+			},
+			wantName:       "such gladness",
+			wantSupportsIn: true,
+		},
+		{
+			desc: "outbound only",
+			spec: MiddlewareSpec{
+				Name: "such gladness",
+				BuildOnewayOutbound: func(a *struct{}, b *Kit) (middleware.OnewayOutbound, error) {
+					return nil, nil
+				},
+			},
+			wantName:        "such gladness",
+			wantSupportsOut: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			s, err := compileMiddlewareSpec(&tt.spec)
+			if tt.wantErr != "" {
+				if assert.Error(t, err, "expected failure") {
+					assert.Equal(t, tt.wantErr, err.Error(), "expected error")
+				}
+				return
+			}
+
+			if assert.NoError(t, err, "expected success") {
+				assert.Equal(t, tt.wantName, s.Name, "expected name")
+				assert.Equal(t, tt.wantSupportsIn, s.SupportsInbound(), "inbound support")
+				assert.Equal(t, tt.wantSupportsOut, s.SupportsOutbound(), "outbound support")
+			}
+		})
+	}
+}
+
 func TestCompilePeerChooserPreset(t *testing.T) {
 	tests := []struct {
 		desc     string
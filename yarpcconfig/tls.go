@@ -0,0 +1,407 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// InboundTLS configures TLS for the inbound of a transport, from PEM files.
+//
+//  tls:
+//    enabled: true
+//    certFile: /etc/certs/server.pem
+//    keyFile: /etc/certs/server-key.pem
+//    caFile: /etc/certs/ca.pem
+//    clientAuth: require-and-verify
+//
+// The certificate, key and CA bundle are read again whenever the files
+// change, so rotated certificates apply to new connections without a
+// restart.
+type InboundTLS struct {
+	// Enabled turns TLS on. TLS is disabled by default.
+	Enabled bool `config:"enabled"`
+
+	// CertFile and KeyFile hold the certificate chain and private key
+	// presented to clients. Both are required.
+	CertFile string `config:"certFile,interpolate"`
+	KeyFile  string `config:"keyFile,interpolate"`
+
+	// CAFile holds the certificate authorities trusted to sign client
+	// certificates. Defaults to the system roots.
+	CAFile string `config:"caFile,interpolate"`
+
+	// ClientAuth is the policy for client certificates: one of none,
+	// request, require, verify-if-given and require-and-verify. Defaults to
+	// require-and-verify, that is mutual TLS, if a CA file is given, and to
+	// none otherwise.
+	ClientAuth string `config:"clientAuth"`
+}
+
+// ServerConfig builds the TLS configuration for servers described by this
+// configuration. It returns nil if TLS is not enabled, and fails if the files
+// cannot be read.
+//
+// With a CA file, each connection is handled by a copy of the returned
+// configuration with the current CA bundle, made by GetConfigForClient.
+// Servers that add application protocols for ALPN must set NextProtos on
+// the returned configuration rather than on a copy of it.
+func (c InboundTLS) ServerConfig() (*tls.Config, error) {
+	if !c.Enabled {
+		return nil, nil
+	}
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, fmt.Errorf(
+			"both certFile and keyFile are required for TLS, got certFile=%q and keyFile=%q", c.CertFile, c.KeyFile)
+	}
+
+	mode := c.ClientAuth
+	if mode == "" {
+		mode = "none"
+		if c.CAFile != "" {
+			mode = "require-and-verify"
+		}
+	}
+	clientAuth, err := clientAuthPolicy(mode)
+	if err != nil {
+		return nil, err
+	}
+
+	certs, err := newKeyPairFiles(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ClientAuth: clientAuth,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return certs.certificate()
+		},
+	}
+	if c.CAFile != "" {
+		cas, err := newCAFiles(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.ClientCAs, err = cas.pool(); err != nil {
+			return nil, err
+		}
+		cfg.GetConfigForClient = newClientCAsConfig(cfg, cas).configForClient
+	}
+	return cfg, nil
+}
+
+// clientCAsConfig provides the configuration of each connection of a server
+// that verifies client certificates against a CA bundle that may be
+// rotated. The TLS library verifies the certificate chains of clients
+// against the ClientCAs of that configuration.
+type clientCAsConfig struct {
+	base *tls.Config
+	cas  *caFiles
+
+	mu     sync.Mutex
+	pool   *x509.CertPool
+	config *tls.Config
+}
+
+func newClientCAsConfig(base *tls.Config, cas *caFiles) *clientCAsConfig {
+	return &clientCAsConfig{base: base, cas: cas}
+}
+
+func (c *clientCAsConfig) configForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	pool, err := c.cas.pool()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.config == nil || c.pool != pool {
+		// The base configuration is copied on each rotation rather than once
+		// so that changes made to it after ServerConfig, like the protocols
+		// added by servers, apply as well.
+		config := c.base.Clone()
+		config.ClientCAs = pool
+		config.GetConfigForClient = nil
+		c.pool, c.config = pool, config
+	}
+	return c.config, nil
+}
+
+// clientAuthPolicy returns the client authentication mode of the TLS
+// library for the named policy.
+func clientAuthPolicy(mode string) (tls.ClientAuthType, error) {
+	switch mode {
+	case "none":
+		return tls.NoClientCert, nil
+	case "request":
+		return tls.RequestClientCert, nil
+	case "require":
+		return tls.RequireAnyClientCert, nil
+	case "verify-if-given":
+		return tls.VerifyClientCertIfGiven, nil
+	case "require-and-verify":
+		return tls.RequireAndVerifyClientCert, nil
+	default:
+		return 0, fmt.Errorf(
+			"unknown clientAuth %q: need one of none, request, require, verify-if-given, require-and-verify", mode)
+	}
+}
+
+// OutboundTLS configures TLS for the outbounds of a transport, from PEM
+// files.
+//
+//  tls:
+//    enabled: true
+//    certFile: /etc/certs/client.pem
+//    keyFile: /etc/certs/client-key.pem
+//    caFile: /etc/certs/ca.pem
+//    serverName: myservice.example.com
+//
+// The client certificate, key and CA bundle are read again whenever the
+// files change, so rotated certificates apply to new connections without a
+// restart. Servers are verified against the current CA bundle by name, which
+// is serverName if given and the host name of each peer otherwise, so peers
+// addressed by IP need serverName when caFile is given.
+type OutboundTLS struct {
+	// Enabled turns TLS on. TLS is disabled by default.
+	Enabled bool `config:"enabled"`
+
+	// CertFile and KeyFile hold the certificate chain and private key
+	// presented to servers that ask for one, as needed for mutual TLS. They
+	// are optional but must be given together.
+	CertFile string `config:"certFile,interpolate"`
+	KeyFile  string `config:"keyFile,interpolate"`
+
+	// CAFile holds the certificate authorities trusted to sign server
+	// certificates. Defaults to the system roots.
+	CAFile string `config:"caFile,interpolate"`
+
+	// ServerName overrides the name expected in server certificates and
+	// sent for SNI. Defaults to the host of each peer.
+	ServerName string `config:"serverName,interpolate"`
+}
+
+// ClientConfig builds the TLS configuration for clients described by this
+// configuration. It returns nil if TLS is not enabled, and fails if the files
+// cannot be read.
+func (c OutboundTLS) ClientConfig() (*tls.Config, error) {
+	if !c.Enabled {
+		return nil, nil
+	}
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return nil, fmt.Errorf(
+			"certFile and keyFile must be given together, got certFile=%q and keyFile=%q", c.CertFile, c.KeyFile)
+	}
+
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: c.ServerName,
+	}
+	if c.CertFile != "" {
+		certs, err := newKeyPairFiles(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return certs.certificate()
+		}
+	}
+	if c.CAFile != "" {
+		cas, err := newCAFiles(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		// Servers are verified by VerifyConnection instead, since the roots
+		// of a configuration cannot change between connections.
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			return verifyServer(cs, cfg.ServerName, cas)
+		}
+	}
+	return cfg, nil
+}
+
+// verifyServer verifies the certificate chain presented by a server against
+// the current CA bundle, for the server name of the connection or the given
+// name if it has none.
+func verifyServer(cs tls.ConnectionState, serverName string, cas *caFiles) error {
+	name := cs.ServerName
+	if name == "" {
+		name = serverName
+	}
+	if name == "" {
+		return errors.New("cannot verify server certificate without a server name, " +
+			"serverName is required for peers addressed by IP")
+	}
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("server did not present a certificate")
+	}
+
+	roots, err := cas.pool()
+	if err != nil {
+		return err
+	}
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		DNSName:       name,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err = cs.PeerCertificates[0].Verify(opts)
+	return err
+}
+
+// watchedFiles holds a value parsed from files, and parses the files again
+// when their size or modification time changes. If the files cannot be read
+// or parsed again, for example halfway through a rotation, the previous
+// value is kept.
+type watchedFiles struct {
+	paths []string
+	parse func() (interface{}, error)
+
+	mu     sync.Mutex
+	stamps []fileStamp
+	value  interface{}
+}
+
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+func newWatchedFiles(parse func() (interface{}, error), paths ...string) (*watchedFiles, error) {
+	w := &watchedFiles{paths: paths, parse: parse}
+	if _, err := w.load(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *watchedFiles) load() (interface{}, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	stamps := make([]fileStamp, len(w.paths))
+	for i, path := range w.paths {
+		info, err := os.Stat(path)
+		if err != nil && w.value == nil {
+			// Report the error of the parser, which names the files.
+			if _, parseErr := w.parse(); parseErr != nil {
+				return nil, parseErr
+			}
+		}
+		if err != nil {
+			return w.keep(err)
+		}
+		stamps[i] = fileStamp{size: info.Size(), modTime: info.ModTime()}
+	}
+	if w.value != nil && equalStamps(stamps, w.stamps) {
+		return w.value, nil
+	}
+
+	value, err := w.parse()
+	if err != nil {
+		return w.keep(err)
+	}
+	w.value, w.stamps = value, stamps
+	return value, nil
+}
+
+// keep returns the previous value if there is one, and the error otherwise.
+func (w *watchedFiles) keep(err error) (interface{}, error) {
+	if w.value != nil {
+		return w.value, nil
+	}
+	return nil, err
+}
+
+func equalStamps(a, b []fileStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].size != b[i].size || !a[i].modTime.Equal(b[i].modTime) {
+			return false
+		}
+	}
+	return true
+}
+
+// keyPairFiles is a certificate and private key read from PEM files.
+type keyPairFiles struct{ *watchedFiles }
+
+func newKeyPairFiles(certFile, keyFile string) (keyPairFiles, error) {
+	w, err := newWatchedFiles(func() (interface{}, error) {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS key pair from %q and %q: %v", certFile, keyFile, err)
+		}
+		return &cert, nil
+	}, certFile, keyFile)
+	return keyPairFiles{w}, err
+}
+
+func (f keyPairFiles) certificate() (*tls.Certificate, error) {
+	v, err := f.load()
+	if err != nil {
+		return nil, err
+	}
+	return v.(*tls.Certificate), nil
+}
+
+// caFiles is a pool of certificate authorities read from a PEM file.
+type caFiles struct{ *watchedFiles }
+
+func newCAFiles(caFile string) (*caFiles, error) {
+	w, err := newWatchedFiles(func() (interface{}, error) {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file %q: %v", caFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificates found in CA file " + caFile)
+		}
+		return pool, nil
+	}, caFile)
+	if err != nil {
+		return nil, err
+	}
+	return &caFiles{w}, nil
+}
+
+func (f *caFiles) pool() (*x509.CertPool, error) {
+	v, err := f.load()
+	if err != nil {
+		return nil, err
+	}
+	return v.(*x509.CertPool), nil
+}
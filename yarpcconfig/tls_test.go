@@ -0,0 +1,354 @@
+// Copyright (c) 2018 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcconfig
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/internal/testtls"
+)
+
+// handshake connects a client with the given configuration to a server with
+// the given configuration, and returns the common names of the certificates
+// each side presented, or the error of the handshake.
+func handshake(t *testing.T, server, client *tls.Config) (serverName, clientName string, err error) {
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", server)
+	require.NoError(t, err)
+	defer ln.Close()
+
+	type result struct {
+		name string
+		err  error
+	}
+	accepted := make(chan result, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			accepted <- result{err: err}
+			return
+		}
+		defer conn.Close()
+
+		tlsConn := conn.(*tls.Conn)
+		if err := tlsConn.Handshake(); err != nil {
+			accepted <- result{err: err}
+			return
+		}
+		var name string
+		if certs := tlsConn.ConnectionState().PeerCertificates; len(certs) > 0 {
+			name = certs[0].Subject.CommonName
+		}
+		_, err = conn.Write([]byte{1})
+		accepted <- result{name: name, err: err}
+	}()
+
+	conn, err := tls.Dial("tcp", ln.Addr().String(), client)
+	if err != nil {
+		<-accepted
+		return "", "", err
+	}
+	defer conn.Close()
+
+	// Servers reject client certificates after the client handshake
+	// completes with TLS 1.3, so wait for the server to answer.
+	_, readErr := conn.Read(make([]byte, 1))
+	res := <-accepted
+	if res.err != nil {
+		return "", "", res.err
+	}
+	if readErr != nil {
+		return "", "", readErr
+	}
+	return conn.ConnectionState().PeerCertificates[0].Subject.CommonName, res.name, nil
+}
+
+func TestTLSDisabled(t *testing.T) {
+	server, err := InboundTLS{CertFile: "cert", KeyFile: "key"}.ServerConfig()
+	require.NoError(t, err)
+	assert.Nil(t, server)
+
+	client, err := OutboundTLS{CAFile: "ca"}.ClientConfig()
+	require.NoError(t, err)
+	assert.Nil(t, client)
+}
+
+func TestTLSConfigErrors(t *testing.T) {
+	s := testtls.NewScenario(t)
+	defer s.Cleanup()
+
+	tests := []struct {
+		desc    string
+		build   func() (*tls.Config, error)
+		wantErr string
+	}{
+		{
+			desc:    "server without key",
+			build:   InboundTLS{Enabled: true, CertFile: s.ServerCertFile}.ServerConfig,
+			wantErr: "both certFile and keyFile are required",
+		},
+		{
+			desc:    "server with missing files",
+			build:   InboundTLS{Enabled: true, CertFile: "missing.pem", KeyFile: "missing-key.pem"}.ServerConfig,
+			wantErr: "failed to load TLS key pair",
+		},
+		{
+			desc: "server with unknown client auth",
+			build: InboundTLS{
+				Enabled:    true,
+				CertFile:   s.ServerCertFile,
+				KeyFile:    s.ServerKeyFile,
+				ClientAuth: "sometimes",
+			}.ServerConfig,
+			wantErr: `unknown clientAuth "sometimes"`,
+		},
+		{
+			desc: "server with invalid CA file",
+			build: InboundTLS{
+				Enabled:  true,
+				CertFile: s.ServerCertFile,
+				KeyFile:  s.ServerKeyFile,
+				CAFile:   s.ServerKeyFile,
+			}.ServerConfig,
+			wantErr: "no certificates found in CA file",
+		},
+		{
+			desc:    "client with cert but no key",
+			build:   OutboundTLS{Enabled: true, CertFile: s.ClientCertFile}.ClientConfig,
+			wantErr: "certFile and keyFile must be given together",
+		},
+		{
+			desc:    "client with missing CA file",
+			build:   OutboundTLS{Enabled: true, CAFile: "missing.pem"}.ClientConfig,
+			wantErr: "failed to read CA file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			_, err := tt.build()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	s := testtls.NewScenario(t)
+	defer s.Cleanup()
+
+	server, err := InboundTLS{
+		Enabled:  true,
+		CertFile: s.ServerCertFile,
+		KeyFile:  s.ServerKeyFile,
+		CAFile:   s.CAFile,
+	}.ServerConfig()
+	require.NoError(t, err)
+
+	client, err := OutboundTLS{
+		Enabled:    true,
+		CertFile:   s.ClientCertFile,
+		KeyFile:    s.ClientKeyFile,
+		CAFile:     s.CAFile,
+		ServerName: "localhost",
+	}.ClientConfig()
+	require.NoError(t, err)
+
+	serverName, clientName, err := handshake(t, server, client)
+	require.NoError(t, err)
+	assert.Equal(t, "server", serverName)
+	assert.Equal(t, "client", clientName)
+
+	t.Run("rotated certificates", func(t *testing.T) {
+		s.IssueServerCert("rotated-server")
+		s.IssueClientCert("rotated-client")
+
+		serverName, clientName, err := handshake(t, server, client)
+		require.NoError(t, err)
+		assert.Equal(t, "rotated-server", serverName)
+		assert.Equal(t, "rotated-client", clientName)
+	})
+
+	t.Run("client without certificate", func(t *testing.T) {
+		anonymous, err := OutboundTLS{Enabled: true, CAFile: s.CAFile, ServerName: "localhost"}.ClientConfig()
+		require.NoError(t, err)
+
+		_, _, err = handshake(t, server, anonymous)
+		assert.Error(t, err, "server must reject clients without certificates")
+	})
+
+	t.Run("client from another authority", func(t *testing.T) {
+		other := testtls.NewScenario(t)
+		defer other.Cleanup()
+
+		untrusted, err := OutboundTLS{
+			Enabled:    true,
+			CertFile:   other.ClientCertFile,
+			KeyFile:    other.ClientKeyFile,
+			CAFile:     s.CAFile,
+			ServerName: "localhost",
+		}.ClientConfig()
+		require.NoError(t, err)
+
+		_, _, err = handshake(t, server, untrusted)
+		assert.Error(t, err, "server must reject untrusted client certificates")
+	})
+}
+
+func TestRotatedClientCAs(t *testing.T) {
+	s := testtls.NewScenario(t)
+	defer s.Cleanup()
+	other := testtls.NewScenario(t)
+	defer other.Cleanup()
+
+	caFile := filepath.Join(filepath.Dir(s.CAFile), "rotated-ca.pem")
+	ca, err := ioutil.ReadFile(s.CAFile)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(caFile, ca, 0600))
+
+	server, err := InboundTLS{
+		Enabled:  true,
+		CertFile: s.ServerCertFile,
+		KeyFile:  s.ServerKeyFile,
+		CAFile:   caFile,
+	}.ServerConfig()
+	require.NoError(t, err)
+	server.NextProtos = []string{"h2"}
+
+	client, err := OutboundTLS{
+		Enabled:    true,
+		CertFile:   other.ClientCertFile,
+		KeyFile:    other.ClientKeyFile,
+		CAFile:     s.CAFile,
+		ServerName: "localhost",
+	}.ClientConfig()
+	require.NoError(t, err)
+	client.NextProtos = []string{"h2"}
+
+	_, _, err = handshake(t, server, client)
+	assert.Error(t, err, "server must reject clients of an authority missing from the bundle")
+
+	otherCA, err := ioutil.ReadFile(other.CAFile)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(caFile, append(ca, otherCA...), 0600))
+
+	_, clientName, err := handshake(t, server, client)
+	require.NoError(t, err, "server must trust authorities added to the bundle")
+	assert.Equal(t, "client", clientName)
+
+	config, err := server.GetConfigForClient(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"h2"}, config.NextProtos, "connections must keep the protocols of the server")
+}
+
+func TestRotatedServerCAs(t *testing.T) {
+	s := testtls.NewScenario(t)
+	defer s.Cleanup()
+	other := testtls.NewScenario(t)
+	defer other.Cleanup()
+
+	caFile := filepath.Join(filepath.Dir(s.CAFile), "rotated-ca.pem")
+	ca, err := ioutil.ReadFile(s.CAFile)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(caFile, ca, 0600))
+
+	server, err := InboundTLS{Enabled: true, CertFile: other.ServerCertFile, KeyFile: other.ServerKeyFile}.ServerConfig()
+	require.NoError(t, err)
+
+	client, err := OutboundTLS{Enabled: true, CAFile: caFile, ServerName: "localhost"}.ClientConfig()
+	require.NoError(t, err)
+
+	_, _, err = handshake(t, server, client)
+	assert.Error(t, err, "client must reject servers of an authority missing from the bundle")
+
+	otherCA, err := ioutil.ReadFile(other.CAFile)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(caFile, append(ca, otherCA...), 0600))
+
+	serverName, _, err := handshake(t, server, client)
+	require.NoError(t, err, "client must trust authorities added to the bundle")
+	assert.Equal(t, "server", serverName)
+
+	wrongName, err := OutboundTLS{Enabled: true, CAFile: caFile, ServerName: "example.com"}.ClientConfig()
+	require.NoError(t, err)
+	_, _, err = handshake(t, server, wrongName)
+	assert.Error(t, err, "client must verify the name of the server")
+
+	noName, err := OutboundTLS{Enabled: true, CAFile: caFile}.ClientConfig()
+	require.NoError(t, err)
+	_, _, err = handshake(t, server, noName)
+	assert.Error(t, err, "client must not trust servers it cannot verify by name")
+}
+
+func TestServerTLSWithoutClientAuth(t *testing.T) {
+	s := testtls.NewScenario(t)
+	defer s.Cleanup()
+
+	server, err := InboundTLS{Enabled: true, CertFile: s.ServerCertFile, KeyFile: s.ServerKeyFile}.ServerConfig()
+	require.NoError(t, err)
+	assert.Equal(t, tls.NoClientCert, server.ClientAuth)
+
+	client, err := OutboundTLS{Enabled: true, CAFile: s.CAFile}.ClientConfig()
+	require.NoError(t, err)
+	client.ServerName = "127.0.0.1"
+
+	serverName, clientName, err := handshake(t, server, client)
+	require.NoError(t, err)
+	assert.Equal(t, "server", serverName)
+	assert.Empty(t, clientName)
+}
+
+func TestVerifyIfGiven(t *testing.T) {
+	s := testtls.NewScenario(t)
+	defer s.Cleanup()
+	other := testtls.NewScenario(t)
+	defer other.Cleanup()
+
+	server, err := InboundTLS{
+		Enabled:    true,
+		CertFile:   s.ServerCertFile,
+		KeyFile:    s.ServerKeyFile,
+		CAFile:     s.CAFile,
+		ClientAuth: "verify-if-given",
+	}.ServerConfig()
+	require.NoError(t, err)
+
+	anonymous, err := OutboundTLS{Enabled: true, CAFile: s.CAFile, ServerName: "localhost"}.ClientConfig()
+	require.NoError(t, err)
+	serverName, _, err := handshake(t, server, anonymous)
+	require.NoError(t, err)
+	assert.Equal(t, "server", serverName, "clients without certificates must be accepted")
+
+	untrusted, err := OutboundTLS{
+		Enabled:    true,
+		CertFile:   other.ClientCertFile,
+		KeyFile:    other.ClientKeyFile,
+		CAFile:     s.CAFile,
+		ServerName: "localhost",
+	}.ClientConfig()
+	require.NoError(t, err)
+	_, _, err = handshake(t, server, untrusted)
+	assert.Error(t, err, "untrusted client certificates must be rejected")
+}
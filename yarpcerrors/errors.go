@@ -68,6 +68,19 @@ type Status struct {
 	code    Code
 	name    string
 	message string
+	details []Detail
+}
+
+// Detail is a typed piece of information attached to a Status.
+//
+// Details have the same structure as the google.protobuf.Any message: Value
+// holds a serialized message and TypeURL identifies its type. This allows
+// details to be exchanged with gRPC clients and servers, which carry them
+// in the google.rpc.Status message. Helpers to build and read details from
+// Protobuf messages are available in the encoding/protobuf package.
+type Detail struct {
+	TypeURL string
+	Value   []byte
 }
 
 // WithName returns a new Status with the given name.
@@ -80,8 +93,6 @@ type Status struct {
 //
 // Deprecated: Use only error codes to represent the type of the error.
 func (s *Status) WithName(name string) *Status {
-	// TODO: We plan to add a WithDetails method to add semantic metadata to
-	// Statuses soon.
 	if s == nil {
 		return nil
 	}
@@ -92,6 +103,32 @@ func (s *Status) WithName(name string) *Status {
 		code:    s.code,
 		name:    name,
 		message: s.message,
+		details: s.details,
+	}
+}
+
+// WithDetails returns a new Status with the given details appended to the
+// details of this Status.
+//
+// Details are propagated by all transports. TChannel can only carry them
+// for errors returned by handlers alongside an application error response,
+// which is the case for errors returned by handlers of all built-in
+// encodings.
+func (s *Status) WithDetails(details ...Detail) *Status {
+	if s == nil {
+		return nil
+	}
+	if len(details) == 0 {
+		return s
+	}
+	all := make([]Detail, 0, len(s.details)+len(details))
+	all = append(all, s.details...)
+	all = append(all, details...)
+	return &Status{
+		code:    s.code,
+		name:    s.name,
+		message: s.message,
+		details: all,
 	}
 }
 
@@ -122,6 +159,14 @@ func (s *Status) Message() string {
 	return s.message
 }
 
+// Details returns the details attached to this Status, if any.
+func (s *Status) Details() []Detail {
+	if s == nil {
+		return nil
+	}
+	return s.details
+}
+
 // Error implements the error interface.
 func (s *Status) Error() string {
 	buffer := bytes.NewBuffer(nil)
@@ -152,6 +152,34 @@ func TestNonYARPCErrors(t *testing.T) {
 	assert.Nil(t, FromHeaders(CodeOK, "", ""))
 }
 
+func TestErrorDetails(t *testing.T) {
+	a := Detail{TypeURL: "type.googleapis.com/foo.A", Value: []byte("a")}
+	b := Detail{TypeURL: "type.googleapis.com/foo.B", Value: []byte("b")}
+
+	status := Newf(CodeInvalidArgument, "hello")
+	assert.Empty(t, status.Details())
+	assert.True(t, status == status.WithDetails(), "expected the same Status without new details")
+
+	withA := status.WithDetails(a)
+	assert.Equal(t, []Detail{a}, withA.Details())
+	assert.Empty(t, status.Details(), "original Status must not be modified")
+
+	withAB := withA.WithDetails(b)
+	assert.Equal(t, []Detail{a, b}, withAB.Details())
+	assert.Equal(t, []Detail{a}, withA.Details())
+	assert.Equal(t, CodeInvalidArgument, withAB.Code())
+	assert.Equal(t, "hello", withAB.Message())
+	assert.Equal(t, "code:invalid-argument message:hello", withAB.Error())
+
+	named := withAB.WithName("foo")
+	assert.Equal(t, []Detail{a, b}, named.Details(), "WithName must keep details")
+	assert.Equal(t, "foo", named.WithDetails(a).Name(), "WithDetails must keep the name")
+
+	var nilStatus *Status
+	assert.Nil(t, nilStatus.WithDetails(a))
+	assert.Nil(t, nilStatus.Details())
+}
+
 func TestFromHeadersBadName(t *testing.T) {
 	assert.Equal(t, validateName("123"), FromHeaders(CodeUnknown, "123", ""))
 }